@@ -2,12 +2,19 @@ package circuit
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker"
+
+	"github.com/zalando/skipper/metrics"
 )
 
-// BreakerType defines the type of the used breaker: consecutive, rate or disabled.
+// BreakerType defines the type of the used breaker: consecutive, rate, slow or disabled.
 type BreakerType int
 
 func (b *BreakerType) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -21,10 +28,12 @@ func (b *BreakerType) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		*b = ConsecutiveFailures
 	case "rate":
 		*b = FailureRate
+	case "slow":
+		*b = SlowCallRate
 	case "disabled":
 		*b = BreakerDisabled
 	default:
-		return fmt.Errorf("invalid breaker type %v (allowed values are: consecutive, rate or disabled)", value)
+		return fmt.Errorf("invalid breaker type %v (allowed values are: consecutive, rate, slow or disabled)", value)
 	}
 
 	return nil
@@ -35,8 +44,71 @@ const (
 	ConsecutiveFailures
 	FailureRate
 	BreakerDisabled
+	SlowCallRate
 )
 
+// StatusCodes is a set of HTTP status codes in a comparable form, so
+// that it can be part of the breaker settings, which are used as keys.
+// Use NewStatusCodes or ParseStatusCodes to create it.
+type StatusCodes string
+
+// NewStatusCodes creates a set of status codes.
+func NewStatusCodes(codes ...int) StatusCodes {
+	sorted := append([]int(nil), codes...)
+	sort.Ints(sorted)
+
+	var ss []string
+	for i, c := range sorted {
+		if i > 0 && c == sorted[i-1] {
+			continue
+		}
+
+		ss = append(ss, strconv.Itoa(c))
+	}
+
+	return StatusCodes(strings.Join(ss, " "))
+}
+
+// ParseStatusCodes parses a set of status codes separated by spaces,
+// e.g. "429 503".
+func ParseStatusCodes(s string) (StatusCodes, error) {
+	codes, err := StatusCodes(s).codes()
+	if err != nil {
+		return "", err
+	}
+
+	return NewStatusCodes(codes...), nil
+}
+
+func (c StatusCodes) codes() ([]int, error) {
+	var codes []int
+	for _, f := range strings.Fields(string(c)) {
+		code, err := strconv.Atoi(f)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code: %s", f)
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+func (c *StatusCodes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var codes []int
+	if err := unmarshal(&codes); err != nil {
+		return err
+	}
+
+	sc := NewStatusCodes(codes...)
+	if _, err := sc.codes(); err != nil {
+		return err
+	}
+
+	*c = sc
+	return nil
+}
+
 // BreakerSettings contains the settings for individual circuit breakers.
 //
 // See the package overview for the detailed merging/overriding rules of the settings and for the meaning of the
@@ -49,10 +121,60 @@ type BreakerSettings struct {
 	Timeout          time.Duration `yaml:"timeout"`
 	HalfOpenRequests int           `yaml:"half-open-requests"`
 	IdleTTL          time.Duration `yaml:"idle-ttl"`
+
+	// SlowCallRate is the percentage of the slow or failed calls within
+	// the window, at which the slow call rate breaker opens.
+	SlowCallRate int `yaml:"slow-call-rate"`
+
+	// SlowCallDuration is the duration, above which a call is
+	// considered slow by the slow call rate breaker.
+	SlowCallDuration time.Duration `yaml:"slow-call-duration"`
+
+	// FailureStatusCodes are the status codes of the backend responses
+	// counted as failures. When not set, the responses with a status
+	// code >=500 are counted as failures.
+	FailureStatusCodes StatusCodes `yaml:"failure-status-codes"`
 }
 
+// the callback expects whether the call was successful and its duration
 type breakerImplementation interface {
-	Allow() (func(bool), bool)
+	Allow() (func(bool, time.Duration), bool)
+	State() gobreaker.State
+}
+
+// ForcedState can be used to override the state of the breakers of a
+// host, e.g. to trip them manually.
+type ForcedState int32
+
+const (
+	// NotForced means that the breakers change their state based on
+	// the outcome of the requests.
+	NotForced ForcedState = iota
+
+	// ForcedOpen means that the breakers reject all the requests.
+	ForcedOpen
+
+	// ForcedClosed means that the breakers allow all the requests,
+	// regardless of the failures.
+	ForcedClosed
+)
+
+const (
+	stateMetricsKey = "circuitbreaker.%s.%s"
+
+	forcedOpenState   = "forced-open"
+	forcedClosedState = "forced-closed"
+
+	// the same as the default of gobreaker
+	defaultTimeout = 60 * time.Second
+)
+
+var hostKeyReplacer = strings.NewReplacer(".", "_", ":", "__")
+
+// onStateChange logs and counts the state changes of the breakers per host
+func onStateChange(host string, from gobreaker.State, to gobreaker.State) {
+	log.Infof("circuit breaker %v went from %v to %v", host, from.String(), to.String())
+	metrics.Default.IncCounter(fmt.Sprintf(stateMetricsKey, hostKeyReplacer.Replace(host), to.String()))
 }
 
 type voidBreaker struct{}
@@ -61,9 +183,17 @@ type voidBreaker struct{}
 //
 // Use the Get() method of the Registry to request fully initialized breakers.
 type Breaker struct {
-	settings BreakerSettings
-	ts       time.Time
-	impl     breakerImplementation
+	settings     BreakerSettings
+	ts           time.Time
+	impl         breakerImplementation
+	failureCodes map[int]bool
+	forced       int32
+
+	// set by the registry to share the open transitions
+	onOpen func(*Breaker)
+
+	// unix nanoseconds until the breaker was tripped by another instance
+	openUntil int64
 }
 
 func (to BreakerSettings) mergeSettings(from BreakerSettings) BreakerSettings {
@@ -78,6 +208,16 @@ func (to BreakerSettings) mergeSettings(from BreakerSettings) BreakerSettings {
 			to.Window = from.Window
 			to.Failures = from.Failures
 		}
+
+		if from.Type == SlowCallRate {
+			to.Window = from.Window
+			to.SlowCallRate = from.SlowCallRate
+			to.SlowCallDuration = from.SlowCallDuration
+		}
+	}
+
+	if to.FailureStatusCodes == "" {
+		to.FailureStatusCodes = from.FailureStatusCodes
 	}
 
 	if to.Timeout == 0 {
@@ -106,6 +246,8 @@ func (s BreakerSettings) String() string {
 		ss = append(ss, "type=consecutive")
 	case FailureRate:
 		ss = append(ss, "type=rate")
+	case SlowCallRate:
+		ss = append(ss, "type=slow")
 	case BreakerDisabled:
 		return "disabled"
 	default:
@@ -116,7 +258,7 @@ func (s BreakerSettings) String() string {
 		ss = append(ss, "host="+s.Host)
 	}
 
-	if s.Type == FailureRate && s.Window > 0 {
+	if (s.Type == FailureRate || s.Type == SlowCallRate) && s.Window > 0 {
 		ss = append(ss, "window="+strconv.Itoa(s.Window))
 	}
 
@@ -136,27 +278,59 @@ func (s BreakerSettings) String() string {
 		ss = append(ss, "idle-ttl="+s.IdleTTL.String())
 	}
 
+	if s.SlowCallRate > 0 {
+		ss = append(ss, "slow-call-rate="+strconv.Itoa(s.SlowCallRate))
+	}
+
+	if s.SlowCallDuration > 0 {
+		ss = append(ss, "slow-call-duration="+s.SlowCallDuration.String())
+	}
+
+	if s.FailureStatusCodes != "" {
+		ss = append(ss, "failure-status-codes="+string(s.FailureStatusCodes))
+	}
+
 	return strings.Join(ss, ",")
 }
 
-func (b voidBreaker) Allow() (func(bool), bool) {
-	return func(bool) {}, true
+func (b voidBreaker) Allow() (func(bool, time.Duration), bool) {
+	return func(bool, time.Duration) {}, true
+}
+
+func (b voidBreaker) State() gobreaker.State {
+	return gobreaker.StateClosed
 }
 
 func newBreaker(s BreakerSettings) *Breaker {
-	var impl breakerImplementation
+	b := &Breaker{settings: s}
+
 	switch s.Type {
 	case ConsecutiveFailures:
-		impl = newConsecutive(s)
+		b.impl = newConsecutive(s, b.stateChange)
 	case FailureRate:
-		impl = newRate(s)
+		b.impl = newRate(s, b.stateChange)
+	case SlowCallRate:
+		b.impl = newSlowCall(s, b.stateChange)
 	default:
-		impl = voidBreaker{}
+		b.impl = voidBreaker{}
 	}
 
-	return &Breaker{
-		settings: s,
-		impl:     impl,
+	var failureCodes map[int]bool
+	if codes, _ := s.FailureStatusCodes.codes(); len(codes) > 0 {
+		failureCodes = make(map[int]bool)
+		for _, c := range codes {
+			failureCodes[c] = true
+		}
+	}
+
+	b.failureCodes = failureCodes
+	return b
+}
+
+func (b *Breaker) stateChange(host string, from gobreaker.State, to gobreaker.State) {
+	onStateChange(host, from, to)
+	if to == gobreaker.StateOpen && b.onOpen != nil {
+		b.onOpen(b)
 	}
 }
 
@@ -164,9 +338,104 @@ func newBreaker(s BreakerSettings) *Breaker {
 // the operation. The callback expects true values if the outcome of the request was successful. Allow may not
 // return a callback function when the state is open.
 func (b *Breaker) Allow() (func(bool), bool) {
+	done, ok := b.allow()
+	if !ok {
+		return nil, false
+	}
+
+	return func(success bool) { done(success, 0) }, true
+}
+
+// AllowRequest is like Allow, but the callback expects the status code of the backend response, or zero when the
+// request failed without a response, and the duration of the request. The outcome is classified as a failure
+// based on the FailureStatusCodes of the settings, and as slow based on the SlowCallDuration.
+func (b *Breaker) AllowRequest() (func(int, time.Duration), bool) {
+	done, ok := b.allow()
+	if !ok {
+		return nil, false
+	}
+
+	return func(statusCode int, d time.Duration) { done(!b.failure(statusCode), d) }, true
+}
+
+func (b *Breaker) allow() (func(bool, time.Duration), bool) {
+	switch b.getForced() {
+	case ForcedOpen:
+		return nil, false
+	case ForcedClosed:
+		return voidBreaker{}.Allow()
+	}
+
+	if b.sharedOpen(time.Now()) {
+		return nil, false
+	}
+
 	return b.impl.Allow()
 }
 
+func (b *Breaker) sharedOpen(now time.Time) bool {
+	return now.UnixNano() < atomic.LoadInt64(&b.openUntil)
+}
+
+func (b *Breaker) setOpenUntil(t time.Time) {
+	var until int64
+	if !t.IsZero() {
+		until = t.UnixNano()
+	}
+
+	atomic.StoreInt64(&b.openUntil, until)
+}
+
+// timeout returns the time the breaker stays open, defaulting to the gobreaker default
+func (b *Breaker) timeout() time.Duration {
+	if b.settings.Timeout <= 0 {
+		return defaultTimeout
+	}
+
+	return b.settings.Timeout
+}
+
+func (b *Breaker) getForced() ForcedState {
+	return ForcedState(atomic.LoadInt32(&b.forced))
+}
+
+func (b *Breaker) setForced(f ForcedState) {
+	atomic.StoreInt32(&b.forced, int32(f))
+}
+
+// State returns the current state of the breaker: closed, open, half-open, forced-open or forced-closed.
+func (b *Breaker) State() string {
+	switch b.getForced() {
+	case ForcedOpen:
+		return forcedOpenState
+	case ForcedClosed:
+		return forcedClosedState
+	}
+
+	if b.sharedOpen(time.Now()) {
+		return gobreaker.StateOpen.String()
+	}
+
+	return b.impl.State().String()
+}
+
+// Settings returns the settings of the breaker, merged with the defaults.
+func (b *Breaker) Settings() BreakerSettings {
+	return b.settings
+}
+
+func (b *Breaker) failure(statusCode int) bool {
+	if statusCode == 0 {
+		return true
+	}
+
+	if b.failureCodes == nil {
+		return statusCode >= 500
+	}
+
+	return b.failureCodes[statusCode]
+}
+
 func (b *Breaker) idle(now time.Time) bool {
 	return now.Sub(b.ts) > b.settings.IdleTTL
 }
@@ -127,6 +127,105 @@ func TestRateBreaker(t *testing.T) {
 	})
 }
 
+func TestSlowCallBreaker(t *testing.T) {
+	s := BreakerSettings{
+		Type:             SlowCallRate,
+		Window:           10,
+		SlowCallRate:     30,
+		SlowCallDuration: 100 * time.Millisecond,
+		HalfOpenRequests: 2,
+		Timeout:          3 * time.Millisecond,
+	}
+
+	call := func(t *testing.T, b *Breaker, statusCode int, d time.Duration) func() {
+		return func() {
+			done, ok := b.AllowRequest()
+			if !ok {
+				t.Error("breaker is unexpectedly open")
+				return
+			}
+
+			done(statusCode, d)
+		}
+	}
+
+	fast := func(t *testing.T, b *Breaker) func() { return call(t, b, 200, 10*time.Millisecond) }
+	slow := func(t *testing.T, b *Breaker) func() { return call(t, b, 200, time.Second) }
+
+	t.Run("doesn't open below the rate", func(t *testing.T) {
+		b := newBreaker(s)
+		times(2, slow(t, b))
+		times(8, fast(t, b))
+		checkClosed(t, b)
+	})
+
+	t.Run("opens on reaching the rate", func(t *testing.T) {
+		b := newBreaker(s)
+		times(7, fast(t, b))
+		times(3, slow(t, b))
+		checkOpen(t, b)
+	})
+
+	t.Run("counts the failures", func(t *testing.T) {
+		b := newBreaker(s)
+		times(2, slow(t, b))
+		times(1, call(t, b, 500, 0))
+		checkOpen(t, b)
+	})
+
+	t.Run("slow call in half-open state opens", func(t *testing.T) {
+		b := newBreaker(s)
+		times(3, slow(t, b))
+		time.Sleep(s.Timeout)
+		times(1, slow(t, b))
+		checkOpen(t, b)
+	})
+
+	t.Run("closes after fast calls in half-open state", func(t *testing.T) {
+		b := newBreaker(s)
+		times(3, slow(t, b))
+		time.Sleep(s.Timeout)
+		times(s.HalfOpenRequests, fast(t, b))
+		checkClosed(t, b)
+	})
+}
+
+func TestFailureStatusCodes(t *testing.T) {
+	for _, tc := range []struct {
+		codes   StatusCodes
+		failure []int
+		success []int
+	}{{
+		failure: []int{0, 500, 503},
+		success: []int{200, 404, 429},
+	}, {
+		codes:   NewStatusCodes(503, 429),
+		failure: []int{0, 429, 503},
+		success: []int{200, 404, 500},
+	}} {
+		b := newBreaker(BreakerSettings{Type: ConsecutiveFailures, Failures: 1, FailureStatusCodes: tc.codes})
+		for _, c := range tc.failure {
+			if !b.failure(c) {
+				t.Errorf("expected failure for %d with %q", c, tc.codes)
+			}
+		}
+
+		for _, c := range tc.success {
+			if b.failure(c) {
+				t.Errorf("expected success for %d with %q", c, tc.codes)
+			}
+		}
+	}
+
+	if c, err := ParseStatusCodes("503 429 503"); err != nil || c != "429 503" {
+		t.Errorf("unexpected status codes: %q, %v", c, err)
+	}
+
+	if _, err := ParseStatusCodes("503 foo"); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
 // no checks, used for race detector
 func TestRateBreakerFuzzy(t *testing.T) {
 	if testing.Short() {
@@ -1,7 +1,8 @@
 package circuit
 
 import (
-	log "github.com/sirupsen/logrus"
+	"time"
+
 	"github.com/sony/gobreaker"
 )
 
@@ -10,19 +11,17 @@ type consecutiveBreaker struct {
 	gb       *gobreaker.TwoStepCircuitBreaker
 }
 
-func newConsecutive(s BreakerSettings) *consecutiveBreaker {
+func newConsecutive(s BreakerSettings, onStateChange func(string, gobreaker.State, gobreaker.State)) *consecutiveBreaker {
 	b := &consecutiveBreaker{
 		settings: s,
 	}
 
 	b.gb = gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
-		Name:        s.Host,
-		MaxRequests: uint32(s.HalfOpenRequests),
-		Timeout:     s.Timeout,
-		ReadyToTrip: b.readyToTrip,
-		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			log.Infof("circuit breaker %v went from %v to %v", name, from.String(), to.String())
-		},
+		Name:          s.Host,
+		MaxRequests:   uint32(s.HalfOpenRequests),
+		Timeout:       s.Timeout,
+		ReadyToTrip:   b.readyToTrip,
+		OnStateChange: onStateChange,
 	})
 
 	return b
@@ -32,7 +31,7 @@ func (b *consecutiveBreaker) readyToTrip(c gobreaker.Counts) bool {
 	return int(c.ConsecutiveFailures) >= b.settings.Failures
 }
 
-func (b *consecutiveBreaker) Allow() (func(bool), bool) {
+func (b *consecutiveBreaker) Allow() (func(bool, time.Duration), bool) {
 	done, err := b.gb.Allow()
 
 	// this error can only indicate that the breaker is not closed
@@ -41,5 +40,9 @@ func (b *consecutiveBreaker) Allow() (func(bool), bool) {
 	if !closed {
 		return nil, false
 	}
-	return done, true
+	return func(success bool, _ time.Duration) { done(success) }, true
+}
+
+func (b *consecutiveBreaker) State() gobreaker.State {
+	return b.gb.State()
 }
@@ -1,7 +1,7 @@
 /*
 Package circuit implements circuit breaker functionality for the proxy.
 
-It provides two types of circuit breakers: consecutive and failure rate based. The circuit breakers can be
+It provides three types of circuit breakers: consecutive, failure rate and slow call rate based. The circuit breakers can be
 configured either globally, based on hosts or individual routes. The registry ensures synchronized access to the
 active breakers and the recycling of the idle ones.
 
@@ -24,6 +24,13 @@ for going open, it maintains a sliding window of the last M events, both success
 when the number of failures reaches N within the window. This way the sliding window is not time based and
 allows the same breaker characteristics for low and high rate traffic.
 
+Breaker Type - Slow Call Rate
+
+The "slow call breaker" works similar to the "rate breaker", but it counts the slow calls besides the failures,
+and opens when their percentage reaches P of the sliding window of the last M events. A call is slow, when it
+takes longer than the slow call duration. The percentage is calculated for the whole window, even when it is not
+filled yet. In the half-open state, a slow call counts as a failure.
+
 Usage
 
 When imported as a package, the Registry can be used to hold the circuit breakers and their settings. On a
@@ -65,11 +72,11 @@ route based values happens with filters in the route definitions.
 
 Settings - Type
 
-It can be ConsecutiveFailures, FailureRate or Disabled, where the first two values select which breaker to use,
+It can be ConsecutiveFailures, FailureRate, SlowCallRate or Disabled, where the first three values select which breaker to use,
 while the Disabled value can override a global or host configuration disabling the circuit breaker for the
 specific host or route.
 
-Command line name: type. Possible command line values: consecutive, rate, disabled.
+Command line name: type. Possible command line values: consecutive, rate, slow, disabled.
 
 Settings - Host
 
@@ -80,7 +87,7 @@ Command line name: host.
 
 Settings - Window
 
-The window value sets the size of the sliding counter window of the failure rate breaker.
+The window value sets the size of the sliding counter window of the failure rate and slow call rate breakers.
 
 Command line name: window. Possible command line values: any positive integer.
 
@@ -110,9 +117,30 @@ Defines the idle timeout after which a circuit breaker gets recycled, if it hasn
 Command line name: idle-ttl. Possible command line values: any positive integer as milliseconds or a duration
 string, e.g. 15m30s.
 
+Settings - Slow Call Rate
+
+The percentage of the slow or failed calls within the window, at which the slow call rate breaker opens.
+
+Command line name: slow-call-rate. Possible command line values: any integer between 1 and 100.
+
+Settings - Slow Call Duration
+
+The duration above which a call is considered slow by the slow call rate breaker.
+
+Command line name: slow-call-duration. Possible command line values: a duration string, e.g. 1s.
+
+Settings - Failure Status Codes
+
+The status codes of the backend responses counted as failures by any type of breaker. When not set, the status
+codes >=500 are counted as failures. The failed connections are always counted as failures.
+
+Command line name: failure-status-codes. Possible command line values: status codes separated by spaces, e.g.
+"429 503".
+
 Filters
 
-The following circuit breaker filters are supported: consecutiveBreaker(), rateBreaker() and disableBreaker().
+The following circuit breaker filters are supported: consecutiveBreaker(), rateBreaker(), slowCallBreaker(),
+breakerFailureStatusCodes() and disableBreaker().
 
 The consecutiveBreaker filter expects one mandatory parameter: the number of consecutive failures to open. It
 accepts the following optional arguments: timeout, half-open requests, idle-ttl.
@@ -124,6 +152,17 @@ of the sliding window. It accepts the following optional arguments: timeout, hal
 
 	rateBreaker(30, 300, "1m", 12, "30m")
 
+The slowCallBreaker filter expects three mandatory parameters: the percentage of the slow or failed calls to
+open, the size of the sliding window and the slow call duration. It accepts the following optional arguments:
+timeout, half-open requests, idle-ttl.
+
+	slowCallBreaker(50, 100, "2s", "1m", 12, "30m")
+
+The breakerFailureStatusCodes filter expects one or more status codes, which are counted as failures by the
+breaker of the route. It can be combined with the other breaker filters.
+
+	breakerFailureStatusCodes(429, 503)
+
 The disableBreaker filter doesn't expect any arguments, and it disables the circuit breaker, if any, for the
 route that it appears in.
 
@@ -133,12 +172,39 @@ Proxy Usage
 
 The proxy, when circuit breakers are configured, uses them for backend connections. It checks the breaker for
 the current backend host if it's closed before making backend requests. It reports the outcome of the request to
-the breaker, considering connection failures and backend responses with status code >=500 as failures. When the
+the breaker, considering connection failures and backend responses with status code >=500, or with the
+configured failure status codes, as failures, together with the duration of the request. When the
 breaker is open, the proxy doesn't try to make backend requests, and returns a response with a status code of
 503 and appending a header to the response:
 
 	X-Circuit-Open: true
 
+The state changes of the breakers are logged, and counted per backend host with the following metrics keys:
+
+	circuitbreaker.<host>.open
+	circuitbreaker.<host>.half-open
+	circuitbreaker.<host>.closed
+
+Shared State
+
+By default, every skipper instance learns independently that a backend host is failing. With the
+-enable-shared-breakers flag and the swarm enabled, the registry shares the open transitions of its breakers via
+Redis, or via the SWIM based swarm, and it fetches the ones of the other instances every second. When a breaker
+of a backend host opens in any of the instances, the breakers of the same host are kept open in all of them for
+the breaker timeout. After the timeout, every instance continues with its own breaker state.
+
+	skipper -enable-swarm -swarm-redis-urls=redis1:6379,redis2:6379 \
+		-breaker type=consecutive,failures=5,timeout=30s -enable-shared-breakers
+
+Admin Endpoint
+
+The Registry implements a read-only http.Handler, which skipper serves on the support listener as /circuitbreakers.
+A GET request lists the active breakers with their host, settings and state. With the -enable-breakers-override
+flag, skipper serves the OverrideHandler instead, where a POST request with the host and state form values forces
+the breakers of a backend host open or closed, or resets them. These requests are not authenticated:
+
+	curl -d host=foo.example.org -d state=open localhost:9911/circuitbreakers
+
 Registry
 
 The active circuit breakers are stored in a registry. They are created on-demand, for the requested settings.
@@ -0,0 +1,77 @@
+package circuit
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ServeHTTP implements the read-only admin endpoint of the circuit
+// breakers. A GET request lists the active circuit breakers as JSON,
+// with their host, settings and state:
+//
+//	curl localhost:9911/circuitbreakers
+//
+// To override the state of the breakers, use the OverrideHandler.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET", "HEAD":
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == "HEAD" {
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(r.Breakers()); err != nil {
+			http.Error(
+				w,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type overrideHandler struct {
+	registry *Registry
+}
+
+// OverrideHandler returns the admin endpoint of the circuit breakers,
+// that besides listing them, accepts POST requests with the host and
+// state form values, to override the state of the breakers of a backend
+// host, where the state can be open, closed or reset:
+//
+//	curl -d host=foo.example.org -d state=open localhost:9911/circuitbreakers
+//
+// The requests are not authenticated, so the handler should be used
+// only on a listener that is not reachable by untrusted clients.
+func (r *Registry) OverrideHandler() http.Handler {
+	return overrideHandler{registry: r}
+}
+
+func (h overrideHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		h.registry.ServeHTTP(w, req)
+		return
+	}
+
+	host := req.FormValue("host")
+	if host == "" {
+		http.Error(w, "missing host", http.StatusBadRequest)
+		return
+	}
+
+	switch req.FormValue("state") {
+	case "open":
+		h.registry.Force(host, ForcedOpen)
+	case "closed":
+		h.registry.Force(host, ForcedClosed)
+	case "reset":
+		h.registry.Reset(host)
+	default:
+		http.Error(w, "invalid state, allowed values are: open, closed or reset", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,166 @@
+package circuit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+func TestForceAndReset(t *testing.T) {
+	r := NewRegistry(BreakerSettings{Type: ConsecutiveFailures, Failures: 1})
+
+	foo := r.Get(BreakerSettings{Host: "foo"})
+	bar := r.Get(BreakerSettings{Host: "bar"})
+
+	r.Force("foo", ForcedOpen)
+	checkOpen(t, foo)
+	checkClosed(t, bar)
+
+	// applies also to the new breakers of the host
+	fooRoute := r.Get(BreakerSettings{Host: "foo", Type: FailureRate, Failures: 1, Window: 10})
+	checkOpen(t, fooRoute)
+
+	r.Force("foo", ForcedClosed)
+	times(3, fail(t, foo))
+	checkClosed(t, foo)
+
+	failOnce(t, bar)
+	checkOpen(t, bar)
+
+	r.Reset("bar")
+	checkClosed(t, r.Get(BreakerSettings{Host: "bar"}))
+
+	r.Reset("foo")
+	foo = r.Get(BreakerSettings{Host: "foo"})
+	failOnce(t, foo)
+	checkOpen(t, foo)
+}
+
+func TestBreakers(t *testing.T) {
+	r := NewRegistry(BreakerSettings{Type: ConsecutiveFailures, Failures: 1})
+
+	failOnce(t, r.Get(BreakerSettings{Host: "foo"}))
+	r.Get(BreakerSettings{Host: "bar"})
+	r.Force("baz", ForcedOpen)
+	r.Get(BreakerSettings{Host: "baz"})
+
+	expected := []BreakerInfo{
+		{Host: "bar", Settings: "type=consecutive,host=bar,failures=1,idle-ttl=1h0m0s", State: "closed"},
+		{Host: "baz", Settings: "type=consecutive,host=baz,failures=1,idle-ttl=1h0m0s", State: "forced-open"},
+		{Host: "foo", Settings: "type=consecutive,host=foo,failures=1,idle-ttl=1h0m0s", State: "open"},
+	}
+
+	if got := r.Breakers(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected breakers, expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestStateChangeMetrics(t *testing.T) {
+	m := &metricstest.MockMetrics{}
+	defer func(d metrics.Metrics) { metrics.Default = d }(metrics.Default)
+	metrics.Default = m
+
+	b := newBreaker(BreakerSettings{Type: ConsecutiveFailures, Host: "foo.example.org:443", Failures: 1, Timeout: time.Millisecond})
+	failOnce(t, b)
+	time.Sleep(2 * time.Millisecond)
+	checkClosed(t, b)
+
+	m.WithCounters(func(counters map[string]int64) {
+		if counters["circuitbreaker.foo_example_org__443.open"] != 1 ||
+			counters["circuitbreaker.foo_example_org__443.half-open"] != 1 {
+			t.Errorf("unexpected counters: %v", counters)
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry(BreakerSettings{Type: ConsecutiveFailures, Failures: 1})
+	r.Get(BreakerSettings{Host: "foo"})
+
+	s := httptest.NewServer(r.OverrideHandler())
+	defer s.Close()
+
+	list := func() []BreakerInfo {
+		rsp, err := http.Get(s.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		var info []BreakerInfo
+		if err := json.NewDecoder(rsp.Body).Decode(&info); err != nil {
+			t.Fatal(err)
+		}
+
+		return info
+	}
+
+	post := func(v url.Values, expected int) {
+		rsp, err := http.PostForm(s.URL, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != expected {
+			t.Errorf("unexpected status code for %v, expected: %d, got: %d", v, expected, rsp.StatusCode)
+		}
+	}
+
+	if info := list(); len(info) != 1 || info[0].Host != "foo" || info[0].State != "closed" {
+		t.Fatalf("unexpected breakers: %v", info)
+	}
+
+	post(url.Values{"host": {"foo"}, "state": {"open"}}, http.StatusNoContent)
+	if info := list(); len(info) != 1 || info[0].State != "forced-open" {
+		t.Fatalf("unexpected breakers: %v", info)
+	}
+
+	post(url.Values{"host": {"foo"}, "state": {"reset"}}, http.StatusNoContent)
+	if info := list(); len(info) != 0 {
+		t.Fatalf("unexpected breakers: %v", info)
+	}
+
+	post(url.Values{"state": {"open"}}, http.StatusBadRequest)
+	post(url.Values{"host": {"foo"}, "state": {"half-open"}}, http.StatusBadRequest)
+
+	req, _ := http.NewRequest("DELETE", s.URL, nil)
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code: %d", rsp.StatusCode)
+	}
+}
+
+func TestHandlerReadOnly(t *testing.T) {
+	r := NewRegistry(BreakerSettings{Type: ConsecutiveFailures, Failures: 1})
+	r.Get(BreakerSettings{Host: "foo"})
+
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	rsp, err := http.PostForm(s.URL, url.Values{"host": {"foo"}, "state": {"open"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code: %d", rsp.StatusCode)
+	}
+
+	if info := r.Breakers(); len(info) != 1 || info[0].State != "closed" {
+		t.Errorf("unexpected breakers: %v", info)
+	}
+}
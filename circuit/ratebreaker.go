@@ -1,8 +1,8 @@
 package circuit
 
 import (
-	log "github.com/sirupsen/logrus"
 	"sync"
+	"time"
 
 	"github.com/sony/gobreaker"
 )
@@ -19,20 +19,18 @@ type rateBreaker struct {
 	gb       *gobreaker.TwoStepCircuitBreaker
 }
 
-func newRate(s BreakerSettings) *rateBreaker {
+func newRate(s BreakerSettings, onStateChange func(string, gobreaker.State, gobreaker.State)) *rateBreaker {
 	b := &rateBreaker{
 		settings: s,
 		mx:       &sync.Mutex{},
 	}
 
 	b.gb = gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
-		Name:        s.Host,
-		MaxRequests: uint32(s.HalfOpenRequests),
-		Timeout:     s.Timeout,
-		ReadyToTrip: func(gobreaker.Counts) bool { return b.readyToTrip() },
-		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			log.Infof("circuit breaker %v went from %v to %v", name, from.String(), to.String())
-		},
+		Name:          s.Host,
+		MaxRequests:   uint32(s.HalfOpenRequests),
+		Timeout:       s.Timeout,
+		ReadyToTrip:   func(gobreaker.Counts) bool { return b.readyToTrip() },
+		OnStateChange: onStateChange,
 	})
 
 	return b
@@ -61,7 +59,7 @@ func (b *rateBreaker) countRate(success bool) {
 	b.sampler.tick(!success)
 }
 
-func (b *rateBreaker) Allow() (func(bool), bool) {
+func (b *rateBreaker) Allow() (func(bool, time.Duration), bool) {
 	done, err := b.gb.Allow()
 
 	// this error can only indicate that the breaker is not closed
@@ -71,8 +69,12 @@ func (b *rateBreaker) Allow() (func(bool), bool) {
 		return nil, false
 	}
 
-	return func(success bool) {
+	return func(success bool, _ time.Duration) {
 		b.countRate(success)
 		done(success)
 	}, true
 }
+
+func (b *rateBreaker) State() gobreaker.State {
+	return b.gb.State()
+}
@@ -1,8 +1,12 @@
 package circuit
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 const DefaultIdleTTL = time.Hour
@@ -13,7 +17,20 @@ type Registry struct {
 	defaults     BreakerSettings
 	hostSettings map[string]BreakerSettings
 	lookup       map[BreakerSettings]*Breaker
+	forced       map[string]ForcedState
 	mx           *sync.Mutex
+
+	shared     SharedState
+	sharedOpen map[string]time.Time
+	quit       chan struct{}
+	once       sync.Once
+}
+
+// BreakerInfo describes an active circuit breaker.
+type BreakerInfo struct {
+	Host     string `json:"host"`
+	Settings string `json:"settings"`
+	State    string `json:"state"`
 }
 
 // NewRegistry initializes a registry with the provided default settings. Settings with an empty Host field are
@@ -50,7 +67,99 @@ func NewRegistry(settings ...BreakerSettings) *Registry {
 		defaults:     defaults,
 		hostSettings: hs,
 		lookup:       make(map[BreakerSettings]*Breaker),
+		forced:       make(map[string]ForcedState),
 		mx:           &sync.Mutex{},
+		sharedOpen:   make(map[string]time.Time),
+		quit:         make(chan struct{}),
+	}
+}
+
+// NewSharedRegistry initializes a registry like NewRegistry, which shares the open transitions of its breakers
+// with the other skipper instances, and applies the ones of the other instances to its breakers. It fetches the
+// state of the breakers of the other instances every DefaultSharedStateInterval. Call Close to stop it.
+func NewSharedRegistry(shared SharedState, settings ...BreakerSettings) *Registry {
+	return newSharedRegistry(shared, DefaultSharedStateInterval, settings...)
+}
+
+func newSharedRegistry(shared SharedState, d time.Duration, settings ...BreakerSettings) *Registry {
+	r := NewRegistry(settings...)
+	r.shared = shared
+	go r.syncShared(d)
+	return r
+}
+
+// Close stops sharing the state of the breakers.
+func (r *Registry) Close() {
+	r.once.Do(func() { close(r.quit) })
+}
+
+// tripShared is called by the breakers, when they go open
+func (r *Registry) tripShared(b *Breaker) {
+	host, until := b.settings.Host, time.Now().Add(b.timeout())
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultSharedStateInterval)
+		defer cancel()
+
+		if err := r.shared.Trip(ctx, host, until); err != nil {
+			log.Errorf("Failed to share the open circuit breaker of %s: %v", host, err)
+		}
+	}()
+}
+
+func (r *Registry) syncShared(d time.Duration) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			r.fetchShared(d)
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+func (r *Registry) hosts() []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	known := make(map[string]bool)
+	var hosts []string
+	for s := range r.lookup {
+		if !known[s.Host] {
+			known[s.Host] = true
+			hosts = append(hosts, s.Host)
+		}
+	}
+
+	return hosts
+}
+
+func (r *Registry) fetchShared(timeout time.Duration) {
+	for _, host := range r.hosts() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		until, err := r.shared.OpenUntil(ctx, host)
+		cancel()
+
+		if err != nil {
+			log.Errorf("Failed to get the shared state of the circuit breakers of %s: %v", host, err)
+			continue
+		}
+
+		r.mx.Lock()
+		if until.IsZero() {
+			delete(r.sharedOpen, host)
+		} else {
+			r.sharedOpen[host] = until
+		}
+
+		for s, b := range r.lookup {
+			if s.Host == host {
+				b.setOpenUntil(until)
+			}
+		}
+		r.mx.Unlock()
 	}
 }
 
@@ -84,6 +193,12 @@ func (r *Registry) get(s BreakerSettings) *Breaker {
 
 		// create a new one
 		b = newBreaker(s)
+		b.setForced(r.forced[s.Host])
+		if r.shared != nil {
+			b.onOpen = r.tripShared
+			b.setOpenUntil(r.sharedOpen[s.Host])
+		}
+
 		r.lookup[s] = b
 	}
 
@@ -113,3 +228,62 @@ func (r *Registry) Get(s BreakerSettings) *Breaker {
 
 	return r.get(s)
 }
+
+// Breakers returns the active circuit breakers, sorted by their host and settings.
+func (r *Registry) Breakers() []BreakerInfo {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	info := make([]BreakerInfo, 0, len(r.lookup))
+	for s, b := range r.lookup {
+		info = append(info, BreakerInfo{
+			Host:     s.Host,
+			Settings: s.String(),
+			State:    b.State(),
+		})
+	}
+
+	sort.Slice(info, func(i, j int) bool {
+		if info[i].Host != info[j].Host {
+			return info[i].Host < info[j].Host
+		}
+
+		return info[i].Settings < info[j].Settings
+	})
+
+	return info
+}
+
+// Force overrides the state of the circuit breakers of a backend host, including the ones created later, until
+// it is reset. Forcing NotForced is the same as calling Reset.
+func (r *Registry) Force(host string, f ForcedState) {
+	if f == NotForced {
+		r.Reset(host)
+		return
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.forced[host] = f
+	for s, b := range r.lookup {
+		if s.Host == host {
+			b.setForced(f)
+		}
+	}
+}
+
+// Reset clears the forced state of the circuit breakers of a backend host, and resets them to the closed state,
+// dropping the counted failures.
+func (r *Registry) Reset(host string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	delete(r.forced, host)
+	delete(r.sharedOpen, host)
+	for s := range r.lookup {
+		if s.Host == host {
+			delete(r.lookup, s)
+		}
+	}
+}
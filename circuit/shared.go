@@ -0,0 +1,107 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+
+	"github.com/zalando/skipper/net"
+)
+
+const (
+	sharedStateKeyPrefix = "circuitbreaker."
+
+	// DefaultSharedStateInterval is the default interval of fetching the
+	// circuit breaker trips of the other instances.
+	DefaultSharedStateInterval = time.Second
+)
+
+// SharedState is used to share the open transitions of the circuit
+// breakers between the skipper instances, so that when a breaker of a
+// backend host opens in one instance, it protects the backend host in
+// all of them for the breaker timeout.
+type SharedState interface {
+	// Trip shares that the breakers of the host are open until the
+	// given time.
+	Trip(ctx context.Context, host string, until time.Time) error
+
+	// OpenUntil returns the time until the breakers of the host were
+	// tripped by any of the instances, or the zero time.
+	OpenUntil(ctx context.Context, host string) (time.Time, error)
+}
+
+// Swarmer is used to share the circuit breaker trips via the swarm. It
+// is implemented by swarm.Swarm.
+type Swarmer interface {
+	ShareValue(string, interface{}) error
+	Values(string) map[string]interface{}
+}
+
+type swarmState struct {
+	swarm Swarmer
+}
+
+// NewSwarmState creates a SharedState, which shares the trips of the
+// circuit breakers via the swarm.
+func NewSwarmState(s Swarmer) SharedState {
+	return &swarmState{swarm: s}
+}
+
+func (s *swarmState) Trip(_ context.Context, host string, until time.Time) error {
+	return s.swarm.ShareValue(sharedStateKeyPrefix+host, until.UnixNano())
+}
+
+func (s *swarmState) OpenUntil(_ context.Context, host string) (time.Time, error) {
+	var latest int64
+	for _, v := range s.swarm.Values(sharedStateKeyPrefix + host) {
+		if until, ok := v.(int64); ok && until > latest {
+			latest = until
+		}
+	}
+
+	if latest == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(0, latest), nil
+}
+
+type redisState struct {
+	ringClient *net.RedisRingClient
+	now        func() time.Time
+}
+
+// NewRedisState creates a SharedState, which stores the trips of the
+// circuit breakers in Redis, expiring with the breaker timeout.
+func NewRedisState(ringClient *net.RedisRingClient) SharedState {
+	return &redisState{ringClient: ringClient, now: time.Now}
+}
+
+func (s *redisState) Trip(ctx context.Context, host string, until time.Time) error {
+	ttl := until.Sub(s.now())
+	if ttl <= 0 {
+		return nil
+	}
+
+	_, err := s.ringClient.Set(ctx, sharedStateKeyPrefix+host, until.UnixNano(), ttl)
+	return err
+}
+
+func (s *redisState) OpenUntil(ctx context.Context, host string) (time.Time, error) {
+	v, err := s.ringClient.Get(ctx, sharedStateKeyPrefix+host)
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	until, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, until), nil
+}
@@ -0,0 +1,141 @@
+package circuit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/net/redistest"
+)
+
+type testSwarm struct {
+	mu     sync.Mutex
+	values map[string]map[string]interface{}
+}
+
+type testSwarmNode struct {
+	name  string
+	swarm *testSwarm
+}
+
+func newTestSwarm() *testSwarm {
+	return &testSwarm{values: make(map[string]map[string]interface{})}
+}
+
+func (s *testSwarm) node(name string) *testSwarmNode {
+	return &testSwarmNode{name: name, swarm: s}
+}
+
+func (n *testSwarmNode) ShareValue(key string, value interface{}) error {
+	n.swarm.mu.Lock()
+	defer n.swarm.mu.Unlock()
+
+	if n.swarm.values[key] == nil {
+		n.swarm.values[key] = make(map[string]interface{})
+	}
+
+	n.swarm.values[key][n.name] = value
+	return nil
+}
+
+func (n *testSwarmNode) Values(key string) map[string]interface{} {
+	n.swarm.mu.Lock()
+	defer n.swarm.mu.Unlock()
+
+	values := make(map[string]interface{})
+	for k, v := range n.swarm.values[key] {
+		values[k] = v
+	}
+
+	return values
+}
+
+func TestSharedRegistry(t *testing.T) {
+	settings := BreakerSettings{Type: ConsecutiveFailures, Failures: 1, Timeout: 100 * time.Millisecond}
+
+	swarm := newTestSwarm()
+	r1 := newSharedRegistry(NewSwarmState(swarm.node("r1")), time.Millisecond, settings)
+	defer r1.Close()
+
+	r2 := newSharedRegistry(NewSwarmState(swarm.node("r2")), time.Millisecond, settings)
+	defer r2.Close()
+
+	foo1 := r1.Get(BreakerSettings{Host: "foo"})
+	foo2 := r2.Get(BreakerSettings{Host: "foo"})
+	bar2 := r2.Get(BreakerSettings{Host: "bar"})
+
+	failOnce(t, foo1)
+	checkOpen(t, foo1)
+
+	waitFor := func(f func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for !f() {
+			if time.Now().After(deadline) {
+				t.Fatal("timeout")
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// the trip of the first instance opens the breaker of the second one
+	waitFor(func() bool { return foo2.State() == "open" })
+	checkOpen(t, foo2)
+	checkClosed(t, bar2)
+
+	// and the new breakers of the host
+	waitFor(func() bool { return r2.Get(BreakerSettings{Host: "foo", Type: FailureRate, Failures: 5, Window: 10}).State() == "open" })
+
+	// until the timeout
+	waitFor(func() bool { return foo2.State() == "closed" })
+	checkClosed(t, foo2)
+}
+
+func TestSwarmStateLatest(t *testing.T) {
+	swarm := newTestSwarm()
+	s1, s2 := NewSwarmState(swarm.node("s1")), NewSwarmState(swarm.node("s2"))
+
+	ctx := context.Background()
+	until, err := s1.OpenUntil(ctx, "foo")
+	if err != nil || !until.IsZero() {
+		t.Fatalf("unexpected state: %v, %v", until, err)
+	}
+
+	now := time.Now()
+	s1.Trip(ctx, "foo", now.Add(time.Minute))
+	s2.Trip(ctx, "foo", now.Add(time.Second))
+
+	until, err = s2.OpenUntil(ctx, "foo")
+	if err != nil || !until.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected state: %v, %v", until, err)
+	}
+}
+
+func TestRedisState(t *testing.T) {
+	redisAddr, done := redistest.NewTestRedis(t)
+	defer done()
+
+	ringClient := net.NewRedisRingClient(&net.RedisOptions{Addrs: []string{redisAddr}})
+	defer ringClient.Close()
+
+	s := NewRedisState(ringClient)
+	ctx := context.Background()
+
+	until, err := s.OpenUntil(ctx, "foo")
+	if err != nil || !until.IsZero() {
+		t.Fatalf("unexpected state: %v, %v", until, err)
+	}
+
+	expected := time.Now().Add(time.Minute)
+	if err := s.Trip(ctx, "foo", expected); err != nil {
+		t.Fatal(err)
+	}
+
+	until, err = s.OpenUntil(ctx, "foo")
+	if err != nil || !until.Equal(expected.Round(0)) {
+		t.Errorf("unexpected state: %v, %v", until, err)
+	}
+}
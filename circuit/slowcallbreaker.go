@@ -0,0 +1,84 @@
+package circuit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+type slowCallBreaker struct {
+	settings BreakerSettings
+	mx       *sync.Mutex
+	sampler  *binarySampler
+	gb       *gobreaker.TwoStepCircuitBreaker
+}
+
+func newSlowCall(s BreakerSettings, onStateChange func(string, gobreaker.State, gobreaker.State)) *slowCallBreaker {
+	b := &slowCallBreaker{
+		settings: s,
+		mx:       &sync.Mutex{},
+	}
+
+	b.gb = gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:          s.Host,
+		MaxRequests:   uint32(s.HalfOpenRequests),
+		Timeout:       s.Timeout,
+		ReadyToTrip:   func(gobreaker.Counts) bool { return b.readyToTrip() },
+		OnStateChange: onStateChange,
+	})
+
+	return b
+}
+
+// the rate is calculated for the whole window, even when it is not
+// filled yet, this way a few slow calls after a reset don't open the
+// breaker
+func (b *slowCallBreaker) readyToTrip() bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.sampler == nil {
+		return false
+	}
+
+	return b.sampler.count*100 >= b.settings.SlowCallRate*b.sampler.size
+}
+
+func (b *slowCallBreaker) slow(d time.Duration) bool {
+	return b.settings.SlowCallDuration > 0 && d > b.settings.SlowCallDuration
+}
+
+// count the slow or failed calls in closed and half-open state
+func (b *slowCallBreaker) countRate(bad bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.sampler == nil {
+		b.sampler = newBinarySampler(b.settings.Window)
+	}
+
+	b.sampler.tick(bad)
+}
+
+func (b *slowCallBreaker) Allow() (func(bool, time.Duration), bool) {
+	done, err := b.gb.Allow()
+
+	// this error can only indicate that the breaker is not closed
+	closed := err == nil
+
+	if !closed {
+		return nil, false
+	}
+
+	return func(success bool, d time.Duration) {
+		// a slow call counts as a failure also in the half-open state
+		ok := success && !b.slow(d)
+		b.countRate(!ok)
+		done(ok)
+	}, true
+}
+
+func (b *slowCallBreaker) State() gobreaker.State {
+	return b.gb.State()
+}
@@ -11,20 +11,43 @@ import (
 
 const breakerUsage = `set global or host specific circuit breakers, e.g. -breaker type=rate,host=www.example.org,window=300s,failures=30
 	possible breaker properties:
-	type: consecutive/rate/disabled (defaults to consecutive)
+	type: consecutive/rate/slow/disabled (defaults to consecutive)
 	host: a host name that overrides the global for a host
 	failures: the number of failures for consecutive or rate breakers
-	window: the size of the sliding window for the rate breaker
+	window: the size of the sliding window for the rate and slow breakers
 	timeout: duration string or milliseconds while the breaker stays open
 	half-open-requests: the number of requests in half-open state to succeed before getting closed again
 	idle-ttl: duration string or milliseconds after the breaker is considered idle and reset
+	slow-call-rate: the percentage of the slow or failed calls within the window for the slow breaker
+	slow-call-duration: duration string above which a call is considered slow by the slow breaker
+	failure-status-codes: the status codes counted as failures separated by spaces, defaults to >=500
 	(see also: https://godoc.org/github.com/zalando/skipper/circuit)`
 
 const enableBreakersUsage = `enable breakers to be set from filters without providing global or host settings (equivalent to: -breaker type=disabled)`
 
+const enableSharedBreakersUsage = `share the open circuit breakers between the skipper instances via the swarm, requires -enable-swarm`
+
+const enableBreakersOverrideUsage = `accept POST requests on the /circuitbreakers endpoint of the support listener to override the state of the circuit breakers, the requests are not authenticated`
+
 type breakerFlags []circuit.BreakerSettings
 
-var errInvalidBreakerConfig = errors.New("invalid breaker config (allowed values are: consecutive, rate or disabled)")
+var errInvalidBreakerConfig = errors.New("invalid breaker config (allowed values are: consecutive, rate, slow or disabled)")
+
+var errInvalidSlowBreakerConfig = errors.New("invalid slow breaker config (slow-call-rate needs to be between 1 and 100, and slow-call-duration needs to be positive)")
+
+// validateSlowCallBreaker checks the settings of the slow call breakers,
+// the same way as the slowCallBreaker filter does.
+func validateSlowCallBreaker(s circuit.BreakerSettings) error {
+	if s.Type != circuit.SlowCallRate {
+		return nil
+	}
+
+	if s.SlowCallRate <= 0 || s.SlowCallRate > 100 || s.SlowCallDuration <= 0 {
+		return errInvalidSlowBreakerConfig
+	}
+
+	return nil
+}
 
 func (b breakerFlags) String() string {
 	s := make([]string, len(b))
@@ -52,6 +75,8 @@ func (b *breakerFlags) Set(value string) error {
 				s.Type = circuit.ConsecutiveFailures
 			case "rate":
 				s.Type = circuit.FailureRate
+			case "slow":
+				s.Type = circuit.SlowCallRate
 			case "disabled":
 				s.Type = circuit.BreakerDisabled
 			default:
@@ -94,6 +119,27 @@ func (b *breakerFlags) Set(value string) error {
 			}
 
 			s.IdleTTL = d
+		case "slow-call-rate":
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+
+			s.SlowCallRate = i
+		case "slow-call-duration":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+
+			s.SlowCallDuration = d
+		case "failure-status-codes":
+			c, err := circuit.ParseStatusCodes(v)
+			if err != nil {
+				return err
+			}
+
+			s.FailureStatusCodes = c
 		default:
 			return errInvalidBreakerConfig
 		}
@@ -103,6 +149,10 @@ func (b *breakerFlags) Set(value string) error {
 		s.Type = circuit.ConsecutiveFailures
 	}
 
+	if err := validateSlowCallBreaker(s); err != nil {
+		return err
+	}
+
 	*b = append(*b, s)
 	return nil
 }
@@ -113,6 +163,10 @@ func (b *breakerFlags) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	if err := validateSlowCallBreaker(breakerSettings); err != nil {
+		return err
+	}
+
 	*b = append(*b, breakerSettings)
 	return nil
 }
@@ -30,6 +30,19 @@ func Test_breakerFlags_String(t *testing.T) {
 			},
 			want: "type=consecutive,host=example.com,timeout=3s,half-open-requests=3,idle-ttl=5s",
 		},
+		{
+			name: "test slow call breaker",
+			b: &breakerFlags{
+				circuit.BreakerSettings{
+					Type:               circuit.SlowCallRate,
+					Window:             100,
+					SlowCallRate:       50,
+					SlowCallDuration:   2 * time.Second,
+					FailureStatusCodes: circuit.NewStatusCodes(503, 429),
+				},
+			},
+			want: "type=slow,window=100,slow-call-rate=50,slow-call-duration=2s,failure-status-codes=429 503",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -101,6 +114,38 @@ func Test_breakerFlags_Set(t *testing.T) {
 				IdleTTL:          5 * time.Second,
 			},
 		},
+		{
+			name:    "test breaker settings slow call rate",
+			args:    "type=slow,window=100,slow-call-rate=50,slow-call-duration=2s,failure-status-codes=503 429",
+			wantErr: false,
+			want: circuit.BreakerSettings{
+				Type:               circuit.SlowCallRate,
+				Window:             100,
+				SlowCallRate:       50,
+				SlowCallDuration:   2 * time.Second,
+				FailureStatusCodes: circuit.NewStatusCodes(429, 503),
+			},
+		},
+		{
+			name:    "test breaker settings slow call rate without rate",
+			args:    "type=slow,window=100,slow-call-duration=2s",
+			wantErr: true,
+		},
+		{
+			name:    "test breaker settings slow call rate above 100",
+			args:    "type=slow,window=100,slow-call-rate=101,slow-call-duration=2s",
+			wantErr: true,
+		},
+		{
+			name:    "test breaker settings slow call rate without duration",
+			args:    "type=slow,window=100,slow-call-rate=50",
+			wantErr: true,
+		},
+		{
+			name:    "test breaker settings invalid failure status codes",
+			args:    "type=rate,failure-status-codes=503 foo",
+			wantErr: true,
+		},
 		{
 			name:    "test breaker settings invalid type",
 			args:    "type=invalid,host=example.com,timeout=3s,half-open-requests=3,idle-ttl=5s",
@@ -181,6 +226,43 @@ half-open-requests: 3
 idle-ttl: 5s`,
 			wantErr: true,
 		},
+		{
+			name: "test breaker settings slow call rate",
+			yml: `type: slow
+window: 100
+slow-call-rate: 50
+slow-call-duration: 2s
+failure-status-codes: [503, 429]`,
+			wantErr: false,
+			want: circuit.BreakerSettings{
+				Type:               circuit.SlowCallRate,
+				Window:             100,
+				SlowCallRate:       50,
+				SlowCallDuration:   2 * time.Second,
+				FailureStatusCodes: circuit.NewStatusCodes(429, 503),
+			},
+		},
+		{
+			name: "test breaker settings slow call rate without rate",
+			yml: `type: slow
+window: 100
+slow-call-duration: 2s`,
+			wantErr: true,
+		},
+		{
+			name: "test breaker settings slow call rate with negative duration",
+			yml: `type: slow
+window: 100
+slow-call-rate: 50
+slow-call-duration: -2s`,
+			wantErr: true,
+		},
+		{
+			name: "test breaker settings invalid failure status codes",
+			yml: `type: rate
+failure-status-codes: [503, 42]`,
+			wantErr: true,
+		},
 		{
 			name: "test breaker settings invalid type",
 			yml: `type: invalid
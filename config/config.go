@@ -19,6 +19,7 @@ import (
 	"github.com/zalando/skipper"
 	"github.com/zalando/skipper/dataclients/kubernetes"
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/net"
 	"github.com/zalando/skipper/proxy"
 	routesrv "github.com/zalando/skipper/routesrv"
@@ -48,15 +49,28 @@ type Config struct {
 	DefaultHTTPStatus               int            `yaml:"default-http-status"`
 	PluginDir                       string         `yaml:"plugindir"`
 	LoadBalancerHealthCheckInterval time.Duration  `yaml:"lb-healthcheck-interval"`
+	EnableOutlierDetection          bool           `yaml:"lb-outlier-detection"`
+	OutlierConsecutiveFailures      int            `yaml:"lb-outlier-consecutive-failures"`
+	OutlierLatencyThreshold         time.Duration  `yaml:"lb-outlier-latency-threshold"`
+	OutlierBaseEjectionTime         time.Duration  `yaml:"lb-outlier-base-ejection-time"`
+	OutlierMaxEjectionTime          time.Duration  `yaml:"lb-outlier-max-ejection-time"`
+	OutlierMaxEjectionPercent       int            `yaml:"lb-outlier-max-ejection-percent"`
+	OutlierFadeInDuration           time.Duration  `yaml:"lb-outlier-fade-in-duration"`
+	LoadBalancerZone                string         `yaml:"lb-zone"`
+	ZoneMinEndpoints                int            `yaml:"lb-zone-min-endpoints"`
+	ZoneMinHealthyPercent           int            `yaml:"lb-zone-min-healthy-percent"`
 	ReverseSourcePredicate          bool           `yaml:"reverse-source-predicate"`
 	RemoveHopHeaders                bool           `yaml:"remove-hop-headers"`
 	RfcPatchPath                    bool           `yaml:"rfc-patch-path"`
 	MaxAuditBody                    int            `yaml:"max-audit-body"`
 	EnableBreakers                  bool           `yaml:"enable-breakers"`
 	Breakers                        breakerFlags   `yaml:"breaker"`
+	EnableSharedBreakers            bool           `yaml:"enable-shared-breakers"`
+	EnableBreakersOverride          bool           `yaml:"enable-breakers-override"`
 	EnableRatelimiters              bool           `yaml:"enable-ratelimits"`
 	Ratelimits                      ratelimitFlags `yaml:"ratelimits"`
 	EnableRouteLIFOMetrics          bool           `yaml:"enable-route-lifo-metrics"`
+	EnableRouteFIFOMetrics          bool           `yaml:"enable-route-fifo-metrics"`
 	MetricsFlavour                  *listFlag      `yaml:"metrics-flavour"`
 	FilterPlugins                   *pluginFlag    `yaml:"filter-plugin"`
 	PredicatePlugins                *pluginFlag    `yaml:"predicate-plugin"`
@@ -154,6 +168,9 @@ type Config struct {
 	KubernetesHTTPSRedirect                 bool                `yaml:"kubernetes-https-redirect"`
 	KubernetesHTTPSRedirectCode             int                 `yaml:"kubernetes-https-redirect-code"`
 	KubernetesIngressV1                     bool                `yaml:"kubernetes-ingress-v1"`
+	KubernetesTopologyZones                 bool                `yaml:"kubernetes-topology-zones"`
+	KubernetesEnableEndpointSlices          bool                `yaml:"enable-kubernetes-endpointslices"`
+	KubernetesWatch                         bool                `yaml:"enable-kubernetes-watch"`
 	KubernetesIngressClass                  string              `yaml:"kubernetes-ingress-class"`
 	KubernetesRouteGroupClass               string              `yaml:"kubernetes-routegroup-class"`
 	WhitelistedHealthCheckCIDR              string              `yaml:"whitelisted-healthcheck-cidr"`
@@ -175,31 +192,38 @@ type Config struct {
 	DefaultFiltersDir string `yaml:"default-filters-dir"`
 
 	// Auth:
-	EnableOAuth2GrantFlow           bool          `yaml:"enable-oauth2-grant-flow"`
-	OauthURL                        string        `yaml:"oauth-url"`
-	OauthScope                      string        `yaml:"oauth-scope"`
-	OauthCredentialsDir             string        `yaml:"oauth-credentials-dir"`
-	Oauth2AuthURL                   string        `yaml:"oauth2-auth-url"`
-	Oauth2TokenURL                  string        `yaml:"oauth2-token-url"`
-	Oauth2RevokeTokenURL            string        `yaml:"oauth2-revoke-token-url"`
-	Oauth2TokeninfoURL              string        `yaml:"oauth2-tokeninfo-url"`
-	Oauth2TokeninfoTimeout          time.Duration `yaml:"oauth2-tokeninfo-timeout"`
-	Oauth2SecretFile                string        `yaml:"oauth2-secret-file"`
-	Oauth2ClientID                  string        `yaml:"oauth2-client-id"`
-	Oauth2ClientSecret              string        `yaml:"oauth2-client-secret"`
-	Oauth2ClientIDFile              string        `yaml:"oauth2-client-id-file"`
-	Oauth2ClientSecretFile          string        `yaml:"oauth2-client-secret-file"`
-	Oauth2AuthURLParameters         mapFlags      `yaml:"oauth2-auth-url-parameters"`
-	Oauth2CallbackPath              string        `yaml:"oauth2-callback-path"`
-	Oauth2TokenintrospectionTimeout time.Duration `yaml:"oauth2-tokenintrospect-timeout"`
-	Oauth2AccessTokenHeaderName     string        `yaml:"oauth2-access-token-header-name"`
-	Oauth2TokeninfoSubjectKey       string        `yaml:"oauth2-tokeninfo-subject-key"`
-	Oauth2TokenCookieName           string        `yaml:"oauth2-token-cookie-name"`
-	WebhookTimeout                  time.Duration `yaml:"webhook-timeout"`
-	OidcSecretsFile                 string        `yaml:"oidc-secrets-file"`
-	OidcDistributedClaimsTimeout    time.Duration `yaml:"oidc-distributed-claims-timeout"`
-	CredentialPaths                 *listFlag     `yaml:"credentials-paths"`
-	CredentialsUpdateInterval       time.Duration `yaml:"credentials-update-interval"`
+	EnableOAuth2GrantFlow             bool          `yaml:"enable-oauth2-grant-flow"`
+	OauthURL                          string        `yaml:"oauth-url"`
+	OauthScope                        string        `yaml:"oauth-scope"`
+	OauthCredentialsDir               string        `yaml:"oauth-credentials-dir"`
+	Oauth2AuthURL                     string        `yaml:"oauth2-auth-url"`
+	Oauth2TokenURL                    string        `yaml:"oauth2-token-url"`
+	Oauth2RevokeTokenURL              string        `yaml:"oauth2-revoke-token-url"`
+	Oauth2TokeninfoURL                string        `yaml:"oauth2-tokeninfo-url"`
+	Oauth2TokeninfoTimeout            time.Duration `yaml:"oauth2-tokeninfo-timeout"`
+	Oauth2TokeninfoCacheSize          int           `yaml:"oauth2-tokeninfo-cache-size"`
+	Oauth2TokeninfoCacheTTL           time.Duration `yaml:"oauth2-tokeninfo-cache-ttl"`
+	Oauth2SecretFile                  string        `yaml:"oauth2-secret-file"`
+	Oauth2ClientID                    string        `yaml:"oauth2-client-id"`
+	Oauth2ClientSecret                string        `yaml:"oauth2-client-secret"`
+	Oauth2ClientIDFile                string        `yaml:"oauth2-client-id-file"`
+	Oauth2ClientSecretFile            string        `yaml:"oauth2-client-secret-file"`
+	Oauth2AuthURLParameters           mapFlags      `yaml:"oauth2-auth-url-parameters"`
+	Oauth2CallbackPath                string        `yaml:"oauth2-callback-path"`
+	Oauth2TokenintrospectionTimeout   time.Duration `yaml:"oauth2-tokenintrospect-timeout"`
+	Oauth2TokenintrospectionCacheSize int           `yaml:"oauth2-tokenintrospect-cache-size"`
+	Oauth2TokenintrospectionCacheTTL  time.Duration `yaml:"oauth2-tokenintrospect-cache-ttl"`
+	Oauth2AccessTokenHeaderName       string        `yaml:"oauth2-access-token-header-name"`
+	Oauth2TokeninfoSubjectKey         string        `yaml:"oauth2-tokeninfo-subject-key"`
+	Oauth2TokenCookieName             string        `yaml:"oauth2-token-cookie-name"`
+	WebhookTimeout                    time.Duration `yaml:"webhook-timeout"`
+	PolicyBundleReloadInterval        time.Duration `yaml:"policy-bundle-reload-interval"`
+	PolicyBundleDir                   string        `yaml:"policy-bundle-dir"`
+	PolicyBundleURLPrefixes           *listFlag     `yaml:"policy-bundle-url-prefixes"`
+	OidcSecretsFile                   string        `yaml:"oidc-secrets-file"`
+	OidcDistributedClaimsTimeout      time.Duration `yaml:"oidc-distributed-claims-timeout"`
+	CredentialPaths                   *listFlag     `yaml:"credentials-paths"`
+	CredentialsUpdateInterval         time.Duration `yaml:"credentials-update-interval"`
 
 	// TLS client certs
 	ClientKeyFile  string            `yaml:"client-tls-key"`
@@ -209,6 +233,11 @@ type Config struct {
 	// TLS version
 	TLSMinVersion string `yaml:"tls-min-version"`
 
+	// TLS client authentication
+	ClientCAPathTLS     string              `yaml:"tls-client-ca"`
+	ClientAuthTLSString string              `yaml:"tls-client-auth"`
+	ClientAuthTLS       *tls.ClientAuthType `yaml:"-"`
+
 	// TLS Config
 	KubernetesEnableTLS bool `yaml:"kubernetes-enable-tls"`
 
@@ -263,7 +292,10 @@ type Config struct {
 	SwarmStaticSelf                   string        `yaml:"swarm-static-self"`
 	SwarmStaticOther                  string        `yaml:"swarm-static-other"`
 
-	ClusterRatelimitMaxGroupShards int `yaml:"cluster-ratelimit-max-group-shards"`
+	ClusterRatelimitMaxGroupShards int           `yaml:"cluster-ratelimit-max-group-shards"`
+	RatelimitQuotaHeaders          bool          `yaml:"ratelimit-quota-headers"`
+	RatelimitPlansFile             string        `yaml:"ratelimit-plans-file"`
+	RatelimitPlansUpdateInterval   time.Duration `yaml:"ratelimit-plans-update-interval"`
 }
 
 const (
@@ -283,6 +315,7 @@ func NewConfig() *Config {
 	cfg.DataclientPlugins = newPluginFlag()
 	cfg.MultiPlugins = newPluginFlag()
 	cfg.CredentialPaths = commaListFlag()
+	cfg.PolicyBundleURLPrefixes = commaListFlag()
 	cfg.SwarmRedisURLs = commaListFlag()
 	cfg.AppendFilters = &defaultFiltersFlags{}
 	cfg.PrependFilters = &defaultFiltersFlags{}
@@ -310,21 +343,36 @@ func NewConfig() *Config {
 	flag.StringVar(&cfg.DebugListener, "debug-listener", "", "when this address is set, skipper starts an additional listener returning the original and transformed requests")
 	flag.StringVar(&cfg.CertPathTLS, "tls-cert", "", "the path on the local filesystem to the certificate file(s) (including any intermediates), multiple may be given comma separated")
 	flag.StringVar(&cfg.KeyPathTLS, "tls-key", "", "the path on the local filesystem to the certificate's private key file(s), multiple keys may be given comma separated - the order must match the certs")
+	flag.StringVar(&cfg.ClientCAPathTLS, "tls-client-ca", "", "the path on the local filesystem to the CA certificate file(s) used to verify the TLS client certificates, multiple may be given comma separated")
+	flag.StringVar(&cfg.ClientAuthTLSString, "tls-client-auth", "", "the TLS client authentication policy: <none|request|require-any|verify-if-given|require-and-verify>, defaults to verify-if-given when -tls-client-ca is set, otherwise to none")
 	flag.Var(cfg.StatusChecks, "status-checks", "experimental URLs to check before reporting healthy on startup")
 	flag.BoolVar(&cfg.PrintVersion, "version", false, "print Skipper version")
 	flag.IntVar(&cfg.MaxLoopbacks, "max-loopbacks", proxy.DefaultMaxLoopbacks, "maximum number of loopbacks for an incoming request, set to -1 to disable loopbacks")
 	flag.IntVar(&cfg.DefaultHTTPStatus, "default-http-status", http.StatusNotFound, "default HTTP status used when no route is found for a request")
 	flag.StringVar(&cfg.PluginDir, "plugindir", "", "set the directory to load plugins from, default is ./")
 	flag.DurationVar(&cfg.LoadBalancerHealthCheckInterval, "lb-healthcheck-interval", 0, "use to set the health checker interval to check healthiness of former dead or unhealthy routes")
+	flag.BoolVar(&cfg.EnableOutlierDetection, "lb-outlier-detection", false, "enables the passive outlier detection, ejecting temporarily the load balanced endpoints that fail consecutively")
+	flag.IntVar(&cfg.OutlierConsecutiveFailures, "lb-outlier-consecutive-failures", 0, "number of consecutive 5xx responses or connection errors after which an endpoint gets ejected, default 5")
+	flag.DurationVar(&cfg.OutlierLatencyThreshold, "lb-outlier-latency-threshold", 0, "when set, requests slower than the threshold count as failures for the outlier detection")
+	flag.DurationVar(&cfg.OutlierBaseEjectionTime, "lb-outlier-base-ejection-time", 0, "ejection time of the first ejection of an endpoint, doubled with every subsequent ejection, default 30s")
+	flag.DurationVar(&cfg.OutlierMaxEjectionTime, "lb-outlier-max-ejection-time", 0, "maximum ejection time of an endpoint, default 5m")
+	flag.IntVar(&cfg.OutlierMaxEjectionPercent, "lb-outlier-max-ejection-percent", 0, "maximum percentage of the endpoints of a route ejected at the same time, default 50")
+	flag.DurationVar(&cfg.OutlierFadeInDuration, "lb-outlier-fade-in-duration", 0, "fade-in duration of the endpoints after their ejection expired, default 10s, negative values disable it")
+	flag.StringVar(&cfg.LoadBalancerZone, "lb-zone", "", "availability zone of the proxy, when set, enables the zone aware load balancing that prefers the endpoints in the same zone")
+	flag.IntVar(&cfg.ZoneMinEndpoints, "lb-zone-min-endpoints", 0, "minimum number of healthy endpoints in the same zone required to keep the traffic in the zone, default 2")
+	flag.IntVar(&cfg.ZoneMinHealthyPercent, "lb-zone-min-healthy-percent", 0, "minimum percentage of healthy endpoints in the same zone required to keep the traffic in the zone, default 70")
 	flag.BoolVar(&cfg.ReverseSourcePredicate, "reverse-source-predicate", false, "reverse the order of finding the client IP from X-Forwarded-For header")
 	flag.BoolVar(&cfg.RemoveHopHeaders, "remove-hop-headers", false, "enables removal of Hop-Headers according to RFC-2616")
 	flag.BoolVar(&cfg.RfcPatchPath, "rfc-patch-path", false, "patches the incoming request path to preserve uncoded reserved characters according to RFC 2616 and RFC 3986")
 	flag.IntVar(&cfg.MaxAuditBody, "max-audit-body", 1024, "sets the max body to read to log in the audit log body")
 	flag.BoolVar(&cfg.EnableBreakers, "enable-breakers", false, enableBreakersUsage)
 	flag.Var(&cfg.Breakers, "breaker", breakerUsage)
+	flag.BoolVar(&cfg.EnableSharedBreakers, "enable-shared-breakers", false, enableSharedBreakersUsage)
+	flag.BoolVar(&cfg.EnableBreakersOverride, "enable-breakers-override", false, enableBreakersOverrideUsage)
 	flag.BoolVar(&cfg.EnableRatelimiters, "enable-ratelimits", false, enableRatelimitsUsage)
 	flag.Var(&cfg.Ratelimits, "ratelimits", ratelimitsUsage)
 	flag.BoolVar(&cfg.EnableRouteLIFOMetrics, "enable-route-lifo-metrics", false, "enable metrics for the individual route LIFO queues")
+	flag.BoolVar(&cfg.EnableRouteFIFOMetrics, "enable-route-fifo-metrics", false, "enable metrics for the individual route FIFO queues")
 	flag.Var(cfg.MetricsFlavour, "metrics-flavour", "Metrics flavour is used to change the exposed metrics format. Supported metric formats: 'codahale' and 'prometheus', you can select both of them")
 	flag.Var(cfg.FilterPlugins, "filter-plugin", "set a custom filter plugins to load, a comma separated list of name and arguments")
 	flag.Var(cfg.PredicatePlugins, "predicate-plugin", "set a custom predicate plugins to load, a comma separated list of name and arguments")
@@ -422,6 +470,9 @@ func NewConfig() *Config {
 	flag.BoolVar(&cfg.KubernetesHTTPSRedirect, "kubernetes-https-redirect", true, "automatic HTTP->HTTPS redirect route; valid only with kubernetes")
 	flag.IntVar(&cfg.KubernetesHTTPSRedirectCode, "kubernetes-https-redirect-code", 308, "overrides the default redirect code (308) when used together with -kubernetes-https-redirect")
 	flag.BoolVar(&cfg.KubernetesIngressV1, "kubernetes-ingress-v1", false, "enable kubernetes ingress version v1, defaults to version v1beta1")
+	flag.BoolVar(&cfg.KubernetesEnableEndpointSlices, "enable-kubernetes-endpointslices", false, "read the kubernetes EndpointSlices instead of the Endpoints, requires permission to list the endpointslices")
+	flag.BoolVar(&cfg.KubernetesWatch, "enable-kubernetes-watch", false, "watch the changes of the kubernetes resources instead of listing them on every poll")
+	flag.BoolVar(&cfg.KubernetesTopologyZones, "kubernetes-topology-zones", false, "sets the availability zones of the endpoints from the topology labels of the nodes, requires permission to list the nodes")
 	flag.StringVar(&cfg.KubernetesIngressClass, "kubernetes-ingress-class", "", "ingress class regular expression used to filter ingress resources for kubernetes")
 	flag.StringVar(&cfg.KubernetesRouteGroupClass, "kubernetes-routegroup-class", "", "route group class regular expression used to filter route group resources for kubernetes")
 	flag.StringVar(&cfg.WhitelistedHealthCheckCIDR, "whitelisted-healthcheck-cidr", "", "sets the iprange/CIDRS to be whitelisted during healthcheck")
@@ -454,11 +505,18 @@ func NewConfig() *Config {
 	flag.StringVar(&cfg.Oauth2CallbackPath, "oauth2-callback-path", "", "sets the path where the OAuth2 callback requests with the authorization code should be redirected to")
 	flag.DurationVar(&cfg.Oauth2TokeninfoTimeout, "oauth2-tokeninfo-timeout", 2*time.Second, "sets the default tokeninfo request timeout duration to 2000ms")
 	flag.DurationVar(&cfg.Oauth2TokenintrospectionTimeout, "oauth2-tokenintrospect-timeout", 2*time.Second, "sets the default tokenintrospection request timeout duration to 2000ms")
+	flag.IntVar(&cfg.Oauth2TokeninfoCacheSize, "oauth2-tokeninfo-cache-size", 0, "when greater than zero, enables caching the tokeninfo responses across requests, and sets the maximum number of the cached tokens")
+	flag.DurationVar(&cfg.Oauth2TokeninfoCacheTTL, "oauth2-tokeninfo-cache-ttl", time.Minute, "sets the maximum time to cache a tokeninfo response, when the token doesn't expire earlier")
+	flag.IntVar(&cfg.Oauth2TokenintrospectionCacheSize, "oauth2-tokenintrospect-cache-size", 0, "when greater than zero, enables caching the tokenintrospection responses across requests, and sets the maximum number of the cached tokens per issuer")
+	flag.DurationVar(&cfg.Oauth2TokenintrospectionCacheTTL, "oauth2-tokenintrospect-cache-ttl", time.Minute, "sets the maximum time to cache a tokenintrospection response, when the token doesn't expire earlier")
 	flag.Var(&cfg.Oauth2AuthURLParameters, "oauth2-auth-url-parameters", "sets additional parameters to send when calling the OAuth2 authorize or token endpoints as key-value pairs")
 	flag.StringVar(&cfg.Oauth2AccessTokenHeaderName, "oauth2-access-token-header-name", "", "sets the access token to a header on the request with this name")
 	flag.StringVar(&cfg.Oauth2TokeninfoSubjectKey, "oauth2-tokeninfo-subject-key", "uid", "sets the access token to a header on the request with this name")
 	flag.StringVar(&cfg.Oauth2TokenCookieName, "oauth2-token-cookie-name", "oauth2-grant", "sets the name of the cookie where the encrypted token is stored")
 	flag.DurationVar(&cfg.WebhookTimeout, "webhook-timeout", 2*time.Second, "sets the webhook request timeout duration")
+	flag.DurationVar(&cfg.PolicyBundleReloadInterval, "policy-bundle-reload-interval", time.Minute, "sets how often the policy bundles of the policyAuthorize filters are reloaded")
+	flag.StringVar(&cfg.PolicyBundleDir, "policy-bundle-dir", "", "directory of the policy bundle files that the policyAuthorize filters can load, without it no files are allowed")
+	flag.Var(cfg.PolicyBundleURLPrefixes, "policy-bundle-url-prefixes", "comma separated list of the URLs, under which the policyAuthorize filters can load the policy bundles from, without it no URLs are allowed")
 	flag.StringVar(&cfg.OidcSecretsFile, "oidc-secrets-file", "", "file storing the encryption key of the OID Connect token")
 	flag.DurationVar(&cfg.OidcDistributedClaimsTimeout, "oidc-distributed-claims-timeout", 2*time.Second, "sets the default OIDC distributed claims request timeout duration to 2000ms")
 	flag.Var(cfg.CredentialPaths, "credentials-paths", "directories or files to watch for credentials to use by bearerinjector filter")
@@ -524,6 +582,9 @@ func NewConfig() *Config {
 	flag.StringVar(&cfg.SwarmStaticOther, "swarm-static-other", "", "set static swarm all nodes, for example 127.0.0.1:9002,127.0.0.1:9003")
 
 	flag.IntVar(&cfg.ClusterRatelimitMaxGroupShards, "cluster-ratelimit-max-group-shards", 1, "sets the maximum number of group shards for the clusterRatelimit filter")
+	flag.BoolVar(&cfg.RatelimitQuotaHeaders, "ratelimit-quota-headers", false, "enables the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers of the ratelimit filters")
+	flag.StringVar(&cfg.RatelimitPlansFile, "ratelimit-plans-file", "", "path of a YAML file with the usage plans of the consumers, enables the usagePlanRatelimit filter")
+	flag.DurationVar(&cfg.RatelimitPlansUpdateInterval, "ratelimit-plans-update-interval", time.Minute, "sets how often the usage plans are reloaded from the file")
 
 	return cfg
 }
@@ -580,10 +641,16 @@ func (c *Config) Parse() error {
 		return err
 	}
 
+	clientAuthTLS, err := parseClientAuthTLS(c.ClientAuthTLSString)
+	if err != nil {
+		return err
+	}
+
 	c.ApplicationLogLevel = logLevel
 	c.KubernetesPathMode = kubernetesPathMode
 	c.KubernetesEastWestRangePredicates = kubernetesEastWestRangePredicates
 	c.HistogramMetricBuckets = histogramBuckets
+	c.ClientAuthTLS = clientAuthTLS
 
 	if c.ClientKeyFile != "" && c.ClientCertFile != "" {
 		certsFiles := strings.Split(c.ClientCertFile, ",")
@@ -635,6 +702,8 @@ func (c *Config) ToRouteSrvOptions() routesrv.Options {
 		KubernetesHTTPSRedirect:            c.KubernetesHTTPSRedirect,
 		KubernetesHTTPSRedirectCode:        c.KubernetesHTTPSRedirectCode,
 		KubernetesIngressV1:                c.KubernetesIngressV1,
+		KubernetesEnableEndpointSlices:     c.KubernetesEnableEndpointSlices,
+		KubernetesWatch:                    c.KubernetesWatch,
 		KubernetesIngressClass:             c.KubernetesIngressClass,
 		KubernetesRouteGroupClass:          c.KubernetesRouteGroupClass,
 		KubernetesPathMode:                 c.KubernetesPathMode,
@@ -679,16 +748,22 @@ func (c *Config) ToOptions() skipper.Options {
 		DebugListener:                   c.DebugListener,
 		CertPathTLS:                     c.CertPathTLS,
 		KeyPathTLS:                      c.KeyPathTLS,
+		ClientCAPathTLS:                 c.ClientCAPathTLS,
+		ClientAuthTLS:                   c.ClientAuthTLS,
 		MaxLoopbacks:                    c.MaxLoopbacks,
 		DefaultHTTPStatus:               c.DefaultHTTPStatus,
 		LoadBalancerHealthCheckInterval: c.LoadBalancerHealthCheckInterval,
+		EnableOutlierDetection:          c.EnableOutlierDetection,
 		ReverseSourcePredicate:          c.ReverseSourcePredicate,
 		MaxAuditBody:                    c.MaxAuditBody,
 		EnableBreakers:                  c.EnableBreakers,
 		BreakerSettings:                 c.Breakers,
+		EnableSharedBreakers:            c.EnableSharedBreakers,
+		EnableBreakersOverride:          c.EnableBreakersOverride,
 		EnableRatelimiters:              c.EnableRatelimiters,
 		RatelimitSettings:               c.Ratelimits,
 		EnableRouteLIFOMetrics:          c.EnableRouteLIFOMetrics,
+		EnableRouteFIFOMetrics:          c.EnableRouteFIFOMetrics,
 		MetricsFlavours:                 c.MetricsFlavour.values,
 		FilterPlugins:                   c.FilterPlugins.values,
 		PredicatePlugins:                c.PredicatePlugins.values,
@@ -770,6 +845,9 @@ func (c *Config) ToOptions() skipper.Options {
 		KubernetesHTTPSRedirect:            c.KubernetesHTTPSRedirect,
 		KubernetesHTTPSRedirectCode:        c.KubernetesHTTPSRedirectCode,
 		KubernetesIngressV1:                c.KubernetesIngressV1,
+		KubernetesTopologyZones:            c.KubernetesTopologyZones,
+		KubernetesEnableEndpointSlices:     c.KubernetesEnableEndpointSlices,
+		KubernetesWatch:                    c.KubernetesWatch,
 		KubernetesIngressClass:             c.KubernetesIngressClass,
 		KubernetesRouteGroupClass:          c.KubernetesRouteGroupClass,
 		WhitelistedHealthCheckCIDR:         whitelistCIDRS,
@@ -795,31 +873,38 @@ func (c *Config) ToOptions() skipper.Options {
 		DefaultFiltersDir: c.DefaultFiltersDir,
 
 		// Auth:
-		EnableOAuth2GrantFlow:          c.EnableOAuth2GrantFlow,
-		OAuthUrl:                       c.OauthURL,
-		OAuthScope:                     c.OauthScope,
-		OAuthCredentialsDir:            c.OauthCredentialsDir,
-		OAuth2AuthURL:                  c.Oauth2AuthURL,
-		OAuth2TokenURL:                 c.Oauth2TokenURL,
-		OAuth2RevokeTokenURL:           c.Oauth2RevokeTokenURL,
-		OAuthTokeninfoURL:              c.Oauth2TokeninfoURL,
-		OAuthTokeninfoTimeout:          c.Oauth2TokeninfoTimeout,
-		OAuth2SecretFile:               c.Oauth2SecretFile,
-		OAuth2ClientID:                 c.Oauth2ClientID,
-		OAuth2ClientSecret:             c.Oauth2ClientSecret,
-		OAuth2ClientIDFile:             c.Oauth2ClientIDFile,
-		OAuth2ClientSecretFile:         c.Oauth2ClientSecretFile,
-		OAuth2CallbackPath:             c.Oauth2CallbackPath,
-		OAuthTokenintrospectionTimeout: c.Oauth2TokenintrospectionTimeout,
-		OAuth2AuthURLParameters:        c.Oauth2AuthURLParameters.values,
-		OAuth2AccessTokenHeaderName:    c.Oauth2AccessTokenHeaderName,
-		OAuth2TokeninfoSubjectKey:      c.Oauth2TokeninfoSubjectKey,
-		OAuth2TokenCookieName:          c.Oauth2TokenCookieName,
-		WebhookTimeout:                 c.WebhookTimeout,
-		OIDCSecretsFile:                c.OidcSecretsFile,
-		OIDCDistributedClaimsTimeout:   c.OidcDistributedClaimsTimeout,
-		CredentialsPaths:               c.CredentialPaths.values,
-		CredentialsUpdateInterval:      c.CredentialsUpdateInterval,
+		EnableOAuth2GrantFlow:            c.EnableOAuth2GrantFlow,
+		OAuthUrl:                         c.OauthURL,
+		OAuthScope:                       c.OauthScope,
+		OAuthCredentialsDir:              c.OauthCredentialsDir,
+		OAuth2AuthURL:                    c.Oauth2AuthURL,
+		OAuth2TokenURL:                   c.Oauth2TokenURL,
+		OAuth2RevokeTokenURL:             c.Oauth2RevokeTokenURL,
+		OAuthTokeninfoURL:                c.Oauth2TokeninfoURL,
+		OAuthTokeninfoTimeout:            c.Oauth2TokeninfoTimeout,
+		OAuthTokeninfoCacheSize:          c.Oauth2TokeninfoCacheSize,
+		OAuthTokeninfoCacheTTL:           c.Oauth2TokeninfoCacheTTL,
+		OAuth2SecretFile:                 c.Oauth2SecretFile,
+		OAuth2ClientID:                   c.Oauth2ClientID,
+		OAuth2ClientSecret:               c.Oauth2ClientSecret,
+		OAuth2ClientIDFile:               c.Oauth2ClientIDFile,
+		OAuth2ClientSecretFile:           c.Oauth2ClientSecretFile,
+		OAuth2CallbackPath:               c.Oauth2CallbackPath,
+		OAuthTokenintrospectionTimeout:   c.Oauth2TokenintrospectionTimeout,
+		OAuthTokenintrospectionCacheSize: c.Oauth2TokenintrospectionCacheSize,
+		OAuthTokenintrospectionCacheTTL:  c.Oauth2TokenintrospectionCacheTTL,
+		OAuth2AuthURLParameters:          c.Oauth2AuthURLParameters.values,
+		OAuth2AccessTokenHeaderName:      c.Oauth2AccessTokenHeaderName,
+		OAuth2TokeninfoSubjectKey:        c.Oauth2TokeninfoSubjectKey,
+		OAuth2TokenCookieName:            c.Oauth2TokenCookieName,
+		WebhookTimeout:                   c.WebhookTimeout,
+		PolicyBundleReloadInterval:       c.PolicyBundleReloadInterval,
+		PolicyBundleDir:                  c.PolicyBundleDir,
+		PolicyBundleURLPrefixes:          c.PolicyBundleURLPrefixes.values,
+		OIDCSecretsFile:                  c.OidcSecretsFile,
+		OIDCDistributedClaimsTimeout:     c.OidcDistributedClaimsTimeout,
+		CredentialsPaths:                 c.CredentialPaths.values,
+		CredentialsUpdateInterval:        c.CredentialsUpdateInterval,
 
 		// connections, timeouts:
 		WaitForHealthcheckInterval:   c.WaitForHealthcheckInterval,
@@ -868,12 +953,35 @@ func (c *Config) ToOptions() skipper.Options {
 		SwarmStaticOther: c.SwarmStaticOther,
 
 		ClusterRatelimitMaxGroupShards: c.ClusterRatelimitMaxGroupShards,
+		RatelimitQuotaHeaders:          c.RatelimitQuotaHeaders,
+		RatelimitPlansFile:             c.RatelimitPlansFile,
+		RatelimitPlansUpdateInterval:   c.RatelimitPlansUpdateInterval,
 	}
 
 	if c.PluginDir != "" {
 		options.PluginDirs = append(options.PluginDirs, c.PluginDir)
 	}
 
+	if c.EnableOutlierDetection {
+		options.OutlierDetection = loadbalancer.OutlierDetectionOptions{
+			ConsecutiveFailures: c.OutlierConsecutiveFailures,
+			LatencyThreshold:    c.OutlierLatencyThreshold,
+			BaseEjectionTime:    c.OutlierBaseEjectionTime,
+			MaxEjectionTime:     c.OutlierMaxEjectionTime,
+			MaxEjectionPercent:  c.OutlierMaxEjectionPercent,
+			FadeInDuration:      c.OutlierFadeInDuration,
+		}
+	}
+
+	if c.LoadBalancerZone != "" {
+		options.EnableZoneAwareLoadBalancing = true
+		options.ZoneAwareLoadBalancing = loadbalancer.ZoneAwareOptions{
+			Zone:              c.LoadBalancerZone,
+			MinEndpoints:      c.ZoneMinEndpoints,
+			MinHealthyPercent: c.ZoneMinHealthyPercent,
+		}
+	}
+
 	if c.Insecure {
 		options.ProxyFlags |= proxy.Insecure
 	}
@@ -969,6 +1077,30 @@ func (c *Config) getMinTLSVersion() uint16 {
 	return tlsVersionTable[defaultMinTLSVersion]
 }
 
+// parseClientAuthTLS returns nil for the empty string, to apply the
+// default policy depending on the client CA.
+func parseClientAuthTLS(s string) (*tls.ClientAuthType, error) {
+	var a tls.ClientAuthType
+	switch s {
+	case "":
+		return nil, nil
+	case "none":
+		a = tls.NoClientCert
+	case "request":
+		a = tls.RequestClientCert
+	case "require-any":
+		a = tls.RequireAnyClientCert
+	case "verify-if-given":
+		a = tls.VerifyClientCertIfGiven
+	case "require-and-verify":
+		a = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid tls-client-auth: %s", s)
+	}
+
+	return &a, nil
+}
+
 func (c *Config) parseHistogramBuckets() ([]float64, error) {
 	if c.HistogramMetricBucketsString == "" {
 		return prometheus.DefBuckets, nil
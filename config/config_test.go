@@ -124,9 +124,13 @@ func Test_NewConfig(t *testing.T) {
 				KubernetesRedisServicePort:              6379,
 				Oauth2TokeninfoTimeout:                  2 * time.Second,
 				Oauth2TokenintrospectionTimeout:         2 * time.Second,
+				Oauth2TokeninfoCacheTTL:                 time.Minute,
+				Oauth2TokenintrospectionCacheTTL:        time.Minute,
 				Oauth2TokeninfoSubjectKey:               "uid",
 				Oauth2TokenCookieName:                   "oauth2-grant",
 				WebhookTimeout:                          2 * time.Second,
+				PolicyBundleReloadInterval:              time.Minute,
+				PolicyBundleURLPrefixes:                 commaListFlag(),
 				OidcDistributedClaimsTimeout:            2 * time.Second,
 				CredentialPaths:                         commaListFlag(),
 				CredentialsUpdateInterval:               10 * time.Minute,
@@ -168,6 +172,7 @@ func Test_NewConfig(t *testing.T) {
 				ForwardedHeadersList:                    commaListFlag(),
 				ForwardedHeadersExcludeCIDRList:         commaListFlag(),
 				ClusterRatelimitMaxGroupShards:          1,
+				RatelimitPlansUpdateInterval:            time.Minute,
 				RefusePayload:                           multiFlag{"foo", "bar", "baz"},
 				ValidateQuery:                           true,
 				ValidateQueryLog:                        true,
@@ -246,6 +251,30 @@ func TestMinTLSVersion(t *testing.T) {
 	})
 }
 
+func TestParseClientAuthTLS(t *testing.T) {
+	a, err := parseClientAuthTLS("")
+	if err != nil || a != nil {
+		t.Errorf("expected no client auth policy for the default, got: %v, %v", a, err)
+	}
+
+	for s, expected := range map[string]tls.ClientAuthType{
+		"none":               tls.NoClientCert,
+		"request":            tls.RequestClientCert,
+		"require-any":        tls.RequireAnyClientCert,
+		"verify-if-given":    tls.VerifyClientCertIfGiven,
+		"require-and-verify": tls.RequireAndVerifyClientCert,
+	} {
+		a, err := parseClientAuthTLS(s)
+		if err != nil || a == nil || *a != expected {
+			t.Errorf("failed to parse %s, got: %v, %v", s, a, err)
+		}
+	}
+
+	if _, err := parseClientAuthTLS("always"); err == nil {
+		t.Error("expected error for an invalid client auth policy")
+	}
+}
+
 type testFormatter struct {
 	messages map[string]log.Level
 }
@@ -13,17 +13,34 @@ import (
 
 const ratelimitsUsage = `set global rate limit settings, e.g. -ratelimits type=client,max-hits=20,time-window=60s
 	possible ratelimit properties:
-	type: client/service/clusterClient/clusterService/disabled (defaults to disabled)
+	type: client/service/clusterClient/clusterService/tokenBucket/clusterTokenBucket/disabled (defaults to disabled)
 	max-hits: the number of hits a ratelimiter can get
 	time-window: the duration of the sliding window for the rate limiter
 	group: defines the ratelimit group, which can be the same for different routes.
+	burst: the capacity of the token bucket ratelimiters, defaults to max-hits
 	(see also: https://godoc.org/github.com/zalando/skipper/ratelimit)`
 
 const enableRatelimitsUsage = `enable ratelimits`
 
 type ratelimitFlags []ratelimit.Settings
 
-var errInvalidRatelimitConfig = errors.New("invalid ratelimit config (allowed values are: client, service or disabled)")
+var errInvalidRatelimitConfig = errors.New("invalid ratelimit config (allowed values are: client, service, clusterClient, clusterService, tokenBucket, clusterTokenBucket or disabled)")
+
+var errInvalidTokenBucketConfig = errors.New("invalid token bucket ratelimit config (max-hits and time-window need to be positive, burst can't be negative, and time-window needs to be at least max-hits nanoseconds)")
+
+// validateTokenBucket checks the settings of the token bucket ratelimits,
+// that the ratelimit package would not be able to apply.
+func validateTokenBucket(s ratelimit.Settings) error {
+	if s.Type != ratelimit.TokenBucketRatelimit && s.Type != ratelimit.ClusterTokenBucketRatelimit {
+		return nil
+	}
+
+	if s.MaxHits <= 0 || s.TimeWindow <= 0 || s.Burst < 0 || s.TimeWindow < time.Duration(s.MaxHits) {
+		return errInvalidTokenBucketConfig
+	}
+
+	return nil
+}
 
 func (r ratelimitFlags) String() string {
 	s := make([]string, len(r))
@@ -58,6 +75,10 @@ func (r *ratelimitFlags) Set(value string) error {
 				s.Type = ratelimit.ClusterClientRatelimit
 			case "clusterService":
 				s.Type = ratelimit.ClusterServiceRatelimit
+			case "tokenBucket":
+				s.Type = ratelimit.TokenBucketRatelimit
+			case "clusterTokenBucket":
+				s.Type = ratelimit.ClusterTokenBucketRatelimit
 			case "disabled":
 				s.Type = ratelimit.DisableRatelimit
 			default:
@@ -78,6 +99,12 @@ func (r *ratelimitFlags) Set(value string) error {
 			s.CleanInterval = d * 10
 		case "group":
 			s.Group = v
+		case "burst":
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			s.Burst = i
 		default:
 			return errInvalidRatelimitConfig
 		}
@@ -87,6 +114,10 @@ func (r *ratelimitFlags) Set(value string) error {
 		s.Type = ratelimit.DisableRatelimit
 	}
 
+	if err := validateTokenBucket(s); err != nil {
+		return err
+	}
+
 	*r = append(*r, s)
 	return nil
 }
@@ -97,6 +128,10 @@ func (r *ratelimitFlags) UnmarshalYAML(unmarshal func(interface{}) error) error
 		return err
 	}
 
+	if err := validateTokenBucket(rateLimitSettings); err != nil {
+		return err
+	}
+
 	rateLimitSettings.CleanInterval = rateLimitSettings.TimeWindow * 10
 
 	*r = append(*r, rateLimitSettings)
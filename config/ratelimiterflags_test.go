@@ -104,11 +104,60 @@ func Test_ratelimitFlags_Set(t *testing.T) {
 				CleanInterval: 2 * time.Minute * 10,
 			},
 		},
+		{
+			name:    "test tokenBucket ratelimit",
+			args:    "type=tokenBucket,max-hits=50,time-window=2m,burst=100",
+			wantErr: false,
+			want: ratelimit.Settings{
+				Type:          ratelimit.TokenBucketRatelimit,
+				MaxHits:       50,
+				TimeWindow:    2 * time.Minute,
+				Burst:         100,
+				CleanInterval: 2 * time.Minute * 10,
+			},
+		},
+		{
+			name:    "test clusterTokenBucket ratelimit",
+			args:    "type=clusterTokenBucket,max-hits=50,time-window=2m,group=foo",
+			wantErr: false,
+			want: ratelimit.Settings{
+				Type:          ratelimit.ClusterTokenBucketRatelimit,
+				MaxHits:       50,
+				TimeWindow:    2 * time.Minute,
+				Group:         "foo",
+				CleanInterval: 2 * time.Minute * 10,
+			},
+		},
+		{
+			name:    "test invalid burst",
+			args:    "type=tokenBucket,max-hits=50,time-window=2m,burst=foo",
+			wantErr: true,
+		},
 		{
 			name:    "test invalid type",
 			args:    "type=invalid,max-hits=50,time-window=2m",
 			wantErr: true,
 		},
+		{
+			name:    "test tokenBucket without max-hits",
+			args:    "type=tokenBucket,time-window=1s",
+			wantErr: true,
+		},
+		{
+			name:    "test clusterTokenBucket without time-window",
+			args:    "type=clusterTokenBucket,max-hits=10,group=foo",
+			wantErr: true,
+		},
+		{
+			name:    "test tokenBucket with negative burst",
+			args:    "type=tokenBucket,max-hits=10,time-window=1s,burst=-1",
+			wantErr: true,
+		},
+		{
+			name:    "test tokenBucket with too short time-window",
+			args:    "type=tokenBucket,max-hits=10,time-window=5ns",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -184,6 +233,12 @@ time-window: 2m`,
 			name: "test invalid type",
 			yml: `type: invalid
 max-hits: 50
+time-window: 2m`,
+			wantErr: true,
+		},
+		{
+			name: "test tokenBucket without max-hits",
+			yml: `type: tokenBucket
 time-window: 2m`,
 			wantErr: true,
 		},
@@ -13,6 +13,7 @@ import (
 	"os"
 	"regexp"
 	"sort"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -32,28 +33,38 @@ const (
 	routeGroupClassKey         = "zalando.org/routegroup.class"
 	ServicesClusterURI         = "/api/v1/services"
 	EndpointsClusterURI        = "/api/v1/endpoints"
+	EndpointSlicesClusterURI   = "/apis/discovery.k8s.io/v1/endpointslices"
 	SecretsClusterURI          = "/api/v1/secrets"
+	NodesClusterURI            = "/api/v1/nodes"
 	defaultKubernetesURL       = "http://localhost:8001"
 	IngressesNamespaceFmt      = "/apis/extensions/v1beta1/namespaces/%s/ingresses"
 	IngressesV1NamespaceFmt    = "/apis/networking.k8s.io/v1/namespaces/%s/ingresses"
 	routeGroupsNamespaceFmt    = "/apis/zalando.org/v1/namespaces/%s/routegroups"
 	ServicesNamespaceFmt       = "/api/v1/namespaces/%s/services"
 	EndpointsNamespaceFmt      = "/api/v1/namespaces/%s/endpoints"
+	EndpointSlicesNamespaceFmt = "/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices"
 	SecretsNamespaceFmt        = "/api/v1/namespaces/%s/secrets"
 	serviceAccountDir          = "/var/run/secrets/kubernetes.io/serviceaccount/"
 	serviceAccountTokenKey     = "token"
 	serviceAccountRootCAKey    = "ca.crt"
+	topologyZoneLabel          = "topology.kubernetes.io/zone"
+	legacyTopologyZoneLabel    = "failure-domain.beta.kubernetes.io/zone"
 )
 
 const RouteGroupsNotInstalledMessage = `RouteGroups CRD is not installed in the cluster.
 See: https://opensource.zalando.com/skipper/kubernetes/routegroups/#installation`
 
 type clusterClient struct {
+	// accessed atomically, keep it first for the alignment
+	watchGeneration uint64
+
 	ingressesURI        string
 	routeGroupsURI      string
 	servicesURI         string
 	endpointsURI        string
+	endpointSlicesURI   string
 	secretsURI          string
+	nodesURI            string
 	tokenProvider       secrets.SecretsProvider
 	apiURL              string
 	certificateRegistry *certregistry.CertRegistry
@@ -62,6 +73,17 @@ type clusterClient struct {
 	ingressClass    *regexp.Regexp
 	httpClient      *http.Client
 	ingressV1       bool
+	topologyZones   bool
+	endpointSlices  bool
+
+	watch           bool
+	watchTimeout    time.Duration
+	watchRetryDelay time.Duration
+	watchers        map[string]*resourceWatcher
+	watchersMx      sync.Mutex
+	watchChanges    map[string]bool
+	watchChangesMx  sync.Mutex
+	quit            <-chan struct{}
 
 	loggedMissingRouteGroups bool
 }
@@ -147,12 +169,22 @@ func newClusterClient(o Options, apiURL, ingCls, rgCls string, quit <-chan struc
 		routeGroupsURI:      routeGroupsClusterURI,
 		servicesURI:         ServicesClusterURI,
 		endpointsURI:        EndpointsClusterURI,
+		endpointSlicesURI:   EndpointSlicesClusterURI,
 		secretsURI:          SecretsClusterURI,
+		nodesURI:            NodesClusterURI,
 		ingressClass:        ingClsRx,
 		routeGroupClass:     rgClsRx,
 		httpClient:          httpClient,
 		apiURL:              apiURL,
 		certificateRegistry: o.CertificateRegistry,
+		topologyZones:       o.TopologyZones,
+		endpointSlices:      o.KubernetesEnableEndpointSlices,
+		watch:               o.KubernetesWatch,
+		watchTimeout:        defaultWatchTimeout,
+		watchRetryDelay:     defaultWatchRetryDelay,
+		watchers:            make(map[string]*resourceWatcher),
+		watchChanges:        make(map[string]bool),
+		quit:                quit,
 	}
 
 	if o.KubernetesInCluster {
@@ -186,6 +218,7 @@ func (c *clusterClient) setNamespace(namespace string) {
 	c.routeGroupsURI = fmt.Sprintf(routeGroupsNamespaceFmt, namespace)
 	c.servicesURI = fmt.Sprintf(ServicesNamespaceFmt, namespace)
 	c.endpointsURI = fmt.Sprintf(EndpointsNamespaceFmt, namespace)
+	c.endpointSlicesURI = fmt.Sprintf(EndpointSlicesNamespaceFmt, namespace)
 	c.secretsURI = fmt.Sprintf(SecretsNamespaceFmt, namespace)
 }
 
@@ -207,6 +240,15 @@ func (c *clusterClient) createRequest(uri string, body io.Reader) (*http.Request
 }
 
 func (c *clusterClient) getJSON(uri string, a interface{}) error {
+	// the resource discovery is not watched
+	if c.watch && uri != ZalandoResourcesClusterURI {
+		return c.getWatchedJSON(uri, a)
+	}
+
+	return c.requestJSON(uri, a)
+}
+
+func (c *clusterClient) requestJSON(uri string, a interface{}) error {
 	log.Debugf("making request to: %s", uri)
 
 	req, err := c.createRequest(uri, nil)
@@ -445,6 +487,62 @@ func (c *clusterClient) loadEndpoints() (map[definitions.ResourceID]*endpoint, e
 	return result, nil
 }
 
+// loadEndpointSlices loads the EndpointSlices, and merges them by
+// service into the same format as the legacy Endpoints. It also
+// returns the zones and the zone hints of the endpoint addresses.
+func (c *clusterClient) loadEndpointSlices() (map[definitions.ResourceID]*endpoint, map[string]string, map[string][]string, error) {
+	var slices endpointSliceList
+	if err := c.getJSON(c.endpointSlicesURI, &slices); err != nil {
+		log.Debugf("requesting all endpointslices failed: %v", err)
+		return nil, nil, nil, err
+	}
+
+	log.Debugf("all endpointslices received: %d", len(slices.Items))
+	endpoints, zones, zoneHints := endpointsFromSlices(slices.Items)
+	return endpoints, zones, zoneHints, nil
+}
+
+// loadNodeZones returns the availability zones of the nodes by their
+// name.
+func (c *clusterClient) loadNodeZones() (map[string]string, error) {
+	var nodes nodeList
+	if err := c.getJSON(c.nodesURI, &nodes); err != nil {
+		log.Debugf("requesting all nodes failed: %v", err)
+		return nil, err
+	}
+
+	log.Debugf("all nodes received: %d", len(nodes.Items))
+	result := make(map[string]string)
+	for _, n := range nodes.Items {
+		if n == nil || n.Metadata == nil {
+			continue
+		}
+
+		if z := n.zone(); z != "" {
+			result[n.Metadata.Name] = z
+		}
+	}
+
+	return result, nil
+}
+
+// endpointZones maps the IP addresses of the endpoints to the
+// availability zones of the nodes where they run.
+func endpointZones(endpoints map[definitions.ResourceID]*endpoint, nodeZones map[string]string) map[string]string {
+	zones := make(map[string]string)
+	for _, ep := range endpoints {
+		for _, s := range ep.Subsets {
+			for _, a := range s.Addresses {
+				if z, ok := nodeZones[a.Node]; ok {
+					zones[a.IP] = z
+				}
+			}
+		}
+	}
+
+	return zones
+}
+
 func (c *clusterClient) logMissingRouteGroupsOnce() {
 	if c.loggedMissingRouteGroups {
 		return
@@ -487,7 +585,18 @@ func (c *clusterClient) fetchClusterState() (*clusterState, error) {
 		return nil, err
 	}
 
-	endpoints, err := c.loadEndpoints()
+	var (
+		endpoints map[definitions.ResourceID]*endpoint
+		zones     map[string]string
+		zoneHints map[string][]string
+	)
+
+	if c.endpointSlices {
+		endpoints, zones, zoneHints, err = c.loadEndpointSlices()
+	} else {
+		endpoints, err = c.loadEndpoints()
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -499,6 +608,24 @@ func (c *clusterClient) fetchClusterState() (*clusterState, error) {
 		}
 	}
 
+	if c.topologyZones {
+		nodeZones, err := c.loadNodeZones()
+		if err != nil {
+			return nil, err
+		}
+
+		// the zones set in the EndpointSlices take precedence
+		for ip, z := range endpointZones(endpoints, nodeZones) {
+			if _, ok := zones[ip]; !ok {
+				if zones == nil {
+					zones = make(map[string]string)
+				}
+
+				zones[ip] = z
+			}
+		}
+	}
+
 	return &clusterState{
 		ingresses:       ingresses,
 		ingressesV1:     ingressesV1,
@@ -506,6 +633,8 @@ func (c *clusterClient) fetchClusterState() (*clusterState, error) {
 		services:        services,
 		endpoints:       endpoints,
 		secrets:         secrets,
+		zones:           zones,
+		zoneHints:       zoneHints,
 		cachedEndpoints: make(map[endpointID][]string),
 	}, nil
 }
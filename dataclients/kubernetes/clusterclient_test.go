@@ -11,6 +11,7 @@ import (
 
 	"github.com/zalando/skipper/dataclients/kubernetes"
 	"github.com/zalando/skipper/dataclients/kubernetes/kubernetestest"
+	"github.com/zalando/skipper/eskip"
 )
 
 func containsCount(s, substr string, count int) bool {
@@ -251,3 +252,209 @@ spec:
 		})
 	}
 }
+
+const topologyZonesSpec = `apiVersion: zalando.org/v1
+kind: RouteGroup
+metadata:
+  name: myapp
+spec:
+  hosts:
+  - example.org
+  backends:
+  - name: app
+    type: service
+    serviceName: myapp
+    servicePort: 80
+  defaultBackends:
+  - backendName: app
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: myapp
+spec:
+  ports:
+  - port: 80
+    protocol: TCP
+    targetPort: 80
+  type: ClusterIP
+---
+apiVersion: v1
+kind: Endpoints
+metadata:
+  name: myapp
+subsets:
+- addresses:
+  - ip: 10.2.4.8
+    nodeName: node1
+  - ip: 10.2.4.16
+    nodeName: node2
+  - ip: 10.2.4.32
+    nodeName: node3
+  ports:
+  - port: 80
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: node1
+  labels:
+    topology.kubernetes.io/zone: eu-central-1a
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: node2
+  labels:
+    failure-domain.beta.kubernetes.io/zone: eu-central-1b
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: node3
+`
+
+func TestTopologyZones(t *testing.T) {
+	for _, tt := range []struct {
+		msg           string
+		topologyZones bool
+		expected      []string
+	}{{
+		msg: "disabled",
+	}, {
+		msg:           "enabled",
+		topologyZones: true,
+		expected:      []string{"eu-central-1b", "", "eu-central-1a"},
+	}} {
+		t.Run(tt.msg, func(t *testing.T) {
+			a, err := kubernetestest.NewAPI(kubernetestest.TestAPIOptions{}, strings.NewReader(topologyZonesSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := httptest.NewServer(a)
+			defer s.Close()
+
+			c, err := kubernetes.New(kubernetes.Options{KubernetesURL: s.URL, TopologyZones: tt.topologyZones})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			r, err := c.LoadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lb *eskip.Route
+			for _, ri := range r {
+				if ri.BackendType == eskip.LBBackend {
+					lb = ri
+				}
+			}
+
+			if lb == nil {
+				t.Fatal("load balanced route not found")
+			}
+
+			zones := eskip.Canonical(lb).LBEndpointZones
+			if len(zones) != len(tt.expected) {
+				t.Fatalf("expected zones: %v, got: %v", tt.expected, zones)
+			}
+
+			for i := range zones {
+				if zones[i] != tt.expected[i] {
+					t.Fatalf("expected zones: %v, got: %v", tt.expected, zones)
+				}
+			}
+		})
+	}
+}
+
+const endpointSliceZonesSpec = `apiVersion: zalando.org/v1
+kind: RouteGroup
+metadata:
+  name: myapp
+spec:
+  hosts:
+  - example.org
+  backends:
+  - name: app
+    type: service
+    serviceName: myapp
+    servicePort: 80
+  defaultBackends:
+  - backendName: app
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: myapp
+spec:
+  ports:
+  - port: 80
+    protocol: TCP
+    targetPort: 80
+  type: ClusterIP
+---
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: myapp-abc
+  labels:
+    kubernetes.io/service-name: myapp
+addressType: IPv4
+endpoints:
+- addresses:
+  - 10.2.4.8
+  zone: eu-central-1a
+- addresses:
+  - 10.2.4.16
+  zone: eu-central-1a
+  hints:
+    forZones:
+    - name: eu-central-1b
+- addresses:
+  - 10.2.4.32
+ports:
+- port: 80
+  protocol: TCP
+`
+
+func TestEndpointSliceZones(t *testing.T) {
+	a, err := kubernetestest.NewAPI(kubernetestest.TestAPIOptions{}, strings.NewReader(endpointSliceZonesSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := httptest.NewServer(a)
+	defer s.Close()
+
+	c, err := kubernetes.New(kubernetes.Options{KubernetesURL: s.URL, KubernetesEnableEndpointSlices: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 1 || r[0].BackendType != eskip.LBBackend {
+		t.Fatalf("failed to load the load balanced route: %v", r)
+	}
+
+	// the zone hints don't set the zones
+	expected := []string{"eu-central-1a", "", "eu-central-1a"}
+	zones := eskip.Canonical(r[0]).LBEndpointZones
+	if len(zones) != len(expected) {
+		t.Fatalf("expected zones: %v, got: %v", expected, zones)
+	}
+
+	for i := range zones {
+		if zones[i] != expected[i] {
+			t.Fatalf("expected zones: %v, got: %v", expected, zones)
+		}
+	}
+}
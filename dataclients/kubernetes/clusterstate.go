@@ -2,6 +2,7 @@ package kubernetes
 
 import (
 	"fmt"
+	"net/url"
 	"sort"
 
 	log "github.com/sirupsen/logrus"
@@ -16,6 +17,8 @@ type clusterState struct {
 	services        map[definitions.ResourceID]*service
 	endpoints       map[definitions.ResourceID]*endpoint
 	secrets         map[definitions.ResourceID]*secret
+	zones           map[string]string
+	zoneHints       map[string][]string
 	cachedEndpoints map[endpointID][]string
 }
 
@@ -85,3 +88,58 @@ func (state *clusterState) GetEndpointsByTarget(namespace, name, protocol string
 	state.cachedEndpoints[epID] = targets
 	return targets
 }
+
+// GetEndpointZones returns the availability zones of the endpoints, in
+// the same order, or nil when none of the zones are known.
+func (state *clusterState) GetEndpointZones(endpoints []string) []string {
+	if len(state.zones) == 0 {
+		return nil
+	}
+
+	var known bool
+	zones := make([]string, len(endpoints))
+	for i, ep := range endpoints {
+		u, err := url.Parse(ep)
+		if err != nil {
+			continue
+		}
+
+		zones[i] = state.zones[u.Hostname()]
+		known = known || zones[i] != ""
+	}
+
+	if !known {
+		return nil
+	}
+
+	return zones
+}
+
+// GetEndpointZoneHints returns the zones that the topology aware
+// routing hints the endpoints for, in the same order as the endpoints,
+// or nil when none of the endpoints have hints. Unlike the zones
+// returned by GetEndpointZones, the hints are not the location of the
+// endpoints.
+func (state *clusterState) GetEndpointZoneHints(endpoints []string) [][]string {
+	if len(state.zoneHints) == 0 {
+		return nil
+	}
+
+	var known bool
+	hints := make([][]string, len(endpoints))
+	for i, ep := range endpoints {
+		u, err := url.Parse(ep)
+		if err != nil {
+			continue
+		}
+
+		hints[i] = state.zoneHints[u.Hostname()]
+		known = known || len(hints[i]) > 0
+	}
+
+	if !known {
+		return nil
+	}
+
+	return hints
+}
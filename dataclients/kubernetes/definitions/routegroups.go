@@ -90,6 +90,11 @@ type SkipperBackend struct {
 	// Endpoints is required for Type lb
 	Endpoints []string
 
+	// EndpointWeights is optional for Type lb. When set, it defines
+	// the relative weight of each endpoint, in the same order as
+	// Endpoints.
+	EndpointWeights []float64
+
 	parseError error
 }
 
@@ -112,6 +117,9 @@ type skipperBackendParser struct {
 	// Endpoints is required for Type lb
 	Endpoints []string `json:"endpoints"`
 
+	// EndpointWeights is optional for Type lb
+	EndpointWeights []float64 `json:"endpointWeights"`
+
 	// ServiceName is required for Type service
 	ServiceName string `json:"serviceName"`
 
@@ -188,6 +196,28 @@ func missingEndpoints(backendName string) error {
 	return fmt.Errorf("missing LB endpoints in backend: %s", backendName)
 }
 
+func invalidEndpointWeights(backendName string) error {
+	return fmt.Errorf("invalid LB endpoint weights in backend: %s", backendName)
+}
+
+func validEndpointWeights(endpoints []string, weights []float64) bool {
+	if len(weights) == 0 {
+		return true
+	}
+
+	if len(weights) != len(endpoints) {
+		return false
+	}
+
+	for _, w := range weights {
+		if w <= 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
 func routeGroupError(m *Metadata, err error) error {
 	return fmt.Errorf("error in route group %s/%s: %w", namespaceString(m.Namespace), m.Name, err)
 }
@@ -235,6 +265,8 @@ func (sb *SkipperBackend) validate() error {
 		return invalidServicePort(sb.Name, sb.ServicePort)
 	case sb.Type == eskip.LBBackend && len(sb.Endpoints) == 0:
 		return missingEndpoints(sb.Name)
+	case sb.Type == eskip.LBBackend && !validEndpointWeights(sb.Endpoints, sb.EndpointWeights):
+		return invalidEndpointWeights(sb.Name)
 	}
 
 	return nil
@@ -280,6 +312,7 @@ func (sb *SkipperBackend) UnmarshalJSON(value []byte) error {
 	b.ServicePort = p.ServicePort
 	b.Algorithm = a
 	b.Endpoints = p.Endpoints
+	b.EndpointWeights = p.EndpointWeights
 	b.parseError = perr
 
 	*sb = b
@@ -0,0 +1,213 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zalando/skipper/dataclients/kubernetes/definitions"
+)
+
+const endpointSliceServiceNameLabel = "kubernetes.io/service-name"
+
+type endpointSliceMetadata struct {
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels"`
+}
+
+type endpointConditions struct {
+	// nil means ready, see the EndpointSlice API
+	Ready       *bool `json:"ready"`
+	Serving     *bool `json:"serving"`
+	Terminating *bool `json:"terminating"`
+}
+
+type forZone struct {
+	Name string `json:"name"`
+}
+
+type endpointHints struct {
+	ForZones []*forZone `json:"forZones"`
+}
+
+type endpointSliceEndpoint struct {
+	Addresses  []string            `json:"addresses"`
+	Conditions *endpointConditions `json:"conditions"`
+	NodeName   string              `json:"nodeName"`
+	Zone       string              `json:"zone"`
+	Hints      *endpointHints      `json:"hints"`
+}
+
+type endpointSlice struct {
+	Metadata    *endpointSliceMetadata   `json:"metadata"`
+	AddressType string                   `json:"addressType"`
+	Endpoints   []*endpointSliceEndpoint `json:"endpoints"`
+	Ports       []*port                  `json:"ports"`
+}
+
+type endpointSliceList struct {
+	Items []*endpointSlice `json:"items"`
+}
+
+func (c *endpointConditions) ready() bool {
+	return c == nil || c.Ready == nil || *c.Ready
+}
+
+// terminating endpoints that are still serving can receive traffic
+// while they are drained, when the service has no ready endpoints.
+func (c *endpointConditions) draining() bool {
+	return c != nil && c.Terminating != nil && *c.Terminating &&
+		(c.Serving == nil || *c.Serving)
+}
+
+// zoneHints returns the zones that the topology aware routing hints
+// the endpoint for. They are not the location of the endpoint.
+func (ep *endpointSliceEndpoint) zoneHints() []string {
+	if ep.Hints == nil {
+		return nil
+	}
+
+	var zones []string
+	for _, z := range ep.Hints.ForZones {
+		if z != nil && z.Name != "" {
+			zones = append(zones, z.Name)
+		}
+	}
+
+	return zones
+}
+
+func (s *endpointSlice) serviceID() (definitions.ResourceID, bool) {
+	if s.Metadata == nil {
+		return definitions.ResourceID{}, false
+	}
+
+	name := s.Metadata.Labels[endpointSliceServiceNameLabel]
+	if name == "" {
+		return definitions.ResourceID{}, false
+	}
+
+	return newResourceID(namespaceString(s.Metadata.Namespace), name), true
+}
+
+func (s *endpointSlice) supportedAddressType() bool {
+	// older API versions allowed IP, too
+	return s.AddressType == "" || s.AddressType == "IPv4" || s.AddressType == "IPv6" || s.AddressType == "IP"
+}
+
+func portsKey(ports []*port) string {
+	keys := make([]string, 0, len(ports))
+	for _, p := range ports {
+		if p != nil {
+			keys = append(keys, fmt.Sprintf("%s:%d:%s", p.Name, p.Port, p.Protocol))
+		}
+	}
+
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// mergedSubsets merges the endpoints of the slices of a single service
+// into subsets with the same ports. When the service has no ready
+// endpoints, the terminating ones that are still serving are used. As
+// long as the service has any ready endpoint, the terminating ones are
+// not used, even when they are still serving.
+func mergedSubsets(slices []*endpointSlice, draining bool) []*subset {
+	var (
+		subsets []*subset
+		byPorts = make(map[string]*subset)
+		seen    = make(map[string]bool)
+	)
+
+	for _, s := range slices {
+		key := portsKey(s.Ports)
+		for _, ep := range s.Endpoints {
+			if ep == nil {
+				continue
+			}
+
+			if draining && !ep.Conditions.draining() || !draining && !ep.Conditions.ready() {
+				continue
+			}
+
+			ss, ok := byPorts[key]
+			if !ok {
+				ss = &subset{Ports: s.Ports}
+				byPorts[key] = ss
+				subsets = append(subsets, ss)
+			}
+
+			for _, a := range ep.Addresses {
+				if seen[key+"/"+a] {
+					continue
+				}
+
+				seen[key+"/"+a] = true
+				ss.Addresses = append(ss.Addresses, &address{IP: a, Node: ep.NodeName})
+			}
+		}
+	}
+
+	return subsets
+}
+
+// endpointsFromSlices merges the EndpointSlices by service, and
+// converts them to the same format as the legacy Endpoints resources.
+// It also returns the zones and the zone hints of the endpoint
+// addresses.
+func endpointsFromSlices(slices []*endpointSlice) (map[definitions.ResourceID]*endpoint, map[string]string, map[string][]string) {
+	byService := make(map[definitions.ResourceID][]*endpointSlice)
+	for _, s := range slices {
+		if s == nil || !s.supportedAddressType() {
+			continue
+		}
+
+		id, ok := s.serviceID()
+		if !ok {
+			continue
+		}
+
+		byService[id] = append(byService[id], s)
+	}
+
+	endpoints := make(map[definitions.ResourceID]*endpoint)
+	zones := make(map[string]string)
+	zoneHints := make(map[string][]string)
+	for id, ss := range byService {
+		subsets := mergedSubsets(ss, false)
+		if len(subsets) == 0 {
+			subsets = mergedSubsets(ss, true)
+		}
+
+		endpoints[id] = &endpoint{
+			Meta: &definitions.Metadata{
+				Namespace: id.Namespace,
+				Name:      id.Name,
+			},
+			Subsets: subsets,
+		}
+
+		for _, s := range ss {
+			for _, ep := range s.Endpoints {
+				if ep == nil {
+					continue
+				}
+
+				if z := ep.Zone; z != "" {
+					for _, a := range ep.Addresses {
+						zones[a] = z
+					}
+				}
+
+				if h := ep.zoneHints(); len(h) > 0 {
+					for _, a := range ep.Addresses {
+						zoneHints[a] = h
+					}
+				}
+			}
+		}
+	}
+
+	return endpoints, zones, zoneHints
+}
@@ -0,0 +1,45 @@
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEndpointSliceZoneHints(t *testing.T) {
+	slices := []*endpointSlice{{
+		Metadata: &endpointSliceMetadata{
+			Namespace: "default",
+			Name:      "myapp-abc",
+			Labels:    map[string]string{endpointSliceServiceNameLabel: "myapp"},
+		},
+		AddressType: "IPv4",
+		Endpoints: []*endpointSliceEndpoint{{
+			Addresses: []string{"10.2.4.8"},
+			Zone:      "eu-central-1a",
+		}, {
+			Addresses: []string{"10.2.4.16"},
+			Zone:      "eu-central-1a",
+			Hints: &endpointHints{ForZones: []*forZone{
+				{Name: "eu-central-1b"},
+				{Name: "eu-central-1c"},
+			}},
+		}},
+		Ports: []*port{{Port: 80, Protocol: "TCP"}},
+	}}
+
+	_, zones, zoneHints := endpointsFromSlices(slices)
+	if zones["10.2.4.16"] != "eu-central-1a" {
+		t.Fatalf("expected the zone of the endpoint, got: %s", zones["10.2.4.16"])
+	}
+
+	state := &clusterState{zones: zones, zoneHints: zoneHints}
+	hints := state.GetEndpointZoneHints([]string{"http://10.2.4.8:80", "http://10.2.4.16:80"})
+	expected := [][]string{nil, {"eu-central-1b", "eu-central-1c"}}
+	if !reflect.DeepEqual(hints, expected) {
+		t.Fatalf("expected zone hints: %v, got: %v", expected, hints)
+	}
+
+	if hints := state.GetEndpointZoneHints([]string{"http://10.2.4.8:80"}); hints != nil {
+		t.Fatalf("expected no zone hints, got: %v", hints)
+	}
+}
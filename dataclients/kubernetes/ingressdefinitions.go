@@ -183,3 +183,30 @@ type secret struct {
 type secretList struct {
 	Items []*secret `json:"items"`
 }
+
+type nodeMetadata struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+}
+
+type node struct {
+	Metadata *nodeMetadata `json:"metadata"`
+}
+
+type nodeList struct {
+	Items []*node `json:"items"`
+}
+
+// zone returns the availability zone of the node from its topology
+// labels.
+func (n *node) zone() string {
+	if n.Metadata == nil {
+		return ""
+	}
+
+	if z, ok := n.Metadata.Labels[topologyZoneLabel]; ok {
+		return z
+	}
+
+	return n.Metadata.Labels[legacyTopologyZoneLabel]
+}
@@ -116,11 +116,12 @@ func convertPathRuleV1(
 	}
 
 	r := &eskip.Route{
-		Id:          routeID(ns, name, host, prule.Path, svcName),
-		BackendType: eskip.LBBackend,
-		LBEndpoints: eps,
-		LBAlgorithm: getLoadBalancerAlgorithm(metadata),
-		HostRegexps: hostRegexp,
+		Id:              routeID(ns, name, host, prule.Path, svcName),
+		BackendType:     eskip.LBBackend,
+		LBEndpoints:     eps,
+		LBEndpointZones: state.GetEndpointZones(eps),
+		LBAlgorithm:     getLoadBalancerAlgorithm(metadata),
+		HostRegexps:     hostRegexp,
 	}
 	setPathV1(pathMode, r, prule.PathType, prule.Path)
 	setTraffic(r, svcName, prule.Backend.Traffic, prule.Backend.NoopCount)
@@ -399,10 +400,11 @@ func (ing *ingress) convertDefaultBackendV1(
 	}
 
 	return &eskip.Route{
-		Id:          routeID(ns, name, "", "", ""),
-		BackendType: eskip.LBBackend,
-		LBEndpoints: eps,
-		LBAlgorithm: getLoadBalancerAlgorithm(i.Metadata),
+		Id:              routeID(ns, name, "", "", ""),
+		BackendType:     eskip.LBBackend,
+		LBEndpoints:     eps,
+		LBEndpointZones: state.GetEndpointZones(eps),
+		LBAlgorithm:     getLoadBalancerAlgorithm(i.Metadata),
 	}, true, nil
 }
 
@@ -115,11 +115,12 @@ func convertPathRule(
 	}
 
 	r := &eskip.Route{
-		Id:          routeID(ns, name, host, prule.Path, svcName),
-		BackendType: eskip.LBBackend,
-		LBEndpoints: eps,
-		LBAlgorithm: getLoadBalancerAlgorithm(metadata),
-		HostRegexps: hostRegexp,
+		Id:              routeID(ns, name, host, prule.Path, svcName),
+		BackendType:     eskip.LBBackend,
+		LBEndpoints:     eps,
+		LBEndpointZones: state.GetEndpointZones(eps),
+		LBAlgorithm:     getLoadBalancerAlgorithm(metadata),
+		HostRegexps:     hostRegexp,
 	}
 	setPath(pathMode, r, prule.Path)
 	setTraffic(r, svcName, prule.Backend.Traffic, prule.Backend.NoopCount)
@@ -384,10 +385,11 @@ func (ing *ingress) convertDefaultBackend(
 	}
 
 	return &eskip.Route{
-		Id:          routeID(ns, name, "", "", ""),
-		BackendType: eskip.LBBackend,
-		LBEndpoints: eps,
-		LBAlgorithm: getLoadBalancerAlgorithm(i.Metadata),
+		Id:              routeID(ns, name, "", "", ""),
+		BackendType:     eskip.LBBackend,
+		LBEndpoints:     eps,
+		LBEndpointZones: state.GetEndpointZones(eps),
+		LBAlgorithm:     getLoadBalancerAlgorithm(i.Metadata),
 	}, true, nil
 }
 
@@ -140,7 +140,9 @@ type Options struct {
 	// want to set this to true.
 	ReverseSourcePredicate bool
 
-	// Noop, WIP.
+	// ForceFullUpdatePeriod is used only in watch mode. It sets how often the routes are
+	// converted again, even when the watched resources did not change, e.g. to pick up the
+	// changes of the default filters. Defaults to 5 minutes.
 	ForceFullUpdatePeriod time.Duration
 
 	// WhitelistedHealthcheckCIDR to be appended to the default iprange
@@ -184,6 +186,23 @@ type Options struct {
 	AllowedExternalNames []*regexp.Regexp
 
 	CertificateRegistry *certregistry.CertRegistry
+
+	// TopologyZones enables setting the availability zones of the LB endpoints, based on the
+	// topology.kubernetes.io/zone label of the nodes where the endpoints run. It requires
+	// permission to list the nodes.
+	TopologyZones bool
+
+	// KubernetesEnableEndpointSlices makes the data client read the EndpointSlices instead of the
+	// legacy Endpoints resources. The slices of a service are merged, the terminating endpoints are
+	// used only when the service has no ready endpoints, and the zones of the endpoints are set
+	// from the zone field of the endpoints. The zone hints are ignored.
+	KubernetesEnableEndpointSlices bool
+
+	// KubernetesWatch makes the data client watch the changes of the Kubernetes resources,
+	// instead of listing all of them on every poll. The resources are listed only once, and
+	// then again only when the watched resource version expires. LoadUpdate converts the
+	// routes only when any of the watched resources changed.
+	KubernetesWatch bool
 }
 
 // Client is a Skipper DataClient implementation used to create routes based on Kubernetes Ingress settings.
@@ -199,6 +218,15 @@ type Client struct {
 	quit                   chan struct{}
 	defaultFiltersDir      string
 	state                  *clusterState
+	forceFullUpdatePeriod  time.Duration
+	loadedGeneration       uint64
+	fullLoadedAt           time.Time
+
+	// the last converted routes of the ingresses and the route groups,
+	// reused when only the other source changed in watch mode
+	converted        bool
+	ingressRoutes    []*eskip.Route
+	routeGroupRoutes []*eskip.Route
 }
 
 // New creates and initializes a Kubernetes DataClient.
@@ -264,6 +292,10 @@ func New(o Options) (*Client, error) {
 	ing := newIngress(o)
 	rg := newRouteGroups(o)
 
+	if o.ForceFullUpdatePeriod <= 0 {
+		o.ForceFullUpdatePeriod = defaultWatchResyncPeriod
+	}
+
 	return &Client{
 		ClusterClient:          clusterClient,
 		ingress:                ing,
@@ -275,6 +307,7 @@ func New(o Options) (*Client, error) {
 		reverseSourcePredicate: o.ReverseSourcePredicate,
 		quit:                   quit,
 		defaultFiltersDir:      o.DefaultFiltersDir,
+		forceFullUpdatePeriod:  o.ForceFullUpdatePeriod,
 	}, nil
 }
 
@@ -331,26 +364,36 @@ func mapRoutes(r []*eskip.Route) map[string]*eskip.Route {
 	return m
 }
 
-func (c *Client) loadAndConvert() ([]*eskip.Route, error) {
-	state, err := c.ClusterClient.fetchClusterState()
-	if err != nil {
-		return nil, err
-	}
-	c.state = state
+// loadAndConvert converts the routes from the current cluster state. When
+// incremental is true, only the routes of those sources are converted
+// again, whose watched resources changed since the last conversion.
+func (c *Client) loadAndConvert(incremental bool) ([]*eskip.Route, error) {
+	// taken before fetching the state, so that the changes during the
+	// conversion are picked up by the next update
+	generation, _ := c.ClusterClient.watchState()
+	changes := c.ClusterClient.takeWatchChanges()
 
-	defaultFilters := c.fetchDefaultFilterConfigs()
+	sources := allRouteSources
+	if incremental && c.converted {
+		sources = c.ClusterClient.changedRouteSources(changes)
+	}
 
-	ri, err := c.ingress.convert(state, defaultFilters, c.ClusterClient.certificateRegistry)
+	ri, rg, err := c.convert(sources)
 	if err != nil {
+		// the changes need to be converted by the next update
+		c.ClusterClient.restoreWatchChanges(changes)
 		return nil, err
 	}
 
-	rg, err := c.routeGroups.convert(state, defaultFilters)
-	if err != nil {
-		return nil, err
+	c.ingressRoutes, c.routeGroupRoutes, c.converted = ri, rg, true
+	c.loadedGeneration = generation
+	if sources == allRouteSources {
+		c.fullLoadedAt = time.Now()
 	}
 
-	r := append(ri, rg...)
+	r := make([]*eskip.Route, 0, len(ri)+len(rg))
+	r = append(r, ri...)
+	r = append(r, rg...)
 
 	if c.provideHealthcheck {
 		r = append(r, healthcheckRoutes(c.reverseSourcePredicate)...)
@@ -363,6 +406,34 @@ func (c *Client) loadAndConvert() ([]*eskip.Route, error) {
 	return r, nil
 }
 
+func (c *Client) convert(sources routeSources) (ri, rg []*eskip.Route, err error) {
+	state, err := c.ClusterClient.fetchClusterState()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.state = state
+	defaultFilters := c.fetchDefaultFilterConfigs()
+
+	ri = c.ingressRoutes
+	if sources.ingresses {
+		ri, err = c.ingress.convert(state, defaultFilters, c.ClusterClient.certificateRegistry)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	rg = c.routeGroupRoutes
+	if sources.routeGroups {
+		rg, err = c.routeGroups.convert(state, defaultFilters)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return ri, rg, nil
+}
+
 func shuntRoute(r *eskip.Route) {
 	r.Filters = []*eskip.Filter{
 		{
@@ -418,7 +489,7 @@ func healthcheckRoutes(reverseSourcePredicate bool) []*eskip.Route {
 
 func (c *Client) LoadAll() ([]*eskip.Route, error) {
 	log.Debug("loading all")
-	r, err := c.loadAndConvert()
+	r, err := c.loadAndConvert(false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load cluster state: %w", err)
 	}
@@ -432,10 +503,21 @@ func (c *Client) LoadAll() ([]*eskip.Route, error) {
 // LoadUpdate returns all known eskip.Route, a list of route IDs
 // scheduled for delete and an error.
 //
-// TODO: implement a force reset after some time.
+// In watch mode, it returns no changes without converting the routes
+// again, when none of the watched resources changed. When only the
+// ingresses or only the route groups changed, only their routes are
+// converted again. All the routes are converted again at least with the
+// period of the forced full updates.
 func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	generation, watch := c.ClusterClient.watchState()
+	fullUpdateDue := time.Since(c.fullLoadedAt) >= c.forceFullUpdatePeriod
+	if watch && generation == c.loadedGeneration && !fullUpdateDue {
+		log.Debugf("no changes in the watched resources")
+		return nil, nil, nil
+	}
+
 	log.Debugf("polling for updates")
-	r, err := c.loadAndConvert()
+	r, err := c.loadAndConvert(watch && !fullUpdateDue)
 	if err != nil {
 		log.Errorf("polling for updates failed: %v", err)
 		return nil, nil, err
@@ -450,8 +532,7 @@ func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
 	)
 
 	for id := range c.current {
-		// TODO: use eskip.Eq()
-		if r, ok := next[id]; ok && r.String() != c.current[id].String() {
+		if r, ok := next[id]; ok && !eskip.Eq(r, c.current[id]) {
 			updatedRoutes = append(updatedRoutes, r)
 		} else if !ok {
 			deletedIDs = append(deletedIDs, id)
@@ -22,11 +22,13 @@ type TestAPIOptions struct {
 }
 
 type namespace struct {
-	services    []byte
-	ingresses   []byte
-	routeGroups []byte
-	endpoints   []byte
-	secrets     []byte
+	services       []byte
+	ingresses      []byte
+	routeGroups    []byte
+	endpoints      []byte
+	endpointSlices []byte
+	secrets        []byte
+	nodes          []byte
 }
 
 type api struct {
@@ -42,7 +44,7 @@ func NewAPI(o TestAPIOptions, specs ...io.Reader) (*api, error) {
 	a := &api{
 		namespaces: make(map[string]namespace),
 		pathRx: regexp.MustCompile(
-			"(/namespaces/([^/]+))?/(services|ingresses|routegroups|endpoints|secrets)",
+			"(/namespaces/([^/]+))?/(services|ingresses|routegroups|endpointslices|endpoints|secrets|nodes)",
 		),
 	}
 
@@ -161,8 +163,12 @@ func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		b = ns.routeGroups
 	case "endpoints":
 		b = ns.endpoints
+	case "endpointslices":
+		b = ns.endpointSlices
 	case "secrets":
 		b = ns.secrets
+	case "nodes":
+		b = ns.nodes
 	default:
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -188,10 +194,18 @@ func initNamespace(kinds map[string][]interface{}) (ns namespace, err error) {
 		return
 	}
 
+	if err = itemsJSON(&ns.endpointSlices, kinds["EndpointSlice"]); err != nil {
+		return
+	}
+
 	if err = itemsJSON(&ns.secrets, kinds["Secret"]); err != nil {
 		return
 	}
 
+	if err = itemsJSON(&ns.nodes, kinds["Node"]); err != nil {
+		return
+	}
+
 	return
 }
 
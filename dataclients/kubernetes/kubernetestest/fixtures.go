@@ -44,6 +44,7 @@ type kubeOptionsParser struct {
 	AllowedExternalNames     []string           `yaml:"allowedExternalNames"`
 	IngressClass             string             `yaml:"kubernetes-ingress-class"`
 	KubernetesEnableTLS      bool               `yaml:"kubernetes-enable-tls"`
+	EnableEndpointSlices     bool               `yaml:"enable-kubernetes-endpointslices"`
 }
 
 func baseNoExt(n string) string {
@@ -225,6 +226,7 @@ func testFixture(t *testing.T, f fixtureSet) {
 		o.BackendNameTracingTag = kop.BackendNameTracingTag
 		o.IngressClass = kop.IngressClass
 		o.CertificateRegistry = cr
+		o.KubernetesEnableEndpointSlices = kop.EnableEndpointSlices
 
 		aen, err := compileRegexps(kop.AllowedExternalNames)
 		if err != nil {
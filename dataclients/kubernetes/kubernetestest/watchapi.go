@@ -0,0 +1,473 @@
+package kubernetestest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	yaml2 "github.com/ghodss/yaml"
+	"gopkg.in/yaml.v2"
+
+	"github.com/zalando/skipper/dataclients/kubernetes"
+)
+
+var kindResources = map[string]string{
+	"Service":       "services",
+	"Ingress":       "ingresses",
+	"RouteGroup":    "routegroups",
+	"Endpoints":     "endpoints",
+	"EndpointSlice": "endpointslices",
+	"Secret":        "secrets",
+	"Node":          "nodes",
+}
+
+type WatchAPIOptions struct {
+	DisableRouteGroups bool
+}
+
+type watchEntry struct {
+	resourceVersion int
+	resource        string
+	namespace       string
+	event           []byte
+}
+
+type watchSubscriber struct {
+	resource  string
+	namespace string
+
+	// a nil item closes the watch
+	events chan []byte
+}
+
+// WatchAPI is a fake Kubernetes API server supporting the list and the
+// watch requests. The objects can be changed while the server is
+// running, and the watching clients receive the changes as events.
+//
+// The history of the events can be compacted, after which the watches
+// starting from an older resource version fail with 410 Gone, like
+// with the real API server.
+type WatchAPI struct {
+	mx              sync.Mutex
+	pathRx          *regexp.Regexp
+	resourceList    []byte
+	resourceVersion int
+	compacted       int
+	objects         map[string]map[string]map[string]interface{}
+	history         []watchEntry
+	subscribers     map[*watchSubscriber]struct{}
+	lists           map[string]int
+	watches         map[string]int
+	expired         map[string]int
+}
+
+// NewWatchAPI creates a fake API server, initialized with the objects
+// in the YAML specs.
+func NewWatchAPI(o WatchAPIOptions, specs ...io.Reader) (*WatchAPI, error) {
+	var clr kubernetes.ClusterResourceList
+	if !o.DisableRouteGroups {
+		clr.Items = append(clr.Items, &kubernetes.ClusterResource{Name: kubernetes.RouteGroupsName})
+	}
+
+	clrb, err := json.Marshal(clr)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &WatchAPI{
+		pathRx: regexp.MustCompile(
+			"(/namespaces/([^/]+))?/(services|ingresses|routegroups|endpointslices|endpoints|secrets|nodes)$",
+		),
+		resourceList: clrb,
+		objects:      make(map[string]map[string]map[string]interface{}),
+		subscribers:  make(map[*watchSubscriber]struct{}),
+		lists:        make(map[string]int),
+		watches:      make(map[string]int),
+		expired:      make(map[string]int),
+	}
+
+	for _, s := range specs {
+		if err := a.Apply(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+func decodeObjects(spec io.Reader) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	d := yaml.NewDecoder(spec)
+	for {
+		var o map[string]interface{}
+		if err := d.Decode(&o); err == io.EOF || err == nil && len(o) == 0 {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// converting back to YAML, because of the interface{} type
+		// keys of the YAML parser
+		y, err := yaml.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+
+		j, err := yaml2.YAMLToJSON(y)
+		if err != nil {
+			return nil, err
+		}
+
+		var jo map[string]interface{}
+		if err := json.Unmarshal(j, &jo); err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, jo)
+	}
+
+	return objects, nil
+}
+
+func objectMeta(o map[string]interface{}) (resource, namespace, name string, meta map[string]interface{}, err error) {
+	kind, _ := o["kind"].(string)
+	resource, ok := kindResources[kind]
+	if !ok {
+		err = errInvalidFixture
+		return
+	}
+
+	meta, ok = o["metadata"].(map[string]interface{})
+	if !ok {
+		err = errInvalidFixture
+		return
+	}
+
+	name, _ = meta["name"].(string)
+	if name == "" {
+		err = errInvalidFixture
+		return
+	}
+
+	if resource != "nodes" {
+		namespace, _ = meta["namespace"].(string)
+		if namespace == "" {
+			namespace = "default"
+			meta["namespace"] = namespace
+		}
+	}
+
+	return
+}
+
+func objectKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+
+	return namespace + "/" + name
+}
+
+// must be called with the lock held
+func (a *WatchAPI) publish(eventType, resource, namespace string, object interface{}) error {
+	e, err := json.Marshal(map[string]interface{}{"type": eventType, "object": object})
+	if err != nil {
+		return err
+	}
+
+	entry := watchEntry{
+		resourceVersion: a.resourceVersion,
+		resource:        resource,
+		namespace:       namespace,
+		event:           e,
+	}
+
+	a.history = append(a.history, entry)
+	for s := range a.subscribers {
+		if s.matches(entry) {
+			s.events <- e
+		}
+	}
+
+	return nil
+}
+
+// Apply creates or updates the objects in the YAML spec, and sends
+// ADDED or MODIFIED events to the watching clients.
+func (a *WatchAPI) Apply(spec io.Reader) error {
+	objects, err := decodeObjects(spec)
+	if err != nil {
+		return err
+	}
+
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	for _, o := range objects {
+		resource, namespace, name, meta, err := objectMeta(o)
+		if err != nil {
+			return err
+		}
+
+		a.resourceVersion++
+		meta["resourceVersion"] = strconv.Itoa(a.resourceVersion)
+
+		eventType := "MODIFIED"
+		if a.objects[resource] == nil {
+			a.objects[resource] = make(map[string]map[string]interface{})
+		}
+
+		key := objectKey(namespace, name)
+		if _, ok := a.objects[resource][key]; !ok {
+			eventType = "ADDED"
+		}
+
+		a.objects[resource][key] = o
+		if err := a.publish(eventType, resource, namespace, o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes an object, and sends a DELETED event to the watching
+// clients. The namespace is ignored for the nodes.
+func (a *WatchAPI) Delete(kind, namespace, name string) error {
+	resource, ok := kindResources[kind]
+	if !ok {
+		return fmt.Errorf("unsupported kind: %s", kind)
+	}
+
+	if resource == "nodes" {
+		namespace = ""
+	}
+
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	key := objectKey(namespace, name)
+	o, ok := a.objects[resource][key]
+	if !ok {
+		return fmt.Errorf("object not found: %s %s", kind, key)
+	}
+
+	delete(a.objects[resource], key)
+	a.resourceVersion++
+	o["metadata"].(map[string]interface{})["resourceVersion"] = strconv.Itoa(a.resourceVersion)
+	return a.publish("DELETED", resource, namespace, o)
+}
+
+// Bookmark sends a BOOKMARK event with the current resource version to
+// all the watching clients.
+func (a *WatchAPI) Bookmark() {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	e, _ := json.Marshal(map[string]interface{}{
+		"type": "BOOKMARK",
+		"object": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"resourceVersion": strconv.Itoa(a.resourceVersion),
+			},
+		},
+	})
+
+	for s := range a.subscribers {
+		s.events <- e
+	}
+}
+
+// Compact drops the history of the events. The watches starting from
+// an older resource version than the current one fail with 410 Gone.
+func (a *WatchAPI) Compact() {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.compacted = a.resourceVersion
+	a.history = nil
+}
+
+// CloseWatches closes the open watch connections, after sending the
+// pending events, like the API server does when a watch times out.
+func (a *WatchAPI) CloseWatches() {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	for s := range a.subscribers {
+		s.events <- nil
+		delete(a.subscribers, s)
+	}
+}
+
+// ListCount returns how many times a resource was listed, e.g.
+// "services".
+func (a *WatchAPI) ListCount(resource string) int {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	return a.lists[resource]
+}
+
+// WatchCount returns how many times a resource watch was started, e.g.
+// "services".
+func (a *WatchAPI) WatchCount(resource string) int {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	return a.watches[resource]
+}
+
+// ExpiredCount returns how many times a resource watch was rejected,
+// because it started from a compacted resource version.
+func (a *WatchAPI) ExpiredCount(resource string) int {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	return a.expired[resource]
+}
+
+func (s *watchSubscriber) matches(e watchEntry) bool {
+	return s.resource == e.resource && (s.namespace == "" || s.namespace == e.namespace)
+}
+
+func (a *WatchAPI) list(w http.ResponseWriter, resource, namespace string) {
+	a.mx.Lock()
+	a.lists[resource]++
+
+	var keys []string
+	for k, o := range a.objects[resource] {
+		if namespace != "" && o["metadata"].(map[string]interface{})["namespace"] != namespace {
+			continue
+		}
+
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	items := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, a.objects[resource][k])
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"kind":     "List",
+		"metadata": map[string]interface{}{"resourceVersion": strconv.Itoa(a.resourceVersion)},
+		"items":    items,
+	})
+
+	a.mx.Unlock()
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(b)
+}
+
+func expiredEvent(rv int) []byte {
+	e, _ := json.Marshal(map[string]interface{}{
+		"type": "ERROR",
+		"object": map[string]interface{}{
+			"kind":    "Status",
+			"status":  "Failure",
+			"reason":  "Expired",
+			"code":    http.StatusGone,
+			"message": fmt.Sprintf("too old resource version: %d", rv),
+		},
+	})
+
+	return e
+}
+
+func (a *WatchAPI) watch(w http.ResponseWriter, r *http.Request, resource, namespace string) {
+	rv, _ := strconv.Atoi(r.URL.Query().Get("resourceVersion"))
+	timeout := time.Hour
+	if ts, err := strconv.Atoi(r.URL.Query().Get("timeoutSeconds")); err == nil && ts > 0 {
+		timeout = time.Duration(ts) * time.Second
+	}
+
+	s := &watchSubscriber{
+		resource:  resource,
+		namespace: namespace,
+		events:    make(chan []byte, 1<<10),
+	}
+
+	a.mx.Lock()
+	a.watches[resource]++
+	if rv < a.compacted {
+		a.expired[resource]++
+		s.events <- expiredEvent(rv)
+		s.events <- nil
+	} else {
+		for _, e := range a.history {
+			if e.resourceVersion > rv && s.matches(e) {
+				s.events <- e.event
+			}
+		}
+
+		a.subscribers[s] = struct{}{}
+	}
+
+	a.mx.Unlock()
+
+	defer func() {
+		a.mx.Lock()
+		delete(a.subscribers, s)
+		a.mx.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	to := time.After(timeout)
+	for {
+		select {
+		case e := <-s.events:
+			if e == nil {
+				return
+			}
+
+			w.Write(e)
+			w.Write([]byte("\n"))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-to:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (a *WatchAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path == kubernetes.ZalandoResourcesClusterURI {
+		w.Write(a.resourceList)
+		return
+	}
+
+	parts := a.pathRx.FindStringSubmatch(r.URL.Path)
+	if len(parts) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("watch") == "true" {
+		a.watch(w, r, parts[3], parts[2])
+		return
+	}
+
+	a.list(w, parts[3], parts[2])
+}
@@ -282,6 +282,7 @@ func applyServiceBackend(ctx *routeGroupContext, backend *definitions.SkipperBac
 
 	r.BackendType = eskip.LBBackend
 	r.LBEndpoints = eps
+	r.LBEndpointZones = ctx.clusterState.GetEndpointZones(eps)
 	r.LBAlgorithm = defaultLoadBalancerAlgorithm
 	if backend.Algorithm != loadbalancer.None {
 		r.LBAlgorithm = backend.Algorithm.String()
@@ -335,6 +336,7 @@ func applyBackend(ctx *routeGroupContext, backend *definitions.SkipperBackend, r
 		}
 
 		r.LBEndpoints = backend.Endpoints
+		r.LBEndpointWeights = backend.EndpointWeights
 		r.LBAlgorithm = defaultLoadBalancerAlgorithm
 		if backend.Algorithm != loadbalancer.None {
 			r.LBAlgorithm = backend.Algorithm.String()
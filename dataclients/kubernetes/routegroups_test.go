@@ -49,3 +49,7 @@ func TestRouteGroupTracingTag(t *testing.T) {
 func TestRouteGroupExternalName(t *testing.T) {
 	kubernetestest.FixturesToTest(t, "testdata/routegroups/external-name")
 }
+
+func TestRouteGroupEndpointSlices(t *testing.T) {
+	kubernetestest.FixturesToTest(t, "testdata/routegroups/endpointslices")
+}
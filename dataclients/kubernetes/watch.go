@@ -0,0 +1,457 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	watchEventAdded    = "ADDED"
+	watchEventModified = "MODIFIED"
+	watchEventDeleted  = "DELETED"
+	watchEventBookmark = "BOOKMARK"
+	watchEventError    = "ERROR"
+
+	// the API server closes the watch connections after this timeout,
+	// and the watch is started again from the last known resource
+	// version
+	defaultWatchTimeout = 5 * time.Minute
+
+	defaultWatchRetryDelay = time.Second
+
+	// the retry delay of the failing watches is doubled on every
+	// consecutive failure, up to this limit
+	maxWatchRetryDelay = 30 * time.Second
+
+	// in watch mode, the routes are converted again at least with
+	// this period, even when there were no changes, e.g. to pick up
+	// the changes of the default filters
+	defaultWatchResyncPeriod = 5 * time.Minute
+)
+
+var errResourceExpired = errors.New("resource version expired")
+
+type watchObjectMetadata struct {
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type watchObject struct {
+	Metadata *watchObjectMetadata `json:"metadata"`
+}
+
+type watchList struct {
+	Metadata *watchObjectMetadata `json:"metadata"`
+	Items    []json.RawMessage    `json:"items"`
+}
+
+type watchStatus struct {
+	Kind    string `json:"kind"`
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// resourceWatcher keeps a local copy of a single resource type, e.g.
+// all the services in the cluster. It lists the resources once, and
+// then watches the changes, starting from the resource version of the
+// list or the last received event. When the resource version expires,
+// it lists the resources again.
+type resourceWatcher struct {
+	client     *clusterClient
+	uri        string
+	timeout    time.Duration
+	retryDelay time.Duration
+	changed    func()
+	quit       <-chan struct{}
+
+	mx              sync.Mutex
+	items           map[string]json.RawMessage
+	resourceVersion string
+}
+
+func newResourceWatcher(c *clusterClient, uri string, changed func(), quit <-chan struct{}) *resourceWatcher {
+	return &resourceWatcher{
+		client:     c,
+		uri:        uri,
+		timeout:    c.watchTimeout,
+		retryDelay: c.watchRetryDelay,
+		changed:    changed,
+		quit:       quit,
+	}
+}
+
+func objectKey(m *watchObjectMetadata) string {
+	if m == nil {
+		return ""
+	}
+
+	if m.Namespace == "" {
+		return m.Name
+	}
+
+	return m.Namespace + "/" + m.Name
+}
+
+// start lists the resources, and when it succeeds, it starts watching
+// the changes in the background.
+func (w *resourceWatcher) start() error {
+	if err := w.list(); err != nil {
+		return err
+	}
+
+	go w.run()
+	return nil
+}
+
+func (w *resourceWatcher) list() error {
+	var l watchList
+	if err := w.client.requestJSON(w.uri, &l); err != nil {
+		return err
+	}
+
+	items := make(map[string]json.RawMessage)
+	for _, i := range l.Items {
+		var o watchObject
+		if err := json.Unmarshal(i, &o); err != nil {
+			return err
+		}
+
+		items[objectKey(o.Metadata)] = i
+	}
+
+	var rv string
+	if l.Metadata != nil {
+		rv = l.Metadata.ResourceVersion
+	}
+
+	w.mx.Lock()
+	w.items = items
+	w.resourceVersion = rv
+	w.mx.Unlock()
+	return nil
+}
+
+func (w *resourceWatcher) watchURI() string {
+	w.mx.Lock()
+	rv := w.resourceVersion
+	w.mx.Unlock()
+
+	q := make(url.Values)
+	q.Set("watch", "true")
+	q.Set("allowWatchBookmarks", "true")
+	q.Set("timeoutSeconds", fmt.Sprint(int(w.timeout/time.Second)))
+	if rv != "" {
+		q.Set("resourceVersion", rv)
+	}
+
+	return w.uri + "?" + q.Encode()
+}
+
+func (w *resourceWatcher) apply(e *watchEvent) error {
+	if e.Type == watchEventError {
+		var s watchStatus
+		if err := json.Unmarshal(e.Object, &s); err != nil {
+			return err
+		}
+
+		if s.Code == http.StatusGone {
+			return errResourceExpired
+		}
+
+		return fmt.Errorf("watch error: %d, %s", s.Code, s.Message)
+	}
+
+	var o watchObject
+	if err := json.Unmarshal(e.Object, &o); err != nil {
+		return err
+	}
+
+	if o.Metadata == nil {
+		return fmt.Errorf("watch event without metadata: %s", e.Type)
+	}
+
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	switch e.Type {
+	case watchEventAdded, watchEventModified:
+		w.items[objectKey(o.Metadata)] = e.Object
+	case watchEventDeleted:
+		delete(w.items, objectKey(o.Metadata))
+	case watchEventBookmark:
+		// bookmarks only move the resource version forward
+		w.resourceVersion = o.Metadata.ResourceVersion
+		return nil
+	default:
+		return fmt.Errorf("unknown watch event type: %s", e.Type)
+	}
+
+	w.resourceVersion = o.Metadata.ResourceVersion
+	w.changed()
+	return nil
+}
+
+// watch receives the watch events until the API server closes the
+// connection, an error happens, or the watcher is stopped. It returns
+// whether it received any events.
+func (w *resourceWatcher) watch() (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-w.quit:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	req, err := w.client.createRequest(w.watchURI(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	rsp, err := w.client.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode == http.StatusGone {
+		return false, errResourceExpired
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("watch request failed, status: %d, %s", rsp.StatusCode, rsp.Status)
+	}
+
+	var received bool
+	dec := json.NewDecoder(rsp.Body)
+	for {
+		var e watchEvent
+		if err := dec.Decode(&e); errors.Is(err, io.EOF) {
+			return received, nil
+		} else if err != nil {
+			return received, err
+		}
+
+		if err := w.apply(&e); err != nil {
+			return received, err
+		}
+
+		received = true
+	}
+}
+
+func (w *resourceWatcher) wait(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-w.quit:
+		return false
+	}
+}
+
+func (w *resourceWatcher) stopped() bool {
+	select {
+	case <-w.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextRetryDelay doubles the retry delay, up to maxWatchRetryDelay.
+func (w *resourceWatcher) nextRetryDelay(d time.Duration) time.Duration {
+	d *= 2
+	if d > maxWatchRetryDelay {
+		d = maxWatchRetryDelay
+	}
+
+	if d < w.retryDelay {
+		d = w.retryDelay
+	}
+
+	return d
+}
+
+func (w *resourceWatcher) run() {
+	delay := w.retryDelay
+	for {
+		start := time.Now()
+		received, err := w.watch()
+		if w.stopped() {
+			return
+		}
+
+		switch {
+		case err == nil && (received || time.Since(start) >= w.retryDelay):
+			// the API server closed the watch, continue from the
+			// last resource version
+			delay = w.retryDelay
+			continue
+		case err == nil:
+			// the API server closed the watch right away, back off
+			// to avoid reconnecting in a busy loop
+			log.Debugf("Watch of %s closed without events, retrying in %v.", w.uri, delay)
+			if !w.wait(delay) {
+				return
+			}
+
+			delay = w.nextRetryDelay(delay)
+			continue
+		case errors.Is(err, errResourceExpired):
+			log.Debugf("Resource version expired for %s, listing again.", w.uri)
+		default:
+			log.Errorf("Failed to watch %s: %v.", w.uri, err)
+			if !w.wait(delay) {
+				return
+			}
+
+			delay = w.nextRetryDelay(delay)
+		}
+
+		for {
+			err := w.list()
+			if err == nil {
+				w.changed()
+				break
+			}
+
+			log.Errorf("Failed to list %s: %v.", w.uri, err)
+			if !w.wait(delay) {
+				return
+			}
+
+			delay = w.nextRetryDelay(delay)
+		}
+	}
+}
+
+// getJSON unmarshals the current state of the watched resources into
+// a list object, the same way as if it was returned by the API server.
+func (w *resourceWatcher) getJSON(a interface{}) error {
+	w.mx.Lock()
+	keys := make([]string, 0, len(w.items))
+	for k := range w.items {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	b := bytes.NewBufferString(`{"items":[`)
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.Write(w.items[k])
+	}
+
+	w.mx.Unlock()
+
+	b.WriteString(`]}`)
+	return json.Unmarshal(b.Bytes(), a)
+}
+
+// getWatchedJSON returns the resources from the local copy kept by the
+// watcher of the uri. It starts the watcher on the first call.
+func (c *clusterClient) getWatchedJSON(uri string, a interface{}) error {
+	c.watchersMx.Lock()
+	w, ok := c.watchers[uri]
+	if !ok {
+		w = newResourceWatcher(c, uri, func() { c.watchChanged(uri) }, c.quit)
+		if err := w.start(); err != nil {
+			c.watchersMx.Unlock()
+			return err
+		}
+
+		c.watchers[uri] = w
+	}
+
+	c.watchersMx.Unlock()
+	return w.getJSON(a)
+}
+
+func (c *clusterClient) watchChanged(uri string) {
+	c.watchChangesMx.Lock()
+	c.watchChanges[uri] = true
+	c.watchChangesMx.Unlock()
+	atomic.AddUint64(&c.watchGeneration, 1)
+}
+
+// takeWatchChanges returns the uris of the watched resources that
+// changed since the last call, and resets them.
+func (c *clusterClient) takeWatchChanges() map[string]bool {
+	c.watchChangesMx.Lock()
+	defer c.watchChangesMx.Unlock()
+	changes := c.watchChanges
+	c.watchChanges = make(map[string]bool)
+	return changes
+}
+
+// restoreWatchChanges keeps the changes for the next update, when the
+// routes could not be converted.
+func (c *clusterClient) restoreWatchChanges(changes map[string]bool) {
+	c.watchChangesMx.Lock()
+	defer c.watchChangesMx.Unlock()
+	for uri := range changes {
+		c.watchChanges[uri] = true
+	}
+}
+
+// routeSources tells the sources of the routes that need to be converted
+// again.
+type routeSources struct {
+	ingresses   bool
+	routeGroups bool
+}
+
+var allRouteSources = routeSources{ingresses: true, routeGroups: true}
+
+// changedRouteSources returns the sources of the routes affected by the
+// changed resources. The changes of the services, endpoints, secrets and
+// nodes affect all the routes.
+func (c *clusterClient) changedRouteSources(changes map[string]bool) routeSources {
+	var s routeSources
+	for uri := range changes {
+		switch uri {
+		case c.ingressesURI:
+			s.ingresses = true
+		case c.routeGroupsURI:
+			s.routeGroups = true
+		default:
+			return allRouteSources
+		}
+	}
+
+	return s
+}
+
+// watchState returns a counter that changes every time when any of the
+// watched resources change, and whether the watch mode is enabled.
+func (c *clusterClient) watchState() (uint64, bool) {
+	return atomic.LoadUint64(&c.watchGeneration), c.watch
+}
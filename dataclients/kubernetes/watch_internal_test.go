@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChangedRouteSources(t *testing.T) {
+	c := &clusterClient{
+		ingressesURI:   IngressesV1ClusterURI,
+		routeGroupsURI: routeGroupsClusterURI,
+	}
+
+	for _, tt := range []struct {
+		name     string
+		changes  []string
+		expected routeSources
+	}{{
+		name:     "ingresses",
+		changes:  []string{IngressesV1ClusterURI},
+		expected: routeSources{ingresses: true},
+	}, {
+		name:     "route groups",
+		changes:  []string{routeGroupsClusterURI},
+		expected: routeSources{routeGroups: true},
+	}, {
+		name:     "ingresses and route groups",
+		changes:  []string{IngressesV1ClusterURI, routeGroupsClusterURI},
+		expected: allRouteSources,
+	}, {
+		name:     "services",
+		changes:  []string{IngressesV1ClusterURI, ServicesClusterURI},
+		expected: allRouteSources,
+	}, {
+		name:     "endpoints",
+		changes:  []string{EndpointsClusterURI},
+		expected: allRouteSources,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := make(map[string]bool)
+			for _, uri := range tt.changes {
+				changes[uri] = true
+			}
+
+			if s := c.changedRouteSources(changes); s != tt.expected {
+				t.Errorf("expected %v, got: %v", tt.expected, s)
+			}
+		})
+	}
+}
+
+func TestWatchBackoffOnEmptyWatches(t *testing.T) {
+	var watches int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// closes the watch right away, without any events
+		atomic.AddInt32(&watches, 1)
+	}))
+	defer s.Close()
+
+	quit := make(chan struct{})
+	c := &clusterClient{
+		apiURL:          s.URL,
+		httpClient:      s.Client(),
+		watchTimeout:    time.Minute,
+		watchRetryDelay: 10 * time.Millisecond,
+	}
+
+	w := newResourceWatcher(c, ServicesClusterURI, func() {}, quit)
+	go w.run()
+	time.Sleep(300 * time.Millisecond)
+	close(quit)
+
+	// 10ms, 20ms, 40ms, 80ms, 160ms
+	if n := atomic.LoadInt32(&watches); n < 2 || n > 8 {
+		t.Errorf("expected the watches to back off, got %d watches", n)
+	}
+}
@@ -0,0 +1,209 @@
+package kubernetes_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/dataclients/kubernetes"
+	"github.com/zalando/skipper/dataclients/kubernetes/kubernetestest"
+	"github.com/zalando/skipper/eskip"
+)
+
+const watchSpec = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  namespace: foo
+  name: qux
+spec:
+  rules:
+  - host: www.example.org
+    http:
+      paths:
+      - path: "/"
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: bar
+            port:
+              name: baz
+---
+apiVersion: v1
+kind: Service
+metadata:
+  namespace: foo
+  name: bar
+spec:
+  clusterIP: 10.3.190.97
+  ports:
+  - name: baz
+    port: 8181
+    protocol: TCP
+    targetPort: 8080
+  type: ClusterIP
+---
+apiVersion: v1
+kind: Endpoints
+metadata:
+  namespace: foo
+  name: bar
+subsets:
+- addresses:
+  - ip: 10.2.9.103
+  - ip: 10.2.9.104
+  ports:
+  - name: baz
+    port: 8080
+    protocol: TCP
+`
+
+const watchEndpointsUpdate = `
+apiVersion: v1
+kind: Endpoints
+metadata:
+  namespace: foo
+  name: bar
+subsets:
+- addresses:
+  - ip: 10.2.9.103
+  - ip: 10.2.9.104
+  - ip: 10.2.9.105
+  ports:
+  - name: baz
+    port: 8080
+    protocol: TCP
+`
+
+const watchNode = `
+apiVersion: v1
+kind: Node
+metadata:
+  name: node-1
+`
+
+func findLBRoute(r []*eskip.Route) *eskip.Route {
+	for _, ri := range r {
+		if ri.BackendType == eskip.LBBackend {
+			return ri
+		}
+	}
+
+	return nil
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	t.Helper()
+	for to := time.After(3 * time.Second); !f(); {
+		select {
+		case <-to:
+			t.Fatal("timeout")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func waitForUpdate(t *testing.T, c *kubernetes.Client, f func([]*eskip.Route, []string) bool) {
+	t.Helper()
+	waitFor(t, func() bool {
+		r, d, err := c.LoadUpdate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return f(r, d)
+	})
+}
+
+func TestWatch(t *testing.T) {
+	a, err := kubernetestest.NewWatchAPI(kubernetestest.WatchAPIOptions{}, strings.NewReader(watchSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := httptest.NewServer(a)
+	defer s.Close()
+
+	c, err := kubernetes.New(kubernetes.Options{
+		KubernetesURL:       s.URL,
+		KubernetesIngressV1: true,
+		KubernetesWatch:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	r, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lb := findLBRoute(r)
+	if lb == nil || len(lb.LBEndpoints) != 2 {
+		t.Fatalf("failed to load the routes: %v", r)
+	}
+
+	waitFor(t, func() bool { return a.WatchCount("services") == 1 && a.WatchCount("endpoints") == 1 })
+
+	r, d, err := c.LoadUpdate()
+	if err != nil || len(r) != 0 || len(d) != 0 {
+		t.Fatalf("unexpected update without changes: %v, %v, %v", r, d, err)
+	}
+
+	if a.ListCount("services") != 1 || a.ListCount("endpoints") != 1 {
+		t.Fatal("unexpected list requests")
+	}
+
+	if err := a.Apply(strings.NewReader(watchEndpointsUpdate)); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForUpdate(t, c, func(r []*eskip.Route, _ []string) bool {
+		lb := findLBRoute(r)
+		return lb != nil && len(lb.LBEndpoints) == 3
+	})
+
+	// the bookmark moves the resource version of the watches forward,
+	// so they can continue after the compaction without listing again
+	if err := a.Apply(strings.NewReader(watchNode)); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Bookmark()
+	a.Compact()
+	a.CloseWatches()
+	waitFor(t, func() bool { return a.WatchCount("services") == 2 })
+	if a.ExpiredCount("services") != 0 || a.ListCount("services") != 1 {
+		t.Fatal("unexpected listing after bookmark")
+	}
+
+	// without a bookmark, the resource version of the watches expires,
+	// and the resources are listed again
+	if err := a.Delete("Node", "", "node-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Compact()
+	a.CloseWatches()
+	waitFor(t, func() bool { return a.ListCount("services") == 2 && a.ListCount("endpoints") == 2 })
+	if a.ExpiredCount("services") != 1 {
+		t.Fatal("failed to expire the watch")
+	}
+
+	if err := a.Delete("Ingress", "foo", "qux"); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForUpdate(t, c, func(_ []*eskip.Route, d []string) bool {
+		for _, id := range d {
+			if id == lb.Id {
+				return true
+			}
+		}
+
+		return false
+	})
+}
@@ -67,6 +67,16 @@ func Copy(r *Route) *Route {
 	c.LBAlgorithm = r.LBAlgorithm
 	c.LBEndpoints = make([]string, len(r.LBEndpoints))
 	copy(c.LBEndpoints, r.LBEndpoints)
+	if len(r.LBEndpointWeights) > 0 {
+		c.LBEndpointWeights = make([]float64, len(r.LBEndpointWeights))
+		copy(c.LBEndpointWeights, r.LBEndpointWeights)
+	}
+
+	if len(r.LBEndpointZones) > 0 {
+		c.LBEndpointZones = make([]string, len(r.LBEndpointZones))
+		copy(c.LBEndpointZones, r.LBEndpointZones)
+	}
+
 	return c
 }
 
@@ -54,6 +54,75 @@ func eqStrings(left, right []string) bool {
 	return true
 }
 
+func eqFloats(left, right []float64) bool {
+	if len(left) != len(right) {
+		return false
+	}
+
+	for i := range left {
+		if left[i] != right[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// returns the sorted copy of the LB endpoints, together with their
+// weights and zones. When all the weights are 1, the returned weights
+// are nil, and when none of the zones are set, the returned zones are
+// nil.
+func canonicalLBEndpoints(endpoints []string, weights []float64, zones []string) ([]string, []float64, []string) {
+	type lbEndpoint struct {
+		endpoint string
+		weight   float64
+		zone     string
+	}
+
+	le := make([]lbEndpoint, len(endpoints))
+	var hasWeights, hasZones bool
+	for i, e := range endpoints {
+		le[i] = lbEndpoint{endpoint: e, weight: 1}
+		if i < len(weights) {
+			le[i].weight = weights[i]
+			hasWeights = hasWeights || weights[i] != 1
+		}
+
+		if i < len(zones) {
+			le[i].zone = zones[i]
+			hasZones = hasZones || zones[i] != ""
+		}
+	}
+
+	sort.SliceStable(le, func(i, j int) bool { return le[i].endpoint < le[j].endpoint })
+	ce := make([]string, len(le))
+	var (
+		cw []float64
+		cz []string
+	)
+
+	if hasWeights {
+		cw = make([]float64, len(le))
+	}
+
+	if hasZones {
+		cz = make([]string, len(le))
+	}
+
+	for i, li := range le {
+		ce[i] = li.endpoint
+		if hasWeights {
+			cw[i] = li.weight
+		}
+
+		if hasZones {
+			cz[i] = li.zone
+		}
+	}
+
+	return ce, cw, cz
+}
+
 func eq2(left, right *Route) bool {
 	lc, rc := Canonical(left), Canonical(right)
 
@@ -107,6 +176,14 @@ func eq2(left, right *Route) bool {
 		return false
 	}
 
+	if !eqFloats(lc.LBEndpointWeights, rc.LBEndpointWeights) {
+		return false
+	}
+
+	if !eqStrings(lc.LBEndpointZones, rc.LBEndpointZones) {
+		return false
+	}
+
 	return true
 }
 
@@ -258,9 +335,7 @@ func Canonical(r *Route) *Route {
 	case LBBackend:
 		// using the LB fields only when apply:
 		c.LBAlgorithm = r.LBAlgorithm
-		c.LBEndpoints = make([]string, len(r.LBEndpoints))
-		copy(c.LBEndpoints, r.LBEndpoints)
-		sort.Strings(c.LBEndpoints)
+		c.LBEndpoints, c.LBEndpointWeights, c.LBEndpointZones = canonicalLBEndpoints(r.LBEndpoints, r.LBEndpointWeights, r.LBEndpointZones)
 	}
 
 	// Name and Namespace stripped
@@ -108,6 +108,39 @@ func TestEq(t *testing.T) {
 			{BackendType: LBBackend, LBEndpoints: []string{"https://one.example.org"}},
 			{BackendType: LBBackend, LBEndpoints: []string{"https://two.example.org"}},
 		},
+	}, {
+		title: "non-eq lb endpoint weights",
+		routes: []*Route{{
+			BackendType:       LBBackend,
+			LBEndpoints:       []string{"https://one.example.org", "https://two.example.org"},
+			LBEndpointWeights: []float64{3, 1},
+		}, {
+			BackendType:       LBBackend,
+			LBEndpoints:       []string{"https://one.example.org", "https://two.example.org"},
+			LBEndpointWeights: []float64{1, 3},
+		}},
+	}, {
+		title: "non-eq lb endpoint zones",
+		routes: []*Route{{
+			BackendType:     LBBackend,
+			LBEndpoints:     []string{"https://one.example.org", "https://two.example.org"},
+			LBEndpointZones: []string{"zone-a", "zone-b"},
+		}, {
+			BackendType: LBBackend,
+			LBEndpoints: []string{"https://one.example.org", "https://two.example.org"},
+		}},
+	}, {
+		title: "eq lb endpoint weights in different order",
+		routes: []*Route{{
+			BackendType:       LBBackend,
+			LBEndpoints:       []string{"https://one.example.org", "https://two.example.org"},
+			LBEndpointWeights: []float64{3, 1},
+		}, {
+			BackendType:       LBBackend,
+			LBEndpoints:       []string{"https://two.example.org", "https://one.example.org"},
+			LBEndpointWeights: []float64{1, 3},
+		}},
+		expect: true,
 	}, {
 		title: "all eq",
 		routes: []*Route{{
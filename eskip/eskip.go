@@ -220,7 +220,10 @@ const (
 	LBBackend
 )
 
-var errMixedProtocols = errors.New("loadbalancer endpoints cannot have mixed protocols")
+var (
+	errMixedProtocols          = errors.New("loadbalancer endpoints cannot have mixed protocols")
+	errInvalidLBEndpointWeight = errors.New("loadbalancer endpoint weights must be positive")
+)
 
 // Route definition used during the parser processes the raw routing
 // document.
@@ -235,6 +238,9 @@ type parsedRoute struct {
 	backend     string
 	lbAlgorithm string
 	lbEndpoints []string
+
+	lbEndpointWeights []float64
+	lbEndpointZones   []string
 }
 
 // A Predicate object represents a parsed, in-memory, route matching predicate
@@ -330,6 +336,17 @@ type Route struct {
 	// load balancing backends.
 	LBEndpoints []string
 
+	// LBEndpointWeights stores the weights of the LB endpoints,
+	// in the same order as LBEndpoints. When nil, the endpoints
+	// have equal weight. E.g. <"http://10.0.0.1:8080": 3, "http://10.0.0.2:8080">.
+	LBEndpointWeights []float64
+
+	// LBEndpointZones stores the availability zones of the LB
+	// endpoints, in the same order as LBEndpoints. When nil, the zones
+	// of the endpoints are unknown. The zone is set after the weight,
+	// if any. E.g. <"http://10.0.0.1:8080": 3: "eu-central-1a", "http://10.0.0.2:8080": "eu-central-1b">.
+	LBEndpointZones []string
+
 	// Name is deprecated and not used.
 	Name string
 
@@ -413,6 +430,16 @@ func (r *Route) Copy() *Route {
 		copy(c.LBEndpoints, r.LBEndpoints)
 	}
 
+	if len(r.LBEndpointWeights) > 0 {
+		c.LBEndpointWeights = make([]float64, len(r.LBEndpointWeights))
+		copy(c.LBEndpointWeights, r.LBEndpointWeights)
+	}
+
+	if len(r.LBEndpointZones) > 0 {
+		c.LBEndpointZones = make([]string, len(r.LBEndpointZones))
+		copy(c.LBEndpointZones, r.LBEndpointZones)
+	}
+
 	return &c
 }
 
@@ -572,6 +599,25 @@ func newRouteDefinition(r *parsedRoute) (*Route, error) {
 	rd.LBAlgorithm = r.lbAlgorithm
 	rd.LBEndpoints = r.lbEndpoints
 
+	// equal weights are not stored
+	for _, w := range r.lbEndpointWeights {
+		if w <= 0 {
+			return nil, errInvalidLBEndpointWeight
+		}
+
+		if w != 1 {
+			rd.LBEndpointWeights = r.lbEndpointWeights
+		}
+	}
+
+	// empty zones are not stored
+	for _, z := range r.lbEndpointZones {
+		if z != "" {
+			rd.LBEndpointZones = r.lbEndpointZones
+			break
+		}
+	}
+
 	switch {
 	case r.shunt:
 		rd.BackendType = ShuntBackend
@@ -11,10 +11,12 @@ type jsonNameArgs struct {
 }
 
 type jsonBackend struct {
-	Type      string   `json:"type"`
-	Address   string   `json:"address,omitempty"`
-	Algorithm string   `json:"algorithm,omitempty"`
-	Endpoints []string `json:"endpoints,omitempty"`
+	Type      string    `json:"type"`
+	Address   string    `json:"address,omitempty"`
+	Algorithm string    `json:"algorithm,omitempty"`
+	Endpoints []string  `json:"endpoints,omitempty"`
+	Weights   []float64 `json:"endpointWeights,omitempty"`
+	Zones     []string  `json:"zones,omitempty"`
 }
 
 type jsonRoute struct {
@@ -38,6 +40,8 @@ func newJSONRoute(r *Route) *jsonRoute {
 			Address:   cr.Backend,
 			Algorithm: cr.LBAlgorithm,
 			Endpoints: cr.LBEndpoints,
+			Weights:   cr.LBEndpointWeights,
+			Zones:     cr.LBEndpointZones,
 		}
 	}
 
@@ -98,6 +102,16 @@ func (r *Route) UnmarshalJSON(b []byte) error {
 		if len(r.LBEndpoints) == 0 {
 			r.LBEndpoints = nil
 		}
+
+		r.LBEndpointWeights = jr.Backend.Weights
+		if len(r.LBEndpointWeights) == 0 {
+			r.LBEndpointWeights = nil
+		}
+
+		r.LBEndpointZones = jr.Backend.Zones
+		if len(r.LBEndpointZones) == 0 {
+			r.LBEndpointZones = nil
+		}
 	}
 
 	r.Filters = jr.Filters
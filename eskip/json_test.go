@@ -91,6 +91,16 @@ func TestMarshalUnmarshalJSON(t *testing.T) {
 			[]*Route{{Id: "beef", BackendType: LBBackend, LBAlgorithm: "yolo", LBEndpoints: []string{"localhost"}}},
 			`[{"id":"beef","backend":{"type":"lb","algorithm":"yolo","endpoints":["localhost"]}}]`,
 		},
+		{
+			"lb backend with endpoint weights",
+			[]*Route{{Id: "beef", BackendType: LBBackend, LBAlgorithm: "yolo", LBEndpoints: []string{"localhost", "127.0.0.1"}, LBEndpointWeights: []float64{3, 1}}},
+			`[{"id":"beef","backend":{"type":"lb","algorithm":"yolo","endpoints":["127.0.0.1","localhost"],"endpointWeights":[1,3]}}]`,
+		},
+		{
+			"lb backend with endpoint zones",
+			[]*Route{{Id: "beef", BackendType: LBBackend, LBAlgorithm: "yolo", LBEndpoints: []string{"localhost", "127.0.0.1"}, LBEndpointZones: []string{"zone-a", "zone-b"}}},
+			`[{"id":"beef","backend":{"type":"lb","algorithm":"yolo","endpoints":["127.0.0.1","localhost"],"zones":["zone-b","zone-a"]}}]`,
+		},
 		{
 			"shunt backend",
 			[]*Route{{Id: "shunty", BackendType: ShuntBackend}},
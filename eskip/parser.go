@@ -20,27 +20,29 @@ func convertNumber(s string) float64 {
 
 //line parser.y:31
 type eskipSymType struct {
-	yys         int
-	token       string
-	route       *parsedRoute
-	routes      []*parsedRoute
-	matchers    []*matcher
-	matcher     *matcher
-	filter      *Filter
-	filters     []*Filter
-	args        []interface{}
-	arg         interface{}
-	backend     string
-	shunt       bool
-	loopback    bool
-	dynamic     bool
-	lbBackend   bool
-	numval      float64
-	stringval   string
-	regexpval   string
-	stringvals  []string
-	lbAlgorithm string
-	lbEndpoints []string
+	yys               int
+	token             string
+	route             *parsedRoute
+	routes            []*parsedRoute
+	matchers          []*matcher
+	matcher           *matcher
+	filter            *Filter
+	filters           []*Filter
+	args              []interface{}
+	arg               interface{}
+	backend           string
+	shunt             bool
+	loopback          bool
+	dynamic           bool
+	lbBackend         bool
+	numval            float64
+	stringval         string
+	regexpval         string
+	lbAlgorithm       string
+	lbEndpoints       []string
+	lbEndpointWeights []float64
+	lbEndpointZone    string
+	lbEndpointZones   []string
 }
 
 const and = 57346
@@ -90,7 +92,7 @@ const eskipEofCode = 1
 const eskipErrCode = 2
 const eskipInitialStackSize = 16
 
-//line parser.y:287
+//line parser.y:336
 
 //line yacctab:1
 var eskipExca = [...]int{
@@ -101,62 +103,68 @@ var eskipExca = [...]int{
 
 const eskipPrivate = 57344
 
-const eskipLast = 62
+const eskipLast = 71
 
 var eskipAct = [...]int{
-	34, 40, 32, 31, 24, 17, 20, 21, 22, 25,
-	27, 26, 19, 48, 36, 9, 37, 25, 41, 9,
-	16, 25, 25, 3, 10, 7, 14, 42, 29, 43,
-	4, 55, 8, 45, 44, 49, 45, 30, 28, 19,
-	50, 15, 13, 47, 46, 38, 23, 51, 52, 39,
-	53, 42, 54, 12, 35, 11, 33, 18, 5, 6,
-	2, 1,
+	34, 33, 32, 40, 24, 42, 17, 49, 25, 41,
+	16, 9, 19, 31, 20, 21, 22, 25, 27, 26,
+	9, 36, 36, 37, 29, 25, 3, 43, 25, 25,
+	10, 7, 14, 8, 44, 4, 59, 50, 46, 19,
+	30, 51, 60, 48, 28, 47, 15, 52, 45, 54,
+	46, 43, 43, 58, 57, 56, 55, 13, 53, 38,
+	12, 61, 11, 23, 39, 35, 18, 5, 6, 2,
+	1,
 }
 
 var eskipPact = [...]int{
-	14, -1000, 11, -1000, -1000, 49, 34, -1000, 15, -1000,
-	2, -8, 10, 10, 4, -1000, -1000, -1000, 39, -1000,
-	-1000, -1000, -1000, -1000, -1000, -1000, 0, 18, -1000, 15,
-	-1000, 27, -1000, -1000, -1000, -1000, -1000, -1000, -8, -7,
-	26, 31, -1000, 4, -1000, 4, -1000, -1000, -1000, 5,
-	5, 24, -1000, -1000, 26, -1000,
+	15, -1000, 17, -1000, -1000, 56, 49, -1000, 21, -1000,
+	-8, 0, 6, 6, 11, -1000, -1000, -1000, 53, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -9, 23, -1000, 21,
+	-1000, 41, -1000, -1000, -1000, -1000, -1000, -1000, 0, -13,
+	28, 32, -1000, 39, 11, -1000, 11, -1000, -1000, -1000,
+	8, 8, 12, 29, -1000, -1000, 28, 34, -1000, -1000,
+	8, -1000,
 }
 
 var eskipPgo = [...]int{
-	0, 61, 60, 23, 30, 59, 58, 5, 57, 25,
-	3, 4, 2, 56, 0, 54, 1, 49, 46,
+	0, 70, 69, 26, 35, 68, 67, 6, 66, 31,
+	13, 4, 2, 1, 0, 65, 5, 3, 64, 63,
 }
 
 var eskipR1 = [...]int{
 	0, 1, 1, 2, 2, 2, 2, 4, 5, 3,
 	3, 6, 6, 9, 9, 8, 8, 11, 10, 10,
-	10, 12, 12, 12, 16, 16, 17, 17, 18, 7,
-	7, 7, 7, 7, 13, 14, 15,
+	10, 12, 12, 12, 16, 16, 16, 16, 17, 17,
+	18, 18, 19, 7, 7, 7, 7, 7, 13, 14,
+	15,
 }
 
 var eskipR2 = [...]int{
 	0, 1, 1, 0, 1, 3, 2, 3, 1, 3,
 	5, 1, 3, 1, 4, 1, 3, 4, 0, 1,
-	3, 1, 1, 1, 1, 3, 1, 3, 3, 1,
-	1, 1, 1, 1, 1, 1, 1,
+	3, 1, 1, 1, 1, 3, 3, 5, 1, 3,
+	1, 3, 3, 1, 1, 1, 1, 1, 1, 1,
+	1,
 }
 
 var eskipChk = [...]int{
 	-1000, -1, -2, -3, -4, -6, -5, -9, 18, 5,
 	13, 6, 4, 8, 11, -4, 18, -7, -8, -14,
-	14, 15, 16, -18, -11, 17, 19, 18, -9, 18,
-	-3, -10, -12, -13, -14, -15, 10, 12, 6, -17,
-	-16, 18, -14, 11, 7, 9, -7, -11, 20, 9,
-	9, -10, -12, -14, -16, 7,
+	14, 15, 16, -19, -11, 17, 19, 18, -9, 18,
+	-3, -10, -12, -13, -14, -15, 10, 12, 6, -18,
+	-17, 18, -16, -14, 11, 7, 9, -7, -11, 20,
+	9, 9, 8, -10, -12, -16, -17, -13, -14, 7,
+	8, -14,
 }
 
 var eskipDef = [...]int{
 	3, -2, 1, 2, 4, 0, 0, 11, 8, 13,
-	6, 0, 0, 0, 18, 5, 8, 9, 0, 29,
-	30, 31, 32, 33, 15, 35, 0, 0, 12, 0,
-	7, 0, 19, 21, 22, 23, 34, 36, 0, 0,
-	26, 0, 24, 18, 14, 0, 10, 16, 28, 0,
-	0, 0, 20, 25, 27, 17,
+	6, 0, 0, 0, 18, 5, 8, 9, 0, 33,
+	34, 35, 36, 37, 15, 39, 0, 0, 12, 0,
+	7, 0, 19, 21, 22, 23, 38, 40, 0, 0,
+	30, 0, 28, 24, 18, 14, 0, 10, 16, 32,
+	0, 0, 0, 0, 20, 29, 31, 25, 26, 17,
+	0, 27,
 }
 
 var eskipTok1 = [...]int{
@@ -511,129 +519,137 @@ eskipdefault:
 
 	case 1:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:75
+//line parser.y:77
 		{
 			eskipVAL.routes = eskipDollar[1].routes
 			eskiplex.(*eskipLex).routes = eskipVAL.routes
 		}
 	case 2:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:80
+//line parser.y:82
 		{
 			eskipVAL.routes = []*parsedRoute{eskipDollar[1].route}
 			eskiplex.(*eskipLex).routes = eskipVAL.routes
 		}
 	case 4:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:87
+//line parser.y:89
 		{
 			eskipVAL.routes = []*parsedRoute{eskipDollar[1].route}
 		}
 	case 5:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:91
+//line parser.y:93
 		{
 			eskipVAL.routes = eskipDollar[1].routes
 			eskipVAL.routes = append(eskipVAL.routes, eskipDollar[3].route)
 		}
 	case 6:
 		eskipDollar = eskipS[eskippt-2 : eskippt+1]
-//line parser.y:96
+//line parser.y:98
 		{
 			eskipVAL.routes = eskipDollar[1].routes
 		}
 	case 7:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:101
+//line parser.y:103
 		{
 			eskipVAL.route = eskipDollar[3].route
 			eskipVAL.route.id = eskipDollar[1].token
 		}
 	case 8:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:107
+//line parser.y:109
 		{
 			eskipVAL.token = eskipDollar[1].token
 			eskiplex.(*eskipLex).lastRouteID = eskipDollar[1].token
 		}
 	case 9:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:113
+//line parser.y:115
 		{
 			eskipVAL.route = &parsedRoute{
-				matchers:    eskipDollar[1].matchers,
-				backend:     eskipDollar[3].backend,
-				shunt:       eskipDollar[3].shunt,
-				loopback:    eskipDollar[3].loopback,
-				dynamic:     eskipDollar[3].dynamic,
-				lbBackend:   eskipDollar[3].lbBackend,
-				lbAlgorithm: eskipDollar[3].lbAlgorithm,
-				lbEndpoints: eskipDollar[3].lbEndpoints,
+				matchers:          eskipDollar[1].matchers,
+				backend:           eskipDollar[3].backend,
+				shunt:             eskipDollar[3].shunt,
+				loopback:          eskipDollar[3].loopback,
+				dynamic:           eskipDollar[3].dynamic,
+				lbBackend:         eskipDollar[3].lbBackend,
+				lbAlgorithm:       eskipDollar[3].lbAlgorithm,
+				lbEndpoints:       eskipDollar[3].lbEndpoints,
+				lbEndpointWeights: eskipDollar[3].lbEndpointWeights,
+				lbEndpointZones:   eskipDollar[3].lbEndpointZones,
 			}
 			eskipDollar[1].matchers = nil
 			eskipDollar[3].lbEndpoints = nil
+			eskipDollar[3].lbEndpointWeights = nil
+			eskipDollar[3].lbEndpointZones = nil
 		}
 	case 10:
 		eskipDollar = eskipS[eskippt-5 : eskippt+1]
-//line parser.y:128
+//line parser.y:134
 		{
 			eskipVAL.route = &parsedRoute{
-				matchers:    eskipDollar[1].matchers,
-				filters:     eskipDollar[3].filters,
-				backend:     eskipDollar[5].backend,
-				shunt:       eskipDollar[5].shunt,
-				loopback:    eskipDollar[5].loopback,
-				dynamic:     eskipDollar[5].dynamic,
-				lbBackend:   eskipDollar[5].lbBackend,
-				lbAlgorithm: eskipDollar[5].lbAlgorithm,
-				lbEndpoints: eskipDollar[5].lbEndpoints,
+				matchers:          eskipDollar[1].matchers,
+				filters:           eskipDollar[3].filters,
+				backend:           eskipDollar[5].backend,
+				shunt:             eskipDollar[5].shunt,
+				loopback:          eskipDollar[5].loopback,
+				dynamic:           eskipDollar[5].dynamic,
+				lbBackend:         eskipDollar[5].lbBackend,
+				lbAlgorithm:       eskipDollar[5].lbAlgorithm,
+				lbEndpoints:       eskipDollar[5].lbEndpoints,
+				lbEndpointWeights: eskipDollar[5].lbEndpointWeights,
+				lbEndpointZones:   eskipDollar[5].lbEndpointZones,
 			}
 			eskipDollar[1].matchers = nil
 			eskipDollar[3].filters = nil
 			eskipDollar[5].lbEndpoints = nil
+			eskipDollar[5].lbEndpointWeights = nil
+			eskipDollar[5].lbEndpointZones = nil
 		}
 	case 11:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:146
+//line parser.y:156
 		{
 			eskipVAL.matchers = []*matcher{eskipDollar[1].matcher}
 		}
 	case 12:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:150
+//line parser.y:160
 		{
 			eskipVAL.matchers = eskipDollar[1].matchers
 			eskipVAL.matchers = append(eskipVAL.matchers, eskipDollar[3].matcher)
 		}
 	case 13:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:156
+//line parser.y:166
 		{
 			eskipVAL.matcher = &matcher{"*", nil}
 		}
 	case 14:
 		eskipDollar = eskipS[eskippt-4 : eskippt+1]
-//line parser.y:160
+//line parser.y:170
 		{
 			eskipVAL.matcher = &matcher{eskipDollar[1].token, eskipDollar[3].args}
 			eskipDollar[3].args = nil
 		}
 	case 15:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:166
+//line parser.y:176
 		{
 			eskipVAL.filters = []*Filter{eskipDollar[1].filter}
 		}
 	case 16:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:170
+//line parser.y:180
 		{
 			eskipVAL.filters = eskipDollar[1].filters
 			eskipVAL.filters = append(eskipVAL.filters, eskipDollar[3].filter)
 		}
 	case 17:
 		eskipDollar = eskipS[eskippt-4 : eskippt+1]
-//line parser.y:176
+//line parser.y:186
 		{
 			eskipVAL.filter = &Filter{
 				Name: eskipDollar[1].token,
@@ -642,71 +658,115 @@ eskipdefault:
 		}
 	case 19:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:185
+//line parser.y:195
 		{
 			eskipVAL.args = []interface{}{eskipDollar[1].arg}
 		}
 	case 20:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:189
+//line parser.y:199
 		{
 			eskipVAL.args = eskipDollar[1].args
 			eskipVAL.args = append(eskipVAL.args, eskipDollar[3].arg)
 		}
 	case 21:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:195
+//line parser.y:205
 		{
 			eskipVAL.arg = eskipDollar[1].numval
 		}
 	case 22:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:199
+//line parser.y:209
 		{
 			eskipVAL.arg = eskipDollar[1].stringval
 		}
 	case 23:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:203
+//line parser.y:213
 		{
 			eskipVAL.arg = eskipDollar[1].regexpval
 		}
 	case 24:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:208
+//line parser.y:218
 		{
-			eskipVAL.stringvals = []string{eskipDollar[1].stringval}
+			eskipVAL.stringval = eskipDollar[1].stringval
+			eskipVAL.numval = 1
+			eskipVAL.lbEndpointZone = ""
 		}
 	case 25:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:212
+//line parser.y:224
 		{
-			eskipVAL.stringvals = eskipDollar[1].stringvals
-			eskipVAL.stringvals = append(eskipVAL.stringvals, eskipDollar[3].stringval)
+			eskipVAL.stringval = eskipDollar[1].stringval
+			eskipVAL.numval = eskipDollar[3].numval
+			eskipVAL.lbEndpointZone = ""
 		}
 	case 26:
-		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:218
+		eskipDollar = eskipS[eskippt-3 : eskippt+1]
+//line parser.y:230
 		{
-			eskipVAL.lbEndpoints = eskipDollar[1].stringvals
+			eskipVAL.stringval = eskipDollar[1].stringval
+			eskipVAL.numval = 1
+			eskipVAL.lbEndpointZone = eskipDollar[3].stringval
 		}
 	case 27:
+		eskipDollar = eskipS[eskippt-5 : eskippt+1]
+//line parser.y:236
+		{
+			eskipVAL.stringval = eskipDollar[1].stringval
+			eskipVAL.numval = eskipDollar[3].numval
+			eskipVAL.lbEndpointZone = eskipDollar[5].stringval
+		}
+	case 28:
+		eskipDollar = eskipS[eskippt-1 : eskippt+1]
+//line parser.y:243
+		{
+			eskipVAL.lbEndpoints = []string{eskipDollar[1].stringval}
+			eskipVAL.lbEndpointWeights = []float64{eskipDollar[1].numval}
+			eskipVAL.lbEndpointZones = []string{eskipDollar[1].lbEndpointZone}
+		}
+	case 29:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:222
+//line parser.y:249
+		{
+			eskipVAL.lbEndpoints = eskipDollar[1].lbEndpoints
+			eskipVAL.lbEndpoints = append(eskipVAL.lbEndpoints, eskipDollar[3].stringval)
+			eskipVAL.lbEndpointWeights = eskipDollar[1].lbEndpointWeights
+			eskipVAL.lbEndpointWeights = append(eskipVAL.lbEndpointWeights, eskipDollar[3].numval)
+			eskipVAL.lbEndpointZones = eskipDollar[1].lbEndpointZones
+			eskipVAL.lbEndpointZones = append(eskipVAL.lbEndpointZones, eskipDollar[3].lbEndpointZone)
+		}
+	case 30:
+		eskipDollar = eskipS[eskippt-1 : eskippt+1]
+//line parser.y:259
+		{
+			eskipVAL.lbEndpoints = eskipDollar[1].lbEndpoints
+			eskipVAL.lbEndpointWeights = eskipDollar[1].lbEndpointWeights
+			eskipVAL.lbEndpointZones = eskipDollar[1].lbEndpointZones
+		}
+	case 31:
+		eskipDollar = eskipS[eskippt-3 : eskippt+1]
+//line parser.y:265
 		{
 			eskipVAL.lbAlgorithm = eskipDollar[1].token
-			eskipVAL.lbEndpoints = eskipDollar[3].stringvals
+			eskipVAL.lbEndpoints = eskipDollar[3].lbEndpoints
+			eskipVAL.lbEndpointWeights = eskipDollar[3].lbEndpointWeights
+			eskipVAL.lbEndpointZones = eskipDollar[3].lbEndpointZones
 		}
-	case 28:
+	case 32:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:228
+//line parser.y:273
 		{
 			eskipVAL.lbAlgorithm = eskipDollar[2].lbAlgorithm
 			eskipVAL.lbEndpoints = eskipDollar[2].lbEndpoints
+			eskipVAL.lbEndpointWeights = eskipDollar[2].lbEndpointWeights
+			eskipVAL.lbEndpointZones = eskipDollar[2].lbEndpointZones
 		}
-	case 29:
+	case 33:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:234
+//line parser.y:281
 		{
 			eskipVAL.backend = eskipDollar[1].stringval
 			eskipVAL.shunt = false
@@ -714,36 +774,36 @@ eskipdefault:
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 30:
+	case 34:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:242
+//line parser.y:289
 		{
 			eskipVAL.shunt = true
 			eskipVAL.loopback = false
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 31:
+	case 35:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:249
+//line parser.y:296
 		{
 			eskipVAL.shunt = false
 			eskipVAL.loopback = true
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 32:
+	case 36:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:256
+//line parser.y:303
 		{
 			eskipVAL.shunt = false
 			eskipVAL.loopback = false
 			eskipVAL.dynamic = true
 			eskipVAL.lbBackend = false
 		}
-	case 33:
+	case 37:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:263
+//line parser.y:310
 		{
 			eskipVAL.shunt = false
 			eskipVAL.loopback = false
@@ -751,22 +811,24 @@ eskipdefault:
 			eskipVAL.lbBackend = true
 			eskipVAL.lbAlgorithm = eskipDollar[1].lbAlgorithm
 			eskipVAL.lbEndpoints = eskipDollar[1].lbEndpoints
+			eskipVAL.lbEndpointWeights = eskipDollar[1].lbEndpointWeights
+			eskipVAL.lbEndpointZones = eskipDollar[1].lbEndpointZones
 		}
-	case 34:
+	case 38:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:273
+//line parser.y:322
 		{
 			eskipVAL.numval = convertNumber(eskipDollar[1].token)
 		}
-	case 35:
+	case 39:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:278
+//line parser.y:327
 		{
 			eskipVAL.stringval = eskipDollar[1].token
 		}
-	case 36:
+	case 40:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:283
+//line parser.y:332
 		{
 			eskipVAL.regexpval = eskipDollar[1].token
 		}
@@ -46,9 +46,11 @@ func convertNumber(s string) float64 {
 	numval float64
 	stringval string
 	regexpval string
-	stringvals []string
 	lbAlgorithm string
 	lbEndpoints []string
+	lbEndpointWeights []float64
+	lbEndpointZone string
+	lbEndpointZones []string
 }
 
 %token and
@@ -120,9 +122,13 @@ route:
 			lbBackend: $3.lbBackend,
 			lbAlgorithm: $3.lbAlgorithm,
 			lbEndpoints: $3.lbEndpoints,
+			lbEndpointWeights: $3.lbEndpointWeights,
+			lbEndpointZones: $3.lbEndpointZones,
 		}
 		$1.matchers = nil
 		$3.lbEndpoints = nil
+		$3.lbEndpointWeights = nil
+		$3.lbEndpointZones = nil
 	}
 	|
 	frontend arrow filters arrow backend {
@@ -136,10 +142,14 @@ route:
 			lbBackend: $5.lbBackend,
 			lbAlgorithm: $5.lbAlgorithm,
 			lbEndpoints: $5.lbEndpoints,
+			lbEndpointWeights: $5.lbEndpointWeights,
+			lbEndpointZones: $5.lbEndpointZones,
 		}
 		$1.matchers = nil
 		$3.filters = nil
 		$5.lbEndpoints = nil
+		$5.lbEndpointWeights = nil
+		$5.lbEndpointZones = nil
 	}
 
 frontend:
@@ -204,30 +214,67 @@ arg:
 		$$.arg = $1.regexpval
 	}
 
-stringvals:
+lbendpoint:
 	stringval {
-		$$.stringvals = []string{$1.stringval}
+		$$.stringval = $1.stringval
+		$$.numval = 1
+		$$.lbEndpointZone = ""
 	}
 	|
-	stringvals comma stringval {
-		$$.stringvals = $1.stringvals
-		$$.stringvals = append($$.stringvals, $3.stringval)
+	stringval colon numval {
+		$$.stringval = $1.stringval
+		$$.numval = $3.numval
+		$$.lbEndpointZone = ""
+	}
+	|
+	stringval colon stringval {
+		$$.stringval = $1.stringval
+		$$.numval = 1
+		$$.lbEndpointZone = $3.stringval
+	}
+	|
+	stringval colon numval colon stringval {
+		$$.stringval = $1.stringval
+		$$.numval = $3.numval
+		$$.lbEndpointZone = $5.stringval
+	}
+
+lbendpoints:
+	lbendpoint {
+		$$.lbEndpoints = []string{$1.stringval}
+		$$.lbEndpointWeights = []float64{$1.numval}
+		$$.lbEndpointZones = []string{$1.lbEndpointZone}
+	}
+	|
+	lbendpoints comma lbendpoint {
+		$$.lbEndpoints = $1.lbEndpoints
+		$$.lbEndpoints = append($$.lbEndpoints, $3.stringval)
+		$$.lbEndpointWeights = $1.lbEndpointWeights
+		$$.lbEndpointWeights = append($$.lbEndpointWeights, $3.numval)
+		$$.lbEndpointZones = $1.lbEndpointZones
+		$$.lbEndpointZones = append($$.lbEndpointZones, $3.lbEndpointZone)
 	}
 
 lbbackendbody:
-	stringvals {
-		$$.lbEndpoints = $1.stringvals
+	lbendpoints {
+		$$.lbEndpoints = $1.lbEndpoints
+		$$.lbEndpointWeights = $1.lbEndpointWeights
+		$$.lbEndpointZones = $1.lbEndpointZones
 	}
 	|
-	symbol comma stringvals {
+	symbol comma lbendpoints {
 		$$.lbAlgorithm = $1.token
-		$$.lbEndpoints = $3.stringvals
+		$$.lbEndpoints = $3.lbEndpoints
+		$$.lbEndpointWeights = $3.lbEndpointWeights
+		$$.lbEndpointZones = $3.lbEndpointZones
 	}
 
 lbbackend:
 	openarrow lbbackendbody closearrow {
 		$$.lbAlgorithm = $2.lbAlgorithm
 		$$.lbEndpoints = $2.lbEndpoints
+		$$.lbEndpointWeights = $2.lbEndpointWeights
+		$$.lbEndpointZones = $2.lbEndpointZones
 	}
 
 backend:
@@ -267,6 +314,8 @@ backend:
 		$$.lbBackend = true
 		$$.lbAlgorithm = $1.lbAlgorithm
 		$$.lbEndpoints = $1.lbEndpoints
+		$$.lbEndpointWeights = $1.lbEndpointWeights
+		$$.lbEndpointZones = $1.lbEndpointZones
 	}
 
 numval:
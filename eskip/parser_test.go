@@ -309,6 +309,61 @@ func TestLBBackend(t *testing.T) {
 				"https://example3.org",
 			},
 		}},
+	}, {
+		title: "endpoint weights",
+		code: `* -> <algFoo,
+		             "https://example1.org": 3,
+		             "https://example2.org",
+		             "https://example3.org": 0.5>`,
+		expectedResult: []*Route{{
+			BackendType: LBBackend,
+			LBAlgorithm: "algFoo",
+			LBEndpoints: []string{
+				"https://example1.org",
+				"https://example2.org",
+				"https://example3.org",
+			},
+			LBEndpointWeights: []float64{3, 1, 0.5},
+		}},
+	}, {
+		title: "default endpoint weights",
+		code:  `* -> <"https://example1.org": 1, "https://example2.org": 1>`,
+		expectedResult: []*Route{{
+			BackendType: LBBackend,
+			LBEndpoints: []string{"https://example1.org", "https://example2.org"},
+		}},
+	}, {
+		title: "zero endpoint weight",
+		code:  `* -> <"https://example1.org": 0, "https://example2.org">`,
+		fail:  true,
+	}, {
+		title: "negative endpoint weight",
+		code:  `* -> <"https://example1.org": -1, "https://example2.org">`,
+		fail:  true,
+	}, {
+		title: "endpoint zones",
+		code: `* -> <algFoo,
+		             "https://example1.org": 3: "zone-a",
+		             "https://example2.org": "zone-b",
+		             "https://example3.org">`,
+		expectedResult: []*Route{{
+			BackendType: LBBackend,
+			LBAlgorithm: "algFoo",
+			LBEndpoints: []string{
+				"https://example1.org",
+				"https://example2.org",
+				"https://example3.org",
+			},
+			LBEndpointWeights: []float64{3, 1, 1},
+			LBEndpointZones:   []string{"zone-a", "zone-b", ""},
+		}},
+	}, {
+		title: "empty endpoint zones",
+		code:  `* -> <"https://example1.org": "", "https://example2.org">`,
+		expectedResult: []*Route{{
+			BackendType: LBBackend,
+			LBEndpoints: []string{"https://example1.org", "https://example2.org"},
+		}},
 	}} {
 		t.Run(test.title, func(t *testing.T) {
 			r, err := Parse(test.code)
@@ -158,6 +158,14 @@ func lbBackendString(r *Route) string {
 		b.WriteByte('"')
 		b.WriteString(ep)
 		b.WriteByte('"')
+		if i < len(r.LBEndpointWeights) && r.LBEndpointWeights[i] != 1 {
+			b.WriteString(": ")
+			b.WriteString(argsString([]interface{}{r.LBEndpointWeights[i]}))
+		}
+		if i < len(r.LBEndpointZones) && r.LBEndpointZones[i] != "" {
+			b.WriteString(": ")
+			b.WriteString(argsString([]interface{}{r.LBEndpointZones[i]}))
+		}
 	}
 	b.WriteByte('>')
 	return b.String()
@@ -120,6 +120,12 @@ func TestRouteString(t *testing.T) {
 	}, {
 		&Route{Method: "GET", LBAlgorithm: "random", BackendType: LBBackend, LBEndpoints: []string{"http://127.0.0.1:9997", "http://127.0.0.1:9998"}},
 		`Method("GET") -> <random, "http://127.0.0.1:9997", "http://127.0.0.1:9998">`,
+	}, {
+		&Route{Method: "GET", LBAlgorithm: "roundRobin", BackendType: LBBackend, LBEndpoints: []string{"http://127.0.0.1:9997", "http://127.0.0.1:9998"}, LBEndpointWeights: []float64{3, 1}},
+		`Method("GET") -> <roundRobin, "http://127.0.0.1:9997": 3, "http://127.0.0.1:9998">`,
+	}, {
+		&Route{Method: "GET", LBAlgorithm: "roundRobin", BackendType: LBBackend, LBEndpoints: []string{"http://127.0.0.1:9997", "http://127.0.0.1:9998"}, LBEndpointWeights: []float64{3, 1}, LBEndpointZones: []string{"zone-a", "zone-b"}},
+		`Method("GET") -> <roundRobin, "http://127.0.0.1:9997": 3: "zone-a", "http://127.0.0.1:9998": "zone-b">`,
 	}, {
 		// test slash escaping
 		&Route{Path: `/`, PathRegexps: []string{`/`}, Filters: []*Filter{{"afilter", []interface{}{`/`}}}, BackendType: ShuntBackend},
@@ -44,6 +44,9 @@ const (
 	invalidToken       rejectReason = "invalid-token"
 	invalidScope       rejectReason = "invalid-scope"
 	invalidClaim       rejectReason = "invalid-claim"
+	invalidIssuer      rejectReason = "invalid-issuer"
+	invalidAudience    rejectReason = "invalid-audience"
+	policyDenied       rejectReason = "policy-denied"
 	invalidFilter      rejectReason = "invalid-filter"
 	invalidAccess      rejectReason = "invalid-access"
 )
@@ -145,6 +148,14 @@ func authorized(ctx filters.FilterContext, username string) {
 	ctx.StateBag()[logfilter.AuthUserKey] = username
 }
 
+// storeTokenContainer saves the validated token for the chained
+// filters. The claims are also stored separately, so that the filters
+// outside of this package, e.g. the ratelimits, can use them.
+func storeTokenContainer(ctx filters.FilterContext, c tokenContainer) {
+	ctx.StateBag()[oidcClaimsCacheKey] = c
+	ctx.StateBag()[filters.ValidatedClaimsKey] = c.Claims
+}
+
 func getStrings(args []interface{}) ([]string, error) {
 	s := make([]string, len(args))
 	var ok bool
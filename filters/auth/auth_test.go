@@ -2,6 +2,9 @@ package auth
 
 import (
 	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
 )
 
 const (
@@ -99,3 +102,17 @@ func Test_all(t *testing.T) {
 
 	}
 }
+
+func TestStoreTokenContainer(t *testing.T) {
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	claims := map[string]interface{}{"sub": "foo"}
+	storeTokenContainer(ctx, tokenContainer{Subject: "foo", Claims: claims})
+
+	if c, ok := ctx.FStateBag[oidcClaimsCacheKey].(tokenContainer); !ok || c.Subject != "foo" {
+		t.Errorf("failed to store the token container, got: %v", ctx.FStateBag[oidcClaimsCacheKey])
+	}
+
+	if c, ok := ctx.FStateBag[filters.ValidatedClaimsKey].(map[string]interface{}); !ok || c["sub"] != "foo" {
+		t.Errorf("failed to store the claims, got: %v", ctx.FStateBag[filters.ValidatedClaimsKey])
+	}
+}
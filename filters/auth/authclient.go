@@ -26,8 +26,9 @@ const (
 )
 
 type authClient struct {
-	url *url.URL
-	cli *net.Client
+	url   *url.URL
+	cli   *net.Client
+	cache *tokenCache
 }
 
 func newAuthClient(baseURL, spanName string, timeout time.Duration, maxIdleConns int, tracer opentracing.Tracer) (*authClient, error) {
@@ -63,7 +64,23 @@ func bindContext(ctx filters.FilterContext, req *http.Request) *http.Request {
 	return req.WithContext(ctx.Request().Context())
 }
 
+// only the rejections of the token are cached, and not the errors of the
+// token validation services
+func invalidTokenStatus(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500 && statusCode != http.StatusTooManyRequests
+}
+
 func (ac *authClient) getTokenintrospect(token string, ctx filters.FilterContext) (tokenIntrospectionInfo, error) {
+	if ac.cache != nil {
+		if info, ok := ac.cache.get(token); ok {
+			if info == nil {
+				return nil, errInvalidToken
+			}
+
+			return info, nil
+		}
+	}
+
 	body := url.Values{}
 	body.Add(tokenKey, token)
 	req, err := http.NewRequest("POST", ac.url.String(), strings.NewReader(body.Encode()))
@@ -87,6 +104,10 @@ func (ac *authClient) getTokenintrospect(token string, ctx filters.FilterContext
 
 	if rsp.StatusCode != 200 {
 		io.Copy(io.Discard, rsp.Body)
+		if ac.cache != nil && invalidTokenStatus(rsp.StatusCode) {
+			ac.cache.set(token, nil, time.Time{})
+		}
+
 		return nil, errInvalidToken
 	}
 
@@ -96,11 +117,24 @@ func (ac *authClient) getTokenintrospect(token string, ctx filters.FilterContext
 	}
 	info := make(tokenIntrospectionInfo)
 	err = json.Unmarshal(buf, &info)
+	if err == nil && ac.cache != nil {
+		ac.cache.set(token, info, expiresAt(info))
+	}
+
 	return info, err
 }
 
 func (ac *authClient) getTokeninfo(token string, ctx filters.FilterContext) (map[string]interface{}, error) {
 	var doc map[string]interface{}
+	if ac.cache != nil {
+		if info, ok := ac.cache.get(token); ok {
+			if info == nil {
+				return doc, errInvalidToken
+			}
+
+			return info, nil
+		}
+	}
 
 	req, err := http.NewRequest("GET", ac.url.String(), nil)
 	if err != nil {
@@ -121,11 +155,19 @@ func (ac *authClient) getTokeninfo(token string, ctx filters.FilterContext) (map
 
 	if rsp.StatusCode != 200 {
 		io.Copy(io.Discard, rsp.Body)
+		if ac.cache != nil && invalidTokenStatus(rsp.StatusCode) {
+			ac.cache.set(token, nil, time.Time{})
+		}
+
 		return doc, errInvalidToken
 	}
 
 	d := json.NewDecoder(rsp.Body)
 	err = d.Decode(&doc)
+	if err == nil && doc != nil && ac.cache != nil {
+		ac.cache.set(token, doc, expiresIn(doc, time.Now()))
+	}
+
 	return doc, err
 }
 
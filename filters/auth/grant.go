@@ -188,7 +188,7 @@ func (f *grantFilter) Request(ctx filters.FilterContext) {
 	// Set token in state bag for response Set-Cookie. By piggy-backing
 	// on the OIDC token container, we gain downstream compatibility with
 	// the oidcClaimsQuery filter.
-	ctx.StateBag()[oidcClaimsCacheKey] = tokenContainer
+	storeTokenContainer(ctx, tokenContainer)
 
 	// Set the tokeninfo also in the tokeninfoCacheKey state bag, so we
 	// can reuse e.g. the forwardToken() filter.
@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,7 +25,22 @@ type (
 	}
 
 	jwtValidationFilter struct {
-		jwksUri string
+		jwksUri    string
+		issuers    []string
+		audiences  []string
+		algorithms []string
+		leeway     time.Duration
+		allClaims  []jwtClaim
+		anyClaims  []jwtClaim
+		headers    map[string]string
+	}
+
+	// jwtClaim is a claim required by the filter. Without a value, only
+	// the presence of the claim is checked.
+	jwtClaim struct {
+		name     string
+		value    string
+		hasValue bool
 	}
 )
 
@@ -46,8 +64,21 @@ func (s *jwtValidationSpec) Name() string {
 	return filters.JwtValidationName
 }
 
+// CreateFilter creates a jwtValidation filter. The first argument is
+// the issuer URL, used to discover the JWKS. The optional further
+// arguments configure additional checks in the form of name=value:
+//
+//	jwtValidation("https://issuer.example.org",
+//		"issuer=https://issuer.example.org",
+//		"audience=my-app",
+//		"algorithm=RS256",
+//		"leeway=30s",
+//		"allClaims=email_verified=true",
+//		"anyClaims=groups=admins",
+//		"anyClaims=groups=developers",
+//		"forwardClaim=X-Subject=sub")
 func (s *jwtValidationSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
-	if len(args) != 1 {
+	if len(args) < 1 {
 		return nil, filters.ErrInvalidFilterParameters
 	}
 	sargs, err := getStrings(args)
@@ -57,6 +88,13 @@ func (s *jwtValidationSpec) CreateFilter(args []interface{}) (filters.Filter, er
 
 	issuerURL := sargs[0]
 
+	f := &jwtValidationFilter{}
+	for _, o := range sargs[1:] {
+		if err := f.setOption(o); err != nil {
+			return nil, err
+		}
+	}
+
 	cfg, err := getOpenIDConfig(issuerURL)
 	if err != nil {
 		return nil, err
@@ -67,11 +105,70 @@ func (s *jwtValidationSpec) CreateFilter(args []interface{}) (filters.Filter, er
 		return nil, err
 	}
 
-	f := &jwtValidationFilter{
-		jwksUri: cfg.JwksURI,
+	f.jwksUri = cfg.JwksURI
+	return f, nil
+}
+
+func parseJWTClaim(s string) (jwtClaim, error) {
+	name, value, hasValue := strings.Cut(s, "=")
+	if name == "" {
+		return jwtClaim{}, fmt.Errorf("%w: missing claim name", filters.ErrInvalidFilterParameters)
 	}
 
-	return f, nil
+	return jwtClaim{name: name, value: value, hasValue: hasValue}, nil
+}
+
+func (f *jwtValidationFilter) setOption(o string) error {
+	name, value, found := strings.Cut(o, "=")
+	if !found || value == "" {
+		return fmt.Errorf("%w: invalid jwtValidation option %q", filters.ErrInvalidFilterParameters, o)
+	}
+
+	switch name {
+	case "issuer":
+		f.issuers = append(f.issuers, value)
+	case "audience":
+		f.audiences = append(f.audiences, value)
+	case "algorithm":
+		if jwt.GetSigningMethod(value) == nil {
+			return fmt.Errorf("%w: unsupported algorithm %q", filters.ErrInvalidFilterParameters, value)
+		}
+
+		f.algorithms = append(f.algorithms, value)
+	case "leeway":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("%w: invalid leeway %q", filters.ErrInvalidFilterParameters, value)
+		}
+
+		f.leeway = d
+	case "allClaims", "anyClaims":
+		c, err := parseJWTClaim(value)
+		if err != nil {
+			return err
+		}
+
+		if name == "allClaims" {
+			f.allClaims = append(f.allClaims, c)
+		} else {
+			f.anyClaims = append(f.anyClaims, c)
+		}
+	case "forwardClaim":
+		header, claim, found := strings.Cut(value, "=")
+		if !found || header == "" || claim == "" {
+			return fmt.Errorf("%w: invalid forwarded claim %q", filters.ErrInvalidFilterParameters, value)
+		}
+
+		if f.headers == nil {
+			f.headers = make(map[string]string)
+		}
+
+		f.headers[header] = claim
+	default:
+		return fmt.Errorf("%w: unknown jwtValidation option %q", filters.ErrInvalidFilterParameters, name)
+	}
+
+	return nil
 }
 
 func hasKeyFunction(url string) bool {
@@ -132,13 +229,20 @@ func (f *jwtValidationFilter) Request(ctx filters.FilterContext) {
 			return
 		}
 
-		claims, err := parseToken(token, f.jwksUri)
+		claims, err := parseToken(token, f.jwksUri, f.algorithms)
 		if err != nil {
 			log.Errorf("Error while parsing jwt token : %v.", err)
 			unauthorized(ctx, "", invalidToken, "", "")
 			return
 		}
 
+		if err := validateTime(claims, time.Now(), f.leeway); err != nil {
+			log.Debugf("Invalid jwt token: %v.", err)
+			sub, _ := claims["sub"].(string)
+			unauthorized(ctx, sub, invalidToken, "", "")
+			return
+		}
+
 		info.Claims = claims
 	} else {
 		info = infoTemp.(tokenContainer)
@@ -150,18 +254,170 @@ func (f *jwtValidationFilter) Request(ctx filters.FilterContext) {
 		return
 	}
 
+	if len(f.issuers) > 0 && !anyClaimValue(info.Claims["iss"], f.issuers) {
+		unauthorized(ctx, sub, invalidIssuer, "", "")
+		return
+	}
+
+	if len(f.audiences) > 0 && !anyClaimValue(info.Claims["aud"], f.audiences) {
+		unauthorized(ctx, sub, invalidAudience, "", "")
+		return
+	}
+
+	if !f.validateClaims(info.Claims) {
+		forbidden(ctx, sub, invalidClaim, "")
+		return
+	}
+
 	authorized(ctx, sub)
+	f.forwardClaims(r.Header, info.Claims)
 
-	ctx.StateBag()[oidcClaimsCacheKey] = info
+	storeTokenContainer(ctx, info)
 }
 
 func (f *jwtValidationFilter) Response(filters.FilterContext) {}
 
-func parseToken(token string, jwksUri string) (map[string]interface{}, error) {
+func (f *jwtValidationFilter) validateClaims(claims map[string]interface{}) bool {
+	for _, c := range f.allClaims {
+		if !c.match(claims) {
+			return false
+		}
+	}
+
+	for _, c := range f.anyClaims {
+		if c.match(claims) {
+			return true
+		}
+	}
+
+	return len(f.anyClaims) == 0
+}
+
+// the forwarded headers are always removed from the incoming request,
+// so that the clients cannot set them
+func (f *jwtValidationFilter) forwardClaims(h http.Header, claims map[string]interface{}) {
+	for header, claim := range f.headers {
+		h.Del(header)
+
+		v, ok := claims[claim]
+		if !ok {
+			continue
+		}
+
+		if s, ok := v.(string); ok {
+			h.Set(header, s)
+			continue
+		}
+
+		b, err := json.Marshal(v)
+		if err != nil {
+			log.Errorf("Failed to serialize jwt claim %s: %v.", claim, err)
+			continue
+		}
+
+		h.Set(header, string(b))
+	}
+}
+
+// match checks the presence of the claim, or when the claim has a value,
+// that the claim equals to it, or in case of a list claim, contains it.
+func (c jwtClaim) match(claims map[string]interface{}) bool {
+	v, ok := claims[c.name]
+	if !ok {
+		return false
+	}
+
+	return !c.hasValue || anyClaimValue(v, []string{c.value})
+}
+
+func claimValueString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	return fmt.Sprint(v)
+}
+
+func anyClaimValue(claim interface{}, values []string) bool {
+	var claimValues []interface{}
+	if l, ok := claim.([]interface{}); ok {
+		claimValues = l
+	} else if claim != nil {
+		claimValues = []interface{}{claim}
+	}
+
+	for _, cv := range claimValues {
+		for _, v := range values {
+			if claimValueString(cv) == v {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func numericClaim(claims map[string]interface{}, name string) (float64, bool, error) {
+	v, ok := claims[name]
+	if !ok {
+		return 0, false, nil
+	}
+
+	switch n := v.(type) {
+	case float64:
+		return n, true, nil
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil, err
+	default:
+		return 0, false, fmt.Errorf("invalid %s claim", name)
+	}
+}
+
+// validateTime checks the exp, nbf and iat claims, when present, allowing
+// the clock skew of leeway.
+func validateTime(claims map[string]interface{}, now time.Time, leeway time.Duration) error {
+	t := float64(now.Unix())
+	l := leeway.Seconds()
+
+	exp, ok, err := numericClaim(claims, "exp")
+	if err != nil {
+		return err
+	} else if ok && t > exp+l {
+		return fmt.Errorf("token is expired")
+	}
+
+	nbf, ok, err := numericClaim(claims, "nbf")
+	if err != nil {
+		return err
+	} else if ok && t+l < nbf {
+		return fmt.Errorf("token is not valid yet")
+	}
+
+	iat, ok, err := numericClaim(claims, "iat")
+	if err != nil {
+		return err
+	} else if ok && t+l < iat {
+		return fmt.Errorf("token used before issued")
+	}
+
+	return nil
+}
+
+// parseToken verifies the signature of the token, and when algorithms
+// are set, that it was signed with one of them. The time based claims are
+// validated separately, to allow a leeway.
+func parseToken(token string, jwksUri string, algorithms []string) (map[string]interface{}, error) {
 	jwks := getKeyFunction(jwksUri)
 
+	var options []jwt.ParserOption
+	options = append(options, jwt.WithoutClaimsValidation())
+	if len(algorithms) > 0 {
+		options = append(options, jwt.WithValidMethods(algorithms))
+	}
+
 	var claims jwt.MapClaims
-	parsedToken, err := jwt.ParseWithClaims(token, &claims, jwks.Keyfunc)
+	parsedToken, err := jwt.NewParser(options...).ParseWithClaims(token, &claims, jwks.Keyfunc)
 	if err != nil {
 		return nil, fmt.Errorf("error while parsing jwt token : %w", err)
 	} else if !parsedToken.Valid {
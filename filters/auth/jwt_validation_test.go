@@ -5,6 +5,7 @@ import (
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -15,6 +16,7 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
 	"github.com/zalando/skipper/net"
 	"github.com/zalando/skipper/proxy/proxytest"
 )
@@ -183,3 +185,180 @@ func TestJWTValidationJwksError(t *testing.T) {
 	}*/
 
 }
+
+func createTokenWithClaims(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	s, err := token.SignedString(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestJWTValidationOptions(t *testing.T) {
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA", "alg":"RS256", "kid": "%s", "n":"%s","e":"AQAB"}]}`,
+			kid, base64.RawURLEncoding.EncodeToString(privateKey.PublicKey.N.Bytes()))
+	}))
+	defer authServer.Close()
+
+	testOidcConfig := getTestOidcConfig()
+	testOidcConfig.JwksURI = authServer.URL + testAuthPath
+	issuerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(testOidcConfig)
+	}))
+	defer issuerServer.Close()
+
+	now := time.Now().Unix()
+	claims := func(extra jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":    "jdoe",
+			"iss":    "https://issuer.example.org",
+			"aud":    []string{"app1", "app2"},
+			"exp":    now + 60,
+			"groups": []string{"dev", "ops"},
+			"email":  "jdoe@example.org",
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	for _, ti := range []struct {
+		msg      string
+		options  []interface{}
+		method   jwt.SigningMethod
+		claims   jwt.MapClaims
+		expected int
+		headers  map[string]string
+	}{{
+		msg:      "no options",
+		claims:   claims(nil),
+		expected: http.StatusOK,
+	}, {
+		msg:      "expired token",
+		claims:   claims(jwt.MapClaims{"exp": now - 10}),
+		expected: http.StatusUnauthorized,
+	}, {
+		msg:      "expired token within leeway",
+		options:  []interface{}{"leeway=30s"},
+		claims:   claims(jwt.MapClaims{"exp": now - 10}),
+		expected: http.StatusOK,
+	}, {
+		msg:      "token not valid yet",
+		claims:   claims(jwt.MapClaims{"nbf": now + 10}),
+		expected: http.StatusUnauthorized,
+	}, {
+		msg:      "token not valid yet within leeway",
+		options:  []interface{}{"leeway=30s"},
+		claims:   claims(jwt.MapClaims{"nbf": now + 10}),
+		expected: http.StatusOK,
+	}, {
+		msg:      "matching issuer",
+		options:  []interface{}{"issuer=https://issuer.example.org"},
+		claims:   claims(nil),
+		expected: http.StatusOK,
+	}, {
+		msg:      "invalid issuer",
+		options:  []interface{}{"issuer=https://other.example.org"},
+		claims:   claims(nil),
+		expected: http.StatusUnauthorized,
+	}, {
+		msg:      "one matching audience",
+		options:  []interface{}{"audience=app3", "audience=app2"},
+		claims:   claims(nil),
+		expected: http.StatusOK,
+	}, {
+		msg:      "invalid audience",
+		options:  []interface{}{"audience=app3"},
+		claims:   claims(nil),
+		expected: http.StatusUnauthorized,
+	}, {
+		msg:      "allowed algorithm",
+		options:  []interface{}{"algorithm=RS256"},
+		claims:   claims(nil),
+		expected: http.StatusOK,
+	}, {
+		msg:      "not allowed algorithm",
+		options:  []interface{}{"algorithm=RS512"},
+		claims:   claims(nil),
+		expected: http.StatusUnauthorized,
+	}, {
+		msg:      "all claims match",
+		options:  []interface{}{"allClaims=groups=ops", "allClaims=email=jdoe@example.org", "allClaims=exp"},
+		claims:   claims(nil),
+		expected: http.StatusOK,
+	}, {
+		msg:      "not all claims match",
+		options:  []interface{}{"allClaims=groups=ops", "allClaims=groups=admin"},
+		claims:   claims(nil),
+		expected: http.StatusForbidden,
+	}, {
+		msg:      "any claim matches",
+		options:  []interface{}{"anyClaims=groups=admin", "anyClaims=groups=dev"},
+		claims:   claims(nil),
+		expected: http.StatusOK,
+	}, {
+		msg:      "no claim matches",
+		options:  []interface{}{"anyClaims=groups=admin", "anyClaims=email_verified"},
+		claims:   claims(nil),
+		expected: http.StatusForbidden,
+	}, {
+		msg:      "forward claims",
+		options:  []interface{}{"forwardClaim=X-Email=email", "forwardClaim=X-Groups=groups", "forwardClaim=X-Missing=missing"},
+		claims:   claims(nil),
+		expected: http.StatusOK,
+		headers:  map[string]string{"X-Email": "jdoe@example.org", "X-Groups": `["dev","ops"]`, "X-Missing": ""},
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			spec := NewJwtValidationWithOptions(TokenintrospectionOptions{})
+			f, err := spec.CreateFilter(append([]interface{}{issuerServer.URL}, ti.options...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, _ := http.NewRequest("GET", "https://www.example.org", nil)
+			req.Header.Set(authHeaderName, authHeaderPrefix+createTokenWithClaims(t, jwt.SigningMethodRS256, ti.claims))
+			req.Header.Set("X-Missing", "spoofed")
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+
+			f.Request(ctx)
+
+			status := http.StatusOK
+			if ctx.FResponse != nil {
+				status = ctx.FResponse.StatusCode
+			}
+
+			if status != ti.expected {
+				t.Fatalf("unexpected status code, expected: %d, got: %d", ti.expected, status)
+			}
+
+			for k, v := range ti.headers {
+				if got := req.Header.Get(k); got != v {
+					t.Errorf("unexpected header %s, expected: %q, got: %q", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestJWTValidationInvalidOptions(t *testing.T) {
+	spec := NewJwtValidationWithOptions(TokenintrospectionOptions{})
+	for _, o := range []string{
+		"audience",
+		"audience=",
+		"algorithm=XY256",
+		"leeway=foo",
+		"allClaims==foo",
+		"forwardClaim=X-Sub",
+		"unknown=foo",
+	} {
+		if _, err := spec.CreateFilter([]interface{}{"https://issuer.example.org", o}); !errors.Is(err, filters.ErrInvalidFilterParameters) {
+			t.Errorf("expected invalid filter parameters for %q, got: %v", o, err)
+		}
+	}
+}
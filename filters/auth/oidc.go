@@ -813,7 +813,7 @@ func (f *tokenOidcFilter) Request(ctx filters.FilterContext) {
 	}
 
 	// saving token info for chained filter
-	ctx.StateBag()[oidcClaimsCacheKey] = container
+	storeTokenContainer(ctx, container)
 
 	// adding upstream headers
 	err = setHeaders(f.upstreamHeaders, ctx, container)
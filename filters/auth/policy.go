@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	logfilter "github.com/zalando/skipper/filters/log"
+	"github.com/zalando/skipper/policy"
+	"github.com/zalando/skipper/routing"
+)
+
+type (
+	policySpec struct {
+		registry *policy.Registry
+	}
+
+	policyFilter struct {
+		registry *policy.Registry
+		source   string
+	}
+)
+
+// NewPolicyAuthorize creates the specification of the policyAuthorize
+// filter, which evaluates the policy bundles of the registry against the
+// requests. The returned Spec also implements routing.PostProcessor,
+// which drops the bundles not used by the routes anymore from the
+// registry.
+func NewPolicyAuthorize(r *policy.Registry) filters.Spec {
+	return &policySpec{registry: r}
+}
+
+func (*policySpec) Name() string {
+	return filters.PolicyAuthorizeName
+}
+
+// CreateFilter creates a policyAuthorize filter. It expects a single
+// argument, the file path or the URL of the policy bundle, allowed by
+// the registry:
+//
+//	policyAuthorize("/etc/skipper/policies/api.yaml")
+func (s *policySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	source, ok := args[0].(string)
+	if !ok || source == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if err := s.registry.Load(source); err != nil {
+		return nil, err
+	}
+
+	return &policyFilter{registry: s.registry, source: source}, nil
+}
+
+// Do implements routing.PostProcessor and drops the bundles, which are
+// not used by any of the routes.
+func (s *policySpec) Do(routes []*routing.Route) []*routing.Route {
+	var sources []string
+	for _, r := range routes {
+		for _, f := range r.Filters {
+			if pf, ok := f.Filter.(*policyFilter); ok {
+				sources = append(sources, pf.source)
+			}
+		}
+	}
+
+	s.registry.Retain(sources)
+	return routes
+}
+
+// listValues passes all the values of the query parameters and the
+// headers to the policies, as lists.
+func listValues(values map[string][]string, lower bool) map[string]interface{} {
+	m := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if len(v) == 0 {
+			continue
+		}
+
+		if lower {
+			k = strings.ToLower(k)
+		}
+
+		m[k] = v
+	}
+
+	return m
+}
+
+func policyInput(ctx filters.FilterContext) map[string]interface{} {
+	r := ctx.Request()
+	input := map[string]interface{}{
+		"request": map[string]interface{}{
+			"method":     r.Method,
+			"host":       r.Host,
+			"path":       r.URL.Path,
+			"remoteAddr": r.RemoteAddr,
+			"query":      listValues(r.URL.Query(), false),
+			"headers":    listValues(r.Header, true),
+		},
+	}
+
+	sb := ctx.StateBag()
+	if c, ok := sb[oidcClaimsCacheKey].(tokenContainer); ok {
+		input["claims"] = c.Claims
+	}
+
+	if info, ok := sb[tokeninfoCacheKey].(map[string]interface{}); ok {
+		input["tokeninfo"] = info
+	}
+
+	if info, ok := sb[tokenintrospectionCacheKey].(tokenIntrospectionInfo); ok {
+		input["introspection"] = map[string]interface{}(info)
+	}
+
+	return input
+}
+
+// Request evaluates the policy bundle, and responds to the denied
+// requests with the response set by the bundle.
+func (f *policyFilter) Request(ctx filters.FilterContext) {
+	b := f.registry.Bundle(f.source)
+	if b == nil {
+		log.Errorf("Policy bundle not found: %s.", f.source)
+		reject(ctx, http.StatusForbidden, "", policyDenied, "", f.source)
+		return
+	}
+
+	d := b.Evaluate(policyInput(ctx))
+	if d.Allow {
+		return
+	}
+
+	log.Debugf("Rejected by policy: %s, rule: %s.", f.source, d.Rule)
+	ctx.StateBag()[logfilter.AuthRejectReasonKey] = string(policyDenied)
+
+	rsp := &http.Response{
+		StatusCode: d.Response.Status,
+		Header:     make(http.Header),
+	}
+
+	for k, v := range d.Response.Headers {
+		rsp.Header.Set(k, v)
+	}
+
+	if d.Response.Body != "" {
+		rsp.Body = io.NopCloser(strings.NewReader(d.Response.Body))
+		rsp.ContentLength = int64(len(d.Response.Body))
+	}
+
+	ctx.Serve(rsp)
+}
+
+func (*policyFilter) Response(filters.FilterContext) {}
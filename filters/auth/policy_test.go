@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/policy"
+	"github.com/zalando/skipper/routing"
+)
+
+const testPolicyBundle = `
+response:
+  status: 401
+  headers:
+    WWW-Authenticate: Bearer
+  body: unauthorized
+rules:
+- name: health
+  effect: allow
+  when:
+  - input: request.path
+    equals: /health
+- name: writers
+  effect: allow
+  when:
+  - input: request.method
+    in: [POST, PUT]
+  - input: tokeninfo.scope
+    contains: write
+- name: admins
+  effect: allow
+  when:
+  - input: claims.groups
+    contains: admins
+  - input: request.query.debug
+    exists: false
+- name: blocked
+  effect: deny
+  response:
+    status: 451
+  when:
+  - input: request.headers.x-country
+    equals: XX
+`
+
+func TestPolicyAuthorize(t *testing.T) {
+	dir := t.TempDir()
+	bundleFile := filepath.Join(dir, "bundle.yaml")
+	if err := os.WriteFile(bundleFile, []byte(testPolicyBundle), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := policy.NewRegistry(policy.Options{BaseDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	spec := NewPolicyAuthorize(r)
+	if _, err := spec.CreateFilter([]interface{}{filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Error("expected error for a missing bundle")
+	}
+
+	if _, err := spec.CreateFilter([]interface{}{"https://policies.example.org/api.yaml"}); err == nil {
+		t.Error("expected error for a not allowed bundle source")
+	}
+
+	f, err := spec.CreateFilter([]interface{}{bundleFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range []struct {
+		msg      string
+		method   string
+		url      string
+		headers  http.Header
+		stateBag map[string]interface{}
+		status   int
+	}{{
+		msg:    "allowed path",
+		method: "GET",
+		url:    "https://www.example.org/health",
+	}, {
+		msg:    "denied by default",
+		method: "GET",
+		url:    "https://www.example.org/api",
+		status: http.StatusUnauthorized,
+	}, {
+		msg:      "allowed by tokeninfo scope",
+		method:   "POST",
+		url:      "https://www.example.org/api",
+		stateBag: map[string]interface{}{tokeninfoCacheKey: map[string]interface{}{"scope": []interface{}{"read", "write"}}},
+	}, {
+		msg:      "allowed by claims",
+		method:   "DELETE",
+		url:      "https://www.example.org/api",
+		stateBag: map[string]interface{}{oidcClaimsCacheKey: tokenContainer{Claims: map[string]interface{}{"groups": []interface{}{"admins"}}}},
+	}, {
+		msg:      "denied by query",
+		method:   "DELETE",
+		url:      "https://www.example.org/api?debug=true",
+		stateBag: map[string]interface{}{oidcClaimsCacheKey: tokenContainer{Claims: map[string]interface{}{"groups": []interface{}{"admins"}}}},
+		status:   http.StatusUnauthorized,
+	}, {
+		msg:     "denied by rule",
+		method:  "GET",
+		url:     "https://www.example.org/health",
+		headers: http.Header{"X-Country": {"XX"}},
+		status:  http.StatusUnavailableForLegalReasons,
+	}, {
+		msg:     "denied by any of the header values",
+		method:  "GET",
+		url:     "https://www.example.org/health",
+		headers: http.Header{"X-Country": {"DE", "XX"}},
+		status:  http.StatusUnavailableForLegalReasons,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			req, _ := http.NewRequest(ti.method, ti.url, nil)
+			for k, v := range ti.headers {
+				req.Header[k] = v
+			}
+
+			stateBag := ti.stateBag
+			if stateBag == nil {
+				stateBag = make(map[string]interface{})
+			}
+
+			ctx := &filtertest.Context{FRequest: req, FStateBag: stateBag}
+			f.Request(ctx)
+
+			if ti.status == 0 {
+				if ctx.FServed {
+					t.Fatalf("unexpected response: %d", ctx.FResponse.StatusCode)
+				}
+
+				return
+			}
+
+			if !ctx.FServed || ctx.FResponse.StatusCode != ti.status {
+				t.Fatalf("expected status %d, got: %v", ti.status, ctx.FResponse)
+			}
+
+			if ti.status == http.StatusUnauthorized {
+				body, _ := io.ReadAll(ctx.FResponse.Body)
+				if string(body) != "unauthorized" || ctx.FResponse.Header.Get("WWW-Authenticate") != "Bearer" {
+					t.Errorf("unexpected response: %v, %s", ctx.FResponse.Header, body)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyAuthorizeDropsUnusedBundles(t *testing.T) {
+	dir := t.TempDir()
+	f1, f2 := filepath.Join(dir, "b1.yaml"), filepath.Join(dir, "b2.yaml")
+	for _, f := range []string{f1, f2} {
+		if err := os.WriteFile(f, []byte(testPolicyBundle), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := policy.NewRegistry(policy.Options{BaseDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	spec := NewPolicyAuthorize(r)
+	f, err := spec.CreateFilter([]interface{}{f1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := spec.CreateFilter([]interface{}{f2}); err != nil {
+		t.Fatal(err)
+	}
+
+	spec.(routing.PostProcessor).Do([]*routing.Route{{Filters: []*routing.RouteFilter{{Filter: f}}}})
+	if r.Bundle(f1) == nil {
+		t.Error("used bundle dropped")
+	}
+
+	if r.Bundle(f2) != nil {
+		t.Error("unused bundle not dropped")
+	}
+}
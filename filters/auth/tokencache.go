@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+)
+
+const defaultTokenCacheTTL = time.Minute
+
+type tokenCacheEntry struct {
+	key     [sha256.Size]byte
+	info    map[string]interface{}
+	expires time.Time
+}
+
+// tokenCache stores the responses of the token validation services
+// across requests, keyed by the hash of the token. The entries expire
+// with the token, but latest after the configured TTL. Invalid tokens
+// are cached too, and when the cache is full, the least recently used
+// entry is evicted.
+type tokenCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List
+	now     func() time.Time
+
+	metrics       metrics.Metrics
+	hitKey        string
+	missKey       string
+	evictionKey   string
+	invalidHitKey string
+}
+
+func newTokenCache(metricsPrefix string, size int, ttl time.Duration) *tokenCache {
+	if ttl <= 0 {
+		ttl = defaultTokenCacheTTL
+	}
+
+	return &tokenCache{
+		size:          size,
+		ttl:           ttl,
+		entries:       make(map[[sha256.Size]byte]*list.Element),
+		lru:           list.New(),
+		now:           time.Now,
+		metrics:       metrics.Default,
+		hitKey:        metricsPrefix + "cache.hit",
+		missKey:       metricsPrefix + "cache.miss",
+		evictionKey:   metricsPrefix + "cache.eviction",
+		invalidHitKey: metricsPrefix + "cache.invalid",
+	}
+}
+
+// get returns a copy of the cached info of the token, and whether the
+// token was found in the cache. When the token was found invalid, the
+// returned info is nil.
+func (c *tokenCache) get(token string) (map[string]interface{}, bool) {
+	key := sha256.Sum256([]byte(token))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		c.metrics.IncCounter(c.missKey)
+		return nil, false
+	}
+
+	entry := e.Value.(*tokenCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(e)
+		c.metrics.IncCounter(c.missKey)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+	c.metrics.IncCounter(c.hitKey)
+	if entry.info == nil {
+		c.metrics.IncCounter(c.invalidHitKey)
+		return nil, true
+	}
+
+	// the filters may store the info in the state bag, where other
+	// filters can change it
+	info := make(map[string]interface{}, len(entry.info))
+	for k, v := range entry.info {
+		info[k] = v
+	}
+
+	return info, true
+}
+
+// set stores the info of a valid token, or when the info is nil, marks
+// the token as invalid. The entry expires latest after the cache TTL,
+// or at the expiry of the token if not zero.
+func (c *tokenCache) set(token string, info map[string]interface{}, tokenExpires time.Time) {
+	expires := c.now().Add(c.ttl)
+	if !tokenExpires.IsZero() && tokenExpires.Before(expires) {
+		expires = tokenExpires
+	}
+
+	if !c.now().Before(expires) {
+		return
+	}
+
+	key := sha256.Sum256([]byte(token))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*tokenCacheEntry)
+		entry.info = info
+		entry.expires = expires
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+		c.metrics.IncCounter(c.evictionKey)
+	}
+
+	c.entries[key] = c.lru.PushFront(&tokenCacheEntry{key: key, info: info, expires: expires})
+}
+
+func (c *tokenCache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*tokenCacheEntry).key)
+}
+
+// the tokeninfo services may return the expires_in field either as a
+// number or as a string
+func expiresIn(info map[string]interface{}, now time.Time) time.Time {
+	var seconds float64
+	switch v := info["expires_in"].(type) {
+	case float64:
+		seconds = v
+	case string:
+		var err error
+		if seconds, err = strconv.ParseFloat(v, 64); err != nil {
+			return time.Time{}
+		}
+	default:
+		return time.Time{}
+	}
+
+	return now.Add(time.Duration(seconds * float64(time.Second)))
+}
+
+func expiresAt(info map[string]interface{}) time.Time {
+	exp, ok := info["exp"].(float64)
+	if !ok {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(exp), 0)
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func newTestTokenCache(size int, ttl time.Duration) (*tokenCache, *metricstest.MockMetrics, *time.Time) {
+	now := time.Now()
+	m := &metricstest.MockMetrics{}
+	c := newTokenCache("test.", size, ttl)
+	c.metrics = m
+	c.now = func() time.Time { return now }
+	return c, m, &now
+}
+
+func TestTokenCacheExpiry(t *testing.T) {
+	c, m, now := newTestTokenCache(10, time.Minute)
+
+	c.set("foo", map[string]interface{}{"uid": "foo"}, time.Time{})
+	c.set("bar", map[string]interface{}{"uid": "bar"}, now.Add(time.Second))
+	c.set("baz", nil, time.Time{})
+
+	// already expired tokens are not cached
+	c.set("qux", map[string]interface{}{"uid": "qux"}, now.Add(-time.Second))
+
+	if info, ok := c.get("foo"); !ok || info["uid"] != "foo" {
+		t.Errorf("unexpected cached info: %v, %v", info, ok)
+	}
+
+	if info, ok := c.get("baz"); !ok || info != nil {
+		t.Errorf("expected invalid token, got: %v, %v", info, ok)
+	}
+
+	if _, ok := c.get("qux"); ok {
+		t.Error("unexpected cached token")
+	}
+
+	*now = now.Add(2 * time.Second)
+	if _, ok := c.get("bar"); ok {
+		t.Error("expected the cached token to expire with the token")
+	}
+
+	*now = now.Add(time.Minute)
+	if _, ok := c.get("foo"); ok {
+		t.Error("expected the cached token to expire with the cache TTL")
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		if counters["test.cache.hit"] != 2 || counters["test.cache.miss"] != 3 || counters["test.cache.invalid"] != 1 {
+			t.Errorf("unexpected counters: %v", counters)
+		}
+	})
+}
+
+func TestTokenCacheEviction(t *testing.T) {
+	c, m, _ := newTestTokenCache(2, time.Minute)
+
+	c.set("foo", map[string]interface{}{}, time.Time{})
+	c.set("bar", map[string]interface{}{}, time.Time{})
+
+	// foo becomes the most recently used
+	c.get("foo")
+
+	c.set("baz", map[string]interface{}{}, time.Time{})
+
+	for token, expected := range map[string]bool{"foo": true, "bar": false, "baz": true} {
+		if _, ok := c.get(token); ok != expected {
+			t.Errorf("unexpected cache state of %s, expected: %v, got: %v", token, expected, ok)
+		}
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		if counters["test.cache.eviction"] != 1 {
+			t.Errorf("unexpected counters: %v", counters)
+		}
+	})
+}
+
+func TestTokenCacheCopiesInfo(t *testing.T) {
+	c, _, _ := newTestTokenCache(1, time.Minute)
+	c.set("foo", map[string]interface{}{"uid": "foo"}, time.Time{})
+
+	info, _ := c.get("foo")
+	info["uid"] = "bar"
+
+	if info, _ := c.get("foo"); info["uid"] != "foo" {
+		t.Errorf("unexpected cached info: %v", info)
+	}
+}
+
+func TestExpiresIn(t *testing.T) {
+	now := time.Now()
+	for _, ti := range []struct {
+		info     map[string]interface{}
+		expected time.Time
+	}{
+		{map[string]interface{}{"expires_in": float64(300)}, now.Add(300 * time.Second)},
+		{map[string]interface{}{"expires_in": "300"}, now.Add(300 * time.Second)},
+		{map[string]interface{}{"expires_in": "never"}, time.Time{}},
+		{map[string]interface{}{}, time.Time{}},
+	} {
+		if got := expiresIn(ti.info, now); !got.Equal(ti.expected) {
+			t.Errorf("unexpected expiry for %v, expected: %v, got: %v", ti.info, ti.expected, got)
+		}
+	}
+}
+
+func TestTokeninfoCache(t *testing.T) {
+	var requests int32
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get(authHeaderName) != authHeaderPrefix+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprintf(w, `{"uid": "jdoe", "scope": ["%s"], "expires_in": 300}`, testScope)
+	}))
+	defer authServer.Close()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer backend.Close()
+
+	spec := NewOAuthTokeninfoAnyScopeWithOptions(TokeninfoOptions{
+		URL:       authServer.URL,
+		Timeout:   testAuthTimeout,
+		CacheSize: 10,
+	})
+
+	fr := make(filters.Registry)
+	fr.Register(spec)
+	r := &eskip.Route{
+		Filters: []*eskip.Filter{{Name: spec.Name(), Args: []interface{}{testScope}}},
+		Backend: backend.URL,
+	}
+
+	p := proxytest.New(fr, r)
+	defer p.Close()
+
+	get := func(token string, expected int) {
+		req, _ := http.NewRequest("GET", p.URL, nil)
+		req.Header.Set(authHeaderName, authHeaderPrefix+token)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != expected {
+			t.Errorf("unexpected status code, expected: %d, got: %d", expected, rsp.StatusCode)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		get(testToken, http.StatusOK)
+		get("invalid-token", http.StatusUnauthorized)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("unexpected number of tokeninfo requests: %d", n)
+	}
+}
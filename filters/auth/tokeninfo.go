@@ -28,6 +28,15 @@ type TokeninfoOptions struct {
 	Timeout      time.Duration
 	MaxIdleConns int
 	Tracer       opentracing.Tracer
+
+	// CacheSize, when greater than zero, enables caching the tokeninfo
+	// responses across requests, and sets the maximum number of the
+	// cached tokens.
+	CacheSize int
+
+	// CacheTTL sets the maximum time to cache a tokeninfo response,
+	// when the token doesn't expire earlier. Defaults to 1m.
+	CacheTTL time.Duration
 }
 
 type (
@@ -185,6 +194,9 @@ func (s *tokeninfoSpec) CreateFilter(args []interface{}) (filters.Filter, error)
 		if err != nil {
 			return nil, filters.ErrInvalidFilterParameters
 		}
+		if s.options.CacheSize > 0 {
+			ac.cache = newTokenCache("tokeninfo.", s.options.CacheSize, s.options.CacheTTL)
+		}
 		tokeninfoAuthClient[s.options.URL] = ac
 	}
 
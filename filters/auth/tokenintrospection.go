@@ -40,6 +40,15 @@ type TokenintrospectionOptions struct {
 	Timeout      time.Duration
 	Tracer       opentracing.Tracer
 	MaxIdleConns int
+
+	// CacheSize, when greater than zero, enables caching the token
+	// introspection responses across requests, and sets the maximum
+	// number of the cached tokens per issuer.
+	CacheSize int
+
+	// CacheTTL sets the maximum time to cache a token introspection
+	// response, when the token doesn't expire earlier. Defaults to 1m.
+	CacheTTL time.Duration
 }
 
 type (
@@ -294,6 +303,9 @@ func (s *tokenIntrospectionSpec) CreateFilter(args []interface{}) (filters.Filte
 		if err != nil {
 			return nil, filters.ErrInvalidFilterParameters
 		}
+		if s.options.CacheSize > 0 {
+			ac.cache = newTokenCache("tokenintrospection.", s.options.CacheSize, s.options.CacheTTL)
+		}
 		issuerAuthClient[issuerURL] = ac
 	}
 
@@ -0,0 +1,34 @@
+package builtin
+
+import "github.com/zalando/skipper/filters"
+
+type backendHTTP2Spec struct{}
+
+type backendHTTP2Filter struct{}
+
+// NewBackendHTTP2 returns a filter specification that is used to specify
+// that the backend request should be sent using HTTP/2. Requests to https
+// backends negotiate HTTP/2 via ALPN, requests to http backends use
+// cleartext HTTP/2 (h2c) with prior knowledge.
+func NewBackendHTTP2() filters.Spec {
+	return &backendHTTP2Spec{}
+}
+
+func (s *backendHTTP2Spec) Name() string {
+	return filters.BackendHTTP2Name
+}
+
+func (s *backendHTTP2Spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &backendHTTP2Filter{}, nil
+}
+
+func (f *backendHTTP2Filter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.BackendHTTP2Key] = struct{}{}
+}
+
+func (f *backendHTTP2Filter) Response(ctx filters.FilterContext) {
+}
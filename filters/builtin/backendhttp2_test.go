@@ -0,0 +1,37 @@
+package builtin
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestBackendHTTP2Filter(t *testing.T) {
+	expectedStateBag := map[string]interface{}{
+		filters.BackendHTTP2Key: struct{}{},
+	}
+
+	ctx := &filtertest.Context{
+		FRequest:  &http.Request{},
+		FStateBag: map[string]interface{}{},
+	}
+
+	f, err := NewBackendHTTP2().CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Request(ctx)
+
+	if !reflect.DeepEqual(expectedStateBag, ctx.FStateBag) {
+		t.Error("StateBags are not equal", expectedStateBag, ctx.FStateBag)
+	}
+}
+
+func TestBackendHTTP2FilterInvalidArgs(t *testing.T) {
+	if _, err := NewBackendHTTP2().CreateFilter([]interface{}{"foo"}); err != filters.ErrInvalidFilterParameters {
+		t.Errorf("expected invalid filter parameters error, got: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package builtin
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/net"
+)
+
+// DefaultBufferRequestBodyMemoryLimit is the size up to which the
+// bufferRequestBody filter keeps the request body in memory, by default.
+const DefaultBufferRequestBodyMemoryLimit = 1 << 20
+
+type bufferRequestBodySpec struct{}
+
+type bufferRequestBody struct {
+	maxSize     int64
+	memoryLimit int64
+}
+
+// NewBufferRequestBody returns a filter specification whose instances read
+// the complete request body before forwarding the request, and make it
+// replayable for retries, tee and loopback requests. The first argument
+// is the maximum size of the body in bytes, above which the filter
+// responds with 413 Request Entity Too Large. The optional second argument
+// is the size above which the body is stored in a temporary file instead
+// of the memory, default: 1MB.
+func NewBufferRequestBody() filters.Spec { return &bufferRequestBodySpec{} }
+
+func (*bufferRequestBodySpec) Name() string { return filters.BufferRequestBodyName }
+
+func getSizeArg(a interface{}) (int64, error) {
+	switch v := a.(type) {
+	case int:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	default:
+		return 0, filters.ErrInvalidFilterParameters
+	}
+}
+
+func (*bufferRequestBodySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	maxSize, err := getSizeArg(args[0])
+	if err != nil || maxSize <= 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &bufferRequestBody{
+		maxSize:     maxSize,
+		memoryLimit: DefaultBufferRequestBodyMemoryLimit,
+	}
+
+	if len(args) == 2 {
+		f.memoryLimit, err = getSizeArg(args[1])
+		if err != nil || f.memoryLimit < 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return f, nil
+}
+
+func serveStatus(ctx filters.FilterContext, code int) {
+	ctx.Serve(&http.Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Length": []string{"0"}},
+		Body:       http.NoBody,
+	})
+}
+
+func (f *bufferRequestBody) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	if _, ok := ctx.StateBag()[filters.RequestBodyBufferKey]; ok {
+		return
+	}
+
+	if req.ContentLength > f.maxSize {
+		serveStatus(ctx, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	b, err := net.NewBodyBuffer(req.Body, f.maxSize, f.memoryLimit)
+	req.Body.Close()
+	if err == net.ErrBodyTooLarge {
+		serveStatus(ctx, http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		log.Errorf("Failed to buffer the request body: %v", err)
+		serveStatus(ctx, http.StatusBadRequest)
+		return
+	}
+
+	req.Body = b.NewReader()
+	req.ContentLength = b.Size()
+	req.TransferEncoding = nil
+	ctx.StateBag()[filters.RequestBodyBufferKey] = b
+}
+
+func (*bufferRequestBody) Response(filters.FilterContext) {}
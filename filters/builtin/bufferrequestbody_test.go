@@ -0,0 +1,111 @@
+package builtin
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/net"
+)
+
+func TestBufferRequestBodyArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{"1024"},
+		{0},
+		{1024, -1},
+		{1024, 512, 256},
+	} {
+		if _, err := NewBufferRequestBody().CreateFilter(args); err == nil {
+			t.Errorf("expected error for args: %v", args)
+		}
+	}
+
+	for _, args := range [][]interface{}{
+		{1024},
+		{1024.0, 512.0},
+		{1024, 0},
+	} {
+		if _, err := NewBufferRequestBody().CreateFilter(args); err != nil {
+			t.Errorf("unexpected error for args %v: %v", args, err)
+		}
+	}
+}
+
+func TestBufferRequestBody(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		body           string
+		contentLength  int64
+		expectedStatus int
+	}{{
+		name:          "buffered",
+		body:          "foobarbaz",
+		contentLength: 9,
+	}, {
+		name:          "buffered with unknown length",
+		body:          "foobarbaz",
+		contentLength: -1,
+	}, {
+		name:           "too large",
+		body:           "foobarbazqux",
+		contentLength:  12,
+		expectedStatus: http.StatusRequestEntityTooLarge,
+	}, {
+		name:           "too large with unknown length",
+		body:           "foobarbazqux",
+		contentLength:  -1,
+		expectedStatus: http.StatusRequestEntityTooLarge,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewBufferRequestBody().CreateFilter([]interface{}{10, 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest("POST", "https://www.example.org", io.NopCloser(strings.NewReader(tt.body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.ContentLength = tt.contentLength
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+
+			if tt.expectedStatus != 0 {
+				if !ctx.FServed || ctx.FResponse.StatusCode != tt.expectedStatus {
+					t.Fatalf("expected status %d", tt.expectedStatus)
+				}
+
+				return
+			}
+
+			b, ok := ctx.FStateBag[filters.RequestBodyBufferKey].(*net.BodyBuffer)
+			if !ok {
+				t.Fatal("expected body buffer in the state bag")
+			}
+			defer b.Close()
+
+			if req.ContentLength != int64(len(tt.body)) {
+				t.Errorf("expected content length %d, got: %d", len(tt.body), req.ContentLength)
+			}
+
+			for i := 0; i < 2; i++ {
+				body, err := io.ReadAll(req.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(body) != tt.body {
+					t.Errorf("unexpected body: %s", body)
+				}
+
+				req.Body.Close()
+				req.Body = b.NewReader()
+			}
+		})
+	}
+}
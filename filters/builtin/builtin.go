@@ -8,6 +8,7 @@ import (
 	"github.com/zalando/skipper/filters/accesslog"
 	"github.com/zalando/skipper/filters/auth"
 	"github.com/zalando/skipper/filters/circuit"
+	"github.com/zalando/skipper/filters/clientcert"
 	"github.com/zalando/skipper/filters/consistenthash"
 	"github.com/zalando/skipper/filters/cookie"
 	"github.com/zalando/skipper/filters/cors"
@@ -15,6 +16,7 @@ import (
 	"github.com/zalando/skipper/filters/fadein"
 	"github.com/zalando/skipper/filters/flowid"
 	logfilter "github.com/zalando/skipper/filters/log"
+	"github.com/zalando/skipper/filters/retry"
 	"github.com/zalando/skipper/filters/rfc"
 	"github.com/zalando/skipper/filters/scheduler"
 	"github.com/zalando/skipper/filters/sed"
@@ -119,6 +121,7 @@ func MakeRegistry() filters.Registry {
 	r := make(filters.Registry)
 	for _, s := range []filters.Spec{
 		NewBackendIsProxy(),
+		NewBackendHTTP2(),
 		NewRequestHeader(),
 		NewSetRequestHeader(),
 		NewAppendRequestHeader(),
@@ -159,6 +162,7 @@ func MakeRegistry() filters.Registry {
 		NewHeaderToQuery(),
 		NewQueryToHeader(),
 		NewBackendTimeout(),
+		NewBufferRequestBody(),
 		NewSetDynamicBackendHostFromHeader(),
 		NewSetDynamicBackendSchemeFromHeader(),
 		NewSetDynamicBackendUrlFromHeader(),
@@ -196,8 +200,12 @@ func MakeRegistry() filters.Registry {
 		circuit.NewConsecutiveBreaker(),
 		circuit.NewRateBreaker(),
 		circuit.NewDisableBreaker(),
+		circuit.NewSlowCallBreaker(),
+		circuit.NewBreakerFailureStatusCodes(),
+		retry.NewRetry(),
 		script.NewLuaScript(),
 		cors.NewOrigin(),
+		cors.New(),
 		logfilter.NewUnverifiedAuditLog(),
 		tracing.NewSpanName(),
 		tracing.NewBaggageToTagFilter(),
@@ -208,8 +216,12 @@ func MakeRegistry() filters.Registry {
 		accesslog.NewEnableAccessLog(),
 		auth.NewForwardToken(),
 		auth.NewForwardTokenField(),
+		clientcert.New(),
 		scheduler.NewLIFO(),
 		scheduler.NewLIFOGroup(),
+		scheduler.NewFIFO(),
+		scheduler.NewFIFOGroup(),
+		scheduler.NewAdaptiveConcurrency(),
 		rfc.NewPath(),
 		rfc.NewHost(),
 		fadein.NewFadeIn(),
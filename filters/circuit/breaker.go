@@ -31,6 +31,12 @@ type filter struct {
 	settings circuit.BreakerSettings
 }
 
+type statusCodesSpec struct{}
+
+type statusCodesFilter struct {
+	codes circuit.StatusCodes
+}
+
 func getIntArg(a interface{}) (int, error) {
 	if i, ok := a.(int); ok {
 		return i, nil
@@ -78,6 +84,32 @@ func NewRateBreaker() filters.Spec {
 	return &spec{typ: circuit.FailureRate}
 }
 
+// NewSlowCallBreaker creates a filter specification to instantiate slowCallBreaker() filters.
+//
+// These filters set a breaker for the current route that open if the slow or failed backend calls for the route
+// reach a percentage P of a window of the last M requests, where a call is slow when it takes longer than D. P, M
+// and D are mandatory arguments of the filter:
+//
+// 	slowCallBreaker(50, 100, "2s")
+//
+// The filter accepts the following optional arguments: timeout (milliseconds or duration string),
+// half-open-requests (integer), idle-ttl (milliseconds or duration string).
+func NewSlowCallBreaker() filters.Spec {
+	return &spec{typ: circuit.SlowCallRate}
+}
+
+// NewBreakerFailureStatusCodes creates a filter specification to instantiate breakerFailureStatusCodes() filters.
+//
+// These filters set the status codes of the backend responses, which are counted as failures by the breaker of
+// the current route, instead of the default >=500:
+//
+// 	breakerFailureStatusCodes(429, 503)
+//
+// The failed connections are always counted as failures.
+func NewBreakerFailureStatusCodes() filters.Spec {
+	return &statusCodesSpec{}
+}
+
 // NewDisableBreaker disables the circuit breaker for a route. It doesn't accept any arguments.
 func NewDisableBreaker() filters.Spec {
 	return &spec{}
@@ -89,6 +121,8 @@ func (s *spec) Name() string {
 		return filters.ConsecutiveBreakerName
 	case circuit.FailureRate:
 		return filters.RateBreakerName
+	case circuit.SlowCallRate:
+		return filters.SlowCallBreakerName
 	default:
 		return filters.DisableBreakerName
 	}
@@ -190,6 +224,71 @@ func rateFilter(args []interface{}) (filters.Filter, error) {
 	}, nil
 }
 
+func slowCallFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 3 || len(args) > 6 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	rate, err := getIntArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if rate <= 0 || rate > 100 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	window, err := getIntArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	slowCallDuration, err := getDurationArg(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	if slowCallDuration <= 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var timeout time.Duration
+	if len(args) > 3 {
+		timeout, err = getDurationArg(args[3])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var halfOpenRequests int
+	if len(args) > 4 {
+		halfOpenRequests, err = getIntArg(args[4])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var idleTTL time.Duration
+	if len(args) > 5 {
+		idleTTL, err = getDurationArg(args[5])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &filter{
+		settings: circuit.BreakerSettings{
+			Type:             circuit.SlowCallRate,
+			SlowCallRate:     rate,
+			Window:           window,
+			SlowCallDuration: slowCallDuration,
+			Timeout:          timeout,
+			HalfOpenRequests: halfOpenRequests,
+			IdleTTL:          idleTTL,
+		},
+	}, nil
+}
+
 func disableFilter(args []interface{}) (filters.Filter, error) {
 	if len(args) != 0 {
 		return nil, filters.ErrInvalidFilterParameters
@@ -208,13 +307,56 @@ func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
 		return consecutiveFilter(args)
 	case circuit.FailureRate:
 		return rateFilter(args)
+	case circuit.SlowCallRate:
+		return slowCallFilter(args)
 	default:
 		return disableFilter(args)
 	}
 }
 
 func (f *filter) Request(ctx filters.FilterContext) {
-	ctx.StateBag()[RouteSettingsKey] = f.settings
+	settings := f.settings
+
+	// keeps the failure status codes, in case they were set by a preceding filter
+	if current, ok := ctx.StateBag()[RouteSettingsKey].(circuit.BreakerSettings); ok {
+		settings.FailureStatusCodes = current.FailureStatusCodes
+	}
+
+	ctx.StateBag()[RouteSettingsKey] = settings
 }
 
 func (f *filter) Response(filters.FilterContext) {}
+
+func (*statusCodesSpec) Name() string { return filters.BreakerFailureStatusCodesName }
+
+func (*statusCodesSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	codes := make([]int, len(args))
+	for i, a := range args {
+		code, err := getIntArg(a)
+		if err != nil {
+			return nil, err
+		}
+
+		if code < 100 || code > 599 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		codes[i] = code
+	}
+
+	return &statusCodesFilter{codes: circuit.NewStatusCodes(codes...)}, nil
+}
+
+// Request sets the failure status codes of the route breaker, keeping
+// the rest of its settings, if any.
+func (f *statusCodesFilter) Request(ctx filters.FilterContext) {
+	settings, _ := ctx.StateBag()[RouteSettingsKey].(circuit.BreakerSettings)
+	settings.FailureStatusCodes = f.codes
+	ctx.StateBag()[RouteSettingsKey] = settings
+}
+
+func (*statusCodesFilter) Response(filters.FilterContext) {}
@@ -53,6 +53,26 @@ func TestArgs(t *testing.T) {
 		t.Run("with idle ttl", testOK(s, 30, 300, 60000, 12, "30m"))
 	})
 
+	t.Run("slow call", func(t *testing.T) {
+		s := NewSlowCallBreaker()
+		t.Run("missing duration", testErr(s, 50, 100))
+		t.Run("too many", testErr(s, 50, 100, "2s", "1m", 12, "30m", 42))
+		t.Run("wrong rate", testErr(s, 150, 100, "2s"))
+		t.Run("wrong duration", testErr(s, 50, 100, "foo"))
+		t.Run("zero duration", testErr(s, 50, 100, "0s"))
+		t.Run("only rate, window and duration", testOK(s, 50, 100, "2s"))
+		t.Run("duration as milliseconds", testOK(s, 50, 100, 2000))
+		t.Run("full", testOK(s, 50, 100, "2s", "1m", 12, "30m"))
+	})
+
+	t.Run("failure status codes", func(t *testing.T) {
+		s := NewBreakerFailureStatusCodes()
+		t.Run("missing", testErr(s))
+		t.Run("wrong status code", testErr(s, 503, "429"))
+		t.Run("invalid status code", testErr(s, 503, 42))
+		t.Run("ok", testOK(s, 503, 429))
+	})
+
 	t.Run("disable", func(t *testing.T) {
 		s := NewDisableBreaker()
 		t.Run("with args fail", testErr(s, 6))
@@ -61,24 +81,27 @@ func TestArgs(t *testing.T) {
 }
 
 func TestBreaker(t *testing.T) {
-	test := func(
-		s func() filters.Spec,
+	create := func(t *testing.T, s func() filters.Spec, args ...interface{}) filters.Filter {
+		f, err := s().CreateFilter(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return f
+	}
+
+	testFilters := func(
 		expect circuit.BreakerSettings,
-		args ...interface{},
+		fs ...func(*testing.T) filters.Filter,
 	) func(*testing.T) {
 		return func(t *testing.T) {
-			s := s()
-
-			f, err := s.CreateFilter(args)
-			if err != nil {
-				t.Error(err)
-			}
-
 			ctx := &filtertest.Context{
 				FStateBag: make(map[string]interface{}),
 			}
 
-			f.Request(ctx)
+			for _, f := range fs {
+				f(t).Request(ctx)
+			}
 
 			settings, ok := ctx.StateBag()[RouteSettingsKey]
 			if !ok {
@@ -93,6 +116,14 @@ func TestBreaker(t *testing.T) {
 		}
 	}
 
+	test := func(
+		s func() filters.Spec,
+		expect circuit.BreakerSettings,
+		args ...interface{},
+	) func(*testing.T) {
+		return testFilters(expect, func(t *testing.T) filters.Filter { return create(t, s, args...) })
+	}
+
 	t.Run("consecutive breaker", test(
 		NewConsecutiveBreaker,
 		circuit.BreakerSettings{
@@ -121,6 +152,38 @@ func TestBreaker(t *testing.T) {
 		12,
 	))
 
+	t.Run("slow call breaker", test(
+		NewSlowCallBreaker,
+		circuit.BreakerSettings{
+			Type:             circuit.SlowCallRate,
+			SlowCallRate:     50,
+			Window:           100,
+			SlowCallDuration: 2 * time.Second,
+			Timeout:          time.Minute,
+		},
+		50,
+		100,
+		"2s",
+		"1m",
+	))
+
+	statusCodes := func(t *testing.T) filters.Filter { return create(t, NewBreakerFailureStatusCodes, 503, 429) }
+	rateBreaker := func(t *testing.T) filters.Filter { return create(t, NewRateBreaker, 30, 300) }
+	expectRate := circuit.BreakerSettings{
+		Type:               circuit.FailureRate,
+		Failures:           30,
+		Window:             300,
+		FailureStatusCodes: circuit.NewStatusCodes(429, 503),
+	}
+
+	t.Run("failure status codes", testFilters(
+		circuit.BreakerSettings{FailureStatusCodes: circuit.NewStatusCodes(429, 503)},
+		statusCodes,
+	))
+
+	t.Run("failure status codes after breaker", testFilters(expectRate, rateBreaker, statusCodes))
+	t.Run("failure status codes before breaker", testFilters(expectRate, statusCodes, rateBreaker))
+
 	t.Run("disable breaker", test(
 		NewDisableBreaker,
 		circuit.BreakerSettings{
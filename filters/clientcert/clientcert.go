@@ -0,0 +1,91 @@
+/*
+Package clientcert implements the forwardClientCert filter, which
+forwards the verified TLS client certificate of the request to the
+backend in a header.
+*/
+package clientcert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"net/url"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/predicates/clientcert"
+)
+
+const (
+	formatPEM         = "pem"
+	formatFingerprint = "fingerprint"
+)
+
+type (
+	spec struct{}
+
+	filter struct {
+		header string
+		format string
+	}
+)
+
+// New creates the specification of the forwardClientCert filter. The
+// filter accepts the name of the header as its first argument, and
+// optionally the format as its second one, which can be pem or
+// fingerprint. With the pem format, which is the default, the header
+// is set to the URL encoded PEM of the certificate, and with the
+// fingerprint format, to the hex encoded SHA-256 fingerprint of it.
+//
+// Only the certificates verified by the listener are forwarded, and the
+// header is always removed from the incoming requests, so that the
+// clients cannot set it.
+//
+// Eskip example:
+//
+//	forwardClientCert("X-Client-Cert")
+//	forwardClientCert("X-Client-Cert-Fingerprint", "fingerprint")
+func New() filters.Spec { return &spec{} }
+
+func (*spec) Name() string { return filters.ForwardClientCertName }
+
+func (*spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	header, ok := args[0].(string)
+	if !ok || header == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	format := formatPEM
+	if len(args) == 2 {
+		format, ok = args[1].(string)
+		if !ok || format != formatPEM && format != formatFingerprint {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return &filter{header: header, format: format}, nil
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	r.Header.Del(f.header)
+
+	c := clientcert.VerifiedCertificate(r)
+	if c == nil {
+		return
+	}
+
+	if f.format == formatFingerprint {
+		sum := sha256.Sum256(c.Raw)
+		r.Header.Set(f.header, hex.EncodeToString(sum[:]))
+		return
+	}
+
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	r.Header.Set(f.header, url.QueryEscape(string(b)))
+}
+
+func (*filter) Response(filters.FilterContext) {}
@@ -0,0 +1,74 @@
+package clientcert
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestForwardClientCert(t *testing.T) {
+	cert := &x509.Certificate{Raw: []byte("test certificate")}
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	for _, ti := range []struct {
+		msg      string
+		args     []interface{}
+		tls      *tls.ConnectionState
+		expected string
+	}{{
+		msg:      "pem",
+		args:     []interface{}{"X-Client-Cert"},
+		tls:      &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		expected: url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))),
+	}, {
+		msg:      "fingerprint",
+		args:     []interface{}{"X-Client-Cert", "fingerprint"},
+		tls:      &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		expected: hex.EncodeToString(fingerprint[:]),
+	}, {
+		msg:  "not verified",
+		args: []interface{}{"X-Client-Cert"},
+		tls:  &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+	}, {
+		msg:  "no tls",
+		args: []interface{}{"X-Client-Cert"},
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			f, err := New().CreateFilter(ti.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, _ := http.NewRequest("GET", "https://www.example.org", nil)
+			req.TLS = ti.tls
+			req.Header.Set("X-Client-Cert", "spoofed")
+
+			f.Request(&filtertest.Context{FRequest: req})
+
+			if got := req.Header.Get("X-Client-Cert"); got != ti.expected {
+				t.Errorf("unexpected header, expected: %q, got: %q", ti.expected, got)
+			}
+		})
+	}
+}
+
+func TestForwardClientCertArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{""},
+		{42},
+		{"X-Client-Cert", "der"},
+		{"X-Client-Cert", "pem", "foo"},
+	} {
+		if _, err := New().CreateFilter(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
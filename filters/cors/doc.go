@@ -1,5 +1,6 @@
 /*
-Package cors implements the origin header for CORS.
+Package cors implements the origin header for CORS, and a complete
+CORS policy, including the preflight requests.
 
 How It Works
 
@@ -12,5 +13,20 @@ Usage
 	corsOrigin()
 	corsOrigin("https://www.example.org")
 	corsOrigin("https://www.example.org", "http://localhost:9001")
+
+CORS Policy
+
+The cors filter answers the preflight requests itself, with the allowed
+methods, headers, credentials and max age, and rejects the preflight
+requests of not allowed origins, methods or headers with 403. For the
+actual requests, it sets the allowed origin and the exposed headers on
+the response of the allowed origins. The origins can be set as exact
+values, with wildcards or as regular expressions. When the response
+depends on the origin of the request, the filter adds Origin to the Vary
+header.
+
+	cors()
+	cors("allowOrigin=https://www.example.org,https://*.example.org", "allowCredentials=true")
+	cors("allowOriginRegexp=^https://[a-z]+[.]example[.]com$", "allowMethods=GET,PUT", "allowHeaders=Authorization", "maxAge=10m")
 */
 package cors
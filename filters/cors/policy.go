@@ -0,0 +1,330 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	originHeader           = "Origin"
+	varyHeader             = "Vary"
+	requestMethodHeader    = "Access-Control-Request-Method"
+	requestHeadersHeader   = "Access-Control-Request-Headers"
+	allowMethodsHeader     = "Access-Control-Allow-Methods"
+	allowHeadersHeader     = "Access-Control-Allow-Headers"
+	allowCredentialsHeader = "Access-Control-Allow-Credentials"
+	exposeHeadersHeader    = "Access-Control-Expose-Headers"
+	maxAgeHeader           = "Access-Control-Max-Age"
+)
+
+var (
+	defaultAllowMethods = []string{"GET", "HEAD", "POST"}
+	defaultAllowHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type"}
+)
+
+type policySpec struct{}
+
+type policy struct {
+	anyOrigin        bool
+	origins          map[string]bool
+	originPatterns   []*regexp.Regexp
+	allowMethods     []string
+	anyHeader        bool
+	allowHeaders     map[string]bool
+	allowHeaderList  []string
+	exposeHeaders    []string
+	allowCredentials bool
+	maxAge           time.Duration
+}
+
+// New creates the specification of the cors filter, which implements
+// a complete CORS policy, including answering the preflight requests.
+//
+// The filter accepts optional arguments in the form of name=value:
+//
+//	cors("allowOrigin=https://www.example.org,https://*.example.org",
+//		"allowOriginRegexp=^https://[a-z]+[.]example[.]com$",
+//		"allowMethods=GET,POST,PUT",
+//		"allowHeaders=Authorization,Content-Type",
+//		"exposeHeaders=X-Request-Id",
+//		"allowCredentials=true",
+//		"maxAge=10m")
+//
+// Without allowed origins, any origin is allowed. Credentials can be
+// allowed only together with explicit origins or origin patterns.
+func New() filters.Spec {
+	return policySpec{}
+}
+
+func (policySpec) Name() string { return filters.CorsName }
+
+func splitList(s string) []string {
+	var l []string
+	for _, si := range strings.Split(s, ",") {
+		if si = strings.TrimSpace(si); si != "" {
+			l = append(l, si)
+		}
+	}
+
+	return l
+}
+
+func wildcardPattern(origin string) (*regexp.Regexp, error) {
+	p := strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, `[^/]+`)
+	return regexp.Compile("^" + p + "$")
+}
+
+func invalidOption(o string) error {
+	return fmt.Errorf("%w: invalid cors option %q", filters.ErrInvalidFilterParameters, o)
+}
+
+func (p *policy) setOption(o string) error {
+	name, value, found := strings.Cut(o, "=")
+	if !found || value == "" {
+		return invalidOption(o)
+	}
+
+	switch name {
+	case "allowOrigin":
+		for _, origin := range splitList(value) {
+			switch {
+			case origin == "*":
+				p.anyOrigin = true
+			case strings.Contains(origin, "*"):
+				rx, err := wildcardPattern(origin)
+				if err != nil {
+					return invalidOption(o)
+				}
+
+				p.originPatterns = append(p.originPatterns, rx)
+			default:
+				p.origins[origin] = true
+			}
+		}
+	case "allowOriginRegexp":
+		rx, err := regexp.Compile(value)
+		if err != nil {
+			return invalidOption(o)
+		}
+
+		p.originPatterns = append(p.originPatterns, rx)
+	case "allowMethods":
+		for _, m := range splitList(value) {
+			p.allowMethods = append(p.allowMethods, strings.ToUpper(m))
+		}
+	case "allowHeaders":
+		for _, h := range splitList(value) {
+			if h == "*" {
+				p.anyHeader = true
+				continue
+			}
+
+			h = http.CanonicalHeaderKey(h)
+			p.allowHeaders[h] = true
+			p.allowHeaderList = append(p.allowHeaderList, h)
+		}
+	case "exposeHeaders":
+		p.exposeHeaders = append(p.exposeHeaders, splitList(value)...)
+	case "allowCredentials":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalidOption(o)
+		}
+
+		p.allowCredentials = b
+	case "maxAge":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return invalidOption(o)
+		}
+
+		p.maxAge = d
+	default:
+		return invalidOption(o)
+	}
+
+	return nil
+}
+
+func (policySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	p := &policy{
+		origins:      make(map[string]bool),
+		allowHeaders: make(map[string]bool),
+	}
+
+	for _, a := range args {
+		s, ok := a.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		if err := p.setOption(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.origins) == 0 && len(p.originPatterns) == 0 {
+		p.anyOrigin = true
+	}
+
+	// allowing the credentials for any origin would let any site send
+	// authenticated requests and read the responses
+	if p.anyOrigin && p.allowCredentials {
+		return nil, fmt.Errorf("%w: cors allowCredentials requires explicit allowed origins", filters.ErrInvalidFilterParameters)
+	}
+
+	if len(p.allowMethods) == 0 {
+		p.allowMethods = defaultAllowMethods
+	}
+
+	if len(p.allowHeaderList) == 0 && !p.anyHeader {
+		for _, h := range defaultAllowHeaders {
+			p.allowHeaders[h] = true
+			p.allowHeaderList = append(p.allowHeaderList, h)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *policy) originAllowed(origin string) bool {
+	if p.anyOrigin || p.origins[origin] {
+		return true
+	}
+
+	for _, rx := range p.originPatterns {
+		if rx.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// when any origin is allowed, the responses don't depend on the origin
+func (p *policy) varyOrigin() bool {
+	return !p.anyOrigin
+}
+
+func (p *policy) allowOrigin(h http.Header, origin string) {
+	if p.varyOrigin() {
+		h.Set(allowOriginHeader, origin)
+	} else {
+		h.Set(allowOriginHeader, "*")
+	}
+
+	if p.allowCredentials {
+		h.Set(allowCredentialsHeader, "true")
+	}
+}
+
+func addVary(h http.Header, values ...string) {
+	existing := make(map[string]bool)
+	for _, v := range h.Values(varyHeader) {
+		for _, vi := range splitList(v) {
+			existing[http.CanonicalHeaderKey(vi)] = true
+		}
+	}
+
+	for _, v := range values {
+		if !existing[v] {
+			h.Add(varyHeader, v)
+		}
+	}
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" && r.Header.Get(originHeader) != "" && r.Header.Get(requestMethodHeader) != ""
+}
+
+func (p *policy) methodAllowed(m string) bool {
+	for _, am := range p.allowMethods {
+		if am == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *policy) headersAllowed(headers []string) bool {
+	if p.anyHeader {
+		return true
+	}
+
+	for _, h := range headers {
+		if !p.allowHeaders[http.CanonicalHeaderKey(h)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Request answers the preflight requests. The preflight requests of
+// not allowed origins, methods or headers are rejected with 403.
+func (p *policy) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	if !isPreflight(r) {
+		return
+	}
+
+	rsp := &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+	addVary(rsp.Header, originHeader, requestMethodHeader, requestHeadersHeader)
+
+	origin := r.Header.Get(originHeader)
+	requestHeaders := splitList(strings.Join(r.Header.Values(requestHeadersHeader), ","))
+	if !p.originAllowed(origin) ||
+		!p.methodAllowed(strings.ToUpper(r.Header.Get(requestMethodHeader))) ||
+		!p.headersAllowed(requestHeaders) {
+		rsp.StatusCode = http.StatusForbidden
+		ctx.Serve(rsp)
+		return
+	}
+
+	p.allowOrigin(rsp.Header, origin)
+	rsp.Header.Set(allowMethodsHeader, strings.Join(p.allowMethods, ", "))
+	if p.anyHeader {
+		if len(requestHeaders) > 0 {
+			rsp.Header.Set(allowHeadersHeader, strings.Join(requestHeaders, ", "))
+		}
+	} else {
+		rsp.Header.Set(allowHeadersHeader, strings.Join(p.allowHeaderList, ", "))
+	}
+
+	if p.maxAge > 0 {
+		rsp.Header.Set(maxAgeHeader, strconv.Itoa(int(p.maxAge.Seconds())))
+	}
+
+	ctx.Serve(rsp)
+}
+
+// Response sets the CORS headers of the actual requests from allowed
+// origins.
+func (p *policy) Response(ctx filters.FilterContext) {
+	r := ctx.Request()
+	if isPreflight(r) {
+		return
+	}
+
+	h := ctx.Response().Header
+	if p.varyOrigin() {
+		addVary(h, originHeader)
+	}
+
+	origin := r.Header.Get(originHeader)
+	if origin == "" || !p.originAllowed(origin) {
+		return
+	}
+
+	p.allowOrigin(h, origin)
+	if len(p.exposeHeaders) > 0 {
+		h.Set(exposeHeadersHeader, strings.Join(p.exposeHeaders, ", "))
+	}
+}
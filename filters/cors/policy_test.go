@@ -0,0 +1,222 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestPolicy(t *testing.T) {
+	for _, ti := range []struct {
+		msg            string
+		args           []interface{}
+		method         string
+		requestHeaders map[string]string
+		status         int
+		headers        map[string]string
+	}{{
+		msg:            "any origin",
+		method:         "GET",
+		requestHeaders: map[string]string{"Origin": "https://www.example.org"},
+		status:         http.StatusOK,
+		headers:        map[string]string{allowOriginHeader: "*", varyHeader: ""},
+	}, {
+		msg:            "wildcard origin with credentials",
+		args:           []interface{}{"allowOrigin=https://*.example.org", "allowCredentials=true"},
+		method:         "GET",
+		requestHeaders: map[string]string{"Origin": "https://www.example.org"},
+		status:         http.StatusOK,
+		headers: map[string]string{
+			allowOriginHeader:      "https://www.example.org",
+			allowCredentialsHeader: "true",
+			varyHeader:             "Origin",
+		},
+	}, {
+		msg:            "exact origin",
+		args:           []interface{}{"allowOrigin=https://www.example.org", "exposeHeaders=X-Request-Id,X-Foo"},
+		method:         "GET",
+		requestHeaders: map[string]string{"Origin": "https://www.example.org"},
+		status:         http.StatusOK,
+		headers: map[string]string{
+			allowOriginHeader:   "https://www.example.org",
+			exposeHeadersHeader: "X-Request-Id, X-Foo",
+			varyHeader:          "Origin",
+		},
+	}, {
+		msg:            "not allowed origin",
+		args:           []interface{}{"allowOrigin=https://www.example.org"},
+		method:         "GET",
+		requestHeaders: map[string]string{"Origin": "https://www.example.com"},
+		status:         http.StatusOK,
+		headers:        map[string]string{allowOriginHeader: "", varyHeader: "Origin"},
+	}, {
+		msg:     "no origin",
+		args:    []interface{}{"allowOrigin=https://www.example.org"},
+		method:  "GET",
+		status:  http.StatusOK,
+		headers: map[string]string{allowOriginHeader: "", varyHeader: "Origin"},
+	}, {
+		msg:            "wildcard origin",
+		args:           []interface{}{"allowOrigin=https://*.example.org"},
+		method:         "GET",
+		requestHeaders: map[string]string{"Origin": "https://api.example.org"},
+		status:         http.StatusOK,
+		headers:        map[string]string{allowOriginHeader: "https://api.example.org"},
+	}, {
+		msg:            "wildcard origin does not match other hosts",
+		args:           []interface{}{"allowOrigin=https://*.example.org"},
+		method:         "GET",
+		requestHeaders: map[string]string{"Origin": "https://example.org.example.com"},
+		status:         http.StatusOK,
+		headers:        map[string]string{allowOriginHeader: ""},
+	}, {
+		msg:            "regexp origin",
+		args:           []interface{}{"allowOriginRegexp=^http://localhost:[0-9]+$"},
+		method:         "GET",
+		requestHeaders: map[string]string{"Origin": "http://localhost:9001"},
+		status:         http.StatusOK,
+		headers:        map[string]string{allowOriginHeader: "http://localhost:9001"},
+	}, {
+		msg:  "preflight",
+		args: []interface{}{"allowOrigin=https://www.example.org", "allowMethods=GET,PUT", "allowHeaders=Authorization", "maxAge=10m", "allowCredentials=true"},
+		requestHeaders: map[string]string{
+			"Origin":             "https://www.example.org",
+			requestMethodHeader:  "PUT",
+			requestHeadersHeader: "authorization",
+		},
+		method: "OPTIONS",
+		status: http.StatusNoContent,
+		headers: map[string]string{
+			allowOriginHeader:      "https://www.example.org",
+			allowMethodsHeader:     "GET, PUT",
+			allowHeadersHeader:     "Authorization",
+			allowCredentialsHeader: "true",
+			maxAgeHeader:           "600",
+			varyHeader:             "Origin",
+		},
+	}, {
+		msg:  "preflight with default methods and any header",
+		args: []interface{}{"allowHeaders=*"},
+		requestHeaders: map[string]string{
+			"Origin":             "https://www.example.org",
+			requestMethodHeader:  "POST",
+			requestHeadersHeader: "X-Foo, X-Bar",
+		},
+		method: "OPTIONS",
+		status: http.StatusNoContent,
+		headers: map[string]string{
+			allowOriginHeader:  "*",
+			allowMethodsHeader: "GET, HEAD, POST",
+			allowHeadersHeader: "X-Foo, X-Bar",
+			maxAgeHeader:       "",
+		},
+	}, {
+		msg:  "preflight of not allowed origin",
+		args: []interface{}{"allowOrigin=https://www.example.org"},
+		requestHeaders: map[string]string{
+			"Origin":            "https://www.example.com",
+			requestMethodHeader: "GET",
+		},
+		method:  "OPTIONS",
+		status:  http.StatusForbidden,
+		headers: map[string]string{allowOriginHeader: ""},
+	}, {
+		msg:  "preflight of not allowed method",
+		args: []interface{}{"allowMethods=GET"},
+		requestHeaders: map[string]string{
+			"Origin":            "https://www.example.org",
+			requestMethodHeader: "DELETE",
+		},
+		method: "OPTIONS",
+		status: http.StatusForbidden,
+	}, {
+		msg: "preflight of not allowed header",
+		requestHeaders: map[string]string{
+			"Origin":             "https://www.example.org",
+			requestMethodHeader:  "GET",
+			requestHeadersHeader: "Content-Type, X-Foo",
+		},
+		method: "OPTIONS",
+		status: http.StatusForbidden,
+	}, {
+		msg:    "options request without preflight headers",
+		method: "OPTIONS",
+		status: http.StatusOK,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			f, err := New().CreateFilter(ti.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, _ := http.NewRequest(ti.method, "https://api.example.org", nil)
+			for k, v := range ti.requestHeaders {
+				req.Header.Set(k, v)
+			}
+
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+			if !ctx.FServed {
+				ctx.FResponse = &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+			}
+
+			f.Response(ctx)
+
+			if ctx.FResponse.StatusCode != ti.status {
+				t.Fatalf("unexpected status code, expected: %d, got: %d", ti.status, ctx.FResponse.StatusCode)
+			}
+
+			for k, v := range ti.headers {
+				if got := ctx.FResponse.Header.Get(k); got != v {
+					t.Errorf("unexpected header %s, expected: %q, got: %q", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyVaryAppended(t *testing.T) {
+	f, _ := New().CreateFilter([]interface{}{"allowOrigin=https://www.example.org"})
+
+	req, _ := http.NewRequest("GET", "https://api.example.org", nil)
+	rsp := &http.Response{Header: http.Header{varyHeader: []string{"Accept-Encoding, origin"}}}
+	f.Response(&filtertest.Context{FRequest: req, FResponse: rsp})
+
+	if v := rsp.Header.Values(varyHeader); len(v) != 1 {
+		t.Errorf("unexpected vary header: %v", v)
+	}
+}
+
+func TestPolicyInvalidArgs(t *testing.T) {
+	for _, a := range []interface{}{
+		42,
+		"allowOrigin",
+		"allowOriginRegexp=[",
+		"allowCredentials=maybe",
+		"maxAge=forever",
+		"allowAnything=true",
+	} {
+		if _, err := New().CreateFilter([]interface{}{a}); !errors.Is(err, filters.ErrInvalidFilterParameters) {
+			t.Errorf("expected invalid filter parameters for %v, got: %v", a, err)
+		}
+	}
+}
+
+func TestPolicyCredentialsWithAnyOrigin(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{"allowCredentials=true"},
+		{"allowOrigin=*", "allowCredentials=true"},
+		{"allowOrigin=https://www.example.org,*", "allowCredentials=true"},
+	} {
+		if _, err := New().CreateFilter(args); !errors.Is(err, filters.ErrInvalidFilterParameters) {
+			t.Errorf("expected invalid filter parameters for %v, got: %v", args, err)
+		}
+	}
+
+	if _, err := New().CreateFilter([]interface{}{"allowOrigin=*", "allowCredentials=false"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -28,6 +28,20 @@ const (
 
 	// BackendRatelimit is the key used in the state bag to configure backend ratelimit in proxy
 	BackendRatelimit = "backend:ratelimit"
+
+	// BackendHTTP2Key is the key used in the state bag to notify proxy that the backend request
+	// should be sent using HTTP/2.
+	BackendHTTP2Key = "backend:http2"
+
+	// RetryKey is the key used in the state bag to configure the retry policy of the backend requests in proxy
+	RetryKey = "backend:retry"
+
+	// RequestBodyBufferKey is the key used in the state bag to store the buffered, replayable request body
+	RequestBodyBufferKey = "request:bodybuffer"
+
+	// ValidatedClaimsKey is the key used in the state bag to pass the claims of the token validated by the
+	// jwtValidation, oauthOidc* and oauthGrant filters to the subsequent filters, as map[string]interface{}
+	ValidatedClaimsKey = "auth:claims"
 )
 
 // Context object providing state and information that is unique to a request.
@@ -181,6 +195,7 @@ func (r Registry) Register(s Spec) {
 // All Skipper filter names
 const (
 	BackendIsProxyName                         = "backendIsProxy"
+	BackendHTTP2Name                           = "backendHTTP2"
 	ModRequestHeaderName                       = "modRequestHeader"
 	SetRequestHeaderName                       = "setRequestHeader"
 	AppendRequestHeaderName                    = "appendRequestHeader"
@@ -214,6 +229,7 @@ const (
 	RandomContentName                          = "randomContent"
 	RepeatContentName                          = "repeatContent"
 	BackendTimeoutName                         = "backendTimeout"
+	BufferRequestBodyName                      = "bufferRequestBody"
 	LatencyName                                = "latency"
 	BandwidthName                              = "bandwidth"
 	ChunksName                                 = "chunks"
@@ -255,6 +271,8 @@ const (
 	GrantLogoutName                            = "grantLogout"
 	GrantClaimsQueryName                       = "grantClaimsQuery"
 	JwtValidationName                          = "jwtValidation"
+	PolicyAuthorizeName                        = "policyAuthorize"
+	ForwardClientCertName                      = "forwardClientCert"
 	OAuthOidcUserInfoName                      = "oauthOidcUserInfo"
 	OAuthOidcAnyClaimsName                     = "oauthOidcAnyClaims"
 	OAuthOidcAllClaimsName                     = "oauthOidcAllClaims"
@@ -265,15 +283,23 @@ const (
 	ConsecutiveBreakerName                     = "consecutiveBreaker"
 	RateBreakerName                            = "rateBreaker"
 	DisableBreakerName                         = "disableBreaker"
+	SlowCallBreakerName                        = "slowCallBreaker"
+	BreakerFailureStatusCodesName              = "breakerFailureStatusCodes"
 	AdmissionControlName                       = "admissionControl"
 	ClientRatelimitName                        = "clientRatelimit"
 	RatelimitName                              = "ratelimit"
 	ClusterClientRatelimitName                 = "clusterClientRatelimit"
 	ClusterRatelimitName                       = "clusterRatelimit"
 	ClusterLeakyBucketRatelimitName            = "clusterLeakyBucketRatelimit"
+	TokenBucketRatelimitName                   = "tokenBucketRatelimit"
+	ClusterTokenBucketRatelimitName            = "clusterTokenBucketRatelimit"
+	UsagePlanRatelimitName                     = "usagePlanRatelimit"
 	BackendRateLimitName                       = "backendRatelimit"
+	ClusterConcurrencyLimitName                = "clusterConcurrencyLimit"
+	RetryName                                  = "retry"
 	LuaName                                    = "lua"
 	CorsOriginName                             = "corsOrigin"
+	CorsName                                   = "cors"
 	HeaderToQueryName                          = "headerToQuery"
 	QueryToHeaderName                          = "queryToHeader"
 	DisableAccessLogName                       = "disableAccessLog"
@@ -289,6 +315,9 @@ const (
 	ApiUsageMonitoringName                     = "apiUsageMonitoring"
 	LifoName                                   = "lifo"
 	LifoGroupName                              = "lifoGroup"
+	FifoName                                   = "fifo"
+	FifoGroupName                              = "fifoGroup"
+	AdaptiveConcurrencyName                    = "adaptiveConcurrency"
 	RfcPathName                                = "rfcPath"
 	RfcHostName                                = "rfcHost"
 	BearerInjectorName                         = "bearerinjector"
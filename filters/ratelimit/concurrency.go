@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/ratelimit"
+	"github.com/zalando/skipper/routing"
+)
+
+type concurrencyLimiter interface {
+	Acquire(ctx context.Context) (func(), bool)
+}
+
+type concurrencySpec struct {
+	create func(group string, max int, leaseTTL time.Duration) concurrencyLimiter
+
+	mu       sync.Mutex
+	limiters map[string]concurrencyLimiter
+}
+
+type concurrencyFilter struct {
+	key        string
+	limiter    concurrencyLimiter
+	statusCode int
+}
+
+// NewClusterConcurrencyLimit creates a filter Spec, whose instances
+// limit the number of the concurrent requests of a group across all the
+// skipper instances, using leases stored in Redis. When Redis is not
+// available, every instance limits the concurrent requests to its share
+// of the limit. When the registry has no Redis configured, every
+// instance limits the concurrent requests to the full limit locally.
+// The SWIM based swarm is not supported.
+//
+// The returned Spec also implements routing.PostProcessor, which drops
+// the limiters not used by the routes anymore.
+//
+// Example to allow 100 concurrent requests to a backend:
+//
+//	clusterConcurrencyLimit("backend", 100)
+func NewClusterConcurrencyLimit(registry *ratelimit.Registry) filters.Spec {
+	return &concurrencySpec{
+		create: func(group string, max int, leaseTTL time.Duration) concurrencyLimiter {
+			return ratelimit.NewClusterConcurrency(registry, group, max, leaseTTL)
+		},
+		limiters: make(map[string]concurrencyLimiter),
+	}
+}
+
+func (*concurrencySpec) Name() string {
+	return filters.ClusterConcurrencyLimitName
+}
+
+func (s *concurrencySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 2 || len(args) > 4 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	group, err := getStringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	max, err := natural(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var leaseTTL time.Duration
+	if len(args) > 2 {
+		leaseTTL, err = getDurationArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+
+		if leaseTTL <= 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	statusCode := http.StatusServiceUnavailable
+	if len(args) > 3 {
+		statusCode, err = getIntArg(args[3])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := fmt.Sprintf("%s-%d-%v", group, max, leaseTTL)
+	return &concurrencyFilter{key: key, limiter: s.get(key, group, max, leaseTTL), statusCode: statusCode}, nil
+}
+
+// get returns the same limiter for the same settings, so that the local
+// fallback is shared by the routes of the group, and it is kept across
+// the route updates.
+func (s *concurrencySpec) get(key, group string, max int, leaseTTL time.Duration) concurrencyLimiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.limiters[key]
+	if !ok {
+		l = s.create(group, max, leaseTTL)
+		s.limiters[key] = l
+	}
+
+	return l
+}
+
+// Do implements routing.PostProcessor and drops the limiters, which are
+// not used by any of the routes.
+func (s *concurrencySpec) Do(routes []*routing.Route) []*routing.Route {
+	inUse := make(map[string]struct{})
+	for _, r := range routes {
+		for _, f := range r.Filters {
+			if cf, ok := f.Filter.(*concurrencyFilter); ok {
+				inUse[cf.key] = struct{}{}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.limiters {
+		if _, ok := inUse[key]; !ok {
+			delete(s.limiters, key)
+		}
+	}
+
+	return routes
+}
+
+func (f *concurrencyFilter) Request(ctx filters.FilterContext) {
+	release, ok := f.limiter.Acquire(ctx.Request().Context())
+	if !ok {
+		ctx.Serve(&http.Response{StatusCode: f.statusCode})
+		return
+	}
+
+	pending, _ := ctx.StateBag()[ratelimit.ConcurrencyKey].([]func())
+	ctx.StateBag()[ratelimit.ConcurrencyKey] = append(pending, release)
+}
+
+// Response releases the lease of the request. The leases of the
+// requests, whose response filters are not executed, e.g. because of a
+// backend error, are released by the proxy.
+func (*concurrencyFilter) Response(ctx filters.FilterContext) {
+	pending, _ := ctx.StateBag()[ratelimit.ConcurrencyKey].([]func())
+	last := len(pending) - 1
+	if last < 0 {
+		return
+	}
+
+	pending[last]()
+	ctx.StateBag()[ratelimit.ConcurrencyKey] = pending[:last]
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/ratelimit"
+	"github.com/zalando/skipper/routing"
+)
+
+type testConcurrency struct {
+	max, active int
+}
+
+func (c *testConcurrency) Acquire(context.Context) (func(), bool) {
+	if c.active >= c.max {
+		return nil, false
+	}
+
+	c.active++
+	return func() { c.active-- }, true
+}
+
+func newTestConcurrencySpec() (*concurrencySpec, *int) {
+	var created int
+	return &concurrencySpec{
+		create: func(_ string, max int, _ time.Duration) concurrencyLimiter {
+			created++
+			return &testConcurrency{max: max}
+		},
+		limiters: make(map[string]concurrencyLimiter),
+	}, &created
+}
+
+func TestClusterConcurrencyLimitArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		args []interface{}
+		fail bool
+	}{
+		{"ok", []interface{}{"backend", 10}, false},
+		{"lease ttl", []interface{}{"backend", 10, "30s"}, false},
+		{"status code", []interface{}{"backend", 10, "30s", 429}, false},
+		{"missing max", []interface{}{"backend"}, true},
+		{"invalid group", []interface{}{1, 10}, true},
+		{"zero max", []interface{}{"backend", 0}, true},
+		{"invalid lease ttl", []interface{}{"backend", 10, "foo"}, true},
+		{"negative lease ttl", []interface{}{"backend", 10, "-1s"}, true},
+		{"invalid status code", []interface{}{"backend", 10, "30s", "foo"}, true},
+		{"too many", []interface{}{"backend", 10, "30s", 429, 1}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, _ := newTestConcurrencySpec()
+			_, err := spec.CreateFilter(tc.args)
+			if tc.fail && err == nil {
+				t.Error("failed to fail")
+			} else if !tc.fail && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestClusterConcurrencyLimitShared(t *testing.T) {
+	spec, created := newTestConcurrencySpec()
+	for _, args := range [][]interface{}{
+		{"backend", 10},
+		{"backend", 10},
+		{"backend", 10, "1m"},
+		{"other", 10},
+		{"backend", 20},
+	} {
+		if _, err := spec.CreateFilter(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if *created != 4 {
+		t.Errorf("expected 4 limiters, got: %d", *created)
+	}
+}
+
+func TestClusterConcurrencyLimitCleanup(t *testing.T) {
+	spec, created := newTestConcurrencySpec()
+
+	f1, err := spec.CreateFilter([]interface{}{"backend", 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := spec.CreateFilter([]interface{}{"other", 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	spec.Do([]*routing.Route{{Filters: []*routing.RouteFilter{{Filter: f1}}}})
+	if len(spec.limiters) != 1 {
+		t.Errorf("expected 1 limiter, got: %d", len(spec.limiters))
+	}
+
+	if _, err := spec.CreateFilter([]interface{}{"backend", 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := spec.CreateFilter([]interface{}{"other", 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if *created != 3 {
+		t.Errorf("expected 3 limiters, got: %d", *created)
+	}
+}
+
+func TestClusterConcurrencyLimit(t *testing.T) {
+	spec, _ := newTestConcurrencySpec()
+	f, err := spec.CreateFilter([]interface{}{"backend", 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limiter := f.(*concurrencyFilter).limiter.(*testConcurrency)
+
+	request := func() *filtertest.Context {
+		ctx := &filtertest.Context{
+			FRequest:  &http.Request{},
+			FStateBag: make(map[string]interface{}),
+		}
+
+		f.Request(ctx)
+		return ctx
+	}
+
+	ctx1 := request()
+	ctx2 := request()
+	if ctx1.FServed || ctx2.FServed || limiter.active != 2 {
+		t.Fatalf("failed to allow the requests, active: %d", limiter.active)
+	}
+
+	ctx3 := request()
+	if !ctx3.FServed || ctx3.FResponse.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("failed to limit the request: %v", ctx3.FResponse)
+	}
+
+	f.Response(ctx1)
+	if limiter.active != 1 || len(ctx1.FStateBag[ratelimit.ConcurrencyKey].([]func())) != 0 {
+		t.Fatalf("failed to release the lease, active: %d", limiter.active)
+	}
+
+	// no lease to release
+	f.Response(ctx3)
+	if limiter.active != 1 {
+		t.Fatalf("unexpected release, active: %d", limiter.active)
+	}
+
+	ctx4 := request()
+	if ctx4.FServed {
+		t.Fatal("failed to allow the request")
+	}
+}
@@ -8,6 +8,7 @@ package ratelimit
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,7 +17,13 @@ import (
 	"github.com/zalando/skipper/ratelimit"
 )
 
-const defaultStatusCode = http.StatusTooManyRequests
+const (
+	defaultStatusCode = http.StatusTooManyRequests
+
+	// defaultIPv6PrefixLength is used by the client IP prefix lookuper,
+	// when only the IPv4 prefix length is set
+	defaultIPv6PrefixLength = 64
+)
 
 type spec struct {
 	typ        ratelimit.RatelimitType
@@ -30,6 +37,9 @@ type filter struct {
 	provider   RatelimitProvider
 	statusCode int
 	maxHits    int // overrides settings.MaxHits
+
+	// quotaHeaders enables the RateLimit quota headers
+	quotaHeaders bool
 }
 
 // RatelimitProvider returns a limit instance for provided Settings
@@ -44,15 +54,24 @@ type limit interface {
 	// RetryAfter is used to inform the client how many seconds it
 	// should wait before making a new request
 	RetryAfter(string) int
+
+	// AllowQuota is used instead of AllowContext to inform the client
+	// about the remaining quota with the RateLimit response headers
+	AllowQuota(context.Context, string) (bool, ratelimit.Quota, bool)
 }
 
+// quotaStateBagKey is used to pass the quota of the allowed requests to
+// the response filters
+const quotaStateBagKey = "filter." + filters.RatelimitName + ".quota"
+
 // RegistryAdapter adapts ratelimit.Registry to RateLimitProvider interface.
 // ratelimit.Registry is not an interface and its Get method returns
 // ratelimit.Ratelimit which is not an interface either
 // RegistryAdapter narrows ratelimit interfaces to necessary minimum
 // and enables easier test stubbing
 type registryAdapter struct {
-	registry *ratelimit.Registry
+	registry     *ratelimit.Registry
+	quotaHeaders bool
 }
 
 func (a *registryAdapter) get(s ratelimit.Settings) limit {
@@ -60,7 +79,15 @@ func (a *registryAdapter) get(s ratelimit.Settings) limit {
 }
 
 func NewRatelimitProvider(registry *ratelimit.Registry) RatelimitProvider {
-	return &registryAdapter{registry}
+	return &registryAdapter{registry: registry}
+}
+
+// NewQuotaHeadersRatelimitProvider is like NewRatelimitProvider, but the
+// ratelimit filters created with the returned provider also set the
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers.
+func NewQuotaHeadersRatelimitProvider(registry *ratelimit.Registry) RatelimitProvider {
+	return &registryAdapter{registry: registry, quotaHeaders: true}
 }
 
 // NewLocalRatelimit is *DEPRECATED*, use NewClientRatelimit, instead
@@ -185,6 +212,39 @@ func NewDisableRatelimit(provider RatelimitProvider) filters.Spec {
 	return &spec{typ: ratelimit.DisableRatelimit, provider: provider, filterName: filters.DisableRatelimitName}
 }
 
+// NewTokenBucketRatelimit creates an instance based client rate limit
+// with a token bucket, that allows the clients to burst up to the
+// given number of requests, while the average rate is limited to the
+// given number of requests per time window. The optional fourth
+// argument chooses the HTTP headers to find the same client, the same
+// way as for the clientRatelimit filter. Without it, the buckets are
+// selected by the X-Forwarded-For header.
+//
+// Example to allow 10 requests per second with bursts of 50 requests:
+//
+//    api: Path("/api")
+//    -> tokenBucketRatelimit(10, "1s", 50)
+//    -> "https://foo.backend.net";
+//
+func NewTokenBucketRatelimit(provider RatelimitProvider) filters.Spec {
+	return &spec{typ: ratelimit.TokenBucketRatelimit, provider: provider, filterName: filters.TokenBucketRatelimitName}
+}
+
+// NewClusterTokenBucketRatelimit creates a client rate limit with a
+// token bucket, that is shared by all instances via redis. The first
+// argument is the ratelimit group, the rest are the same as for the
+// tokenBucketRatelimit filter.
+//
+// Example:
+//
+//    api: Path("/api")
+//    -> clusterTokenBucketRatelimit("api", 100, "1s", 500, "Authorization")
+//    -> "https://foo.backend.net";
+//
+func NewClusterTokenBucketRatelimit(provider RatelimitProvider) filters.Spec {
+	return &spec{typ: ratelimit.ClusterTokenBucketRatelimit, provider: provider, filterName: filters.ClusterTokenBucketRatelimitName}
+}
+
 func (s *spec) Name() string {
 	return s.filterName
 }
@@ -314,14 +374,9 @@ func clusterClientRatelimitFilter(args []interface{}) (*filter, error) {
 		if err != nil {
 			return nil, err
 		}
-		if strings.Contains(lookuperString, ",") {
-			var lookupers []ratelimit.Lookuper
-			for _, ls := range strings.Split(lookuperString, ",") {
-				lookupers = append(lookupers, getLookuper(ls))
-			}
-			s.Lookuper = ratelimit.NewTupleLookuper(lookupers...)
-		} else {
-			s.Lookuper = getLookuper(lookuperString)
+		s.Lookuper, err = getLookupers(lookuperString)
+		if err != nil {
+			return nil, err
 		}
 	} else {
 		s.Lookuper = ratelimit.NewXForwardedForLookuper()
@@ -330,13 +385,142 @@ func clusterClientRatelimitFilter(args []interface{}) (*filter, error) {
 	return &filter{settings: s, statusCode: defaultStatusCode}, nil
 }
 
-func getLookuper(s string) ratelimit.Lookuper {
-	headerName := http.CanonicalHeaderKey(s)
-	if headerName == "X-Forwarded-For" {
-		return ratelimit.NewXForwardedForLookuper()
-	} else {
-		return ratelimit.NewHeaderLookuper(headerName)
+func tokenBucketRatelimitFilter(typ ratelimit.RatelimitType, args []interface{}) (*filter, error) {
+	var group string
+	if typ == ratelimit.ClusterTokenBucketRatelimit {
+		if len(args) == 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		var err error
+		group, err = getStringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		args = args[1:]
+	}
+
+	if !(len(args) == 3 || len(args) == 4) {
+		return nil, filters.ErrInvalidFilterParameters
 	}
+
+	maxHits, err := getIntArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	timeWindow, err := getDurationArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	burst, err := getIntArg(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	// the time window needs to be long enough to refill a token in at
+	// least a nanosecond
+	if maxHits <= 0 || timeWindow <= 0 || burst <= 0 || timeWindow < time.Duration(maxHits) {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	s := ratelimit.Settings{
+		Type:          typ,
+		Group:         group,
+		MaxHits:       maxHits,
+		TimeWindow:    timeWindow,
+		Burst:         burst,
+		CleanInterval: 10 * timeWindow,
+		Lookuper:      ratelimit.NewXForwardedForLookuper(),
+	}
+
+	if len(args) > 3 {
+		lookuperString, err := getStringArg(args[3])
+		if err != nil {
+			return nil, err
+		}
+
+		s.Lookuper, err = getLookupers(lookuperString)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &filter{settings: s, statusCode: defaultStatusCode}, nil
+}
+
+// getLookuper returns the lookuper defined by s, which is either a
+// header name, or one of "jwt:<claim>", "cookie:<name>", "path:<name>",
+// "query:<name>" and "ip:<IPv4 prefix length>[:<IPv6 prefix length>]".
+func getLookuper(s string) (ratelimit.Lookuper, error) {
+	kind, name, ok := strings.Cut(s, ":")
+	if !ok {
+		headerName := http.CanonicalHeaderKey(s)
+		if headerName == "X-Forwarded-For" {
+			return ratelimit.NewXForwardedForLookuper(), nil
+		} else {
+			return ratelimit.NewHeaderLookuper(headerName), nil
+		}
+	}
+
+	if name == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	switch kind {
+	case "jwt":
+		return ratelimit.NewJWTClaimLookuper(name), nil
+	case "cookie":
+		return ratelimit.NewCookieLookuper(name), nil
+	case "path":
+		return ratelimit.NewPathParamLookuper(name), nil
+	case "query":
+		return ratelimit.NewQueryLookuper(name), nil
+	case "ip":
+		return getClientIPPrefixLookuper(name)
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+}
+
+func getClientIPPrefixLookuper(s string) (ratelimit.Lookuper, error) {
+	v4, v6, hasV6 := strings.Cut(s, ":")
+	v4Bits, err := strconv.Atoi(v4)
+	if err != nil || v4Bits < 0 || v4Bits > 32 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	v6Bits := defaultIPv6PrefixLength
+	if hasV6 {
+		v6Bits, err = strconv.Atoi(v6)
+		if err != nil || v6Bits < 0 || v6Bits > 128 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return ratelimit.NewClientIPPrefixLookuper(v4Bits, v6Bits), nil
+}
+
+// getLookupers returns the lookuper defined by s, combining the
+// lookupers separated by ",".
+func getLookupers(s string) (ratelimit.Lookuper, error) {
+	var lookupers []ratelimit.Lookuper
+	for _, ls := range strings.Split(s, ",") {
+		l, err := getLookuper(ls)
+		if err != nil {
+			return nil, err
+		}
+
+		lookupers = append(lookupers, l)
+	}
+
+	if len(lookupers) > 1 {
+		return ratelimit.NewTupleLookuper(lookupers...), nil
+	}
+
+	return lookupers[0], nil
 }
 
 func clientRatelimitFilter(args []interface{}) (*filter, error) {
@@ -360,12 +544,11 @@ func clientRatelimitFilter(args []interface{}) (*filter, error) {
 		if err != nil {
 			return nil, err
 		}
-		if strings.Contains(lookuperString, ",") {
-			var lookupers []ratelimit.Lookuper
-			for _, ls := range strings.Split(lookuperString, ",") {
-				lookupers = append(lookupers, getLookuper(ls))
+		if strings.ContainsAny(lookuperString, ",:") {
+			lookuper, err = getLookupers(lookuperString)
+			if err != nil {
+				return nil, err
 			}
-			lookuper = ratelimit.NewTupleLookuper(lookupers...)
 		} else {
 			lookuper = ratelimit.NewHeaderLookuper(lookuperString)
 		}
@@ -398,6 +581,9 @@ func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
 	f, err := s.createFilter(args)
 	if f != nil {
 		f.provider = s.provider
+		if a, ok := s.provider.(*registryAdapter); ok {
+			f.quotaHeaders = a.quotaHeaders
+		}
 	}
 	return f, err
 }
@@ -415,6 +601,8 @@ func (s *spec) createFilter(args []interface{}) (*filter, error) {
 		return clusterRatelimitFilter(s.maxShards, args)
 	case ratelimit.ClusterClientRatelimit:
 		return clusterClientRatelimitFilter(args)
+	case ratelimit.TokenBucketRatelimit, ratelimit.ClusterTokenBucketRatelimit:
+		return tokenBucketRatelimitFilter(s.typ, args)
 	default:
 		return disableFilter(args)
 	}
@@ -471,22 +659,87 @@ func (f *filter) Request(ctx filters.FilterContext) {
 		return
 	}
 
-	s := f.settings.Lookuper.Lookup(ctx.Request())
+	s := lookup(f.settings.Lookuper, ctx)
 	if s == "" {
 		log.Debugf("Lookuper found no data in request for settings: %s and request: %v", f.settings, ctx.Request())
 		return
 	}
 
-	if !rateLimiter.AllowContext(ctx.Request().Context(), s) {
+	var (
+		allowed  bool
+		q        ratelimit.Quota
+		hasQuota bool
+	)
+	if f.quotaHeaders {
+		allowed, q, hasQuota = rateLimiter.AllowQuota(ctx.Request().Context(), s)
+	} else {
+		allowed = rateLimiter.AllowContext(ctx.Request().Context(), s)
+	}
+
+	if !allowed {
 		maxHits := f.settings.MaxHits
 		if f.maxHits != 0 {
 			maxHits = f.maxHits
 		}
+
+		header := ratelimit.Headers(maxHits, f.settings.TimeWindow, rateLimiter.RetryAfter(s))
+		if hasQuota {
+			copyHeader(header, ratelimit.QuotaHeaders(q))
+		}
+
 		ctx.Serve(&http.Response{
 			StatusCode: f.statusCode,
-			Header:     ratelimit.Headers(maxHits, f.settings.TimeWindow, rateLimiter.RetryAfter(s)),
+			Header:     header,
 		})
+
+		return
+	}
+
+	if hasQuota {
+		storeQuota(ctx, q)
+	}
+}
+
+// storeQuota passes the quota of an allowed request to the response
+// filters. With multiple ratelimits on the route, the most restrictive
+// one is reported.
+func storeQuota(ctx filters.FilterContext, q ratelimit.Quota) {
+	if current, ok := ctx.StateBag()[quotaStateBagKey].(ratelimit.Quota); !ok || q.Remaining < current.Remaining {
+		ctx.StateBag()[quotaStateBagKey] = q
+	}
+}
+
+// setQuotaHeaders sets the RateLimit response headers of the allowed
+// requests.
+func setQuotaHeaders(ctx filters.FilterContext) {
+	q, ok := ctx.StateBag()[quotaStateBagKey].(ratelimit.Quota)
+	if !ok {
+		return
+	}
+
+	rsp := ctx.Response()
+	if rsp.Header == nil {
+		rsp.Header = make(http.Header)
+	}
+
+	copyHeader(rsp.Header, ratelimit.QuotaHeaders(q))
+}
+
+func lookup(l ratelimit.Lookuper, ctx filters.FilterContext) string {
+	if lc, ok := l.(ratelimit.FilterContextLookuper); ok {
+		return lc.LookupFilterContext(ctx)
+	}
+
+	return l.Lookup(ctx.Request())
+}
+
+func copyHeader(to, from http.Header) {
+	for k, v := range from {
+		to[k] = v
 	}
 }
 
-func (*filter) Response(filters.FilterContext) {}
+// Response sets the RateLimit response headers of the allowed requests.
+func (*filter) Response(ctx filters.FilterContext) {
+	setQuotaHeaders(ctx)
+}
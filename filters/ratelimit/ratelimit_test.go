@@ -52,12 +52,34 @@ func TestArgs(t *testing.T) {
 	t.Run("clusterClient", func(t *testing.T) {
 		rl := NewClusterClientRateLimit(provider)
 		t.Run("missing", testErr(rl, nil))
+		t.Run("lookupers", testOK(rl, "mygroup", 10, "1s", "jwt:sub,cookie:session,path:id,query:key,ip:24,ip:16:48"))
+		t.Run("unknown lookuper", testErr(rl, "mygroup", 10, "1s", "foo:bar"))
+		t.Run("missing lookuper name", testErr(rl, "mygroup", 10, "1s", "jwt:"))
+		t.Run("invalid IPv4 prefix", testErr(rl, "mygroup", 10, "1s", "ip:33"))
+		t.Run("invalid IPv6 prefix", testErr(rl, "mygroup", 10, "1s", "ip:24:129"))
+		t.Run("invalid prefix", testErr(rl, "mygroup", 10, "1s", "ip:foo"))
 	})
 
 	t.Run("disable", func(t *testing.T) {
 		rl := NewDisableRatelimit(provider)
 		t.Run("no args, ok", testOK(rl))
 	})
+
+	t.Run("tokenBucket", func(t *testing.T) {
+		rl := NewTokenBucketRatelimit(provider)
+		t.Run("missing", testErr(rl, nil))
+		t.Run("missing burst", testErr(rl, 10, "1s"))
+		t.Run("zero burst", testErr(rl, 10, "1s", 0))
+		t.Run("ok", testOK(rl, 10, "1s", 20))
+		t.Run("with lookuper", testOK(rl, 10, "1s", 20, "Authorization"))
+	})
+
+	t.Run("clusterTokenBucket", func(t *testing.T) {
+		rl := NewClusterTokenBucketRatelimit(provider)
+		t.Run("missing", testErr(rl, nil))
+		t.Run("missing group", testErr(rl, 10, "1s", 20))
+		t.Run("ok", testOK(rl, "mygroup", 10, "1s", 20))
+	})
 }
 
 type testLimit struct {
@@ -79,6 +101,9 @@ func (l *testLimit) get(s ratelimit.Settings) limit {
 }
 func (l *testLimit) AllowContext(context.Context, string) bool { return false }
 func (l *testLimit) RetryAfter(string) int                     { return 31415 }
+func (l *testLimit) AllowQuota(ctx context.Context, s string) (bool, ratelimit.Quota, bool) {
+	return l.AllowContext(ctx, s), ratelimit.Quota{}, false
+}
 
 func TestRateLimit(t *testing.T) {
 	test := func(
@@ -258,6 +283,27 @@ func TestRateLimit(t *testing.T) {
 		"Authorization",
 	))
 
+	t.Run("ratelimit client IP prefix", test(
+		NewClientRatelimit,
+		ratelimit.Settings{
+			Type:          ratelimit.ClientRatelimit,
+			MaxHits:       3,
+			TimeWindow:    1 * time.Second,
+			CleanInterval: 10 * time.Second,
+			Lookuper:      ratelimit.NewClientIPPrefixLookuper(24, 64),
+		},
+		&http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header: http.Header{
+				"X-Rate-Limit": []string{"10800"},
+				"Retry-After":  []string{"31415"},
+			},
+		},
+		3,
+		"1s",
+		"ip:24",
+	))
+
 	t.Run("ratelimit cluster", test(
 		NewClusterRateLimit,
 		ratelimit.Settings{
@@ -371,6 +417,33 @@ func TestRateLimit(t *testing.T) {
 		"Authorization,X-Forwarded-For",
 	))
 
+	t.Run("ratelimit clusterClient lookupers", test(
+		NewClusterClientRateLimit,
+		ratelimit.Settings{
+			Type:          ratelimit.ClusterClientRatelimit,
+			MaxHits:       3,
+			TimeWindow:    1 * time.Second,
+			CleanInterval: 10 * time.Second,
+			Lookuper: ratelimit.NewTupleLookuper(
+				ratelimit.NewCookieLookuper("session"),
+				ratelimit.NewPathParamLookuper("id"),
+				ratelimit.NewQueryLookuper("key"),
+				ratelimit.NewClientIPPrefixLookuper(24, 48)),
+			Group: "mygroup",
+		},
+		&http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header: http.Header{
+				"X-Rate-Limit": []string{"10800"},
+				"Retry-After":  []string{"31415"},
+			},
+		},
+		"mygroup",
+		3,
+		"1s",
+		"cookie:session,path:id,query:key,ip:24:48",
+	))
+
 	t.Run("ratelimit clusterClient header", test(
 		NewClusterClientRateLimit,
 		ratelimit.Settings{
@@ -394,6 +467,56 @@ func TestRateLimit(t *testing.T) {
 		"Authorization",
 	))
 
+	t.Run("ratelimit tokenBucket", test(
+		NewTokenBucketRatelimit,
+		ratelimit.Settings{
+			Type:          ratelimit.TokenBucketRatelimit,
+			MaxHits:       3,
+			TimeWindow:    1 * time.Second,
+			Burst:         10,
+			CleanInterval: 10 * time.Second,
+			Lookuper:      ratelimit.NewXForwardedForLookuper(),
+		},
+		&http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header: http.Header{
+				"X-Rate-Limit": []string{"10800"},
+				"Retry-After":  []string{"31415"},
+			},
+		},
+		3,
+		"1s",
+		10,
+	))
+
+	t.Run("ratelimit clusterTokenBucket with tuple lookuper", test(
+		NewClusterTokenBucketRatelimit,
+		ratelimit.Settings{
+			Type:          ratelimit.ClusterTokenBucketRatelimit,
+			MaxHits:       3,
+			TimeWindow:    1 * time.Second,
+			Burst:         10,
+			CleanInterval: 10 * time.Second,
+			Lookuper: ratelimit.NewTupleLookuper(
+				ratelimit.NewHeaderLookuper("Authorization"),
+				ratelimit.NewXForwardedForLookuper(),
+			),
+			Group: "mygroup",
+		},
+		&http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header: http.Header{
+				"X-Rate-Limit": []string{"10800"},
+				"Retry-After":  []string{"31415"},
+			},
+		},
+		"mygroup",
+		3,
+		"1s",
+		10,
+		"Authorization,X-Forwarded-For",
+	))
+
 	t.Run("ratelimit disable", test(
 		NewDisableRatelimit,
 		ratelimit.Settings{Type: ratelimit.DisableRatelimit},
@@ -413,6 +536,9 @@ func (n *noLimit) get(ratelimit.Settings) limit {
 }
 func (n *noLimit) AllowContext(context.Context, string) bool { return true }
 func (n *noLimit) RetryAfter(string) int                     { panic("unexpected RetryAfter call") }
+func (n *noLimit) AllowQuota(ctx context.Context, s string) (bool, ratelimit.Quota, bool) {
+	return n.AllowContext(ctx, s), ratelimit.Quota{}, false
+}
 
 func TestNilLimit(t *testing.T) {
 	f := &filter{provider: &noLimit{nilLimit: true}}
@@ -464,6 +590,143 @@ func TestAllowsContext(t *testing.T) {
 	}
 }
 
+type quotaLimit struct {
+	allow   bool
+	quota   ratelimit.Quota
+	queried bool
+}
+
+func (q *quotaLimit) get(ratelimit.Settings) limit              { return q }
+func (q *quotaLimit) AllowContext(context.Context, string) bool { return q.allow }
+func (q *quotaLimit) RetryAfter(string) int                     { return 3 }
+func (q *quotaLimit) AllowQuota(context.Context, string) (bool, ratelimit.Quota, bool) {
+	q.queried = true
+	return q.allow, q.quota, true
+}
+
+func TestQuotaHeaders(t *testing.T) {
+	settings := ratelimit.Settings{MaxHits: 10, TimeWindow: time.Minute, Lookuper: &lookuper{"key"}}
+
+	t.Run("allowed", func(t *testing.T) {
+		f1 := &filter{settings: settings, quotaHeaders: true, provider: &quotaLimit{allow: true, quota: ratelimit.Quota{Limit: 10, Remaining: 7, Reset: 2 * time.Second, Policy: "10;w=60"}}}
+		f2 := &filter{settings: settings, quotaHeaders: true, provider: &quotaLimit{allow: true, quota: ratelimit.Quota{Limit: 20, Remaining: 9, Reset: time.Second, Policy: "20;w=60"}}}
+		ctx := &filtertest.Context{FRequest: &http.Request{}, FStateBag: make(map[string]interface{})}
+
+		f1.Request(ctx)
+		f2.Request(ctx)
+		if ctx.FResponse != nil {
+			t.Fatalf("unexpected response: %v", ctx.FResponse)
+		}
+
+		ctx.FResponse = &http.Response{StatusCode: http.StatusOK}
+		f2.Response(ctx)
+		f1.Response(ctx)
+
+		expected := http.Header{
+			"Ratelimit-Limit":     []string{"10"},
+			"Ratelimit-Remaining": []string{"7"},
+			"Ratelimit-Reset":     []string{"2"},
+			"Ratelimit-Policy":    []string{"10;w=60"},
+		}
+		if !reflect.DeepEqual(ctx.FResponse.Header, expected) {
+			t.Errorf("unexpected headers, expected %v, got: %v", expected, ctx.FResponse.Header)
+		}
+	})
+
+	t.Run("ratelimited", func(t *testing.T) {
+		f := &filter{settings: settings, statusCode: http.StatusTooManyRequests, quotaHeaders: true, provider: &quotaLimit{quota: ratelimit.Quota{Limit: 10, Reset: 3 * time.Second, Policy: "10;w=60"}}}
+		ctx := &filtertest.Context{FRequest: &http.Request{}, FStateBag: make(map[string]interface{})}
+
+		f.Request(ctx)
+
+		expected := &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header: http.Header{
+				"X-Rate-Limit":        []string{"600"},
+				"Retry-After":         []string{"3"},
+				"Ratelimit-Limit":     []string{"10"},
+				"Ratelimit-Remaining": []string{"0"},
+				"Ratelimit-Reset":     []string{"3"},
+				"Ratelimit-Policy":    []string{"10;w=60"},
+			},
+		}
+		if !reflect.DeepEqual(ctx.FResponse, expected) {
+			t.Errorf("unexpected response, expected %v, got: %v", expected, ctx.FResponse)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		l := &quotaLimit{allow: true, quota: ratelimit.Quota{Limit: 10, Remaining: 7, Reset: 2 * time.Second, Policy: "10;w=60"}}
+		f := &filter{settings: settings, provider: l}
+		ctx := &filtertest.Context{FRequest: &http.Request{}, FStateBag: make(map[string]interface{})}
+
+		f.Request(ctx)
+		ctx.FResponse = &http.Response{StatusCode: http.StatusOK}
+		f.Response(ctx)
+
+		if l.queried {
+			t.Error("unexpected quota query")
+		}
+
+		if len(ctx.FResponse.Header) != 0 {
+			t.Errorf("unexpected headers: %v", ctx.FResponse.Header)
+		}
+	})
+}
+
+func TestQuotaHeadersProvider(t *testing.T) {
+	registry := ratelimit.NewRegistry()
+	defer registry.Close()
+
+	for _, tt := range []struct {
+		provider RatelimitProvider
+		expected bool
+	}{
+		{NewRatelimitProvider(registry), false},
+		{NewQuotaHeadersRatelimitProvider(registry), true},
+	} {
+		f, err := NewRatelimit(tt.provider).CreateFilter([]interface{}{3, "1s"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if qh := f.(*filter).quotaHeaders; qh != tt.expected {
+			t.Errorf("expected quota headers %v, got: %v", tt.expected, qh)
+		}
+	}
+}
+
+func TestPathParamLookuper(t *testing.T) {
+	f, err := NewClientRatelimit(&noLimit{}).CreateFilter([]interface{}{3, "1s", "path:id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	f.(*filter).provider = &lookupLimit{keys: &keys}
+	ctx := &filtertest.Context{FRequest: &http.Request{}, FParams: map[string]string{"id": "foo"}}
+
+	f.Request(ctx)
+
+	if len(keys) != 1 || keys[0] != "foo" {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
+
+type lookupLimit struct {
+	keys *[]string
+}
+
+func (l *lookupLimit) get(ratelimit.Settings) limit { return l }
+func (l *lookupLimit) AllowContext(_ context.Context, s string) bool {
+	*l.keys = append(*l.keys, s)
+	return true
+}
+func (l *lookupLimit) RetryAfter(string) int { return 0 }
+func (l *lookupLimit) AllowQuota(ctx context.Context, s string) (bool, ratelimit.Quota, bool) {
+	return l.AllowContext(ctx, s), ratelimit.Quota{}, false
+}
+
 func TestGetKeyShards(t *testing.T) {
 	for _, tc := range []struct {
 		maxHits      int
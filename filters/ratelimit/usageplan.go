@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/ratelimit"
+)
+
+type usagePlans interface {
+	AllowContext(ctx context.Context, group, consumer string) (bool, int, ratelimit.Quota)
+}
+
+type usagePlanSpec struct {
+	plans usagePlans
+}
+
+type usagePlanFilter struct {
+	plans    usagePlans
+	group    string
+	lookuper ratelimit.Lookuper
+}
+
+// NewUsagePlanRatelimit creates a filter Spec, whose instances limit
+// the requests of the consumers according to their usage plans. The
+// consumers are identified by the lookuper, which accepts the same
+// definitions as the clientRatelimit filter, e.g. a header name or
+// "jwt:client_id".
+//
+// Example to limit the requests by the client_id claim of the JWT:
+//
+//	api: Path("/api")
+//	-> jwtValidation("https://login.example.org")
+//	-> usagePlanRatelimit("api", "jwt:client_id")
+//	-> "https://foo.backend.net";
+func NewUsagePlanRatelimit(plans *ratelimit.UsagePlans) filters.Spec {
+	return &usagePlanSpec{plans: plans}
+}
+
+func (*usagePlanSpec) Name() string {
+	return filters.UsagePlanRatelimitName
+}
+
+func (s *usagePlanSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	group, err := getStringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	lookuperString, err := getStringArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	lookuper, err := getLookupers(lookuperString)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usagePlanFilter{plans: s.plans, group: group, lookuper: lookuper}, nil
+}
+
+// Request checks the request against the usage plan of the consumer,
+// and responds with 429 Too Many Requests, when any of the limits of
+// the plan is reached. The requests without a consumer are allowed.
+func (f *usagePlanFilter) Request(ctx filters.FilterContext) {
+	consumer := lookup(f.lookuper, ctx)
+	if consumer == "" {
+		return
+	}
+
+	allowed, retryAfter, q := f.plans.AllowContext(ctx.Request().Context(), f.group, consumer)
+	if allowed {
+		if q.Policy != "" {
+			storeQuota(ctx, q)
+		}
+
+		return
+	}
+
+	header := http.Header{ratelimit.RetryAfterHeader: []string{strconv.Itoa(retryAfter)}}
+	if q.Policy != "" {
+		copyHeader(header, ratelimit.QuotaHeaders(q))
+	}
+
+	ctx.Serve(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     header,
+	})
+}
+
+// Response sets the RateLimit response headers of the allowed requests.
+func (*usagePlanFilter) Response(ctx filters.FilterContext) {
+	setQuotaHeaders(ctx)
+}
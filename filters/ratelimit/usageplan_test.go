@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/ratelimit"
+)
+
+type testPlans struct {
+	allowed    bool
+	retryAfter int
+	quota      ratelimit.Quota
+	group      string
+	consumer   string
+}
+
+func (p *testPlans) AllowContext(_ context.Context, group, consumer string) (bool, int, ratelimit.Quota) {
+	p.group, p.consumer = group, consumer
+	return p.allowed, p.retryAfter, p.quota
+}
+
+func TestUsagePlanArgs(t *testing.T) {
+	spec := &usagePlanSpec{plans: &testPlans{}}
+	for _, tc := range []struct {
+		name string
+		args []interface{}
+		fail bool
+	}{
+		{"ok", []interface{}{"api", "jwt:client_id"}, false},
+		{"tuple", []interface{}{"api", "Authorization,ip:24"}, false},
+		{"missing lookuper", []interface{}{"api"}, true},
+		{"invalid group", []interface{}{42, "Authorization"}, true},
+		{"invalid lookuper", []interface{}{"api", "foo:bar"}, true},
+		{"too many", []interface{}{"api", "Authorization", 42}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := spec.CreateFilter(tc.args)
+			if tc.fail && err == nil {
+				t.Error("failed to fail")
+			} else if !tc.fail && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUsagePlanRatelimit(t *testing.T) {
+	quota := ratelimit.Quota{Limit: 1000, Remaining: 10, Reset: time.Hour, Policy: "1000;w=86400"}
+
+	create := func(t *testing.T, plans *testPlans) *filtertest.Context {
+		f, err := (&usagePlanSpec{plans: plans}).CreateFilter([]interface{}{"api", "X-Consumer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := &filtertest.Context{
+			FRequest:  &http.Request{Header: http.Header{"X-Consumer": []string{"client-a"}}},
+			FStateBag: make(map[string]interface{}),
+		}
+
+		f.Request(ctx)
+		if ctx.FResponse == nil {
+			ctx.FResponse = &http.Response{StatusCode: http.StatusOK}
+			f.Response(ctx)
+		}
+
+		return ctx
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		plans := &testPlans{allowed: true, quota: quota}
+		ctx := create(t, plans)
+
+		if plans.group != "api" || plans.consumer != "client-a" {
+			t.Errorf("unexpected group or consumer: %s, %s", plans.group, plans.consumer)
+		}
+
+		expected := http.Header{
+			"Ratelimit-Limit":     []string{"1000"},
+			"Ratelimit-Remaining": []string{"10"},
+			"Ratelimit-Reset":     []string{"3600"},
+			"Ratelimit-Policy":    []string{"1000;w=86400"},
+		}
+		if ctx.FResponse.StatusCode != http.StatusOK || !reflect.DeepEqual(ctx.FResponse.Header, expected) {
+			t.Errorf("unexpected response: %v", ctx.FResponse)
+		}
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		ctx := create(t, &testPlans{allowed: true})
+		if ctx.FResponse.StatusCode != http.StatusOK || len(ctx.FResponse.Header) != 0 {
+			t.Errorf("unexpected response: %v", ctx.FResponse)
+		}
+	})
+
+	t.Run("ratelimited", func(t *testing.T) {
+		q := quota
+		q.Remaining = 0
+		ctx := create(t, &testPlans{retryAfter: 3600, quota: q})
+
+		expected := &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header: http.Header{
+				"Retry-After":         []string{"3600"},
+				"Ratelimit-Limit":     []string{"1000"},
+				"Ratelimit-Remaining": []string{"0"},
+				"Ratelimit-Reset":     []string{"3600"},
+				"Ratelimit-Policy":    []string{"1000;w=86400"},
+			},
+		}
+		if !reflect.DeepEqual(ctx.FResponse, expected) {
+			t.Errorf("unexpected response, expected %v, got: %v", expected, ctx.FResponse)
+		}
+	})
+
+	t.Run("no consumer", func(t *testing.T) {
+		plans := &testPlans{}
+		f, _ := (&usagePlanSpec{plans: plans}).CreateFilter([]interface{}{"api", "X-Consumer"})
+		ctx := &filtertest.Context{FRequest: &http.Request{}, FStateBag: make(map[string]interface{})}
+
+		f.Request(ctx)
+
+		if ctx.FResponse != nil || plans.consumer != "" {
+			t.Errorf("unexpected response: %v", ctx.FResponse)
+		}
+	})
+}
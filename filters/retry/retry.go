@@ -0,0 +1,330 @@
+/*
+Package retry provides a filter to configure the retries of the backend
+requests on the route level.
+
+The retry() filter sets the retry policy of the route, that the proxy
+applies when the backend request fails or the backend responds with a
+retryable status code:
+
+	retry(3)
+	retry(3, "connect-failure,timeout,503")
+	retry(3, "connect-failure,502,503,504", "25ms", "250ms", 20)
+
+The arguments are:
+
+  - the maximum number of attempts, including the first one (int)
+  - the comma separated list of the retry conditions, optional, default:
+    "connect-failure,502,503,504". The conditions can be status codes,
+    "5xx", "connect-failure" and "timeout". When "non-idempotent" is set,
+    the requests with non-idempotent methods, e.g. POST, are retried, too.
+  - the base of the exponential backoff between the attempts, optional,
+    milliseconds or duration string, default: 25ms
+  - the maximum backoff, optional, milliseconds or duration string, default: 250ms
+  - the retry budget as a percentage of the requests of the route,
+    optional, default: 20
+
+Requests with a body are retried only when the body was buffered by the
+bufferRequestBody() filter.
+
+Every retry picks a different endpoint of a load balanced backend, when
+there are more endpoints available. The retry budget makes sure that the
+retries can't amplify an outage of the backend: every request of the route
+deposits the budget percentage to the budget, and every retry withdraws
+one. When the budget is exhausted, the requests are not retried. The
+budgets are kept by the proxy per route id, so they are not reset when the
+routes are updated.
+
+The backend timeout set by the backendTimeout() filter applies to every
+attempt, so that the timeout condition matches both the transport timeouts
+and the backend timeouts of the route.
+*/
+package retry
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	DefaultConditions = "connect-failure,502,503,504"
+	DefaultBackoff    = 25 * time.Millisecond
+	DefaultMaxBackoff = 250 * time.Millisecond
+	DefaultBudget     = 20
+
+	// the number of retries that a route can make initially, and the
+	// maximum number of retries the budget can be saved for
+	initialBudgetBalance = 10
+	maxBudgetBalance     = 100
+
+	// the budgets of the routes without requests for this duration are
+	// dropped
+	budgetIdleTTL = time.Hour
+)
+
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+type budget struct {
+	balance  float64
+	lastUsed time.Time
+}
+
+// Budgets hold the retry budgets of the routes, keyed by the route id, so
+// that the budgets are kept when the routes are updated. The budgets of
+// the routes that didn't receive requests for an hour are dropped.
+type Budgets struct {
+	mu        sync.Mutex
+	budgets   map[string]*budget
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// Retry is the retry policy of a route, set in the state bag by the
+// retry() filter.
+type Retry struct {
+	// MaxAttempts is the maximum number of the backend requests,
+	// including the first one.
+	MaxAttempts int
+
+	// Backoff is the base of the exponential backoff.
+	Backoff time.Duration
+
+	// MaxBackoff is the upper limit of the backoff.
+	MaxBackoff time.Duration
+
+	statusCodes    map[int]bool
+	all5xx         bool
+	connectFailure bool
+	timeout        bool
+	nonIdempotent  bool
+	budgetRatio    float64
+}
+
+type spec struct{}
+
+// NewBudgets creates the registry of the retry budgets.
+func NewBudgets() *Budgets {
+	return &Budgets{
+		budgets: make(map[string]*budget),
+		now:     time.Now,
+	}
+}
+
+// needs to be called with the lock held.
+func (b *Budgets) get(routeID string) *budget {
+	now := b.now()
+	if now.Sub(b.lastPrune) > budgetIdleTTL {
+		for id, bi := range b.budgets {
+			if now.Sub(bi.lastUsed) > budgetIdleTTL {
+				delete(b.budgets, id)
+			}
+		}
+
+		b.lastPrune = now
+	}
+
+	bi, ok := b.budgets[routeID]
+	if !ok {
+		bi = &budget{balance: initialBudgetBalance}
+		b.budgets[routeID] = bi
+	}
+
+	bi.lastUsed = now
+	return bi
+}
+
+// Deposit deposits the budget percentage of the retry policy to the
+// retry budget of the route. It needs to be called for every request of
+// the route.
+func (b *Budgets) Deposit(routeID string, r *Retry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bi := b.get(routeID)
+	bi.balance += r.budgetRatio
+	if bi.balance > maxBudgetBalance {
+		bi.balance = maxBudgetBalance
+	}
+}
+
+// Withdraw withdraws a retry from the retry budget of the route. It
+// returns false if the budget is exhausted.
+func (b *Budgets) Withdraw(routeID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bi := b.get(routeID)
+	if bi.balance < 1 {
+		return false
+	}
+
+	bi.balance--
+	return true
+}
+
+// NewRetry creates a filter specification for the retry() filter.
+func NewRetry() filters.Spec { return &spec{} }
+
+func (*spec) Name() string { return filters.RetryName }
+
+func getIntArg(a interface{}) (int, error) {
+	switch v := a.(type) {
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	default:
+		return 0, filters.ErrInvalidFilterParameters
+	}
+}
+
+func getDurationArg(a interface{}) (time.Duration, error) {
+	if s, ok := a.(string); ok {
+		return time.ParseDuration(s)
+	}
+
+	i, err := getIntArg(a)
+	return time.Duration(i) * time.Millisecond, err
+}
+
+func (r *Retry) parseConditions(s string) error {
+	r.statusCodes = make(map[int]bool)
+	for _, c := range strings.Split(s, ",") {
+		switch c = strings.TrimSpace(c); c {
+		case "5xx":
+			r.all5xx = true
+		case "connect-failure":
+			r.connectFailure = true
+		case "timeout":
+			r.timeout = true
+		case "non-idempotent":
+			r.nonIdempotent = true
+		default:
+			code, err := strconv.Atoi(c)
+			if err != nil || code < 100 || code > 599 {
+				return filters.ErrInvalidFilterParameters
+			}
+
+			r.statusCodes[code] = true
+		}
+	}
+
+	return nil
+}
+
+func (*spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args) > 5 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	attempts, err := getIntArg(args[0])
+	if err != nil || attempts < 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	conditions := DefaultConditions
+	if len(args) > 1 {
+		var ok bool
+		if conditions, ok = args[1].(string); !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	r := &Retry{
+		MaxAttempts: attempts,
+		Backoff:     DefaultBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+	}
+
+	if err := r.parseConditions(conditions); err != nil {
+		return nil, err
+	}
+
+	if len(args) > 2 {
+		if r.Backoff, err = getDurationArg(args[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(args) > 3 {
+		if r.MaxBackoff, err = getDurationArg(args[3]); err != nil {
+			return nil, err
+		}
+	}
+
+	percentage := float64(DefaultBudget)
+	if len(args) > 4 {
+		switch v := args[4].(type) {
+		case int:
+			percentage = float64(v)
+		case float64:
+			percentage = v
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	if percentage < 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	r.budgetRatio = percentage / 100
+	return r, nil
+}
+
+// Request sets the retry policy of the route in the state bag.
+func (r *Retry) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.RetryKey] = r
+}
+
+func (*Retry) Response(filters.FilterContext) {}
+
+// RetryableMethod returns true if the requests with the given method can
+// be retried.
+func (r *Retry) RetryableMethod(method string) bool {
+	return r.nonIdempotent || idempotentMethods[method]
+}
+
+// RetryOnStatus returns true if the responses with the given status code
+// should be retried.
+func (r *Retry) RetryOnStatus(code int) bool {
+	return r.statusCodes[code] || r.all5xx && code >= 500 && code < 600
+}
+
+// RetryOnConnectFailure returns true if the requests should be retried
+// when connecting to the backend failed.
+func (r *Retry) RetryOnConnectFailure() bool { return r.connectFailure }
+
+// RetryOnTimeout returns true if the requests should be retried when the
+// backend didn't respond in time.
+func (r *Retry) RetryOnTimeout() bool { return r.timeout }
+
+// BackoffDuration returns the time to wait before the given retry,
+// counted from 1, using exponential backoff with full jitter.
+func (r *Retry) BackoffDuration(retry int) time.Duration {
+	if r.Backoff <= 0 {
+		return 0
+	}
+
+	d := r.Backoff
+	for i := 1; i < retry && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if r.MaxBackoff > 0 && d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+
+	/* #nosec */
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
@@ -0,0 +1,183 @@
+package retry
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []interface{}
+		err  bool
+	}{
+		{name: "no args", args: nil, err: true},
+		{name: "attempts", args: []interface{}{3}},
+		{name: "attempts as float", args: []interface{}{3.0}},
+		{name: "invalid attempts", args: []interface{}{0}, err: true},
+		{name: "conditions", args: []interface{}{3, "connect-failure, timeout, 5xx, 429, non-idempotent"}},
+		{name: "invalid condition", args: []interface{}{3, "foo"}, err: true},
+		{name: "invalid status", args: []interface{}{3, "999"}, err: true},
+		{name: "backoff", args: []interface{}{3, "503", "10ms", 100}},
+		{name: "invalid backoff", args: []interface{}{3, "503", "foo"}, err: true},
+		{name: "budget", args: []interface{}{3, "503", "10ms", "100ms", 10.5}},
+		{name: "invalid budget", args: []interface{}{3, "503", "10ms", "100ms", "10"}, err: true},
+		{name: "too many args", args: []interface{}{3, "503", "10ms", "100ms", 10, 1}, err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRetry().CreateFilter(tt.args)
+			if tt.err && err == nil {
+				t.Error("expected error")
+			} else if !tt.err && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func createRetry(t *testing.T, args ...interface{}) *Retry {
+	f, err := NewRetry().CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f.(*Retry)
+}
+
+func TestConditions(t *testing.T) {
+	r := createRetry(t, 3)
+	if !r.RetryOnConnectFailure() || r.RetryOnTimeout() {
+		t.Error("unexpected default conditions")
+	}
+
+	for code, expected := range map[int]bool{500: false, 502: true, 503: true, 504: true, 429: false} {
+		if r.RetryOnStatus(code) != expected {
+			t.Errorf("unexpected retry for %d", code)
+		}
+	}
+
+	if !r.RetryableMethod("GET") || !r.RetryableMethod("PUT") || r.RetryableMethod("POST") {
+		t.Error("unexpected retryable methods")
+	}
+
+	r = createRetry(t, 3, "5xx,429,timeout,non-idempotent")
+	if r.RetryOnConnectFailure() || !r.RetryOnTimeout() {
+		t.Error("unexpected conditions")
+	}
+
+	for code, expected := range map[int]bool{500: true, 599: true, 429: true, 404: false} {
+		if r.RetryOnStatus(code) != expected {
+			t.Errorf("unexpected retry for %d", code)
+		}
+	}
+
+	if !r.RetryableMethod("POST") {
+		t.Error("expected non-idempotent methods to be retryable")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := createRetry(t, 5, "503", "10ms", "30ms")
+	for retry, max := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 30 * time.Millisecond, 10: 30 * time.Millisecond} {
+		for i := 0; i < 100; i++ {
+			if d := r.BackoffDuration(retry); d < 0 || d > max {
+				t.Fatalf("invalid backoff for retry %d: %v", retry, d)
+			}
+		}
+	}
+
+	r = createRetry(t, 5, "503", 0)
+	if d := r.BackoffDuration(3); d != 0 {
+		t.Errorf("expected no backoff, got: %v", d)
+	}
+}
+
+func TestRequest(t *testing.T) {
+	r := createRetry(t, 3)
+	ctx := &filtertest.Context{FRequest: &http.Request{}, FStateBag: make(map[string]interface{})}
+	r.Request(ctx)
+	if ctx.FStateBag[filters.RetryKey] != r {
+		t.Error("expected retry policy in the state bag")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	r := createRetry(t, 3, "503", 0, 0, 25)
+	b := NewBudgets()
+
+	for i := 0; i < initialBudgetBalance; i++ {
+		if !b.Withdraw("route1") {
+			t.Fatalf("expected retry %d to be allowed by the initial budget", i)
+		}
+	}
+
+	if b.Withdraw("route1") {
+		t.Fatal("expected budget to be exhausted")
+	}
+
+	for i := 0; i < 3; i++ {
+		b.Deposit("route1", r)
+	}
+
+	if b.Withdraw("route1") {
+		t.Fatal("expected budget to be exhausted")
+	}
+
+	// the budget is kept for the route, when the route is updated
+	updated := createRetry(t, 3, "503", 0, 0, 25)
+	b.Deposit("route1", updated)
+	if !b.Withdraw("route1") {
+		t.Fatal("expected retry to be allowed after 4 requests")
+	}
+
+	if !b.Withdraw("route2") {
+		t.Error("expected the initial budget for another route")
+	}
+}
+
+func TestBudgetLimit(t *testing.T) {
+	r := createRetry(t, 3, "503", 0, 0, 100)
+	b := NewBudgets()
+	for i := 0; i < 2*maxBudgetBalance; i++ {
+		b.Deposit("route1", r)
+	}
+
+	for i := 0; i < maxBudgetBalance; i++ {
+		if !b.Withdraw("route1") {
+			t.Fatalf("expected retry %d to be allowed", i)
+		}
+	}
+
+	if b.Withdraw("route1") {
+		t.Error("expected budget to be limited")
+	}
+}
+
+func TestBudgetIdle(t *testing.T) {
+	now := time.Now()
+	b := NewBudgets()
+	b.now = func() time.Time { return now }
+	for i := 0; i < initialBudgetBalance; i++ {
+		b.Withdraw("route1")
+	}
+
+	now = now.Add(budgetIdleTTL / 2)
+	b.Withdraw("route2")
+	if b.Withdraw("route1") {
+		t.Fatal("expected budget to be exhausted")
+	}
+
+	now = now.Add(budgetIdleTTL + time.Second)
+	b.Withdraw("route2")
+	if len(b.budgets) != 1 {
+		t.Fatalf("expected the idle budgets to be dropped, got: %d", len(b.budgets))
+	}
+
+	if !b.Withdraw("route1") {
+		t.Error("expected the initial budget after the idle budget was dropped")
+	}
+}
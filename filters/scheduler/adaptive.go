@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aryszka/jobqueue"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/scheduler"
+)
+
+const (
+	defaultAdaptiveMinLimit     = 10
+	defaultAdaptiveMaxLimit     = 1000
+	defaultAdaptiveMaxQueueSize = 100
+	defaultAdaptiveTimeout      = 100 * time.Millisecond
+	adaptiveRetryAfter          = "1"
+)
+
+type (
+	adaptiveSpec struct{}
+
+	adaptiveFilter struct {
+		config scheduler.AdaptiveConfig
+		queue  *scheduler.AdaptiveQueue
+	}
+)
+
+func NewAdaptiveConcurrency() filters.Spec {
+	return &adaptiveSpec{}
+}
+
+func (*adaptiveSpec) Name() string { return filters.AdaptiveConcurrencyName }
+
+// CreateFilter creates an adaptiveFilter, that limits the concurrency of
+// the requests to the backend, and adjusts the limit based on the
+// measured latency of the backend. The first parameter is the Algorithm,
+// the second MinLimit, the third MaxLimit, the fourth MaxQueueSize, the
+// fifth Timeout and the sixth LatencyThreshold.
+//
+// All parameters are optional and defaults to Algorithm "gradient",
+// MinLimit 10, MaxLimit 1000, MaxQueueSize 100, Timeout 100ms and no
+// LatencyThreshold. The LatencyThreshold is used only by the "aimd"
+// algorithm.
+//
+// The requests over the current limit wait in a LIFO queue for at most
+// Timeout. When the queue is full or the timeout expires, the request is
+// rejected.
+func (*adaptiveSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) > 6 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &adaptiveFilter{
+		config: scheduler.AdaptiveConfig{
+			Algorithm:    scheduler.AlgorithmGradient,
+			MinLimit:     defaultAdaptiveMinLimit,
+			MaxLimit:     defaultAdaptiveMaxLimit,
+			MaxQueueSize: defaultAdaptiveMaxQueueSize,
+			Timeout:      defaultAdaptiveTimeout,
+		},
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case scheduler.AlgorithmGradient, scheduler.AlgorithmAIMD:
+			f.config.Algorithm = args[0].(string)
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	if len(args) > 1 {
+		c, err := intArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if c >= 1 {
+			f.config.MinLimit = c
+		}
+	}
+
+	if len(args) > 2 {
+		c, err := intArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if c >= 1 {
+			f.config.MaxLimit = c
+		}
+	}
+
+	if f.config.MaxLimit < f.config.MinLimit {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if len(args) > 3 {
+		c, err := intArg(args[3])
+		if err != nil {
+			return nil, err
+		}
+		if c >= 0 {
+			f.config.MaxQueueSize = c
+		}
+	}
+
+	if len(args) > 4 {
+		d, err := durationArg(args[4])
+		if err != nil {
+			return nil, err
+		}
+		if d >= 1*time.Millisecond {
+			f.config.Timeout = d
+		}
+	}
+
+	if len(args) > 5 {
+		d, err := durationArg(args[5])
+		if err != nil {
+			return nil, err
+		}
+		if d > 0 {
+			f.config.LatencyThreshold = d
+		}
+	}
+
+	return f, nil
+}
+
+// Config returns the scheduler configuration for the given filter
+func (f *adaptiveFilter) Config() scheduler.AdaptiveConfig {
+	return f.config
+}
+
+// SetQueue binds the queue to the current filter context
+func (f *adaptiveFilter) SetQueue(q *scheduler.AdaptiveQueue) {
+	f.queue = q
+}
+
+// GetQueue is only used in tests.
+func (f *adaptiveFilter) GetQueue() *scheduler.AdaptiveQueue {
+	return f.queue
+}
+
+// Request is the filter.Filter interface implementation. Request will
+// increase the number of inflight requests, or respond with 503 and a
+// Retry-After header, when the request is over the current limit and
+// it could not wait for it in the queue.
+func (f *adaptiveFilter) Request(ctx filters.FilterContext) {
+	q := f.GetQueue()
+	if q == nil {
+		log.Warningf("Unexpected scheduler.AdaptiveQueue is nil for key %s", scheduler.AdaptiveKey)
+		return
+	}
+
+	done, err := q.Wait()
+	if err != nil {
+		if span := opentracing.SpanFromContext(ctx.Request().Context()); span != nil {
+			ext.Error.Set(span, true)
+		}
+
+		switch err {
+		case jobqueue.ErrStackFull, jobqueue.ErrTimeout:
+			log.Debugf("Request over the adaptive concurrency limit: %v for host %s", err, ctx.Request().Host)
+		default:
+			log.Errorf("Unknown error for route based %s: %v for host %s", scheduler.AdaptiveKey, err, ctx.Request().Host)
+		}
+
+		ctx.Serve(&http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Status:     "Concurrency limit reached - https://opensource.zalando.com/skipper/operation/operation/#scheduler",
+			Header:     http.Header{"Retry-After": []string{adaptiveRetryAfter}},
+		})
+
+		return
+	}
+
+	// the response is not available when the proxy calls the pending
+	// functions, in which case the request is sampled as failed
+	start := time.Now()
+	finish := func() {
+		rsp := ctx.Response()
+		q.Sample(time.Since(start), rsp != nil && rsp.StatusCode < http.StatusInternalServerError)
+		done()
+	}
+
+	pending, _ := ctx.StateBag()[scheduler.AdaptiveKey].([]func())
+	ctx.StateBag()[scheduler.AdaptiveKey] = append(pending, finish)
+}
+
+// Response is the filter.Filter interface implementation. Response
+// samples the latency and the status of the response, and decreases the
+// number of inflight requests.
+func (f *adaptiveFilter) Response(ctx filters.FilterContext) {
+	response(scheduler.AdaptiveKey, ctx)
+}
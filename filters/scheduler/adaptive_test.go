@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+	"github.com/zalando/skipper/scheduler"
+)
+
+func TestNewAdaptiveConcurrency(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		args       []interface{}
+		wantErr    bool
+		wantConfig scheduler.AdaptiveConfig
+	}{
+		{
+			name: "defaults",
+			wantConfig: scheduler.AdaptiveConfig{
+				Algorithm:    scheduler.AlgorithmGradient,
+				MinLimit:     defaultAdaptiveMinLimit,
+				MaxLimit:     defaultAdaptiveMaxLimit,
+				MaxQueueSize: defaultAdaptiveMaxQueueSize,
+				Timeout:      defaultAdaptiveTimeout,
+			},
+		},
+		{
+			name: "aimd with valid configuration",
+			args: []interface{}{"aimd", 5, 50.0, 10, "20ms", "200ms"},
+			wantConfig: scheduler.AdaptiveConfig{
+				Algorithm:        scheduler.AlgorithmAIMD,
+				MinLimit:         5,
+				MaxLimit:         50,
+				MaxQueueSize:     10,
+				Timeout:          20 * time.Millisecond,
+				LatencyThreshold: 200 * time.Millisecond,
+			},
+		},
+		{
+			name: "gradient without queue",
+			args: []interface{}{"gradient", 5, 50, 0},
+			wantConfig: scheduler.AdaptiveConfig{
+				Algorithm: scheduler.AlgorithmGradient,
+				MinLimit:  5,
+				MaxLimit:  50,
+				Timeout:   defaultAdaptiveTimeout,
+			},
+		},
+		{
+			name:    "unknown algorithm",
+			args:    []interface{}{"vegas"},
+			wantErr: true,
+		},
+		{
+			name:    "max limit lower than min limit",
+			args:    []interface{}{"aimd", 50, 5},
+			wantErr: true,
+		},
+		{
+			name:    "invalid timeout",
+			args:    []interface{}{"aimd", 5, 50, 10, "foo"},
+			wantErr: true,
+		},
+		{
+			name:    "too many args",
+			args:    []interface{}{"aimd", 5, 50, 10, "20ms", "200ms", 42},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewAdaptiveConcurrency()
+			require.Equal(t, filters.AdaptiveConcurrencyName, s.Name())
+
+			f, err := s.CreateFilter(tt.args)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantConfig, f.(*adaptiveFilter).Config())
+		})
+	}
+}
+
+func TestAdaptiveConcurrencyShedding(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer backend.Close()
+
+	doc := fmt.Sprintf(`aroute: * -> adaptiveConcurrency("aimd", 2, 2, 3, "50ms") -> "%s"`, backend.URL)
+	dc, err := testdataclient.NewDoc(doc)
+	require.NoError(t, err)
+
+	metrics := &metricstest.MockMetrics{}
+	reg := scheduler.RegistryWith(scheduler.Options{Metrics: metrics})
+	defer reg.Close()
+
+	fr := make(filters.Registry)
+	fr.Register(NewAdaptiveConcurrency())
+
+	rt := routing.New(routing.Options{
+		SignalFirstLoad: true,
+		FilterRegistry:  fr,
+		DataClients:     []routing.DataClient{dc},
+		PostProcessors:  []routing.PostProcessor{reg},
+	})
+	defer rt.Close()
+
+	<-rt.FirstLoad()
+
+	pr := proxy.WithParams(proxy.Params{Routing: rt})
+	defer pr.Close()
+
+	ts := httptest.NewServer(pr)
+	defer ts.Close()
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		codes      = make(map[int]int)
+		retryAfter int
+	)
+
+	const n = 10
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			rsp, err := http.Get(ts.URL)
+			require.NoError(t, err)
+			defer rsp.Body.Close()
+
+			mu.Lock()
+			defer mu.Unlock()
+			codes[rsp.StatusCode]++
+			if rsp.Header.Get("Retry-After") == "1" {
+				retryAfter++
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, map[int]int{
+		200: 2, // within the limit
+		503: 8, // rejected, either timed out in the queue or found it full
+	}, codes)
+
+	assert.Equal(t, 8, retryAfter)
+
+	reg.UpdateMetrics()
+
+	metrics.WithCounters(func(counters map[string]int64) {
+		assert.Equal(t, int64(8), counters["adaptiveconcurrency.aroute.error.timeout"]+counters["adaptiveconcurrency.aroute.error.full"])
+		assert.LessOrEqual(t, counters["adaptiveconcurrency.aroute.error.full"], int64(5))
+	})
+
+	metrics.WithGauges(func(gauges map[string]float64) {
+		assert.Equal(t, 2.0, gauges["adaptiveconcurrency.aroute.limit"])
+	})
+}
@@ -18,6 +18,16 @@
 // scheduler group and lifo will get a per route unique scheduler
 // group.
 //
+// The fifo and fifoGroup filters provide the same bounds with a first
+// in first out queue, for the cases when fairness matters more than
+// the tail latency.
+//
+// The adaptiveConcurrency filter doesn't need a fixed concurrency limit.
+// It measures the latency of the backend, and adjusts the limit with
+// the gradient or the AIMD algorithm. The requests over the limit can
+// wait briefly in a LIFO queue, and when they can't be processed, they
+// are rejected with 503 and a Retry-After header.
+//
 // Bounded schedulers were tested in Kubernetes with 3 proxy instances
 // with 500m CPU and 500Mi memory resources. The load test was done
 // with 500 requests per second to backends with 25 seconds latency
@@ -0,0 +1,21 @@
+package scheduler
+
+import (
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/scheduler"
+)
+
+// NewFIFO creates the fifo filter spec. The fifo filter accepts the same
+// parameters and has the same defaults as the lifo filter, but it
+// processes the waiting requests in first in first out order.
+func NewFIFO() filters.Spec {
+	return &lifoSpec{ordering: scheduler.FIFO}
+}
+
+// NewFIFOGroup creates the fifoGroup filter spec. The fifoGroup filter
+// accepts the same parameters and has the same defaults as the
+// lifoGroup filter, but it processes the waiting requests in first in
+// first out order.
+func NewFIFOGroup() filters.Spec {
+	return &lifoGroupSpec{ordering: scheduler.FIFO}
+}
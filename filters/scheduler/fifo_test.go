@@ -0,0 +1,287 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+	"github.com/zalando/skipper/scheduler"
+)
+
+func TestNewFIFO(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		args       []interface{}
+		schedFunc  func() filters.Spec
+		wantName   string
+		wantKey    string
+		wantErr    bool
+		wantConfig scheduler.Config
+	}{
+		{
+			name:      "fifo with valid configuration",
+			args:      []interface{}{10, 15, "5s"},
+			schedFunc: NewFIFO,
+			wantName:  filters.FifoName,
+			wantConfig: scheduler.Config{
+				MaxConcurrency: 10,
+				MaxQueueSize:   15,
+				Timeout:        5 * time.Second,
+				Ordering:       scheduler.FIFO,
+			},
+		},
+		{
+			name:      "fifoGroup with valid configuration",
+			args:      []interface{}{"mygroup", 10, 15, "5s"},
+			schedFunc: NewFIFOGroup,
+			wantName:  filters.FifoGroupName,
+			wantKey:   "mygroup",
+			wantConfig: scheduler.Config{
+				MaxConcurrency: 10,
+				MaxQueueSize:   15,
+				Timeout:        5 * time.Second,
+				Ordering:       scheduler.FIFO,
+			},
+		},
+		{
+			name:      "fifoGroup with valid float64 configuration",
+			args:      []interface{}{"mygroup", 10.1, 15.2, "5s"},
+			schedFunc: NewFIFOGroup,
+			wantName:  filters.FifoGroupName,
+			wantKey:   "mygroup",
+			wantConfig: scheduler.Config{
+				MaxConcurrency: 10,
+				MaxQueueSize:   15,
+				Timeout:        5 * time.Second,
+				Ordering:       scheduler.FIFO,
+			},
+		},
+		{
+			name:      "fifo with partial invalid configuration, applies defaults",
+			args:      []interface{}{-1, -15},
+			schedFunc: NewFIFO,
+			wantName:  filters.FifoName,
+			wantConfig: scheduler.Config{
+				MaxConcurrency: defaultMaxConcurreny,
+				MaxQueueSize:   defaultMaxQueueSize,
+				Timeout:        defaultTimeout,
+				Ordering:       scheduler.FIFO,
+			},
+		},
+		{
+			name:      "fifo with invalid configuration, does not create filter",
+			args:      []interface{}{-1, -15, "4a"},
+			schedFunc: NewFIFO,
+			wantName:  filters.FifoName,
+			wantErr:   true,
+		},
+		{
+			name:      "fifo with too many args, does not create filter",
+			args:      []interface{}{1, 2, "4s", 42},
+			schedFunc: NewFIFO,
+			wantName:  filters.FifoName,
+			wantErr:   true,
+		},
+		{
+			name:      "fifoGroup without name, does not create filter",
+			args:      []interface{}{},
+			schedFunc: NewFIFOGroup,
+			wantName:  filters.FifoGroupName,
+			wantErr:   true,
+		},
+		{
+			name:      "fifoGroup with invalid int type, does not create filter",
+			args:      []interface{}{"mygroup", "foo", -15, "4s"},
+			schedFunc: NewFIFOGroup,
+			wantName:  filters.FifoGroupName,
+			wantErr:   true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.schedFunc()
+			if s.Name() != tt.wantName {
+				t.Errorf("Failed to get name, got %s, want %s", s.Name(), tt.wantName)
+			}
+
+			f, err := s.CreateFilter(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Failed to get the expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to create filter: %v", err)
+			}
+
+			switch ff := f.(type) {
+			case *lifoFilter:
+				if c := ff.Config(); c != tt.wantConfig {
+					t.Errorf("Failed to get Config, got: %v, want: %v", c, tt.wantConfig)
+				}
+			case *lifoGroupFilter:
+				if c := ff.Config(); c != tt.wantConfig {
+					t.Errorf("Failed to get Config, got: %v, want: %v", c, tt.wantConfig)
+				}
+
+				if !ff.HasConfig() {
+					t.Error("Failed to HasConfig")
+				}
+
+				if ff.Group() != tt.wantKey {
+					t.Errorf("Failed to get Group, got: %v, want: %v", ff.Group(), tt.wantKey)
+				}
+			default:
+				t.Fatalf("Failed to get lifoFilter or lifoGroupFilter from filter: %v", f)
+			}
+		})
+	}
+}
+
+func TestFifoOrder(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`r: * -> fifo(1, 10, "10s") -> <shunt>`)
+	require.NoError(t, err)
+
+	reg := scheduler.NewRegistry()
+	defer reg.Close()
+
+	fr := make(filters.Registry)
+	fr.Register(NewFIFO())
+
+	rt := routing.New(routing.Options{
+		SignalFirstLoad: true,
+		FilterRegistry:  fr,
+		DataClients:     []routing.DataClient{dc},
+		PostProcessors:  []routing.PostProcessor{reg},
+	})
+	defer rt.Close()
+
+	<-rt.FirstLoad()
+
+	req, err := http.NewRequest("GET", "http://www.example.org", nil)
+	require.NoError(t, err)
+
+	r, _ := rt.Route(req)
+	require.NotNil(t, r)
+
+	f := r.Filters[0].Filter
+	q := f.(scheduler.LIFOFilter).GetQueue()
+	require.NotNil(t, q)
+
+	waitForQueued := func(n int) {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for q.Status().QueuedRequests != n {
+			select {
+			case <-timeout:
+				t.Fatalf("Failed to get %d queued requests", n)
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+
+	first := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+	f.Request(first)
+
+	const queued = 5
+	order := make(chan int, queued)
+	for i := 0; i < queued; i++ {
+		go func(i int) {
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+			order <- i
+			f.Response(ctx)
+		}(i)
+
+		waitForQueued(i + 1)
+	}
+
+	f.Response(first)
+	for i := 0; i < queued; i++ {
+		if got := <-order; got != i {
+			t.Fatalf("Failed to process the requests in order, got: %d, want: %d", got, i)
+		}
+	}
+}
+
+func TestFifoErrors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer backend.Close()
+
+	doc := fmt.Sprintf(`aroute: * -> fifo(5, 7, "100ms") -> "%s"`, backend.URL)
+
+	dc, err := testdataclient.NewDoc(doc)
+	require.NoError(t, err)
+
+	metrics := &metricstest.MockMetrics{}
+	reg := scheduler.RegistryWith(scheduler.Options{
+		Metrics:                metrics,
+		EnableRouteFIFOMetrics: true,
+	})
+	defer reg.Close()
+
+	fr := make(filters.Registry)
+	fr.Register(NewFIFO())
+
+	ro := routing.Options{
+		SignalFirstLoad: true,
+		FilterRegistry:  fr,
+		DataClients:     []routing.DataClient{dc},
+		PostProcessors:  []routing.PostProcessor{reg},
+	}
+
+	rt := routing.New(ro)
+	defer rt.Close()
+
+	<-rt.FirstLoad()
+
+	tracer := &testTracer{MockTracer: mocktracer.New()}
+	pr := proxy.WithParams(proxy.Params{
+		Routing:     rt,
+		OpenTracing: &proxy.OpenTracingParams{Tracer: tracer},
+	})
+	defer pr.Close()
+
+	ts := httptest.NewServer(pr)
+	defer ts.Close()
+
+	requestSpike(t, 20, ts.URL)
+
+	codes := make(map[uint16]int)
+	for _, span := range tracer.FinishedSpans() {
+		if span.OperationName == "ingress" {
+			code := span.Tag("http.status_code").(uint16)
+			codes[code]++
+		}
+	}
+
+	assert.Equal(t, map[uint16]int{
+		// 20 request in total, of which:
+		200: 5, // went straight to the backend
+		502: 7, // were queued and timed out as backend latency is greater than scheduling timeout
+		503: 8, // were refused due to full queue
+	}, codes)
+
+	reg.UpdateMetrics()
+
+	metrics.WithCounters(func(counters map[string]int64) {
+		assert.Equal(t, int64(7), counters["fifo.aroute.error.timeout"])
+		assert.Equal(t, int64(8), counters["fifo.aroute.error.full"])
+	})
+}
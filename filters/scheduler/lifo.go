@@ -13,8 +13,8 @@ import (
 )
 
 type (
-	lifoSpec      struct{}
-	lifoGroupSpec struct{}
+	lifoSpec      struct{ ordering scheduler.Ordering }
+	lifoGroupSpec struct{ ordering scheduler.Ordering }
 
 	lifoFilter struct {
 		config scheduler.Config
@@ -68,7 +68,23 @@ func durationArg(a interface{}) (time.Duration, error) {
 	}
 }
 
-func (s *lifoSpec) Name() string { return filters.LifoName }
+// stateBagKey returns the key used to pass the queue values from the
+// filters to the proxy.
+func stateBagKey(o scheduler.Ordering) string {
+	if o == scheduler.FIFO {
+		return scheduler.FIFOKey
+	}
+
+	return scheduler.LIFOKey
+}
+
+func (s *lifoSpec) Name() string {
+	if s.ordering == scheduler.FIFO {
+		return filters.FifoName
+	}
+
+	return filters.LifoName
+}
 
 // CreateFilter creates a lifoFilter, that will use a queue based
 // queue for handling requests instead of the fifo queue. The first
@@ -86,12 +102,13 @@ func (s *lifoSpec) Name() string { return filters.LifoName }
 // MaxConcurrency and MaxQueueSize: total max = MaxConcurrency + MaxQueueSize
 //
 // Min values are 1 for MaxConcurrency and MaxQueueSize, and 1ms for
-// Timeout. All configration that is below will be set to these min
+// Timeout. All configuration that is below will be set to these min
 // values.
 func (s *lifoSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
 	var l lifoFilter
 
 	// set defaults
+	l.config.Ordering = s.ordering
 	l.config.MaxConcurrency = defaultMaxConcurreny
 	l.config.MaxQueueSize = defaultMaxQueueSize
 	l.config.Timeout = defaultTimeout
@@ -133,7 +150,13 @@ func (s *lifoSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
 	return &l, nil
 }
 
-func (*lifoGroupSpec) Name() string { return filters.LifoGroupName }
+func (s *lifoGroupSpec) Name() string {
+	if s.ordering == scheduler.FIFO {
+		return filters.FifoGroupName
+	}
+
+	return filters.LifoGroupName
+}
 
 // CreateFilter creates a lifoGroupFilter, that will use a queue based
 // queue for handling requests instead of the fifo queue. The first
@@ -154,7 +177,7 @@ func (*lifoGroupSpec) Name() string { return filters.LifoGroupName }
 // MaxConcurrency and MaxQueueSize: total max = MaxConcurrency + MaxQueueSize
 //
 // Min values are 1 for MaxConcurrency and MaxQueueSize, and 1ms for
-// Timeout. All configration that is below will be set to these min
+// Timeout. All configuration that is below will be set to these min
 // values.
 //
 // It is enough to set the concurrency, queue size and timeout parameters for
@@ -164,7 +187,7 @@ func (*lifoGroupSpec) Name() string { return filters.LifoGroupName }
 // is accidentally a difference between the settings in the same group, a
 // warning will be logged.
 //
-func (*lifoGroupSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+func (s *lifoGroupSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
 	if len(args) < 1 || len(args) > 4 {
 		return nil, filters.ErrInvalidFilterParameters
 	}
@@ -183,6 +206,7 @@ func (*lifoGroupSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
 		MaxConcurrency: defaultMaxConcurreny,
 		MaxQueueSize:   defaultMaxQueueSize,
 		Timeout:        defaultTimeout,
+		Ordering:       s.ordering,
 	}
 	l.config = cfg
 
@@ -242,13 +266,13 @@ func (l *lifoFilter) GetQueue() *scheduler.Queue {
 // - 503 if jobqueue.ErrQueueFull
 // - 502 if jobqueue.ErrTimeout
 func (l *lifoFilter) Request(ctx filters.FilterContext) {
-	request(l.GetQueue(), scheduler.LIFOKey, ctx)
+	request(l.GetQueue(), stateBagKey(l.config.Ordering), ctx)
 }
 
 // Response is the filter.Filter interface implementation. Response
 // will decrease the number of inflight requests.
 func (l *lifoFilter) Response(ctx filters.FilterContext) {
-	response(scheduler.LIFOKey, ctx)
+	response(stateBagKey(l.config.Ordering), ctx)
 }
 
 func (l *lifoGroupFilter) Group() string {
@@ -281,13 +305,13 @@ func (l *lifoGroupFilter) GetQueue() *scheduler.Queue {
 // - 503 if jobqueue.ErrStackFull
 // - 502 if jobqueue.ErrTimeout
 func (l *lifoGroupFilter) Request(ctx filters.FilterContext) {
-	request(l.GetQueue(), scheduler.LIFOKey, ctx)
+	request(l.GetQueue(), stateBagKey(l.config.Ordering), ctx)
 }
 
 // Response is the filter.Filter interface implementation. Response
 // will decrease the number of inflight requests.
 func (l *lifoGroupFilter) Response(ctx filters.FilterContext) {
-	response(scheduler.LIFOKey, ctx)
+	response(stateBagKey(l.config.Ordering), ctx)
 }
 
 func request(q *scheduler.Queue, key string, ctx filters.FilterContext) {
@@ -315,7 +339,7 @@ func request(q *scheduler.Queue, key string, ctx filters.FilterContext) {
 				Status:     "Queue timeout - https://opensource.zalando.com/skipper/operation/operation/#scheduler",
 			})
 		default:
-			log.Errorf("Unknown error for route based LIFO: %v for host %s", err, ctx.Request().Host)
+			log.Errorf("Unknown error for route based %s: %v for host %s", key, err, ctx.Request().Host)
 			ctx.Serve(&http.Response{StatusCode: http.StatusInternalServerError})
 		}
 		return
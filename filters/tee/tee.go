@@ -10,6 +10,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/net"
 )
 
 const (
@@ -147,7 +148,8 @@ func (r *tee) Response(filters.FilterContext) {}
 // Request is copied and then modified to adopt changes in new backend
 func (r *tee) Request(fc filters.FilterContext) {
 	req := fc.Request()
-	copyOfRequest, tr, err := cloneRequest(r, req)
+	buffer, _ := fc.StateBag()[filters.RequestBodyBufferKey].(*net.BodyBuffer)
+	copyOfRequest, tr, err := cloneRequest(r, req, buffer)
 	if err != nil {
 		log.Warn("tee: error while cloning the tee request", err)
 		return
@@ -175,7 +177,10 @@ func (r *tee) Request(fc filters.FilterContext) {
 // copies requests changes URL and Host in request.
 // If 2nd and 3rd params are given path is also modified by applying regexp
 // Returns the cloned request and the tee body to be used on the main request.
-func cloneRequest(t *tee, req *http.Request) (*http.Request, io.ReadCloser, error) {
+// When the request body was buffered by the bufferRequestBody filter, the
+// shadow request reads it independently from the main request, and a slow
+// shadow backend can't stall the main one.
+func cloneRequest(t *tee, req *http.Request, buffer *net.BodyBuffer) (*http.Request, io.ReadCloser, error) {
 	u := new(url.URL)
 	*u = *req.URL
 	u.Host = t.host
@@ -197,7 +202,9 @@ func cloneRequest(t *tee, req *http.Request) (*http.Request, io.ReadCloser, erro
 	mainBody := req.Body
 
 	// see proxy.go:231
-	if req.ContentLength != 0 {
+	if buffer != nil {
+		teeBody = buffer.NewReader()
+	} else if req.ContentLength != 0 {
 		pr, pw := io.Pipe()
 		teeBody = pr
 		mainBody = &teeTie{mainBody, pw}
@@ -34,7 +34,7 @@ func TestTeeHostHeaderChanges(t *testing.T) {
 	fc := buildfilterContext()
 
 	rep, _ := f.(*tee)
-	modifiedRequest, _, err := cloneRequest(rep, fc.Request())
+	modifiedRequest, _, err := cloneRequest(rep, fc.Request(), nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -55,7 +55,7 @@ func TestTeeSchemeChanges(t *testing.T) {
 	fc := buildfilterContext()
 
 	rep, _ := f.(*tee)
-	modifiedRequest, _, err := cloneRequest(rep, fc.Request())
+	modifiedRequest, _, err := cloneRequest(rep, fc.Request(), nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -76,7 +76,7 @@ func TestTeeUrlHostChanges(t *testing.T) {
 	fc := buildfilterContext()
 
 	rep, _ := f.(*tee)
-	modifiedRequest, _, err := cloneRequest(rep, fc.Request())
+	modifiedRequest, _, err := cloneRequest(rep, fc.Request(), nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -97,7 +97,7 @@ func TestTeeWithPathChanges(t *testing.T) {
 	fc := buildfilterContext()
 
 	rep, _ := f.(*tee)
-	modifiedRequest, _, err := cloneRequest(rep, fc.Request())
+	modifiedRequest, _, err := cloneRequest(rep, fc.Request(), nil)
 	if err != nil {
 		t.Error(err)
 		return
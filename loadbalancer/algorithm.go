@@ -35,6 +35,12 @@ const (
 
 	// PowerOfRandomNChoices selects N random endpoints and picks the one with least outstanding requests from them.
 	PowerOfRandomNChoices
+
+	// LeastConnections selects the endpoint with the least outstanding requests.
+	LeastConnections
+
+	// PeakEWMA selects the endpoint with the lowest peak EWMA response time, weighted by the outstanding requests.
+	PeakEWMA
 )
 
 const powerOfRandomNChoicesDefaultN = 2
@@ -49,6 +55,8 @@ var (
 		Random:                newRandom,
 		ConsistentHash:        newConsistentHash,
 		PowerOfRandomNChoices: newPowerOfRandomNChoices,
+		LeastConnections:      newLeastConnections,
+		PeakEWMA:              newPeakEWMA,
 	}
 	defaultAlgorithm = newRoundRobin
 )
@@ -71,7 +79,7 @@ func shiftWeighted(rnd *rand.Rand, ctx *routing.LBContext, w []float64, now time
 	var sum float64
 	weightSums := w
 	rt := ctx.Route
-	ep := ctx.Route.LBEndpoints
+	ep := lbEndpoints(ctx)
 	for _, epi := range ep {
 		wi := fadeIn(now, rt.LBFadeInDuration, rt.LBFadeInExponent, epi.Detected)
 		sum += wi
@@ -92,7 +100,7 @@ func shiftWeighted(rnd *rand.Rand, ctx *routing.LBContext, w []float64, now time
 
 func shiftToRemaining(rnd *rand.Rand, ctx *routing.LBContext, wi []int, wf []float64, now time.Time) routing.LBEndpoint {
 	notFadingIndexes := wi
-	ep := ctx.Route.LBEndpoints
+	ep := lbEndpoints(ctx)
 	for i := 0; i < len(ep); i++ {
 		if _, fadingIn := fadeInState(now, ctx.Route.LBFadeInDuration, ep[i].Detected); !fadingIn {
 			notFadingIndexes = append(notFadingIndexes, i)
@@ -113,26 +121,97 @@ func shiftToRemaining(rnd *rand.Rand, ctx *routing.LBContext, wi []int, wf []flo
 
 func withFadeIn(rnd *rand.Rand, ctx *routing.LBContext, wi []int, wf []float64, choice int) routing.LBEndpoint {
 	now := time.Now()
+	ep := lbEndpoints(ctx)
 	f := fadeIn(
 		now,
 		ctx.Route.LBFadeInDuration,
 		ctx.Route.LBFadeInExponent,
-		ctx.Route.LBEndpoints[choice].Detected,
+		ep[choice].Detected,
 	)
 
 	if rnd.Float64() < f {
-		return ctx.Route.LBEndpoints[choice]
+		return ep[choice]
 	}
 
 	return shiftToRemaining(rnd, ctx, wi, wf, now)
 }
 
+// lbEndpoints returns the endpoints that the algorithm can select: the
+// LBEndpoints of the context when set, otherwise the endpoints of the
+// route.
+func lbEndpoints(ctx *routing.LBContext) []routing.LBEndpoint {
+	if len(ctx.LBEndpoints) > 0 {
+		return ctx.LBEndpoints
+	}
+
+	return ctx.Route.LBEndpoints
+}
+
+// selectable tells whether the endpoint of the route at index i can be
+// selected, i.e. it is one of the LBEndpoints of the context, when they
+// are set.
+func selectable(ctx *routing.LBContext, i int) bool {
+	if len(ctx.LBEndpoints) == 0 {
+		return true
+	}
+
+	return containsEndpoint(ctx.LBEndpoints, ctx.Route.LBEndpoints[i])
+}
+
+func containsEndpoint(endpoints []routing.LBEndpoint, e routing.LBEndpoint) bool {
+	for _, ei := range endpoints {
+		if ei.Host == e.Host && ei.Scheme == e.Scheme {
+			return true
+		}
+	}
+
+	return false
+}
+
+// endpointWeight returns the static weight of an endpoint, defaulting to 1.
+func endpointWeight(e routing.LBEndpoint) float64 {
+	if e.Weight <= 0 {
+		return 1
+	}
+
+	return e.Weight
+}
+
+// weighted tells whether the route defines different weights for its
+// endpoints.
+func weighted(ctx *routing.LBContext) bool {
+	return len(ctx.Route.Route.LBEndpointWeights) > 0
+}
+
+// weightedChoice selects an endpoint randomly, proportionally to its
+// static weight multiplied by its fade-in weight.
+func weightedChoice(rnd *rand.Rand, ctx *routing.LBContext, now time.Time) routing.LBEndpoint {
+	ep := lbEndpoints(ctx)
+	var sum float64
+	for _, e := range ep {
+		sum += endpointWeight(e) * fadeInWeight(ctx, e, now)
+	}
+
+	r := rnd.Float64() * sum
+	for _, e := range ep {
+		r -= endpointWeight(e) * fadeInWeight(ctx, e, now)
+		if r < 0 {
+			return e
+		}
+	}
+
+	return ep[len(ep)-1]
+}
+
 type roundRobin struct {
 	mx               sync.Mutex
 	index            int
 	rnd              *rand.Rand
 	notFadingIndexes []int
 	fadingWeights    []float64
+
+	// current weights of the smooth weighted round-robin
+	current []float64
 }
 
 func newRoundRobin(endpoints []string) routing.LBAlgorithm {
@@ -149,21 +228,60 @@ func newRoundRobin(endpoints []string) routing.LBAlgorithm {
 
 // Apply implements routing.LBAlgorithm with a roundrobin algorithm.
 func (r *roundRobin) Apply(ctx *routing.LBContext) routing.LBEndpoint {
-	if len(ctx.Route.LBEndpoints) == 1 {
-		return ctx.Route.LBEndpoints[0]
+	ep := lbEndpoints(ctx)
+	if len(ep) == 1 {
+		return ep[0]
 	}
 
 	r.mx.Lock()
 	defer r.mx.Unlock()
-	r.index = (r.index + 1) % len(ctx.Route.LBEndpoints)
+	if weighted(ctx) {
+		return r.smoothWeighted(ctx)
+	}
+
+	r.index = (r.index + 1) % len(ep)
 
 	if ctx.Route.LBFadeInDuration <= 0 {
-		return ctx.Route.LBEndpoints[r.index]
+		return ep[r.index]
 	}
 
 	return withFadeIn(r.rnd, ctx, r.notFadingIndexes, r.fadingWeights, r.index)
 }
 
+// smoothWeighted implements the smooth weighted round-robin: in every
+// round, the current weight of each endpoint is increased by its
+// effective weight, the endpoint with the highest current weight is
+// selected, and its current weight is decreased by the total. This way
+// the endpoints are selected proportionally to their weights, while
+// the selections of the same endpoint are spread evenly. The current
+// weights are kept for all the endpoints of the route, and only the
+// selectable ones take part in the round.
+func (r *roundRobin) smoothWeighted(ctx *routing.LBContext) routing.LBEndpoint {
+	ep := ctx.Route.LBEndpoints
+	if len(r.current) != len(ep) {
+		r.current = make([]float64, len(ep))
+	}
+
+	now := time.Now()
+	var total float64
+	best := -1
+	for i, e := range ep {
+		if !selectable(ctx, i) {
+			continue
+		}
+
+		w := endpointWeight(e) * fadeInWeight(ctx, e, now)
+		r.current[i] += w
+		total += w
+		if best < 0 || r.current[i] > r.current[best] {
+			best = i
+		}
+	}
+
+	r.current[best] -= total
+	return ep[best]
+}
+
 type random struct {
 	rand             *rand.Rand
 	notFadingIndexes []int
@@ -184,13 +302,18 @@ func newRandom(endpoints []string) routing.LBAlgorithm {
 
 // Apply implements routing.LBAlgorithm with a stateless random algorithm.
 func (r *random) Apply(ctx *routing.LBContext) routing.LBEndpoint {
-	if len(ctx.Route.LBEndpoints) == 1 {
-		return ctx.Route.LBEndpoints[0]
+	ep := lbEndpoints(ctx)
+	if len(ep) == 1 {
+		return ep[0]
+	}
+
+	if weighted(ctx) {
+		return weightedChoice(r.rand, ctx, time.Now())
 	}
 
-	i := r.rand.Intn(len(ctx.Route.LBEndpoints))
+	i := r.rand.Intn(len(ep))
 	if ctx.Route.LBFadeInDuration <= 0 {
-		return ctx.Route.LBEndpoints[i]
+		return ep[i]
 	}
 
 	return withFadeIn(r.rand, ctx, r.notFadingIndexes, r.fadingWeights, i)
@@ -209,21 +332,64 @@ func (ch consistentHash) Less(i, j int) bool { return ch[i].hash < ch[j].hash }
 func (ch consistentHash) Swap(i, j int)      { ch[i], ch[j] = ch[j], ch[i] }
 
 func newConsistentHashInternal(endpoints []string, hashesPerEndpoint int) routing.LBAlgorithm {
-	ch := consistentHash(make([]endpointHash, hashesPerEndpoint*len(endpoints)))
+	return newWeightedConsistentHashInternal(endpoints, nil, hashesPerEndpoint)
+}
+
+// newWeightedConsistentHashInternal creates the hash ring with a number of
+// virtual nodes per endpoint proportional to the weight of the endpoint,
+// where the average weight gets hashesPerEndpoint virtual nodes.
+func newWeightedConsistentHashInternal(endpoints []string, weights []float64, hashesPerEndpoint int) routing.LBAlgorithm {
+	hashes := make([]int, len(endpoints))
+	for i := range hashes {
+		hashes[i] = hashesPerEndpoint
+	}
+
+	if len(weights) > 0 {
+		var sum float64
+		for i := range endpoints {
+			sum += endpointWeightAt(weights, i)
+		}
+
+		avg := sum / float64(len(endpoints))
+		for i := range hashes {
+			hashes[i] = int(math.Round(float64(hashesPerEndpoint) * endpointWeightAt(weights, i) / avg))
+			if hashes[i] < 1 {
+				hashes[i] = 1
+			}
+		}
+	}
+
+	var total int
+	for _, h := range hashes {
+		total += h
+	}
+
+	ch := consistentHash(make([]endpointHash, 0, total))
 	for i, ep := range endpoints {
-		endpointStartIndex := hashesPerEndpoint * i
-		for j := 0; j < hashesPerEndpoint; j++ {
-			ch[endpointStartIndex+j] = endpointHash{i, hash(fmt.Sprintf("%s-%d", ep, j))}
+		for j := 0; j < hashes[i]; j++ {
+			ch = append(ch, endpointHash{i, hash(fmt.Sprintf("%s-%d", ep, j))})
 		}
 	}
 	sort.Sort(ch)
 	return ch
 }
 
+func endpointWeightAt(weights []float64, i int) float64 {
+	if i >= len(weights) || weights[i] <= 0 {
+		return 1
+	}
+
+	return weights[i]
+}
+
 func newConsistentHash(endpoints []string) routing.LBAlgorithm {
 	return newConsistentHashInternal(endpoints, 100)
 }
 
+func newWeightedConsistentHash(endpoints []string, weights []float64) routing.LBAlgorithm {
+	return newWeightedConsistentHashInternal(endpoints, weights, 100)
+}
+
 func hash(s string) uint64 {
 	return xxhash.Sum64String(s)
 }
@@ -244,9 +410,22 @@ func (ch consistentHash) search(key string) int {
 	return ch[ringIndex].index
 }
 
+// Returns index of the selectable endpoint with closest hash to key's hash
+func (ch consistentHash) searchSelectable(key string, ctx *routing.LBContext) int {
+	ringIndex := ch.searchRing(key)
+	for i := 0; i < ch.Len(); i++ {
+		if endpointIndex := ch[ringIndex].index; selectable(ctx, endpointIndex) {
+			return endpointIndex
+		}
+		ringIndex = (ringIndex + 1) % ch.Len()
+	}
+
+	return ch[ringIndex].index
+}
+
 func computeLoadAverage(ctx *routing.LBContext) float64 {
 	sum := 1.0 // add 1 to include the request that just arrived
-	endpoints := ctx.Route.LBEndpoints
+	endpoints := lbEndpoints(ctx)
 	for _, v := range endpoints {
 		sum += float64(v.Metrics.GetInflightRequests())
 	}
@@ -264,7 +443,7 @@ func (ch consistentHash) boundedLoadSearch(key string, balanceFactor float64, ct
 		load := ctx.Route.LBEndpoints[endpointIndex].Metrics.GetInflightRequests()
 		// We know there must be an endpoint whose load <= average load.
 		// Since targetLoad >= average load (balancerFactor >= 1), there must also be an endpoint with load <= targetLoad.
-		if load <= int(targetLoad) {
+		if load <= int(targetLoad) && selectable(ctx, endpointIndex) {
 			break
 		}
 		ringIndex = (ringIndex + 1) % ch.Len()
@@ -275,8 +454,8 @@ func (ch consistentHash) boundedLoadSearch(key string, balanceFactor float64, ct
 
 // Apply implements routing.LBAlgorithm with a consistent hash algorithm.
 func (ch consistentHash) Apply(ctx *routing.LBContext) routing.LBEndpoint {
-	if len(ctx.Route.LBEndpoints) == 1 {
-		return ctx.Route.LBEndpoints[0]
+	if ep := lbEndpoints(ctx); len(ep) == 1 {
+		return ep[0]
 	}
 
 	key, ok := ctx.Params[ConsistentHashKey].(string)
@@ -286,7 +465,7 @@ func (ch consistentHash) Apply(ctx *routing.LBContext) routing.LBEndpoint {
 	balanceFactor, ok := ctx.Params[ConsistentHashBalanceFactor].(float64)
 	var choice int
 	if !ok {
-		choice = ch.search(key)
+		choice = ch.searchSelectable(key, ctx)
 	} else {
 		choice = ch.boundedLoadSearch(key, balanceFactor, ctx)
 	}
@@ -311,15 +490,16 @@ func newPowerOfRandomNChoices(endpoints []string) routing.LBAlgorithm {
 
 // Apply implements routing.LBAlgorithm with power of random N choices algorithm.
 func (p *powerOfRandomNChoices) Apply(ctx *routing.LBContext) routing.LBEndpoint {
-	ne := len(ctx.Route.LBEndpoints)
+	ep := lbEndpoints(ctx)
+	ne := len(ep)
 
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	best := ctx.Route.LBEndpoints[p.rand.Intn(ne)]
+	best := ep[p.rand.Intn(ne)]
 
 	for i := 1; i < p.numberOfChoices; i++ {
-		ce := ctx.Route.LBEndpoints[p.rand.Intn(ne)]
+		ce := ep[p.rand.Intn(ne)]
 
 		if p.getScore(ce) > p.getScore(best) {
 			best = ce
@@ -328,10 +508,121 @@ func (p *powerOfRandomNChoices) Apply(ctx *routing.LBContext) routing.LBEndpoint
 	return best
 }
 
-// getScore returns negative value of inflightrequests count.
-func (p *powerOfRandomNChoices) getScore(e routing.LBEndpoint) int {
+// getScore returns negative value of inflightrequests count, including the
+// request to be sent, divided by the weight of the endpoint. Counting the
+// request to be sent makes the weights effective also when there are no
+// requests in flight.
+func (p *powerOfRandomNChoices) getScore(e routing.LBEndpoint) float64 {
 	// endpoints with higher inflight request should have lower score
-	return -e.Metrics.GetInflightRequests()
+	return -float64(e.Metrics.GetInflightRequests()+1) / endpointWeight(e)
+}
+
+// fadeInWeight returns the weight of an endpoint, which is less than 1
+// while the endpoint is fading in.
+func fadeInWeight(ctx *routing.LBContext, e routing.LBEndpoint, now time.Time) float64 {
+	if ctx.Route.LBFadeInDuration <= 0 {
+		return 1
+	}
+
+	return fadeIn(now, ctx.Route.LBFadeInDuration, ctx.Route.LBFadeInExponent, e.Detected)
+}
+
+// selectLowestScore returns the endpoint with the lowest score, where the
+// score is divided by the static and the fade-in weight of the endpoint. Starting from a
+// random offset, equal scores are distributed randomly.
+func selectLowestScore(ctx *routing.LBContext, offset int, score func(routing.LBEndpoint) float64) routing.LBEndpoint {
+	now := time.Now()
+	ep := lbEndpoints(ctx)
+	best := -1
+	var bestScore float64
+	for i := range ep {
+		j := (offset + i) % len(ep)
+		s := score(ep[j]) / (endpointWeight(ep[j]) * fadeInWeight(ctx, ep[j], now))
+		if best < 0 || s < bestScore {
+			best, bestScore = j, s
+		}
+	}
+
+	return ep[best]
+}
+
+type leastConnections struct {
+	mx   sync.Mutex
+	rand *rand.Rand
+}
+
+// newLeastConnections selects the endpoint with the least outstanding requests.
+func newLeastConnections(endpoints []string) routing.LBAlgorithm {
+	return &leastConnections{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())), // #nosec
+	}
+}
+
+// Apply implements routing.LBAlgorithm with a least connections algorithm.
+func (l *leastConnections) Apply(ctx *routing.LBContext) routing.LBEndpoint {
+	ep := lbEndpoints(ctx)
+	ne := len(ep)
+	if ne == 1 {
+		return ep[0]
+	}
+
+	l.mx.Lock()
+	offset := l.rand.Intn(ne)
+	l.mx.Unlock()
+
+	return selectLowestScore(ctx, offset, func(e routing.LBEndpoint) float64 {
+		return float64(e.Metrics.GetInflightRequests() + 1)
+	})
+}
+
+type peakEWMA struct {
+	mx   sync.Mutex
+	rand *rand.Rand
+}
+
+// newPeakEWMA selects the endpoint with the lowest peak EWMA response
+// time multiplied by the outstanding requests.
+func newPeakEWMA(endpoints []string) routing.LBAlgorithm {
+	return &peakEWMA{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())), // #nosec
+	}
+}
+
+// Apply implements routing.LBAlgorithm with a peak EWMA algorithm.
+func (p *peakEWMA) Apply(ctx *routing.LBContext) routing.LBEndpoint {
+	ep := lbEndpoints(ctx)
+	ne := len(ep)
+	if ne == 1 {
+		return ep[0]
+	}
+
+	p.mx.Lock()
+	offset := p.rand.Intn(ne)
+	p.mx.Unlock()
+
+	// endpoints without observed latency are assumed to be average
+	var sum time.Duration
+	var observed int
+	for _, e := range ep {
+		if l := e.Metrics.GetLatency(); l > 0 {
+			sum += l
+			observed++
+		}
+	}
+
+	defaultLatency := float64(1)
+	if observed > 0 {
+		defaultLatency = float64(sum) / float64(observed)
+	}
+
+	return selectLowestScore(ctx, offset, func(e routing.LBEndpoint) float64 {
+		latency := float64(e.Metrics.GetLatency())
+		if latency <= 0 {
+			latency = defaultLatency
+		}
+
+		return latency * float64(e.Metrics.GetInflightRequests()+1)
+	})
 }
 
 type (
@@ -360,6 +651,10 @@ func AlgorithmFromString(a string) (Algorithm, error) {
 		return ConsistentHash, nil
 	case "powerOfRandomNChoices":
 		return PowerOfRandomNChoices, nil
+	case "leastConnections":
+		return LeastConnections, nil
+	case "peakEWMA":
+		return PeakEWMA, nil
 	default:
 		return None, errors.New("unsupported algorithm")
 	}
@@ -376,6 +671,10 @@ func (a Algorithm) String() string {
 		return "consistentHash"
 	case PowerOfRandomNChoices:
 		return "powerOfRandomNChoices"
+	case LeastConnections:
+		return "leastConnections"
+	case PeakEWMA:
+		return "peakEWMA"
 	default:
 		return ""
 	}
@@ -393,6 +692,11 @@ func parseEndpoints(r *routing.Route) error {
 			Scheme:  eu.Scheme,
 			Host:    eu.Host,
 			Metrics: &routing.LBMetrics{},
+			Weight:  endpointWeightAt(r.Route.LBEndpointWeights, i),
+		}
+
+		if i < len(r.Route.LBEndpointZones) {
+			r.LBEndpoints[i].Zone = r.Route.LBEndpointZones[i]
 		}
 	}
 
@@ -410,6 +714,11 @@ func setAlgorithm(r *routing.Route) error {
 		initialize = algorithms[t]
 	}
 
+	if t == ConsistentHash && len(r.Route.LBEndpointWeights) > 0 {
+		r.LBAlgorithm = newWeightedConsistentHash(r.Route.LBEndpoints, r.Route.LBEndpointWeights)
+		return nil
+	}
+
 	r.LBAlgorithm = initialize(r.Route.LBEndpoints)
 	return nil
 }
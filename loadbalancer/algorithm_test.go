@@ -5,6 +5,7 @@ import (
 	"math"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/net"
@@ -244,6 +245,16 @@ func TestApply(t *testing.T) {
 			expected:      N,
 			algorithm:     newPowerOfRandomNChoices(eps),
 			algorithmName: "powerOfRandomNChoices",
+		}, {
+			name:          "leastConnections algorithm",
+			expected:      N,
+			algorithm:     newLeastConnections(eps),
+			algorithmName: "leastConnections",
+		}, {
+			name:          "peakEWMA algorithm",
+			expected:      N,
+			algorithm:     newPeakEWMA(eps),
+			algorithmName: "peakEWMA",
 		}} {
 		t.Run(tt.name, func(t *testing.T) {
 			req, _ := http.NewRequest("GET", "http://127.0.0.1:1234/foo", nil)
@@ -417,6 +428,277 @@ func TestConsistentHashKeyDistribution(t *testing.T) {
 	}
 }
 
+func newTestLBContext(t *testing.T, algorithm string, endpoints ...string) *routing.LBContext {
+	return newWeightedTestLBContext(t, algorithm, endpoints, nil)
+}
+
+func newWeightedTestLBContext(t *testing.T, algorithm string, endpoints []string, weights []float64) *routing.LBContext {
+	r := &routing.Route{
+		Route: eskip.Route{
+			BackendType:       eskip.LBBackend,
+			LBAlgorithm:       algorithm,
+			LBEndpoints:       endpoints,
+			LBEndpointWeights: weights,
+		},
+	}
+
+	rr := NewAlgorithmProvider().Do([]*routing.Route{r})
+	if len(rr) != 1 {
+		t.Fatal("failed to process LB route")
+	}
+
+	return &routing.LBContext{Route: rr[0]}
+}
+
+func TestLeastConnections(t *testing.T) {
+	ctx := newTestLBContext(t, "leastConnections", "http://127.0.0.1:1234", "http://127.0.0.1:1235", "http://127.0.0.1:1236")
+	if _, ok := ctx.Route.LBAlgorithm.(*leastConnections); !ok {
+		t.Fatal("failed to set the right algorithm")
+	}
+
+	ep := ctx.Route.LBEndpoints
+	addInflightRequests(ep[0], 3)
+	addInflightRequests(ep[1], 1)
+	addInflightRequests(ep[2], 2)
+
+	for i := 0; i < 100; i++ {
+		if e := ctx.Route.LBAlgorithm.Apply(ctx); e.Host != ep[1].Host {
+			t.Fatalf("expected the endpoint with the least outstanding requests, got: %s", e.Host)
+		}
+	}
+
+	// the requests are distributed until the load is equal
+	for i := 0; i < 3; i++ {
+		e := ctx.Route.LBAlgorithm.Apply(ctx)
+		e.Metrics.IncInflightRequest()
+	}
+
+	for _, e := range ep {
+		if n := e.Metrics.GetInflightRequests(); n != 3 {
+			t.Errorf("expected 3 outstanding requests for %s, got: %d", e.Host, n)
+		}
+	}
+}
+
+func TestLeastConnectionsFadeIn(t *testing.T) {
+	ctx := newTestLBContext(t, "leastConnections", "http://127.0.0.1:1234", "http://127.0.0.1:1235")
+	ctx.Route.LBFadeInDuration = time.Minute
+	ctx.Route.LBFadeInExponent = 1
+	ep := ctx.Route.LBEndpoints
+	ep[0].Detected = time.Now().Add(-time.Hour)
+	ep[1].Detected = time.Now().Add(-time.Minute / 4)
+
+	// the endpoint fading in counts as if it had 4 times more requests
+	addInflightRequests(ep[0], 2)
+	if e := ctx.Route.LBAlgorithm.Apply(ctx); e.Host != ep[0].Host {
+		t.Fatalf("expected the old endpoint, got: %s", e.Host)
+	}
+
+	addInflightRequests(ep[0], 2)
+	if e := ctx.Route.LBAlgorithm.Apply(ctx); e.Host != ep[1].Host {
+		t.Fatalf("expected the endpoint fading in, got: %s", e.Host)
+	}
+}
+
+func TestPeakEWMA(t *testing.T) {
+	ctx := newTestLBContext(t, "peakEWMA", "http://127.0.0.1:1234", "http://127.0.0.1:1235", "http://127.0.0.1:1236")
+	if _, ok := ctx.Route.LBAlgorithm.(*peakEWMA); !ok {
+		t.Fatal("failed to set the right algorithm")
+	}
+
+	ep := ctx.Route.LBEndpoints
+	ep[0].Metrics.ObserveLatency(30 * time.Millisecond)
+	ep[1].Metrics.ObserveLatency(10 * time.Millisecond)
+	ep[2].Metrics.ObserveLatency(20 * time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		if e := ctx.Route.LBAlgorithm.Apply(ctx); e.Host != ep[1].Host {
+			t.Fatalf("expected the fastest endpoint, got: %s", e.Host)
+		}
+	}
+
+	// the fastest endpoint gets avoided when it has too many outstanding requests
+	addInflightRequests(ep[1], 3)
+	if e := ctx.Route.LBAlgorithm.Apply(ctx); e.Host != ep[2].Host {
+		t.Fatalf("expected the second fastest endpoint, got: %s", e.Host)
+	}
+
+	// higher latency is taken immediately
+	ep[2].Metrics.ObserveLatency(100 * time.Millisecond)
+	if l := ep[2].Metrics.GetLatency(); l < 99*time.Millisecond || l > 100*time.Millisecond {
+		t.Fatalf("expected the peak latency, got: %v", l)
+	}
+
+	if e := ctx.Route.LBAlgorithm.Apply(ctx); e.Host != ep[0].Host {
+		t.Fatalf("expected the endpoint with the lowest cost, got: %s", e.Host)
+	}
+
+	// lower latency decreases the average
+	ep[2].Metrics.ObserveLatency(10 * time.Millisecond)
+	if l := ep[2].Metrics.GetLatency(); l <= 10*time.Millisecond || l > 100*time.Millisecond {
+		t.Fatalf("unexpected latency: %v", l)
+	}
+}
+
+func TestPeakEWMAUnobservedEndpoint(t *testing.T) {
+	ctx := newTestLBContext(t, "peakEWMA", "http://127.0.0.1:1234", "http://127.0.0.1:1235", "http://127.0.0.1:1236")
+	ep := ctx.Route.LBEndpoints
+	ep[0].Metrics.ObserveLatency(10 * time.Millisecond)
+	ep[1].Metrics.ObserveLatency(30 * time.Millisecond)
+
+	// the new endpoint is assumed to have the average latency
+	if e := ctx.Route.LBAlgorithm.Apply(ctx); e.Host != ep[0].Host {
+		t.Fatalf("expected the fastest endpoint, got: %s", e.Host)
+	}
+
+	addInflightRequests(ep[0], 2)
+	if e := ctx.Route.LBAlgorithm.Apply(ctx); e.Host != ep[2].Host {
+		t.Fatalf("expected the endpoint without observed latency, got: %s", e.Host)
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	ctx := newWeightedTestLBContext(t, "roundRobin", []string{"http://127.0.0.1:1234", "http://127.0.0.1:1235"}, []float64{3, 1})
+	ep := ctx.Route.LBEndpoints
+
+	var selected, consecutive int
+	var last string
+	for i := 0; i < 400; i++ {
+		e := ctx.Route.LBAlgorithm.Apply(ctx)
+		if e.Host == ep[0].Host {
+			selected++
+		}
+
+		if e.Host == last {
+			consecutive++
+		} else {
+			consecutive = 1
+		}
+
+		// the selections of the same endpoint are spread evenly
+		if consecutive > 3 {
+			t.Fatalf("endpoint selected too many times in a row: %s", e.Host)
+		}
+
+		last = e.Host
+	}
+
+	if selected != 300 {
+		t.Fatalf("expected the endpoint to be selected proportionally to its weight, got: %d", selected)
+	}
+}
+
+func TestWeightedRandom(t *testing.T) {
+	ctx := newWeightedTestLBContext(t, "random", []string{"http://127.0.0.1:1234", "http://127.0.0.1:1235"}, []float64{3, 1})
+	ep := ctx.Route.LBEndpoints
+
+	const n = 4000
+	var selected int
+	for i := 0; i < n; i++ {
+		if ctx.Route.LBAlgorithm.Apply(ctx).Host == ep[0].Host {
+			selected++
+		}
+	}
+
+	if share := float64(selected) / n; share < 0.7 || share > 0.8 {
+		t.Fatalf("expected the endpoint to be selected proportionally to its weight, got: %f", share)
+	}
+}
+
+func TestWeightedConsistentHash(t *testing.T) {
+	endpoints := []string{"http://127.0.0.1:1234", "http://127.0.0.1:1235", "http://127.0.0.1:1236"}
+	ch := newWeightedConsistentHashInternal(endpoints, []float64{2, 1, 1}, 100).(consistentHash)
+
+	counts := make(map[int]int)
+	for _, h := range ch {
+		counts[h.index]++
+	}
+
+	if counts[0] != 150 || counts[1] != 75 || counts[2] != 75 {
+		t.Fatalf("expected virtual nodes proportional to the weights, got: %v", counts)
+	}
+
+	ctx := newWeightedTestLBContext(t, "consistentHash", endpoints, []float64{2, 1, 1})
+	if len(ctx.Route.LBAlgorithm.(consistentHash)) != 300 {
+		t.Fatal("failed to create the weighted consistent hash")
+	}
+}
+
+func TestWeightedPowerOfRandomNChoices(t *testing.T) {
+	ctx := newWeightedTestLBContext(t, "powerOfRandomNChoices", []string{"http://127.0.0.1:1234", "http://127.0.0.1:1235"}, []float64{4, 1})
+	p := ctx.Route.LBAlgorithm.(*powerOfRandomNChoices)
+	ep := ctx.Route.LBEndpoints
+	addInflightRequests(ep[0], 2)
+	addInflightRequests(ep[1], 1)
+
+	if p.getScore(ep[0]) <= p.getScore(ep[1]) {
+		t.Fatal("expected the endpoint with the higher weight to have the better score")
+	}
+}
+
+func TestWeightedPowerOfRandomNChoicesWithoutLoad(t *testing.T) {
+	ctx := newWeightedTestLBContext(t, "powerOfRandomNChoices", []string{"http://127.0.0.1:1234", "http://127.0.0.1:1235"}, []float64{4, 1})
+	ep := ctx.Route.LBEndpoints
+
+	const n = 4000
+	var selected int
+	for i := 0; i < n; i++ {
+		if ctx.Route.LBAlgorithm.Apply(ctx).Host == ep[0].Host {
+			selected++
+		}
+	}
+
+	// without requests in flight, the endpoint with the lower weight is
+	// selected only when both choices pick it
+	if share := float64(selected) / n; share < 0.7 || share > 0.8 {
+		t.Fatalf("expected the weights to be effective without requests in flight, got: %f", share)
+	}
+}
+
+func TestApplyLimitedEndpoints(t *testing.T) {
+	endpoints := []string{"http://127.0.0.1:1234", "http://127.0.0.1:1235", "http://127.0.0.1:1236"}
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:1234/foo", nil)
+	for _, tt := range []struct {
+		algorithm string
+		weights   []float64
+	}{
+		{algorithm: "roundRobin"},
+		{algorithm: "roundRobin", weights: []float64{3, 2, 1}},
+		{algorithm: "random"},
+		{algorithm: "random", weights: []float64{3, 2, 1}},
+		{algorithm: "consistentHash"},
+		{algorithm: "consistentHash", weights: []float64{3, 2, 1}},
+		{algorithm: "powerOfRandomNChoices"},
+		{algorithm: "leastConnections"},
+		{algorithm: "peakEWMA"},
+	} {
+		t.Run(fmt.Sprintf("%s %v", tt.algorithm, tt.weights), func(t *testing.T) {
+			ctx := newWeightedTestLBContext(t, tt.algorithm, endpoints, tt.weights)
+			ctx.Request = req
+			excluded := ctx.Route.LBAlgorithm.Apply(ctx)
+			for _, e := range ctx.Route.LBEndpoints {
+				if e.Host != excluded.Host {
+					ctx.LBEndpoints = append(ctx.LBEndpoints, e)
+				}
+			}
+
+			selected := make(map[string]bool)
+			for i := 0; i < 100; i++ {
+				e := ctx.Route.LBAlgorithm.Apply(ctx)
+				if e.Host == excluded.Host {
+					t.Fatalf("excluded endpoint selected: %s", e.Host)
+				}
+
+				selected[e.Host] = true
+			}
+
+			if tt.algorithm == "consistentHash" && len(selected) != 1 {
+				t.Errorf("expected the same endpoint for the same key, got: %v", selected)
+			}
+		})
+	}
+}
+
 func addInflightRequests(endpoint routing.LBEndpoint, count int) {
 	for i := 0; i < count; i++ {
 		endpoint.Metrics.IncInflightRequest()
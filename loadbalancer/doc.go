@@ -25,7 +25,19 @@ powerOfRandomNChoices Algorithm
 	and picks the one with least outstanding requests from them.
 	Currently, N is 2.
 
-The roundRobin and the random algorithms also provide fade-in behavior for LB endpoints of routes where the
+leastConnections Algorithm
+
+	The leastConnections algorithm selects the endpoint with the least
+	outstanding requests. Endpoints with equal load are selected
+	randomly.
+
+peakEWMA Algorithm
+
+	The peakEWMA algorithm selects the endpoint with the lowest peak
+	exponentially weighted moving average of the response times,
+	multiplied by the outstanding requests of the endpoint.
+
+The roundRobin, random, leastConnections and peakEWMA algorithms also provide fade-in behavior for LB endpoints of routes where the
 fade-in duration was configured. This feature can be used to gradually add traffic to new instances of
 applications that require a certain amount of warm-up time.
 
@@ -36,7 +48,20 @@ Eskip example:
         r2: * -> <consistentHash, "http://127.0.0.1:9998", "http://127.0.0.1:9997">;
         r3: * -> <random, "http://127.0.0.1:9998", "http://127.0.0.1:9997">;
         r4: * -> <powerOfRandomNChoices, "http://127.0.0.1:9998", "http://127.0.0.1:9997">;
+        r5: * -> <leastConnections, "http://127.0.0.1:9998", "http://127.0.0.1:9997">;
+        r6: * -> <peakEWMA, "http://127.0.0.1:9998", "http://127.0.0.1:9997">;
+
+
+Package loadbalancer also implements passive outlier detection with the
+OutlierDetector. It ejects temporarily the endpoints that fail
+consecutively, based on the results of the proxied requests, and makes
+all the algorithms above skip the ejected endpoints. After the ejection,
+the endpoints receive gradually increasing traffic during a fade-in
+period.
 
+With ZoneAware, the algorithms prefer the endpoints in the same
+availability zone as the proxy, as long as the zone has enough healthy
+endpoints, and spill over to the other zones otherwise.
 
 Package loadbalancer also implements health checking of pool members for
 a group of routes, if backend calls are reported to the loadbalancer.
@@ -0,0 +1,396 @@
+package loadbalancer
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	// DefaultOutlierConsecutiveFailures is the number of consecutive
+	// failed requests after which an endpoint gets ejected.
+	DefaultOutlierConsecutiveFailures = 5
+
+	// DefaultOutlierBaseEjectionTime is the ejection time of the first
+	// ejection of an endpoint. Every subsequent ejection lasts twice as
+	// long as the previous one.
+	DefaultOutlierBaseEjectionTime = 30 * time.Second
+
+	// DefaultOutlierMaxEjectionTime is the upper limit of the ejection
+	// time.
+	DefaultOutlierMaxEjectionTime = 5 * time.Minute
+
+	// DefaultOutlierMaxEjectionPercent is the maximum percentage of the
+	// endpoints of a route that can be ejected at the same time.
+	DefaultOutlierMaxEjectionPercent = 50
+
+	// DefaultOutlierFadeInDuration is the duration of the fade-in
+	// applied to the endpoints after their ejection time expired.
+	DefaultOutlierFadeInDuration = 10 * time.Second
+)
+
+// OutlierDetectionOptions configure the passive outlier detection.
+type OutlierDetectionOptions struct {
+	// ConsecutiveFailures sets after how many consecutive failed
+	// requests an endpoint gets ejected. Responses with a status code
+	// 5xx and connection errors count as failures. Defaults to
+	// DefaultOutlierConsecutiveFailures.
+	ConsecutiveFailures int
+
+	// LatencyThreshold, when set, makes requests slower than the
+	// threshold count as failures, too.
+	LatencyThreshold time.Duration
+
+	// BaseEjectionTime sets how long an endpoint is ejected for the
+	// first time. Consecutive ejections double it every time. Defaults
+	// to DefaultOutlierBaseEjectionTime.
+	BaseEjectionTime time.Duration
+
+	// MaxEjectionTime caps the ejection time of an endpoint. An
+	// endpoint that stays healthy for this duration after being
+	// re-admitted starts again with the base ejection time. Defaults
+	// to DefaultOutlierMaxEjectionTime.
+	MaxEjectionTime time.Duration
+
+	// MaxEjectionPercent limits the percentage of the endpoints of a
+	// route that can be ejected at the same time. At least one
+	// endpoint can always be ejected when the route has more than one.
+	// Defaults to DefaultOutlierMaxEjectionPercent.
+	MaxEjectionPercent int
+
+	// FadeInDuration sets the duration of the fade-in of the endpoints
+	// after their ejection expired. Defaults to
+	// DefaultOutlierFadeInDuration, and negative values disable it.
+	FadeInDuration time.Duration
+}
+
+type outlierState struct {
+	consecutiveFailures int
+	ejections           int
+	ejectedUntil        time.Time
+	lastActive          time.Time
+	latency             time.Duration
+}
+
+// OutlierDetector implements passive outlier detection of load
+// balanced endpoints, based on the results of the proxied requests.
+// Endpoints failing consecutively are ejected temporarily, and the
+// load balancing algorithms of the routes avoid them until the
+// ejection time expires. After that, the endpoints receive gradually
+// increasing traffic during the fade-in duration.
+//
+// The OutlierDetector needs to be set as a routing.PostProcessor after
+// the one returned by NewAlgorithmProvider, and the proxy needs to
+// report the result of the backend requests to it.
+type OutlierDetector struct {
+	mu        sync.RWMutex
+	options   OutlierDetectionOptions
+	endpoints map[string]*outlierState
+	active    map[string]bool
+	now       func() time.Time
+}
+
+type outlierAwareAlgorithm struct {
+	mu        sync.Mutex
+	algorithm routing.LBAlgorithm
+	detector  *OutlierDetector
+	rnd       *rand.Rand
+}
+
+// NewOutlierDetector creates an OutlierDetector. Unset options take
+// their default values.
+func NewOutlierDetector(o OutlierDetectionOptions) *OutlierDetector {
+	if o.ConsecutiveFailures <= 0 {
+		o.ConsecutiveFailures = DefaultOutlierConsecutiveFailures
+	}
+
+	if o.BaseEjectionTime <= 0 {
+		o.BaseEjectionTime = DefaultOutlierBaseEjectionTime
+	}
+
+	if o.MaxEjectionTime <= 0 {
+		o.MaxEjectionTime = DefaultOutlierMaxEjectionTime
+	}
+
+	if o.MaxEjectionTime < o.BaseEjectionTime {
+		o.MaxEjectionTime = o.BaseEjectionTime
+	}
+
+	if o.MaxEjectionPercent <= 0 {
+		o.MaxEjectionPercent = DefaultOutlierMaxEjectionPercent
+	}
+
+	if o.MaxEjectionPercent > 100 {
+		o.MaxEjectionPercent = 100
+	}
+
+	if o.FadeInDuration == 0 {
+		o.FadeInDuration = DefaultOutlierFadeInDuration
+	}
+
+	return &OutlierDetector{
+		options:   o,
+		endpoints: make(map[string]*outlierState),
+		active:    make(map[string]bool),
+		now:       time.Now,
+	}
+}
+
+func outlierKey(e routing.LBEndpoint) string {
+	return e.Scheme + "://" + e.Host
+}
+
+// ejectionTime returns the base ejection time doubled for every
+// consecutive ejection, capped by the maximum ejection time.
+func (d *OutlierDetector) ejectionTime(ejections int) time.Duration {
+	t := d.options.BaseEjectionTime
+	for i := 1; i < ejections && t < d.options.MaxEjectionTime; i++ {
+		t *= 2
+	}
+
+	if t > d.options.MaxEjectionTime {
+		t = d.options.MaxEjectionTime
+	}
+
+	return t
+}
+
+// needs to be called with the lock held.
+func (d *OutlierDetector) ejectedCount(endpoints []routing.LBEndpoint, now time.Time) int {
+	var n int
+	for _, e := range endpoints {
+		if s, ok := d.endpoints[outlierKey(e)]; ok && now.Before(s.ejectedUntil) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// needs to be called with the lock held.
+func (d *OutlierDetector) canEject(endpoints []routing.LBEndpoint, now time.Time) bool {
+	if len(endpoints) <= 1 {
+		return false
+	}
+
+	max := len(endpoints) * d.options.MaxEjectionPercent / 100
+	if max < 1 {
+		max = 1
+	}
+
+	return d.ejectedCount(endpoints, now) < max
+}
+
+// Report records the result of a request made to an endpoint of a
+// load balanced route. The failed flag should be set for 5xx responses
+// and connection errors, while the latency is the time it took to
+// receive the response. The results of the endpoints that were removed
+// from the routes meanwhile are ignored.
+func (d *OutlierDetector) Report(r *routing.Route, e routing.LBEndpoint, failed bool, latency time.Duration) {
+	if d.options.LatencyThreshold > 0 && latency > d.options.LatencyThreshold {
+		failed = true
+	}
+
+	now := d.now()
+	key := outlierKey(e)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.active[key] {
+		return
+	}
+
+	s, ok := d.endpoints[key]
+	if !ok {
+		s = &outlierState{}
+		d.endpoints[key] = s
+	}
+
+	s.lastActive = now
+	if s.latency == 0 {
+		s.latency = latency
+	} else {
+		// exponentially weighted moving average of the latency
+		s.latency = (4*s.latency + latency) / 5
+	}
+
+	if !failed {
+		s.consecutiveFailures = 0
+		return
+	}
+
+	// already ejected, results of the requests in flight
+	if now.Before(s.ejectedUntil) {
+		return
+	}
+
+	s.consecutiveFailures++
+	if s.consecutiveFailures < d.options.ConsecutiveFailures {
+		return
+	}
+
+	if r == nil || !d.canEject(r.LBEndpoints, now) {
+		return
+	}
+
+	// an endpoint that stayed healthy long enough starts again with
+	// the base ejection time
+	if !s.ejectedUntil.IsZero() && now.Sub(s.ejectedUntil) > d.options.MaxEjectionTime {
+		s.ejections = 0
+	}
+
+	s.ejections++
+	s.consecutiveFailures = 0
+	s.ejectedUntil = now.Add(d.ejectionTime(s.ejections))
+}
+
+// Ejected tells whether an endpoint is currently ejected.
+func (d *OutlierDetector) Ejected(e routing.LBEndpoint) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	s, ok := d.endpoints[outlierKey(e)]
+	return ok && d.now().Before(s.ejectedUntil)
+}
+
+// Latency returns the moving average of the latency observed for an
+// endpoint, or zero when no request to the endpoint was reported.
+func (d *OutlierDetector) Latency(e routing.LBEndpoint) time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if s, ok := d.endpoints[outlierKey(e)]; ok {
+		return s.latency
+	}
+
+	return 0
+}
+
+// admissionWeight returns 0 for ejected endpoints, a value between 0
+// and 1 for endpoints fading in after their ejection, and 1 for the
+// rest.
+func (d *OutlierDetector) admissionWeight(e routing.LBEndpoint, now time.Time) float64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	s, ok := d.endpoints[outlierKey(e)]
+	if !ok || s.ejectedUntil.IsZero() {
+		return 1
+	}
+
+	if now.Before(s.ejectedUntil) {
+		return 0
+	}
+
+	if d.options.FadeInDuration <= 0 {
+		return 1
+	}
+
+	return fadeIn(now, d.options.FadeInDuration, 1, s.ejectedUntil)
+}
+
+// Do implements routing.PostProcessor. It makes the load balancing
+// algorithms of the routes skip the ejected endpoints, and, on every
+// update of the routes, drops the state of the endpoints that are not
+// used by any route anymore.
+func (d *OutlierDetector) Do(r []*routing.Route) []*routing.Route {
+	active := make(map[string]bool)
+	for _, ri := range r {
+		if ri.Route.BackendType != eskip.LBBackend || ri.LBAlgorithm == nil {
+			continue
+		}
+
+		for _, e := range ri.LBEndpoints {
+			active[outlierKey(e)] = true
+		}
+
+		ri.LBAlgorithm = d.wrap(ri.LBAlgorithm)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.active = active
+	for key := range d.endpoints {
+		if !active[key] {
+			delete(d.endpoints, key)
+		}
+	}
+
+	return r
+}
+
+func (d *OutlierDetector) wrap(a routing.LBAlgorithm) routing.LBAlgorithm {
+	if _, ok := a.(*outlierAwareAlgorithm); ok {
+		return a
+	}
+
+	return &outlierAwareAlgorithm{
+		algorithm: a,
+		detector:  d,
+		rnd:       rand.New(rand.NewSource(time.Now().UnixNano())), // #nosec
+	}
+}
+
+// healthy returns the number of endpoints that are not ejected.
+func (d *OutlierDetector) healthy(endpoints []routing.LBEndpoint) int {
+	now := d.now()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(endpoints) - d.ejectedCount(endpoints, now)
+}
+
+func (a *outlierAwareAlgorithm) admit(e routing.LBEndpoint, now time.Time) bool {
+	w := a.detector.admissionWeight(e, now)
+	if w >= 1 {
+		return true
+	}
+
+	if w <= 0 {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rnd.Float64() < w
+}
+
+// Apply implements routing.LBAlgorithm. It calls the wrapped algorithm,
+// and when it selects an ejected endpoint, it tries again. Algorithms
+// that always select the same endpoint for the same request, e.g.
+// consistentHash, fall back to the next endpoint that is not ejected.
+func (a *outlierAwareAlgorithm) Apply(ctx *routing.LBContext) routing.LBEndpoint {
+	e := a.algorithm.Apply(ctx)
+	endpoints := lbEndpoints(ctx)
+	if len(endpoints) == 1 {
+		return e
+	}
+
+	now := a.detector.now()
+	if a.admit(e, now) {
+		return e
+	}
+
+	for i := 0; i < len(endpoints); i++ {
+		if next := a.algorithm.Apply(ctx); a.admit(next, now) {
+			return next
+		}
+	}
+
+	start := 0
+	for i, ei := range endpoints {
+		if ei.Host == e.Host && ei.Scheme == e.Scheme {
+			start = i
+			break
+		}
+	}
+
+	for i := 1; i <= len(endpoints); i++ {
+		if next := endpoints[(start+i)%len(endpoints)]; a.detector.admissionWeight(next, now) > 0 {
+			return next
+		}
+	}
+
+	return e
+}
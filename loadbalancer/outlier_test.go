@@ -0,0 +1,258 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestOutlierDetector(o OutlierDetectionOptions) (*OutlierDetector, *testClock) {
+	c := &testClock{now: time.Now()}
+	d := NewOutlierDetector(o)
+	d.now = c.Now
+	return d, c
+}
+
+func newOutlierTestRoute(t *testing.T, d *OutlierDetector, algorithm string, endpoints ...string) *routing.Route {
+	r := &routing.Route{
+		Route: eskip.Route{
+			Id:          "test",
+			BackendType: eskip.LBBackend,
+			LBAlgorithm: algorithm,
+			LBEndpoints: endpoints,
+		},
+	}
+
+	rr := d.Do(NewAlgorithmProvider().Do([]*routing.Route{r}))
+	if len(rr) != 1 {
+		t.Fatal("failed to process LB route")
+	}
+
+	return rr[0]
+}
+
+func reportFailures(d *OutlierDetector, r *routing.Route, e routing.LBEndpoint, n int) {
+	for i := 0; i < n; i++ {
+		d.Report(r, e, true, time.Millisecond)
+	}
+}
+
+func TestOutlierEjection(t *testing.T) {
+	d, clock := newTestOutlierDetector(OutlierDetectionOptions{
+		ConsecutiveFailures: 3,
+		BaseEjectionTime:    10 * time.Second,
+		MaxEjectionTime:     45 * time.Second,
+		FadeInDuration:      -1,
+	})
+
+	r := newOutlierTestRoute(t, d, "roundRobin", "http://10.0.0.1:8080", "http://10.0.0.2:8080")
+	e := r.LBEndpoints[0]
+
+	reportFailures(d, r, e, 2)
+	d.Report(r, e, false, time.Millisecond)
+	reportFailures(d, r, e, 2)
+	if d.Ejected(e) {
+		t.Fatal("ejected without consecutive failures")
+	}
+
+	d.Report(r, e, true, time.Millisecond)
+	if !d.Ejected(e) {
+		t.Fatal("failed to eject")
+	}
+
+	for _, expected := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 45 * time.Second} {
+		clock.Advance(expected - time.Millisecond)
+		if !d.Ejected(e) {
+			t.Fatalf("ejected shorter than %v", expected)
+		}
+
+		clock.Advance(time.Millisecond)
+		if d.Ejected(e) {
+			t.Fatalf("ejected longer than %v", expected)
+		}
+
+		reportFailures(d, r, e, 3)
+		if !d.Ejected(e) {
+			t.Fatal("failed to eject again")
+		}
+	}
+
+	// staying healthy resets the ejection time
+	clock.Advance(45*time.Second + 46*time.Second)
+	d.Report(r, e, false, time.Millisecond)
+	reportFailures(d, r, e, 3)
+	clock.Advance(10 * time.Second)
+	if d.Ejected(e) {
+		t.Fatal("failed to reset the ejection time")
+	}
+}
+
+func TestOutlierLatencyThreshold(t *testing.T) {
+	d, _ := newTestOutlierDetector(OutlierDetectionOptions{
+		ConsecutiveFailures: 2,
+		LatencyThreshold:    100 * time.Millisecond,
+	})
+
+	r := newOutlierTestRoute(t, d, "roundRobin", "http://10.0.0.1:8080", "http://10.0.0.2:8080")
+	e := r.LBEndpoints[0]
+
+	d.Report(r, e, false, 150*time.Millisecond)
+	d.Report(r, e, false, 50*time.Millisecond)
+	d.Report(r, e, false, 150*time.Millisecond)
+	if d.Ejected(e) {
+		t.Fatal("ejected without consecutive slow responses")
+	}
+
+	d.Report(r, e, false, 150*time.Millisecond)
+	if !d.Ejected(e) {
+		t.Fatal("failed to eject slow endpoint")
+	}
+
+	if l := d.Latency(e); l <= 50*time.Millisecond || l >= 150*time.Millisecond {
+		t.Fatalf("unexpected average latency: %v", l)
+	}
+}
+
+func TestOutlierMaxEjectionPercent(t *testing.T) {
+	for _, tc := range []struct {
+		percent   int
+		endpoints []string
+		expected  int
+	}{{
+		percent:   50,
+		endpoints: []string{"http://10.0.0.1:8080"},
+		expected:  0,
+	}, {
+		percent:   10,
+		endpoints: []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080"},
+		expected:  1,
+	}, {
+		percent:   50,
+		endpoints: []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080", "http://10.0.0.4:8080"},
+		expected:  2,
+	}, {
+		percent:   100,
+		endpoints: []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080"},
+		expected:  3,
+	}} {
+		d, _ := newTestOutlierDetector(OutlierDetectionOptions{
+			ConsecutiveFailures: 1,
+			MaxEjectionPercent:  tc.percent,
+		})
+
+		r := newOutlierTestRoute(t, d, "roundRobin", tc.endpoints...)
+		for _, e := range r.LBEndpoints {
+			reportFailures(d, r, e, 1)
+		}
+
+		var ejected int
+		for _, e := range r.LBEndpoints {
+			if d.Ejected(e) {
+				ejected++
+			}
+		}
+
+		if ejected != tc.expected {
+			t.Errorf("%d%% of %d endpoints: expected %d ejected, got %d", tc.percent, len(tc.endpoints), tc.expected, ejected)
+		}
+	}
+}
+
+func TestOutlierAlgorithmsSkipEjected(t *testing.T) {
+	endpoints := []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080"}
+	for _, algorithm := range []string{"roundRobin", "random", "consistentHash", "powerOfRandomNChoices"} {
+		t.Run(algorithm, func(t *testing.T) {
+			d, _ := newTestOutlierDetector(OutlierDetectionOptions{ConsecutiveFailures: 1})
+			r := newOutlierTestRoute(t, d, algorithm, endpoints...)
+
+			req, _ := http.NewRequest("GET", "http://www.example.org", nil)
+			req.RemoteAddr = "192.168.0.1:9999"
+			ctx := &routing.LBContext{Request: req, Route: r}
+
+			// eject the endpoint selected by the consistent hash, too
+			ejected := r.LBAlgorithm.Apply(ctx)
+			reportFailures(d, r, ejected, 1)
+			if !d.Ejected(ejected) {
+				t.Fatal("failed to eject")
+			}
+
+			for i := 0; i < 100; i++ {
+				if e := r.LBAlgorithm.Apply(ctx); e.Host == ejected.Host {
+					t.Fatal("ejected endpoint selected")
+				}
+			}
+		})
+	}
+}
+
+func TestOutlierFadeIn(t *testing.T) {
+	d, clock := newTestOutlierDetector(OutlierDetectionOptions{
+		ConsecutiveFailures: 1,
+		BaseEjectionTime:    time.Second,
+		FadeInDuration:      time.Minute,
+	})
+
+	r := newOutlierTestRoute(t, d, "roundRobin", "http://10.0.0.1:8080", "http://10.0.0.2:8080")
+	e := r.LBEndpoints[0]
+	reportFailures(d, r, e, 1)
+	clock.Advance(time.Second + 6*time.Second)
+
+	ctx := &routing.LBContext{Route: r}
+	const n = 1000
+	var selected int
+	for i := 0; i < n; i++ {
+		if r.LBAlgorithm.Apply(ctx).Host == e.Host {
+			selected++
+		}
+	}
+
+	// at the 10% of the fade-in, expecting a lot less than the half of
+	// the requests
+	if selected == 0 || selected > n/4 {
+		t.Fatalf("unexpected number of requests during fade-in: %d", selected)
+	}
+
+	clock.Advance(time.Minute)
+	selected = 0
+	for i := 0; i < n; i++ {
+		if r.LBAlgorithm.Apply(ctx).Host == e.Host {
+			selected++
+		}
+	}
+
+	if selected != n/2 {
+		t.Fatalf("unexpected number of requests after fade-in: %d", selected)
+	}
+}
+
+func TestOutlierCleanup(t *testing.T) {
+	d, _ := newTestOutlierDetector(OutlierDetectionOptions{ConsecutiveFailures: 1})
+	r := newOutlierTestRoute(t, d, "roundRobin", "http://10.0.0.1:8080", "http://10.0.0.2:8080")
+	reportFailures(d, r, r.LBEndpoints[0], 1)
+	d.Report(r, r.LBEndpoints[1], false, time.Millisecond)
+
+	if !d.Ejected(r.LBEndpoints[0]) {
+		t.Fatal("failed to eject")
+	}
+
+	newOutlierTestRoute(t, d, "roundRobin", "http://10.0.0.3:8080")
+	if len(d.endpoints) != 0 {
+		t.Fatalf("failed to clean up the state of the endpoints: %d", len(d.endpoints))
+	}
+
+	// results of the requests in flight to the removed endpoints
+	reportFailures(d, r, r.LBEndpoints[0], 1)
+	if len(d.endpoints) != 0 {
+		t.Fatalf("unexpected state of a removed endpoint: %d", len(d.endpoints))
+	}
+}
@@ -0,0 +1,228 @@
+package loadbalancer
+
+import (
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	// DefaultZoneMinEndpoints is the minimum number of healthy
+	// endpoints in the local zone, required to keep the traffic in
+	// the zone.
+	DefaultZoneMinEndpoints = 2
+
+	// DefaultZoneMinHealthyPercent is the minimum percentage of the
+	// healthy endpoints in the local zone, required to keep the
+	// traffic in the zone.
+	DefaultZoneMinHealthyPercent = 70
+
+	zoneSameKey    = "lb.zone.same"
+	zoneCrossKey   = "lb.zone.cross"
+	zoneUnknownKey = "lb.zone.unknown"
+)
+
+// ZoneAwareOptions configure the zone aware load balancing.
+type ZoneAwareOptions struct {
+	// Zone is the availability zone where the proxy runs. Zone aware
+	// load balancing is disabled when not set.
+	Zone string
+
+	// MinEndpoints sets how many healthy endpoints the local zone
+	// needs to have, to keep the traffic in the zone. Defaults to
+	// DefaultZoneMinEndpoints.
+	MinEndpoints int
+
+	// MinHealthyPercent sets the minimum percentage of the endpoints
+	// of the local zone that need to be healthy, to keep the traffic
+	// in the zone. Defaults to DefaultZoneMinHealthyPercent.
+	MinHealthyPercent int
+
+	// OutlierDetector, when set, is used to tell which endpoints are
+	// healthy. Without it, all endpoints are considered healthy.
+	OutlierDetector *OutlierDetector
+
+	// Metrics is used to count the requests sent to the same zone,
+	// to other zones, and to endpoints with unknown zone. Defaults
+	// to metrics.Default.
+	Metrics metrics.Metrics
+}
+
+// ZoneAware implements zone aware load balancing. It makes the load
+// balanced routes prefer the endpoints in the same availability zone
+// as the proxy, as long as there are enough of them and they are
+// healthy. Otherwise, the traffic spills over to the endpoints in the
+// other zones, too.
+//
+// The ZoneAware post processor needs to be set after the one returned
+// by NewAlgorithmProvider, and after the OutlierDetector, if any.
+type ZoneAware struct {
+	options ZoneAwareOptions
+}
+
+type zoneAwareAlgorithm struct {
+	algorithm routing.LBAlgorithm
+	local     *routing.Route
+	zone      *ZoneAware
+}
+
+// NewZoneAware creates a ZoneAware post processor. Unset options take
+// their default values.
+func NewZoneAware(o ZoneAwareOptions) *ZoneAware {
+	if o.MinEndpoints <= 0 {
+		o.MinEndpoints = DefaultZoneMinEndpoints
+	}
+
+	if o.MinHealthyPercent <= 0 {
+		o.MinHealthyPercent = DefaultZoneMinHealthyPercent
+	}
+
+	if o.MinHealthyPercent > 100 {
+		o.MinHealthyPercent = 100
+	}
+
+	if o.Metrics == nil {
+		o.Metrics = metrics.Default
+	}
+
+	return &ZoneAware{options: o}
+}
+
+// localRoute creates a shallow copy of the route, using only the
+// endpoints of the local zone, with its own instance of the load
+// balancing algorithm. It returns nil when the route has no endpoints
+// in the local zone.
+func (z *ZoneAware) localRoute(r *routing.Route) *routing.Route {
+	var (
+		endpoints []string
+		weights   []float64
+		local     []routing.LBEndpoint
+	)
+
+	for i, e := range r.LBEndpoints {
+		if e.Zone != z.options.Zone {
+			continue
+		}
+
+		endpoints = append(endpoints, r.Route.LBEndpoints[i])
+		weights = append(weights, endpointWeight(e))
+		local = append(local, e)
+	}
+
+	if len(local) == 0 {
+		return nil
+	}
+
+	lr := *r
+	lr.Route.LBEndpoints = endpoints
+	lr.Route.LBEndpointWeights = nil
+	for _, w := range weights {
+		if w != 1 {
+			lr.Route.LBEndpointWeights = weights
+			break
+		}
+	}
+
+	if err := setAlgorithm(&lr); err != nil {
+		return nil
+	}
+
+	lr.LBEndpoints = local
+	if z.options.OutlierDetector != nil {
+		lr.LBAlgorithm = z.options.OutlierDetector.wrap(lr.LBAlgorithm)
+	}
+
+	return &lr
+}
+
+// Do implements routing.PostProcessor. It makes the load balancing
+// algorithms of the routes prefer the endpoints in the local zone.
+func (z *ZoneAware) Do(r []*routing.Route) []*routing.Route {
+	if z.options.Zone == "" {
+		return r
+	}
+
+	for _, ri := range r {
+		if ri.Route.BackendType != eskip.LBBackend || ri.LBAlgorithm == nil {
+			continue
+		}
+
+		if _, ok := ri.LBAlgorithm.(*zoneAwareAlgorithm); ok {
+			continue
+		}
+
+		ri.LBAlgorithm = &zoneAwareAlgorithm{
+			algorithm: ri.LBAlgorithm,
+			local:     z.localRoute(ri),
+			zone:      z,
+		}
+	}
+
+	return r
+}
+
+// preferLocal tells whether the local zone has enough healthy
+// endpoints to receive all the traffic.
+func (z *ZoneAware) preferLocal(local []routing.LBEndpoint) bool {
+	healthy := len(local)
+	if z.options.OutlierDetector != nil {
+		healthy = z.options.OutlierDetector.healthy(local)
+	}
+
+	return healthy >= z.options.MinEndpoints &&
+		healthy*100 >= len(local)*z.options.MinHealthyPercent
+}
+
+func (z *ZoneAware) measure(e routing.LBEndpoint) {
+	switch e.Zone {
+	case "":
+		z.options.Metrics.IncCounter(zoneUnknownKey)
+	case z.options.Zone:
+		z.options.Metrics.IncCounter(zoneSameKey)
+	default:
+		z.options.Metrics.IncCounter(zoneCrossKey)
+	}
+}
+
+// applyLocal applies the load balancing algorithm to the endpoints of
+// the local zone, when there are enough healthy ones. When the context
+// limits the endpoints, only those of them are used that are in the
+// local zone, and when there are none, it returns false.
+func (a *zoneAwareAlgorithm) applyLocal(ctx *routing.LBContext) (routing.LBEndpoint, bool) {
+	if a.local == nil || !a.zone.preferLocal(a.local.LBEndpoints) {
+		return routing.LBEndpoint{}, false
+	}
+
+	var local []routing.LBEndpoint
+	if len(ctx.LBEndpoints) > 0 {
+		for _, e := range a.local.LBEndpoints {
+			if containsEndpoint(ctx.LBEndpoints, e) {
+				local = append(local, e)
+			}
+		}
+
+		if len(local) == 0 {
+			return routing.LBEndpoint{}, false
+		}
+	}
+
+	return a.local.LBAlgorithm.Apply(&routing.LBContext{
+		Request:     ctx.Request,
+		Route:       a.local,
+		LBEndpoints: local,
+		Params:      ctx.Params,
+	}), true
+}
+
+// Apply implements routing.LBAlgorithm. It applies the load balancing
+// algorithm of the route to the endpoints of the local zone, when
+// there are enough healthy ones, otherwise to all the endpoints.
+func (a *zoneAwareAlgorithm) Apply(ctx *routing.LBContext) routing.LBEndpoint {
+	e, ok := a.applyLocal(ctx)
+	if !ok {
+		e = a.algorithm.Apply(ctx)
+	}
+
+	a.zone.measure(e)
+	return e
+}
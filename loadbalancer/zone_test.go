@@ -0,0 +1,115 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/routing"
+)
+
+func newZoneTestRoute(t *testing.T, algorithm string, postProcessors ...routing.PostProcessor) *routing.Route {
+	r := &routing.Route{
+		Route: eskip.Route{
+			Id:          "test",
+			BackendType: eskip.LBBackend,
+			LBAlgorithm: algorithm,
+			LBEndpoints: []string{
+				"http://10.0.0.1:8080",
+				"http://10.0.0.2:8080",
+				"http://10.0.0.3:8080",
+				"http://10.0.0.4:8080",
+			},
+			LBEndpointZones: []string{"zone-a", "zone-a", "zone-b", "zone-b"},
+		},
+	}
+
+	rr := NewAlgorithmProvider().Do([]*routing.Route{r})
+	for _, pp := range postProcessors {
+		rr = pp.Do(rr)
+	}
+
+	if len(rr) != 1 {
+		t.Fatal("failed to process LB route")
+	}
+
+	return rr[0]
+}
+
+func countZones(r *routing.Route, n int) map[string]int {
+	req, _ := http.NewRequest("GET", "http://www.example.org", nil)
+	req.RemoteAddr = "192.168.0.1:9999"
+	ctx := &routing.LBContext{Request: req, Route: r}
+	zones := make(map[string]int)
+	for i := 0; i < n; i++ {
+		zones[r.LBAlgorithm.Apply(ctx).Zone]++
+	}
+
+	return zones
+}
+
+func TestZoneAwarePrefersLocalZone(t *testing.T) {
+	for _, algorithm := range []string{"roundRobin", "random", "consistentHash", "powerOfRandomNChoices", "leastConnections", "peakEWMA"} {
+		t.Run(algorithm, func(t *testing.T) {
+			m := &metricstest.MockMetrics{}
+			r := newZoneTestRoute(t, algorithm, NewZoneAware(ZoneAwareOptions{Zone: "zone-a", Metrics: m}))
+			if r.LBEndpoints[0].Zone != "zone-a" || r.LBEndpoints[2].Zone != "zone-b" {
+				t.Fatal("failed to set the zones of the endpoints")
+			}
+
+			if zones := countZones(r, 100); zones["zone-a"] != 100 {
+				t.Fatalf("expected only the local zone to be selected, got: %v", zones)
+			}
+
+			m.WithCounters(func(c map[string]int64) {
+				if c[zoneSameKey] != 100 || c[zoneCrossKey] != 0 {
+					t.Fatalf("unexpected zone metrics: %v", c)
+				}
+			})
+		})
+	}
+}
+
+func TestZoneAwareSpillsOver(t *testing.T) {
+	d, _ := newTestOutlierDetector(OutlierDetectionOptions{ConsecutiveFailures: 1})
+	m := &metricstest.MockMetrics{}
+	z := NewZoneAware(ZoneAwareOptions{Zone: "zone-a", OutlierDetector: d, Metrics: m})
+	r := newZoneTestRoute(t, "roundRobin", d, z)
+
+	reportFailures(d, r, r.LBEndpoints[0], 1)
+	if !d.Ejected(r.LBEndpoints[0]) {
+		t.Fatal("failed to eject")
+	}
+
+	zones := countZones(r, 90)
+	if zones["zone-b"] != 60 || zones["zone-a"] != 30 {
+		t.Fatalf("expected the traffic to spill over to the other zone, got: %v", zones)
+	}
+
+	for i := 0; i < 90; i++ {
+		if r.LBAlgorithm.Apply(&routing.LBContext{Route: r}).Host == r.LBEndpoints[0].Host {
+			t.Fatal("ejected endpoint selected")
+		}
+	}
+
+	m.WithCounters(func(c map[string]int64) {
+		if c[zoneCrossKey] != 120 || c[zoneSameKey] != 60 {
+			t.Fatalf("unexpected zone metrics: %v", c)
+		}
+	})
+}
+
+func TestZoneAwareNoLocalEndpoints(t *testing.T) {
+	r := newZoneTestRoute(t, "roundRobin", NewZoneAware(ZoneAwareOptions{Zone: "zone-c", Metrics: &metricstest.MockMetrics{}}))
+	if zones := countZones(r, 100); zones["zone-a"] != 50 || zones["zone-b"] != 50 {
+		t.Fatalf("expected all the zones to be selected, got: %v", zones)
+	}
+}
+
+func TestZoneAwareDisabled(t *testing.T) {
+	r := newZoneTestRoute(t, "roundRobin", NewZoneAware(ZoneAwareOptions{}))
+	if _, ok := r.LBAlgorithm.(*zoneAwareAlgorithm); ok {
+		t.Fatal("unexpected zone aware algorithm")
+	}
+}
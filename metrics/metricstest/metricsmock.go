@@ -1,9 +1,12 @@
 package metricstest
 
 import (
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/zalando/skipper/metrics"
 )
 
 type MockMetrics struct {
@@ -108,56 +111,64 @@ func (m *MockMetrics) IncFloatCounterBy(key string, value float64) {
 	})
 }
 
-func (*MockMetrics) MeasureRouteLookup(start time.Time) {
-	panic("implement me")
+// The proxy measurements are recorded with the keys of the CodaHale
+// backend, as if all the optional metrics were enabled.
+
+func (m *MockMetrics) MeasureRouteLookup(start time.Time) {
+	m.MeasureSince(metrics.KeyRouteLookup, start)
 }
 
-func (*MockMetrics) MeasureFilterRequest(filterName string, start time.Time) {
-	panic("implement me")
+func (m *MockMetrics) MeasureFilterRequest(filterName string, start time.Time) {
+	m.MeasureSince(fmt.Sprintf(metrics.KeyFilterRequest, filterName), start)
 }
 
-func (*MockMetrics) MeasureAllFiltersRequest(routeId string, start time.Time) {
-	panic("implement me")
+func (m *MockMetrics) MeasureAllFiltersRequest(routeId string, start time.Time) {
+	m.MeasureSince(metrics.KeyAllFiltersRequestCombined, start)
+	m.MeasureSince(fmt.Sprintf(metrics.KeyFiltersRequest, routeId), start)
 }
 
-func (*MockMetrics) MeasureBackend(routeId string, start time.Time) {
-	panic("implement me")
+func (m *MockMetrics) MeasureBackend(routeId string, start time.Time) {
+	m.MeasureSince(metrics.KeyProxyBackendCombined, start)
+	m.MeasureSince(fmt.Sprintf(metrics.KeyProxyBackend, routeId), start)
 }
 
-func (*MockMetrics) MeasureBackendHost(routeBackendHost string, start time.Time) {
-	panic("implement me")
+func (m *MockMetrics) MeasureBackendHost(routeBackendHost string, start time.Time) {
+	m.MeasureSince(fmt.Sprintf(metrics.KeyProxyBackendHost, routeBackendHost), start)
 }
 
-func (*MockMetrics) MeasureFilterResponse(filterName string, start time.Time) {
-	panic("implement me")
+func (m *MockMetrics) MeasureFilterResponse(filterName string, start time.Time) {
+	m.MeasureSince(fmt.Sprintf(metrics.KeyFilterResponse, filterName), start)
 }
 
-func (*MockMetrics) MeasureAllFiltersResponse(routeId string, start time.Time) {
-	panic("implement me")
+func (m *MockMetrics) MeasureAllFiltersResponse(routeId string, start time.Time) {
+	m.MeasureSince(metrics.KeyAllFiltersResponseCombined, start)
+	m.MeasureSince(fmt.Sprintf(metrics.KeyFiltersResponse, routeId), start)
 }
 
-func (*MockMetrics) MeasureResponse(code int, method string, routeId string, start time.Time) {
-	panic("implement me")
+func (m *MockMetrics) MeasureResponse(code int, method string, routeId string, start time.Time) {
+	m.MeasureSince(fmt.Sprintf(metrics.KeyResponseCombined, code, method), start)
+	m.MeasureSince(fmt.Sprintf(metrics.KeyResponse, code, method, routeId), start)
 }
 
-func (*MockMetrics) MeasureServe(routeId, host, method string, code int, start time.Time) {
-	panic("implement me")
+func (m *MockMetrics) MeasureServe(routeId, host, method string, code int, start time.Time) {
+	m.MeasureSince(fmt.Sprintf("serveroute.%s.%s.%d", routeId, method, code), start)
+	m.MeasureSince(fmt.Sprintf("servehost.%s.%s.%d", host, method, code), start)
 }
 
-func (*MockMetrics) IncRoutingFailures() {
-	panic("implement me")
+func (m *MockMetrics) IncRoutingFailures() {
+	m.IncCounter(metrics.KeyRouteFailure)
 }
 
-func (*MockMetrics) IncErrorsBackend(routeId string) {
-	panic("implement me")
+func (m *MockMetrics) IncErrorsBackend(routeId string) {
+	m.IncCounter(fmt.Sprintf(metrics.KeyErrorsBackend, routeId))
 }
 
-func (*MockMetrics) MeasureBackend5xx(t time.Time) {
-	panic("implement me")
+func (m *MockMetrics) MeasureBackend5xx(t time.Time) {
+	m.MeasureSince(metrics.Key5xxsBackend, t)
 }
 
-func (*MockMetrics) IncErrorsStreaming(routeId string) {
-	panic("implement me")
+func (m *MockMetrics) IncErrorsStreaming(routeId string) {
+	m.IncCounter(fmt.Sprintf(metrics.KeyErrorsStreaming, routeId))
 }
 
 func (*MockMetrics) RegisterHandler(path string, handler *http.ServeMux) {
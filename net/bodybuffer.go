@@ -0,0 +1,128 @@
+package net
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrBodyTooLarge is returned by NewBodyBuffer when the body exceeds the
+// configured maximum size.
+var ErrBodyTooLarge = errors.New("body too large")
+
+// BodyBuffer holds a fully read request body, in memory or, above the
+// memory limit, in a temporary file, and allows to read it multiple times,
+// e.g. for retries or for sending it to shadow backends.
+//
+// The buffer is released, and the temporary file is closed, when the
+// buffer itself and all the readers created by NewReader were closed.
+type BodyBuffer struct {
+	mu     sync.Mutex
+	refs   int
+	mem    []byte
+	file   *os.File
+	remove bool
+	size   int64
+}
+
+type bodyBufferReader struct {
+	io.Reader
+	buffer *BodyBuffer
+	once   sync.Once
+}
+
+// NewBodyBuffer reads the body from r. When the body exceeds maxSize, it
+// returns ErrBodyTooLarge. When the body exceeds memoryLimit, it is stored
+// in a temporary file.
+func NewBodyBuffer(r io.Reader, maxSize, memoryLimit int64) (*BodyBuffer, error) {
+	if memoryLimit > maxSize {
+		memoryLimit = maxSize
+	}
+
+	var mem bytes.Buffer
+	n, err := io.Copy(&mem, io.LimitReader(r, memoryLimit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if n <= memoryLimit {
+		return &BodyBuffer{refs: 1, mem: mem.Bytes(), size: n}, nil
+	}
+
+	if n > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+
+	f, err := os.CreateTemp("", "skipper-body-")
+	if err != nil {
+		return nil, err
+	}
+
+	// the open file can be read after it was removed, and the disk space
+	// is reclaimed when the file is closed
+	removeErr := os.Remove(f.Name())
+
+	fileSize, err := io.Copy(f, io.MultiReader(&mem, io.LimitReader(r, maxSize+1-n)))
+	if err == nil && fileSize > maxSize {
+		err = ErrBodyTooLarge
+	}
+
+	if err != nil {
+		f.Close()
+		if removeErr != nil {
+			os.Remove(f.Name())
+		}
+
+		return nil, err
+	}
+
+	return &BodyBuffer{refs: 1, file: f, remove: removeErr != nil, size: fileSize}, nil
+}
+
+// Size returns the size of the buffered body.
+func (b *BodyBuffer) Size() int64 { return b.size }
+
+// NewReader returns a reader that reads the buffered body from the
+// beginning. The readers are independent, and can be used concurrently.
+func (b *BodyBuffer) NewReader() io.ReadCloser {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refs++
+
+	r := &bodyBufferReader{buffer: b}
+	if b.file != nil {
+		r.Reader = io.NewSectionReader(b.file, 0, b.size)
+	} else {
+		r.Reader = bytes.NewReader(b.mem)
+	}
+
+	return r
+}
+
+func (b *BodyBuffer) release() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refs--
+	if b.refs > 0 || b.file == nil {
+		return nil
+	}
+
+	err := b.file.Close()
+	if b.remove {
+		os.Remove(b.file.Name())
+	}
+
+	return err
+}
+
+// Close releases the buffer. The buffered body can still be read by the
+// readers that were not closed yet.
+func (b *BodyBuffer) Close() error { return b.release() }
+
+func (r *bodyBufferReader) Close() error {
+	var err error
+	r.once.Do(func() { err = r.buffer.release() })
+	return err
+}
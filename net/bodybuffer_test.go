@@ -0,0 +1,151 @@
+package net
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func readAll(t *testing.T, r io.ReadCloser) string {
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestBodyBuffer(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		body        string
+		maxSize     int64
+		memoryLimit int64
+		inFile      bool
+		err         error
+	}{{
+		name:        "empty",
+		maxSize:     10,
+		memoryLimit: 5,
+	}, {
+		name:        "in memory",
+		body:        "foo",
+		maxSize:     10,
+		memoryLimit: 5,
+	}, {
+		name:        "memory limit",
+		body:        "fooba",
+		maxSize:     10,
+		memoryLimit: 5,
+	}, {
+		name:        "in file",
+		body:        "foobarbaz",
+		maxSize:     10,
+		memoryLimit: 5,
+		inFile:      true,
+	}, {
+		name:        "max size in file",
+		body:        "foobarbazq",
+		maxSize:     10,
+		memoryLimit: 5,
+		inFile:      true,
+	}, {
+		name:        "too large in file",
+		body:        "foobarbazqux",
+		maxSize:     10,
+		memoryLimit: 5,
+		err:         ErrBodyTooLarge,
+	}, {
+		name:        "too large in memory",
+		body:        "foobarbazqux",
+		maxSize:     10,
+		memoryLimit: 20,
+		err:         ErrBodyTooLarge,
+	}, {
+		name:        "no memory",
+		body:        "foo",
+		maxSize:     10,
+		memoryLimit: 0,
+		inFile:      true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBodyBuffer(strings.NewReader(tt.body), tt.maxSize, tt.memoryLimit)
+			if err != tt.err {
+				t.Fatalf("expected error %v, got: %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if (b.file != nil) != tt.inFile {
+				t.Errorf("expected body in file: %v", tt.inFile)
+			}
+
+			if b.Size() != int64(len(tt.body)) {
+				t.Errorf("expected size %d, got: %d", len(tt.body), b.Size())
+			}
+
+			r1, r2 := b.NewReader(), b.NewReader()
+			b.Close()
+
+			if s := readAll(t, r1); s != tt.body {
+				t.Errorf("expected body %s, got: %s", tt.body, s)
+			}
+
+			if s := readAll(t, r2); s != tt.body {
+				t.Errorf("expected body %s, got: %s", tt.body, s)
+			}
+		})
+	}
+}
+
+func TestBodyBufferConcurrentReaders(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789"), 1000)
+	b, err := NewBodyBuffer(bytes.NewReader(body), 1<<20, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		r := b.NewReader()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s := readAll(t, r); s != string(body) {
+				t.Error("unexpected body")
+			}
+		}()
+	}
+
+	b.Close()
+	wg.Wait()
+
+	if b.refs != 0 {
+		t.Errorf("expected buffer to be released, got %d references", b.refs)
+	}
+}
+
+func TestBodyBufferReleasedOnce(t *testing.T) {
+	b, err := NewBodyBuffer(strings.NewReader("foobarbaz"), 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := b.NewReader()
+	r.Close()
+	r.Close()
+
+	if s := readAll(t, b.NewReader()); s != "foobarbaz" {
+		t.Errorf("expected buffer to be readable, got: %s", s)
+	}
+
+	b.Close()
+	if _, err := b.file.Stat(); err == nil {
+		t.Error("expected file to be closed")
+	}
+}
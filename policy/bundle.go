@@ -0,0 +1,332 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Effect is the effect of a matching rule, allow or deny.
+type Effect string
+
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+// Condition checks a value of the input document, selected by a dot
+// separated path, e.g. request.method or claims.groups. Dots in the keys
+// of the input can be escaped with a backslash. Every condition needs to
+// have exactly one check. When the selected value is a list, the check
+// holds when it holds for any of its items.
+type Condition struct {
+	// Input is the path of the checked value in the input document.
+	Input string `yaml:"input"`
+
+	// Equals checks that the value is equal to the given one.
+	Equals string `yaml:"equals,omitempty"`
+
+	// In checks that the value is equal to one of the given ones.
+	In []string `yaml:"in,omitempty"`
+
+	// Contains checks that a string value contains the given one, or a
+	// list value has an item equal to it.
+	Contains string `yaml:"contains,omitempty"`
+
+	// Prefix checks that the value starts with the given one.
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// Matches checks that the value matches the given regular
+	// expression.
+	Matches string `yaml:"matches,omitempty"`
+
+	// Exists checks whether the value exists in the input document.
+	Exists *bool `yaml:"exists,omitempty"`
+
+	// Not negates the result of the check.
+	Not bool `yaml:"not,omitempty"`
+
+	path []string
+	rx   *regexp.Regexp
+}
+
+// Response is the response to the denied requests.
+type Response struct {
+	Status  int               `yaml:"status,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+}
+
+// Rule matches the requests, where all the conditions hold.
+type Rule struct {
+	Name   string      `yaml:"name"`
+	Effect Effect      `yaml:"effect"`
+	When   []Condition `yaml:"when"`
+
+	// Response, when set, overrides the default response of the bundle
+	// for the requests denied by the rule.
+	Response *Response `yaml:"response,omitempty"`
+}
+
+// Bundle is a set of rules evaluated together. When any deny rule
+// matches, the request is denied, otherwise when any allow rule matches,
+// it is allowed. When no rule matches, the default effect applies.
+type Bundle struct {
+	// Default is the effect when no rule matches. Defaults to deny.
+	Default Effect `yaml:"default,omitempty"`
+
+	// Response is the default response to the denied requests. The
+	// status defaults to 403.
+	Response Response `yaml:"response,omitempty"`
+
+	Rules []Rule `yaml:"rules"`
+}
+
+// Decision is the result of evaluating a bundle.
+type Decision struct {
+	Allow bool
+
+	// Rule is the name of the rule deciding the request, empty when the
+	// default effect applied.
+	Rule string
+
+	// Response is the response to send when the request is denied.
+	Response Response
+}
+
+// ParseBundle parses and validates a YAML bundle.
+func ParseBundle(b []byte) (*Bundle, error) {
+	var bundle Bundle
+	if err := yaml.UnmarshalStrict(b, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse policy bundle: %w", err)
+	}
+
+	if err := bundle.compile(); err != nil {
+		return nil, err
+	}
+
+	return &bundle, nil
+}
+
+func validEffect(e Effect) bool {
+	return e == Allow || e == Deny
+}
+
+func (b *Bundle) compile() error {
+	if b.Default == "" {
+		b.Default = Deny
+	}
+
+	if !validEffect(b.Default) {
+		return fmt.Errorf("invalid default effect: %s", b.Default)
+	}
+
+	if b.Response.Status == 0 {
+		b.Response.Status = http.StatusForbidden
+	}
+
+	for i := range b.Rules {
+		r := &b.Rules[i]
+		if !validEffect(r.Effect) {
+			return fmt.Errorf("invalid effect of rule %s: %s", r.Name, r.Effect)
+		}
+
+		if r.Response != nil && r.Response.Status == 0 {
+			r.Response.Status = b.Response.Status
+		}
+
+		for j := range r.When {
+			if err := r.When[j].compile(); err != nil {
+				return fmt.Errorf("invalid condition of rule %s: %w", r.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func splitPath(p string) []string {
+	var (
+		path    []string
+		current strings.Builder
+		escaped bool
+	)
+
+	for _, c := range p {
+		switch {
+		case escaped:
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '.':
+			path = append(path, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(c)
+		}
+	}
+
+	return append(path, current.String())
+}
+
+func (c *Condition) compile() error {
+	if c.Input == "" {
+		return errors.New("missing input")
+	}
+
+	c.path = splitPath(c.Input)
+
+	var checks int
+	for _, set := range []bool{c.Equals != "", len(c.In) > 0, c.Contains != "", c.Prefix != "", c.Matches != "", c.Exists != nil} {
+		if set {
+			checks++
+		}
+	}
+
+	if checks != 1 {
+		return fmt.Errorf("condition of %s needs exactly one check", c.Input)
+	}
+
+	if c.Matches != "" {
+		rx, err := regexp.Compile(c.Matches)
+		if err != nil {
+			return err
+		}
+
+		c.rx = rx
+	}
+
+	return nil
+}
+
+func lookup(input map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = input
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return v, v != nil
+}
+
+func stringValues(v interface{}) []string {
+	switch vv := v.(type) {
+	case string:
+		return []string{vv}
+	case []string:
+		return vv
+	case []interface{}:
+		var s []string
+		for _, item := range vv {
+			s = append(s, stringValues(item)...)
+		}
+
+		return s
+	case map[string]interface{}, nil:
+		return nil
+	default:
+		return []string{fmt.Sprint(vv)}
+	}
+}
+
+func anyValue(values []string, check func(string) bool) bool {
+	for _, v := range values {
+		if check(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Condition) holds(input map[string]interface{}) bool {
+	return c.check(input) != c.Not
+}
+
+func (c *Condition) check(input map[string]interface{}) bool {
+	v, ok := lookup(input, c.path)
+	if c.Exists != nil {
+		return ok == *c.Exists
+	}
+
+	if !ok {
+		return false
+	}
+
+	values := stringValues(v)
+	switch {
+	case c.Equals != "":
+		return anyValue(values, func(s string) bool { return s == c.Equals })
+	case len(c.In) > 0:
+		return anyValue(values, func(s string) bool {
+			for _, in := range c.In {
+				if s == in {
+					return true
+				}
+			}
+
+			return false
+		})
+	case c.Contains != "":
+		if s, ok := v.(string); ok {
+			return strings.Contains(s, c.Contains)
+		}
+
+		return anyValue(values, func(s string) bool { return s == c.Contains })
+	case c.Prefix != "":
+		return anyValue(values, func(s string) bool { return strings.HasPrefix(s, c.Prefix) })
+	default:
+		return anyValue(values, c.rx.MatchString)
+	}
+}
+
+func (r *Rule) matches(input map[string]interface{}) bool {
+	for i := range r.When {
+		if !r.When[i].holds(input) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Evaluate evaluates the rules of the bundle against the input document.
+func (b *Bundle) Evaluate(input map[string]interface{}) Decision {
+	var allowedBy *Rule
+	for i := range b.Rules {
+		r := &b.Rules[i]
+		if !r.matches(input) {
+			continue
+		}
+
+		if r.Effect == Deny {
+			d := Decision{Rule: r.Name, Response: b.Response}
+			if r.Response != nil {
+				d.Response = *r.Response
+			}
+
+			return d
+		}
+
+		if allowedBy == nil {
+			allowedBy = r
+		}
+	}
+
+	if allowedBy != nil {
+		return Decision{Allow: true, Rule: allowedBy.Name}
+	}
+
+	return Decision{Allow: b.Default == Allow, Response: b.Response}
+}
@@ -0,0 +1,139 @@
+package policy
+
+import (
+	"testing"
+)
+
+const testBundle = `
+response:
+  status: 401
+  headers:
+    WWW-Authenticate: Bearer
+rules:
+- name: public-read
+  effect: allow
+  when:
+  - input: request.method
+    in: [GET, HEAD]
+  - input: request.path
+    prefix: /public/
+- name: admins
+  effect: allow
+  when:
+  - input: claims.groups
+    contains: admins
+- name: blocked
+  effect: deny
+  response:
+    status: 429
+    body: blocked
+  when:
+  - input: request.headers.user-agent
+    matches: ^bad-bot/
+- name: internal
+  effect: deny
+  when:
+  - input: request.path
+    prefix: /internal/
+  - input: claims.https://example\.org/internal
+    exists: true
+    not: true
+`
+
+func TestEvaluate(t *testing.T) {
+	b, err := ParseBundle([]byte(testBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, path, userAgent string) map[string]interface{} {
+		return map[string]interface{}{
+			"method":  method,
+			"path":    path,
+			"headers": map[string]interface{}{"user-agent": userAgent},
+		}
+	}
+
+	for _, ti := range []struct {
+		msg    string
+		input  map[string]interface{}
+		allow  bool
+		rule   string
+		status int
+	}{{
+		msg:   "public read",
+		input: map[string]interface{}{"request": request("GET", "/public/docs", "curl")},
+		allow: true,
+		rule:  "public-read",
+	}, {
+		msg:    "public write",
+		input:  map[string]interface{}{"request": request("POST", "/public/docs", "curl")},
+		status: 401,
+	}, {
+		msg: "admin",
+		input: map[string]interface{}{
+			"request": request("POST", "/api", "curl"),
+			"claims":  map[string]interface{}{"groups": []interface{}{"dev", "admins"}},
+		},
+		allow: true,
+		rule:  "admins",
+	}, {
+		msg:    "deny overrides allow",
+		input:  map[string]interface{}{"request": request("GET", "/public/docs", "bad-bot/1.0")},
+		rule:   "blocked",
+		status: 429,
+	}, {
+		msg: "negated condition",
+		input: map[string]interface{}{
+			"request": request("GET", "/internal/", "curl"),
+			"claims":  map[string]interface{}{"groups": []interface{}{"admins"}},
+		},
+		rule:   "internal",
+		status: 401,
+	}, {
+		msg: "escaped path",
+		input: map[string]interface{}{
+			"request": request("GET", "/internal/", "curl"),
+			"claims": map[string]interface{}{
+				"groups":                       []interface{}{"admins"},
+				"https://example.org/internal": true,
+			},
+		},
+		allow: true,
+		rule:  "admins",
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			d := b.Evaluate(ti.input)
+			if d.Allow != ti.allow || d.Rule != ti.rule || !d.Allow && d.Response.Status != ti.status {
+				t.Errorf("unexpected decision: %+v", d)
+			}
+		})
+	}
+}
+
+func TestDefaultAllow(t *testing.T) {
+	b, err := ParseBundle([]byte("default: allow\nrules: []\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := b.Evaluate(map[string]interface{}{}); !d.Allow {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+}
+
+func TestInvalidBundle(t *testing.T) {
+	for _, b := range []string{
+		"default: maybe",
+		"rules:\n- effect: permit",
+		"rules:\n- effect: allow\n  when:\n  - equals: foo",
+		"rules:\n- effect: allow\n  when:\n  - input: request.path",
+		"rules:\n- effect: allow\n  when:\n  - input: request.path\n    equals: /\n    prefix: /",
+		"rules:\n- effect: allow\n  when:\n  - input: request.path\n    matches: '['",
+		"unknown: field",
+	} {
+		if _, err := ParseBundle([]byte(b)); err == nil {
+			t.Errorf("expected error for %q", b)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Package policy implements an embedded policy engine, used by the
+policyAuthorize filter to authorize the requests in process, without
+calling an external service. The policies are written in a YAML rule
+language specific to this package, it is not compatible with OPA and
+Rego.
+
+Bundles
+
+The policies are defined in bundles, which are YAML documents with a
+list of rules. A rule matches a request, when all its conditions hold
+for the input document of the request. When any matching rule has the
+deny effect, the request is denied, otherwise, when any matching rule
+has the allow effect, the request is allowed. When no rule matches, the
+default effect of the bundle applies, which defaults to deny. The
+response to the denied requests can be set for the whole bundle and
+overridden by the deny rules:
+
+	default: deny
+	response:
+	  status: 403
+	  headers:
+	    Content-Type: application/json
+	  body: '{"error": "forbidden"}'
+	rules:
+	- name: public-read
+	  effect: allow
+	  when:
+	  - input: request.method
+	    in: [GET, HEAD]
+	  - input: request.path
+	    prefix: /public/
+	- name: admins
+	  effect: allow
+	  when:
+	  - input: claims.groups
+	    contains: admins
+	- name: blocked-clients
+	  effect: deny
+	  response:
+	    status: 429
+	  when:
+	  - input: request.headers.user-agent
+	    matches: ^bad-bot/
+
+Every condition selects a value of the input document by a dot separated
+path, and has exactly one of the equals, in, contains, prefix, matches
+or exists checks, which can be negated with not: true. When the selected
+value is a list, the check holds when it holds for any of its items.
+
+The input document of the policyAuthorize filter contains the request
+method, host, path, remote address, the lists of the values of the query
+parameters and of the headers with lowercase names, and the token claims
+found in the state bag:
+
+	request:
+	  method: GET
+	  host: api.example.org
+	  path: /public/docs
+	  remoteAddr: 10.2.3.4:56789
+	  query:
+	    page: ["2"]
+	  headers:
+	    user-agent: [curl/7.83.1]
+	claims:        # from jwtValidation or the oauthOidc* filters
+	tokeninfo:     # from the oauthTokeninfo* filters
+	introspection: # from the oauthTokenintrospection* filters
+
+Loading
+
+The Registry loads the bundles from files or http(s) URLs, and reloads
+them periodically. The sources are set by the routes, so the Registry
+loads only the files of its base directory, and the URLs under its
+allowed URL prefixes, without following redirects. When reloading a
+bundle fails, the previous version is kept. The bundles loaded from URLs
+are only parsed again, when their ETag changes. The policyAuthorize filter
+drops the bundles not used by any route anymore with Retain.
+*/
+package policy
@@ -0,0 +1,341 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReloadInterval = time.Minute
+	defaultTimeout        = 10 * time.Second
+)
+
+// Options configures the registry of the policy bundles.
+type Options struct {
+	// ReloadInterval sets how often the bundles are reloaded from their
+	// sources. Defaults to 1m.
+	ReloadInterval time.Duration
+
+	// Timeout sets the timeout of loading the bundles from URLs.
+	// Defaults to 10s.
+	Timeout time.Duration
+
+	// BaseDir is the directory of the bundle files. The relative file
+	// sources are resolved against it, and the sources outside of it
+	// are rejected. Without it, no bundles can be loaded from files.
+	BaseDir string
+
+	// AllowedURLPrefixes lists the URLs, under which the bundles can be
+	// loaded from, e.g. https://policies.example.org/bundles. The
+	// scheme and the host of a source need to match one of them
+	// exactly, and its path needs to be under the path of the same
+	// prefix. Without it, no bundles can be loaded from URLs.
+	AllowedURLPrefixes []string
+}
+
+// ErrSourceNotAllowed is returned, when a bundle source is outside of
+// the base directory or of the allowed URL prefixes.
+var ErrSourceNotAllowed = errors.New("policy bundle source not allowed")
+
+type bundleEntry struct {
+	bundle *Bundle
+	etag   string
+}
+
+// Registry loads the policy bundles from files or URLs, and reloads
+// them periodically. When reloading a bundle fails, the registry keeps
+// the previous version of it.
+type Registry struct {
+	client      *http.Client
+	baseDir     string
+	urlPrefixes []*url.URL
+
+	mu      sync.RWMutex
+	bundles map[string]*bundleEntry
+
+	quit chan struct{}
+	once sync.Once
+}
+
+// NewRegistry creates a registry of policy bundles, and starts
+// reloading the bundles in the background. It returns an error, when
+// the base directory or the allowed URL prefixes are invalid. On tear
+// down, make sure to Close() it.
+func NewRegistry(o Options) (*Registry, error) {
+	if o.ReloadInterval <= 0 {
+		o.ReloadInterval = defaultReloadInterval
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	var baseDir string
+	if o.BaseDir != "" {
+		d, err := filepath.Abs(o.BaseDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy bundle directory: %w", err)
+		}
+
+		baseDir = d
+	}
+
+	var prefixes []*url.URL
+	for _, p := range o.AllowedURLPrefixes {
+		u, err := url.Parse(p)
+		if err != nil || !isURL(p) || u.Host == "" {
+			return nil, fmt.Errorf("invalid policy bundle URL prefix: %q", p)
+		}
+
+		prefixes = append(prefixes, u)
+	}
+
+	r := &Registry{
+		client: &http.Client{
+			Timeout: o.Timeout,
+
+			// a redirect could lead outside of the allowed URLs
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		baseDir:     baseDir,
+		urlPrefixes: prefixes,
+		bundles:     make(map[string]*bundleEntry),
+		quit:        make(chan struct{}),
+	}
+
+	go r.run(o.ReloadInterval)
+	return r, nil
+}
+
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+func (r *Registry) checkURL(source string) error {
+	u, err := url.Parse(source)
+	if err != nil {
+		return err
+	}
+
+	if u.User != nil {
+		return fmt.Errorf("%w: %s", ErrSourceNotAllowed, source)
+	}
+
+	p := path.Clean("/" + u.Path)
+	for _, prefix := range r.urlPrefixes {
+		if u.Scheme != prefix.Scheme || u.Host != prefix.Host {
+			continue
+		}
+
+		pp := strings.TrimSuffix(prefix.Path, "/")
+		if p == pp || strings.HasPrefix(p, pp+"/") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrSourceNotAllowed, source)
+}
+
+// file returns the path of a file source inside the base directory
+func (r *Registry) file(source string) (string, error) {
+	if r.baseDir == "" {
+		return "", fmt.Errorf("%w: %s", ErrSourceNotAllowed, source)
+	}
+
+	f := strings.TrimPrefix(source, "file://")
+	if !filepath.IsAbs(f) {
+		f = filepath.Join(r.baseDir, f)
+	}
+
+	f = filepath.Clean(f)
+	rel, err := filepath.Rel(r.baseDir, f)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrSourceNotAllowed, source)
+	}
+
+	return f, nil
+}
+
+func (r *Registry) fetch(source, etag string) (*bundleEntry, error) {
+	req, err := http.NewRequest("GET", source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	rsp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode == http.StatusNotModified && etag != "" {
+		return nil, nil
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to load policy bundle from %s: %s", source, rsp.Status)
+	}
+
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := ParseBundle(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bundleEntry{bundle: bundle, etag: rsp.Header.Get("ETag")}, nil
+}
+
+// load returns nil without an error, when the bundle was not modified
+func (r *Registry) load(source, etag string) (*bundleEntry, error) {
+	if isURL(source) {
+		if err := r.checkURL(source); err != nil {
+			return nil, err
+		}
+
+		return r.fetch(source, etag)
+	}
+
+	f, err := r.file(source)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := ParseBundle(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bundleEntry{bundle: bundle}, nil
+}
+
+// Load loads the bundle from the source, when it is not loaded yet. The
+// source is either the path of a YAML file in the base directory,
+// optionally with the file:// prefix, or an http or https URL under one
+// of the allowed URL prefixes. Other sources are rejected with
+// ErrSourceNotAllowed.
+func (r *Registry) Load(source string) error {
+	r.mu.RLock()
+	_, ok := r.bundles[source]
+	r.mu.RUnlock()
+	if ok {
+		return nil
+	}
+
+	e, err := r.load(source, "")
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.bundles[source]; !ok {
+		r.bundles[source] = e
+	}
+
+	return nil
+}
+
+// Bundle returns the current version of a loaded bundle, or nil, when
+// it is not loaded.
+func (r *Registry) Bundle(source string) *Bundle {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if e, ok := r.bundles[source]; ok {
+		return e.bundle
+	}
+
+	return nil
+}
+
+// Retain drops the loaded bundles, whose source is not listed, e.g.
+// because no route uses them anymore. The dropped bundles are not
+// reloaded, and the next Load of their source loads them again.
+func (r *Registry) Retain(sources []string) {
+	keep := make(map[string]struct{}, len(sources))
+	for _, s := range sources {
+		keep[s] = struct{}{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for source := range r.bundles {
+		if _, ok := keep[source]; !ok {
+			delete(r.bundles, source)
+		}
+	}
+}
+
+func (r *Registry) reload() {
+	r.mu.RLock()
+	etags := make(map[string]string, len(r.bundles))
+	for source, e := range r.bundles {
+		etags[source] = e.etag
+	}
+	r.mu.RUnlock()
+
+	for source, etag := range etags {
+		e, err := r.load(source, etag)
+		if err != nil {
+			log.Errorf("Failed to reload the policy bundle %s, keeping the previous one: %v", source, err)
+			continue
+		}
+
+		if e == nil {
+			continue
+		}
+
+		// the bundle may have been dropped in the meantime
+		r.mu.Lock()
+		if _, ok := r.bundles[source]; ok {
+			r.bundles[source] = e
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *Registry) run(d time.Duration) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			r.reload()
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// Close stops reloading the bundles.
+func (r *Registry) Close() {
+	r.once.Do(func() { close(r.quit) })
+}
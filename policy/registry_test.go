@@ -0,0 +1,219 @@
+package policy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	allowAll = "default: allow\nrules: []\n"
+	denyAll  = "default: deny\nrules: []\n"
+)
+
+func waitForDecision(t *testing.T, r *Registry, source string, allow bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for r.Bundle(source).Evaluate(nil).Allow != allow {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newTestRegistry(t *testing.T, o Options) *Registry {
+	t.Helper()
+	r, err := NewRegistry(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestRegistryFile(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "bundle.yaml")
+	if err := os.WriteFile(f, []byte(allowAll), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newTestRegistry(t, Options{ReloadInterval: 5 * time.Millisecond, BaseDir: dir})
+	defer r.Close()
+
+	if r.Bundle(f) != nil {
+		t.Fatal("unexpected bundle before loading")
+	}
+
+	if err := r.Load(f); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Load("bundle.yaml"); err != nil {
+		t.Errorf("failed to load a relative path: %v", err)
+	}
+
+	waitForDecision(t, r, f, true)
+
+	if err := os.WriteFile(f, []byte(denyAll), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForDecision(t, r, f, false)
+
+	// keeps the previous bundle when the new one is invalid
+	if err := os.WriteFile(f, []byte("default: maybe"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if r.Bundle(f).Evaluate(nil).Allow {
+		t.Error("unexpected decision")
+	}
+
+	if err := r.Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestRegistryRetain(t *testing.T) {
+	dir := t.TempDir()
+	f1, f2 := filepath.Join(dir, "b1.yaml"), filepath.Join(dir, "b2.yaml")
+	for _, f := range []string{f1, f2} {
+		if err := os.WriteFile(f, []byte(allowAll), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := newTestRegistry(t, Options{BaseDir: dir})
+	defer r.Close()
+
+	for _, f := range []string{f1, f2} {
+		if err := r.Load(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r.Retain([]string{f1})
+	if r.Bundle(f1) == nil {
+		t.Error("retained bundle dropped")
+	}
+
+	if r.Bundle(f2) != nil {
+		t.Error("unused bundle not dropped")
+	}
+
+	if err := r.Load(f2); err != nil || r.Bundle(f2) == nil {
+		t.Errorf("failed to load the dropped bundle again: %v", err)
+	}
+}
+
+func TestRegistryURL(t *testing.T) {
+	var (
+		requests int32
+		bundle   atomic.Value
+	)
+
+	bundle.Store(allowAll)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		b := bundle.Load().(string)
+		etag := `"` + b[len("default: "):len("default: ")+4] + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(b))
+	}))
+	defer s.Close()
+
+	r := newTestRegistry(t, Options{ReloadInterval: 5 * time.Millisecond, AllowedURLPrefixes: []string{s.URL}})
+	defer r.Close()
+
+	if err := r.Load(s.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForDecision(t, r, s.URL, true)
+
+	bundle.Store(denyAll)
+	waitForDecision(t, r, s.URL, false)
+
+	if atomic.LoadInt32(&requests) < 2 {
+		t.Error("expected reloading the bundle")
+	}
+}
+
+func TestRegistrySourceNotAllowed(t *testing.T) {
+	dir := t.TempDir()
+	bundles := filepath.Join(dir, "bundles")
+	if err := os.Mkdir(bundles, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(dir, "outside.yaml")
+	if err := os.WriteFile(outside, []byte(allowAll), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(allowAll))
+	}))
+	defer s.Close()
+
+	r := newTestRegistry(t, Options{BaseDir: bundles, AllowedURLPrefixes: []string{s.URL + "/bundles/"}})
+	defer r.Close()
+
+	for _, source := range []string{
+		outside,
+		"file://" + outside,
+		"../outside.yaml",
+		"/etc/passwd",
+		s.URL + "/admin",
+		s.URL + "/bundles-other/api.yaml",
+		s.URL + "/bundles/../admin",
+		"http://169.254.169.254/latest/meta-data/",
+	} {
+		if err := r.Load(source); !errors.Is(err, ErrSourceNotAllowed) {
+			t.Errorf("expected source not allowed for %s, got: %v", source, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("unexpected requests: %d", n)
+	}
+
+	if err := r.Load(s.URL + "/bundles/api.yaml"); err != nil {
+		t.Errorf("failed to load an allowed URL: %v", err)
+	}
+
+	empty := newTestRegistry(t, Options{})
+	defer empty.Close()
+
+	if err := empty.Load(s.URL + "/bundles/api.yaml"); !errors.Is(err, ErrSourceNotAllowed) {
+		t.Errorf("expected source not allowed without URL prefixes, got: %v", err)
+	}
+
+	if err := empty.Load(outside); !errors.Is(err, ErrSourceNotAllowed) {
+		t.Errorf("expected source not allowed without base directory, got: %v", err)
+	}
+}
+
+func TestRegistryInvalidOptions(t *testing.T) {
+	for _, prefix := range []string{"policies.example.org", "ftp://policies.example.org", "https://"} {
+		if _, err := NewRegistry(Options{AllowedURLPrefixes: []string{prefix}}); err == nil {
+			t.Errorf("expected error for the URL prefix %q", prefix)
+		}
+	}
+}
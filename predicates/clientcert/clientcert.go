@@ -0,0 +1,131 @@
+/*
+Package clientcert implements predicates to match the routes based on
+the TLS client certificate of the request.
+
+The predicates match only the client certificates verified by the
+listener against the client CA bundle, and they check only the leaf
+certificate presented by the client. The predicates accept a regular
+expression as their single argument.
+
+ClientCertSubject matches the distinguished name of the subject of the
+certificate, in the RFC 2253 format, e.g. CN=client.example.org,O=Example:
+
+	ClientCertSubject(/^CN=[^,]+[.]example[.]org,/) -> "https://www.example.org";
+
+ClientCertSAN matches when any of the DNS names, email addresses, IP
+addresses or URIs of the subject alternative names matches:
+
+	ClientCertSAN(/^spiffe:[/][/]example[.]org[/]ns[/]payments[/]/) -> "https://www.example.org";
+
+ClientCertIssuer matches the distinguished name of the issuer of the
+certificate:
+
+	ClientCertIssuer(/^CN=Example Internal CA,/) -> "https://www.example.org";
+*/
+package clientcert
+
+import (
+	"crypto/x509"
+	"net/http"
+	"regexp"
+
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+type (
+	spec struct {
+		name   string
+		values func(*x509.Certificate) []string
+	}
+
+	predicate struct {
+		values func(*x509.Certificate) []string
+		exp    *regexp.Regexp
+	}
+)
+
+func subject(c *x509.Certificate) []string {
+	return []string{c.Subject.String()}
+}
+
+func issuer(c *x509.Certificate) []string {
+	return []string{c.Issuer.String()}
+}
+
+func subjectAltNames(c *x509.Certificate) []string {
+	var names []string
+	names = append(names, c.DNSNames...)
+	names = append(names, c.EmailAddresses...)
+	for _, ip := range c.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	for _, u := range c.URIs {
+		names = append(names, u.String())
+	}
+
+	return names
+}
+
+// NewSubject creates the specification of the ClientCertSubject
+// predicate.
+func NewSubject() routing.PredicateSpec {
+	return &spec{name: predicates.ClientCertSubjectName, values: subject}
+}
+
+// NewSAN creates the specification of the ClientCertSAN predicate.
+func NewSAN() routing.PredicateSpec {
+	return &spec{name: predicates.ClientCertSANName, values: subjectAltNames}
+}
+
+// NewIssuer creates the specification of the ClientCertIssuer
+// predicate.
+func NewIssuer() routing.PredicateSpec {
+	return &spec{name: predicates.ClientCertIssuerName, values: issuer}
+}
+
+func (s *spec) Name() string { return s.name }
+
+func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) != 1 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	value, ok := args[0].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	exp, err := regexp.Compile(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &predicate{values: s.values, exp: exp}, nil
+}
+
+// VerifiedCertificate returns the verified leaf client certificate of
+// the request, or nil.
+func VerifiedCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return r.TLS.VerifiedChains[0][0]
+}
+
+func (p *predicate) Match(r *http.Request) bool {
+	c := VerifiedCertificate(r)
+	if c == nil {
+		return false
+	}
+
+	for _, v := range p.values(c) {
+		if p.exp.MatchString(v) {
+			return true
+		}
+	}
+
+	return false
+}
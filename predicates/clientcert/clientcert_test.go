@@ -0,0 +1,107 @@
+package clientcert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func testCertificate() *x509.Certificate {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/payments/sa/api")
+	return &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "client.example.org", Organization: []string{"Example"}},
+		Issuer:         pkix.Name{CommonName: "Example Internal CA"},
+		DNSNames:       []string{"client.example.org"},
+		EmailAddresses: []string{"team@example.org"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:           []*url.URL{spiffe},
+	}
+}
+
+func TestClientCert(t *testing.T) {
+	verified := &http.Request{TLS: &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{testCertificate()},
+		VerifiedChains:   [][]*x509.Certificate{{testCertificate()}},
+	}}
+
+	notVerified := &http.Request{TLS: &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{testCertificate()},
+	}}
+
+	for _, ti := range []struct {
+		msg      string
+		arg      string
+		request  *http.Request
+		expected bool
+	}{{
+		msg:      "subject",
+		arg:      "^CN=client[.]example[.]org,O=Example$",
+		request:  verified,
+		expected: true,
+	}, {
+		msg:      "subject not verified",
+		arg:      "^CN=client[.]example[.]org,",
+		request:  notVerified,
+		expected: false,
+	}, {
+		msg:      "plain http",
+		arg:      ".*",
+		request:  &http.Request{},
+		expected: false,
+	}, {
+		msg:      "subject mismatch",
+		arg:      "^CN=other[.]example[.]org,",
+		request:  verified,
+		expected: false,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			p, err := NewSubject().Create([]interface{}{ti.arg})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if m := p.Match(ti.request); m != ti.expected {
+				t.Errorf("unexpected match result, expected: %v, got: %v", ti.expected, m)
+			}
+		})
+	}
+
+	for _, arg := range []string{
+		"^client[.]example[.]org$",
+		"^team@example[.]org$",
+		"^10[.]0[.]0[.]1$",
+		"^spiffe://example[.]org/ns/payments/",
+	} {
+		p, _ := NewSAN().Create([]interface{}{arg})
+		if !p.Match(verified) {
+			t.Errorf("expected SAN to match %s", arg)
+		}
+	}
+
+	p, _ := NewSAN().Create([]interface{}{"^other[.]example[.]org$"})
+	if p.Match(verified) {
+		t.Error("unexpected SAN match")
+	}
+
+	p, _ = NewIssuer().Create([]interface{}{"^CN=Example Internal CA$"})
+	if !p.Match(verified) {
+		t.Error("expected issuer to match")
+	}
+}
+
+func TestClientCertArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{"foo", "bar"},
+		{42},
+		{"["},
+	} {
+		if _, err := NewSubject().Create(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
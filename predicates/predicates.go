@@ -41,6 +41,9 @@ const (
 	SourceName                = "Source"
 	SourceFromLastName        = "SourceFromLast"
 	ClientIPName              = "ClientIP"
+	ClientCertSubjectName     = "ClientCertSubject"
+	ClientCertSANName         = "ClientCertSAN"
+	ClientCertIssuerName      = "ClientCertIssuer"
 	TeeName                   = "Tee"
 	TrafficName               = "Traffic"
 )
@@ -618,3 +618,26 @@ func TestBreakerMultipleHostsAndRouteBasedSettings(t *testing.T) {
 		},
 	})
 }
+
+func TestBreakerFailureStatusCodes(t *testing.T) {
+	testBreaker(t, breakerScenario{
+		settings: []circuit.BreakerSettings{{
+			Type:     circuit.ConsecutiveFailures,
+			Failures: testConsecutiveFailureCount,
+		}},
+		filters: map[string][]*eskip.Filter{
+			defaultHost: {{
+				Name: filters.BreakerFailureStatusCodesName,
+				Args: []interface{}{http.StatusServiceUnavailable},
+			}},
+		},
+		steps: []scenarioStep{
+			setBackendFail,
+			times(testConsecutiveFailureCount+1, request(500)),
+			checkBackendCounter(testConsecutiveFailureCount + 1),
+			setBackendDown,
+			times(testConsecutiveFailureCount, request(http.StatusBadGateway)),
+			requestOpen,
+		},
+	})
+}
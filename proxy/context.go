@@ -47,6 +47,9 @@ type context struct {
 	proxy                *Proxy
 	routeLookup          *routing.RouteLookup
 	cancelBackendContext stdlibcontext.CancelFunc
+	grpcMethod           string
+	retryAttempt         int
+	excludedEndpoints    map[string]bool
 }
 
 type filterMetrics struct {
@@ -147,6 +150,10 @@ func newContext(
 		c.originalRequest = cloneRequestMetadata(r)
 	}
 
+	if isGRPCRequest(r) {
+		c.grpcMethod = r.URL.Path
+	}
+
 	return c
 }
 
@@ -262,7 +269,7 @@ func (c *context) Split() (filters.FilterContext, error) {
 	u := new(url.URL)
 	*u = *originalRequest.URL
 	u.Host = originalRequest.Host
-	cr, body, err := cloneRequestForSplit(u, originalRequest)
+	cr, body, err := cloneRequestForSplit(u, originalRequest, requestBodyBuffer(c))
 	if err != nil {
 		c.proxy.log.Errorf("context: failed to clone request: %v", err)
 		return nil, err
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gRPC status codes, as defined in
+// https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	grpcStatusOK                = 0
+	grpcStatusCanceled          = 1
+	grpcStatusUnknown           = 2
+	grpcStatusDeadlineExceeded  = 4
+	grpcStatusPermissionDenied  = 7
+	grpcStatusResourceExhausted = 8
+	grpcStatusUnimplemented     = 12
+	grpcStatusInternal          = 13
+	grpcStatusUnavailable       = 14
+	grpcStatusUnauthenticated   = 16
+)
+
+const (
+	grpcStatusHeader  = "Grpc-Status"
+	grpcMessageHeader = "Grpc-Message"
+)
+
+var grpcStatusNames = map[int]string{
+	grpcStatusOK:                "OK",
+	grpcStatusCanceled:          "CANCELLED",
+	grpcStatusUnknown:           "UNKNOWN",
+	3:                           "INVALID_ARGUMENT",
+	grpcStatusDeadlineExceeded:  "DEADLINE_EXCEEDED",
+	5:                           "NOT_FOUND",
+	6:                           "ALREADY_EXISTS",
+	grpcStatusPermissionDenied:  "PERMISSION_DENIED",
+	grpcStatusResourceExhausted: "RESOURCE_EXHAUSTED",
+	9:                           "FAILED_PRECONDITION",
+	10:                          "ABORTED",
+	11:                          "OUT_OF_RANGE",
+	grpcStatusUnimplemented:     "UNIMPLEMENTED",
+	grpcStatusInternal:          "INTERNAL",
+	grpcStatusUnavailable:       "UNAVAILABLE",
+	15:                          "DATA_LOSS",
+	grpcStatusUnauthenticated:   "UNAUTHENTICATED",
+}
+
+// isGRPCRequest returns true when the request is a gRPC request over
+// HTTP/2. gRPC-Web requests are not considered, because they can be
+// proxied as plain HTTP/1.1.
+func isGRPCRequest(r *http.Request) bool {
+	if r.ProtoMajor != 2 {
+		return false
+	}
+
+	ct := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "application/grpc") {
+		return false
+	}
+
+	return len(ct) == len("application/grpc") || ct[len("application/grpc")] == '+' || ct[len("application/grpc")] == ';'
+}
+
+// grpcStatusFromHTTP maps the HTTP status codes of the proxy errors to
+// gRPC status codes, following
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+//
+// Additionally, 429 caused by ratelimits is mapped to RESOURCE_EXHAUSTED,
+// 499 is mapped to CANCELLED and 504 is mapped to DEADLINE_EXCEEDED.
+func grpcStatusFromHTTP(code int) int {
+	switch code {
+	case http.StatusOK:
+		return grpcStatusOK
+	case http.StatusBadRequest:
+		return grpcStatusInternal
+	case http.StatusUnauthorized:
+		return grpcStatusUnauthenticated
+	case http.StatusForbidden:
+		return grpcStatusPermissionDenied
+	case http.StatusNotFound:
+		return grpcStatusUnimplemented
+	case http.StatusTooManyRequests:
+		return grpcStatusResourceExhausted
+	case 499:
+		return grpcStatusCanceled
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return grpcStatusUnavailable
+	case http.StatusGatewayTimeout:
+		return grpcStatusDeadlineExceeded
+	default:
+		return grpcStatusUnknown
+	}
+}
+
+func grpcStatusName(status int) string {
+	if n, ok := grpcStatusNames[status]; ok {
+		return n
+	}
+
+	return strconv.Itoa(status)
+}
+
+// encodeGRPCMessage percent-encodes the grpc-message value as required by
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// setGRPCStatus sets the gRPC status headers to be sent as a
+// Trailers-Only response, mapped from the HTTP status code.
+func setGRPCStatus(h http.Header, code int) {
+	h.Set("Content-Type", "application/grpc")
+	h.Set(grpcStatusHeader, strconv.Itoa(grpcStatusFromHTTP(code)))
+	h.Set(grpcMessageHeader, encodeGRPCMessage(http.StatusText(code)))
+	h.Del("Content-Length")
+}
+
+// grpcStatusFromResponse returns the gRPC status sent by the backend,
+// either in the trailers or, in case of Trailers-Only responses, in the
+// headers.
+func grpcStatusFromResponse(rsp *http.Response) int {
+	s := rsp.Trailer.Get(grpcStatusHeader)
+	if s == "" {
+		s = rsp.Header.Get(grpcStatusHeader)
+	}
+
+	if s == "" {
+		return grpcStatusFromHTTP(rsp.StatusCode)
+	}
+
+	status, err := strconv.Atoi(s)
+	if err != nil {
+		return grpcStatusUnknown
+	}
+
+	return status
+}
+
+// convertGRPCErrorResponse converts a non-gRPC error response, e.g. served
+// by a shunting filter, to a Trailers-Only gRPC response, so that the gRPC
+// clients can interpret it.
+func convertGRPCErrorResponse(rsp *http.Response) {
+	if rsp.StatusCode == http.StatusOK || rsp.Header.Get(grpcStatusHeader) != "" {
+		return
+	}
+
+	if rsp.Body != nil {
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+	}
+
+	setGRPCStatus(rsp.Header, rsp.StatusCode)
+	rsp.StatusCode = http.StatusOK
+	rsp.ContentLength = 0
+	rsp.Body = defaultBody()
+}
+
+// send a premature error response to a gRPC client
+func (p *Proxy) sendGRPCError(c *context, id string, code int) {
+	addBranding(c.responseWriter.Header())
+	setGRPCStatus(c.responseWriter.Header(), code)
+	c.responseWriter.WriteHeader(http.StatusOK)
+
+	p.measureGRPC(id, c.grpcMethod, grpcStatusFromHTTP(code), c.startServe)
+	p.metrics.MeasureServe(
+		id,
+		c.metricsHost(),
+		c.request.Method,
+		http.StatusOK,
+		c.startServe,
+	)
+}
+
+// grpcMetricsMethod returns the method name used in the metrics keys, in
+// the form of package.Service/Method. The method names are set by the
+// clients, so they are used only when a route was matched, and when they
+// have the form of a gRPC method path. Otherwise, "unknown" is used, to
+// avoid creating an unbounded number of metrics.
+func grpcMetricsMethod(routeID, path string) string {
+	if routeID == unknownRouteID {
+		return "unknown"
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "unknown"
+	}
+
+	return parts[0] + "/" + parts[1]
+}
+
+// measureGRPC records the gRPC metrics, keyed by the route id and the
+// method, as grpc.<route>.<method>.<status> counters and
+// grpc.<route>.<method> timers.
+func (p *Proxy) measureGRPC(routeID, path string, status int, start time.Time) {
+	key := fmt.Sprintf("grpc.%s.%s", routeID, grpcMetricsMethod(routeID, path))
+	p.metrics.IncCounter(fmt.Sprintf("%s.%s", key, grpcStatusName(status)))
+	p.metrics.MeasureSince(key, start)
+}
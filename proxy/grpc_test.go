@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics/metricstest"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func newGRPCTestClient() *http.Client {
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+}
+
+func doGRPCRequest(t *testing.T, u string) *http.Response {
+	req, err := http.NewRequest("POST", u+"/helloworld.Greeter/SayHello", strings.NewReader("\x00\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+
+	rsp, err := newGRPCTestClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// trailers are available only after reading the body
+	io.Copy(io.Discard, rsp.Body)
+	rsp.Body.Close()
+	return rsp
+}
+
+func TestIsGRPCRequest(t *testing.T) {
+	for _, tt := range []struct {
+		contentType string
+		protoMajor  int
+		expected    bool
+	}{
+		{"application/grpc", 2, true},
+		{"application/grpc+proto", 2, true},
+		{"application/grpc;charset=utf-8", 2, true},
+		{"application/grpc", 1, false},
+		{"application/grpc-web", 2, false},
+		{"application/json", 2, false},
+	} {
+		r := &http.Request{ProtoMajor: tt.protoMajor, Header: http.Header{"Content-Type": []string{tt.contentType}}}
+		if isGRPCRequest(r) != tt.expected {
+			t.Errorf("%s over HTTP/%d: expected %v", tt.contentType, tt.protoMajor, tt.expected)
+		}
+	}
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	if m := encodeGRPCMessage("Service Unavailable 100%\n"); m != "Service Unavailable 100%25%0A" {
+		t.Errorf("unexpected encoded message: %s", m)
+	}
+}
+
+func TestGRPCMetricsMethod(t *testing.T) {
+	for _, tt := range []struct {
+		routeID  string
+		path     string
+		expected string
+	}{
+		{"route1", "/helloworld.Greeter/SayHello", "helloworld.Greeter/SayHello"},
+		{"route1", "/helloworld.Greeter", "unknown"},
+		{"route1", "/helloworld.Greeter/SayHello/foo", "unknown"},
+		{"route1", "//SayHello", "unknown"},
+		{unknownRouteID, "/helloworld.Greeter/SayHello", "unknown"},
+	} {
+		if m := grpcMetricsMethod(tt.routeID, tt.path); m != tt.expected {
+			t.Errorf("%s %s: expected %s, got: %s", tt.routeID, tt.path, tt.expected, m)
+		}
+	}
+}
+
+func TestGRPCErrorResponse(t *testing.T) {
+	wait := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-wait
+	}))
+	defer func() {
+		close(wait)
+		slow.Close()
+	}()
+
+	for _, tt := range []struct {
+		name            string
+		route           string
+		expectedStatus  string
+		expectedMessage string
+	}{{
+		name:            "route not found",
+		route:           `Path("/foo") -> <shunt>`,
+		expectedStatus:  "12",
+		expectedMessage: "Not Found",
+	}, {
+		name:            "backend timeout",
+		route:           fmt.Sprintf(`* -> backendTimeout("1ms") -> "%s"`, slow.URL),
+		expectedStatus:  "4",
+		expectedMessage: "Gateway Timeout",
+	}, {
+		name:            "shunted by filter",
+		route:           `* -> status(429) -> <shunt>`,
+		expectedStatus:  "8",
+		expectedMessage: "Too Many Requests",
+	}, {
+		name:            "backend unavailable",
+		route:           `* -> "http://127.0.0.1:1"`,
+		expectedStatus:  "14",
+		expectedMessage: "Bad Gateway",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, err := newTestProxy(tt.route, FlagsNone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tp.close()
+
+			ps := httptest.NewServer(h2c.NewHandler(tp.proxy, &http2.Server{}))
+			defer ps.Close()
+
+			rsp := doGRPCRequest(t, ps.URL)
+
+			if rsp.StatusCode != http.StatusOK {
+				t.Errorf("expected status 200, got: %d", rsp.StatusCode)
+			}
+
+			if ct := rsp.Header.Get("Content-Type"); ct != "application/grpc" {
+				t.Errorf("expected gRPC content type, got: %s", ct)
+			}
+
+			if s := rsp.Header.Get("Grpc-Status"); s != tt.expectedStatus {
+				t.Errorf("expected grpc-status %s, got: %s", tt.expectedStatus, s)
+			}
+
+			if m := rsp.Header.Get("Grpc-Message"); m != tt.expectedMessage {
+				t.Errorf("expected grpc-message %s, got: %s", tt.expectedMessage, m)
+			}
+		})
+	}
+}
+
+func TestGRPCBackend(t *testing.T) {
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2 backend request, got: %s", r.Proto)
+		}
+
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\x00\x00\x00\x00\x00"))
+		w.Header().Set("Grpc-Status", "5")
+	}), &http2.Server{}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`grpc: * -> backendHTTP2() -> "%s"`, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	m := &metricstest.MockMetrics{Now: time.Now()}
+	tp.proxy.metrics = m
+
+	ps := httptest.NewServer(h2c.NewHandler(tp.proxy, &http2.Server{}))
+	defer ps.Close()
+
+	rsp := doGRPCRequest(t, ps.URL)
+
+	if s := rsp.Trailer.Get("Grpc-Status"); s != "5" {
+		t.Errorf("expected grpc-status trailer 5, got: %v", rsp.Trailer)
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		if c := counters["grpc.grpc.helloworld.Greeter/SayHello.NOT_FOUND"]; c != 1 {
+			t.Errorf("expected gRPC route counter, got: %v", counters)
+		}
+	})
+
+	m.WithMeasures(func(measures map[string][]time.Duration) {
+		if _, ok := measures["grpc.grpc.helloworld.Greeter/SayHello"]; !ok {
+			t.Errorf("expected gRPC route latency, got: %v", measures)
+		}
+	})
+}
+
+func TestGRPCBackendWithoutHTTP2(t *testing.T) {
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend-Proto", r.Proto)
+	}), &http2.Server{}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> "%s"`, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	ps := httptest.NewServer(h2c.NewHandler(tp.proxy, &http2.Server{}))
+	defer ps.Close()
+
+	rsp := doGRPCRequest(t, ps.URL)
+	if p := rsp.Header.Get("X-Backend-Proto"); p != "HTTP/1.1" {
+		t.Errorf("expected HTTP/1.1 backend request without opt-in, got: %s", p)
+	}
+}
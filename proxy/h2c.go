@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// h2cConnPool is the connection pool of the h2c transport. Unlike the
+// default pool of the http2 transport, it dials the new connections with
+// the context of the request, so that the cancellation and the timeouts
+// of the request apply to the connection setup, too.
+type h2cConnPool struct {
+	transport *http2.Transport
+	dialer    *skipperDialer
+
+	mu    sync.Mutex
+	conns map[string][]*http2.ClientConn
+}
+
+// h2cTransport is the cleartext HTTP/2 transport with prior knowledge,
+// using the h2cConnPool.
+type h2cTransport struct {
+	*http2.Transport
+	pool *h2cConnPool
+}
+
+func newH2CTransport(dialer *skipperDialer) *h2cTransport {
+	t := &http2.Transport{AllowHTTP: true}
+	p := &h2cConnPool{
+		transport: t,
+		dialer:    dialer,
+		conns:     make(map[string][]*http2.ClientConn),
+	}
+
+	t.ConnPool = p
+	return &h2cTransport{Transport: t, pool: p}
+}
+
+func (p *h2cConnPool) GetClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	p.mu.Lock()
+	for _, cc := range p.conns[addr] {
+		if cc.ReserveNewRequest() {
+			p.mu.Unlock()
+			return cc, nil
+		}
+	}
+	p.mu.Unlock()
+
+	conn, err := p.dialer.DialContext(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := p.transport.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// a new connection may still reject the request, when the
+	// server advertised zero concurrent streams
+	if !cc.ReserveNewRequest() {
+		cc.Close()
+		return nil, http2.ErrNoCachedConn
+	}
+
+	p.mu.Lock()
+	p.conns[addr] = append(p.conns[addr], cc)
+	p.mu.Unlock()
+	return cc, nil
+}
+
+func (p *h2cConnPool) MarkDead(cc *http2.ClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, conns := range p.conns {
+		for i, ci := range conns {
+			if ci != cc {
+				continue
+			}
+
+			conns = append(conns[:i], conns[i+1:]...)
+			if len(conns) == 0 {
+				delete(p.conns, addr)
+			} else {
+				p.conns[addr] = conns
+			}
+
+			return
+		}
+	}
+}
+
+func (p *h2cConnPool) closeIdleConnections() {
+	var idle []*http2.ClientConn
+	p.mu.Lock()
+	for _, conns := range p.conns {
+		for _, cc := range conns {
+			s := cc.State()
+			if s.StreamsActive == 0 && s.StreamsReserved == 0 && s.StreamsPending == 0 {
+				idle = append(idle, cc)
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	// shutting down is graceful, in case a request takes the
+	// connection meanwhile
+	for _, cc := range idle {
+		go cc.Shutdown(stdlibcontext.Background())
+	}
+}
+
+// CloseIdleConnections closes the connections of the pool that don't
+// serve any requests.
+func (t *h2cTransport) CloseIdleConnections() {
+	t.pool.closeIdleConnections()
+}
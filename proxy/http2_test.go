@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func newHTTP2TestBackend(t *testing.T) *httptest.Server {
+	return httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+		}
+
+		w.Header().Set("X-Backend-Proto", r.Proto)
+		w.Header().Set("X-Backend-Te", r.Header.Get("Te"))
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+		w.(http.Flusher).Flush()
+
+		// not announced trailer, like the grpc-status sent by gRPC servers
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	}), &http2.Server{}))
+}
+
+func TestHTTP2Backend(t *testing.T) {
+	backend := newHTTP2TestBackend(t)
+	defer backend.Close()
+
+	host := strings.TrimPrefix(backend.URL, "http://")
+	for _, tt := range []struct {
+		name          string
+		route         string
+		expectedProto string
+	}{{
+		name:          "http/1.1 by default",
+		route:         fmt.Sprintf(`* -> "http://%s"`, host),
+		expectedProto: "HTTP/1.1",
+	}, {
+		name:          "h2c scheme",
+		route:         fmt.Sprintf(`* -> "h2c://%s"`, host),
+		expectedProto: "HTTP/2.0",
+	}, {
+		name:          "h2c scheme in load balanced backend",
+		route:         fmt.Sprintf(`* -> <roundRobin, "h2c://%s">`, host),
+		expectedProto: "HTTP/2.0",
+	}, {
+		name:          "http2 backend filter",
+		route:         fmt.Sprintf(`* -> backendHTTP2() -> "http://%s"`, host),
+		expectedProto: "HTTP/2.0",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, err := newTestProxy(tt.route, HopHeadersRemoval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			req, err := http.NewRequest("POST", ps.URL, strings.NewReader("Hello, world!"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Te", "trailers")
+
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rsp.Body.Close()
+
+			b, err := io.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rsp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status 200, got: %d", rsp.StatusCode)
+			}
+
+			if string(b) != "Hello, world!" {
+				t.Errorf("unexpected body: %s", b)
+			}
+
+			if p := rsp.Header.Get("X-Backend-Proto"); p != tt.expectedProto {
+				t.Errorf("expected backend protocol %s, got: %s", tt.expectedProto, p)
+			}
+
+			if te := rsp.Header.Get("X-Backend-Te"); te != "trailers" {
+				t.Errorf("expected te header to be forwarded, got: %s", te)
+			}
+
+			if tt.expectedProto == "HTTP/2.0" {
+				if s := rsp.Trailer.Get("Grpc-Status"); s != "0" {
+					t.Errorf("expected trailer to be forwarded, got: %v", rsp.Trailer)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTP2BackendTLS(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend-Proto", r.Proto)
+	}))
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	defer backend.Close()
+
+	for _, tt := range []struct {
+		route         string
+		expectedProto string
+	}{{
+		route:         fmt.Sprintf(`* -> "%s"`, backend.URL),
+		expectedProto: "HTTP/1.1",
+	}, {
+		route:         fmt.Sprintf(`* -> backendHTTP2() -> "%s"`, backend.URL),
+		expectedProto: "HTTP/2.0",
+	}} {
+		t.Run(tt.expectedProto, func(t *testing.T) {
+			tp, err := newTestProxy(tt.route, Insecure)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			rsp, err := http.Get(ps.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rsp.Body.Close()
+
+			if p := rsp.Header.Get("X-Backend-Proto"); p != tt.expectedProto {
+				t.Errorf("expected backend protocol %s, got: %s", tt.expectedProto, p)
+			}
+		})
+	}
+}
+
+type h2cTestContextKey struct{}
+
+func TestH2CTransportDialsWithRequestContext(t *testing.T) {
+	backend := newHTTP2TestBackend(t)
+	defer backend.Close()
+
+	var dials []interface{}
+	dialer := newSkipperDialer(net.Dialer{})
+	dialer.f = func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+		dials = append(dials, ctx.Value(h2cTestContextKey{}))
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+
+	tr := newH2CTransport(dialer)
+	defer tr.CloseIdleConnections()
+
+	for i := 0; i < 2; i++ {
+		ctx := stdlibcontext.WithValue(stdlibcontext.Background(), h2cTestContextKey{}, i)
+		req, err := http.NewRequestWithContext(ctx, "GET", backend.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+		if rsp.Proto != "HTTP/2.0" {
+			t.Errorf("expected HTTP/2.0, got: %s", rsp.Proto)
+		}
+	}
+
+	if len(dials) != 1 || dials[0] != 0 {
+		t.Errorf("expected a single dial with the context of the first request, got: %v", dials)
+	}
+
+	ctx, cancel := stdlibcontext.WithCancel(stdlibcontext.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", backend.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr.CloseIdleConnections()
+	if _, err := tr.RoundTrip(req); err == nil {
+		t.Error("expected error for canceled request")
+	}
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/loadbalancer"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestOutlierDetection(t *testing.T) {
+	var failingCount, healthyCount int32
+	failing := newCountingBackend(http.StatusServiceUnavailable, &failingCount)
+	defer failing.Close()
+
+	healthy := newCountingBackend(http.StatusOK, &healthyCount)
+	defer healthy.Close()
+
+	dc, err := testdataclient.NewDoc(fmt.Sprintf(`* -> <roundRobin, "%s", "%s">`, failing.URL, healthy.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outliers := loadbalancer.NewOutlierDetector(loadbalancer.OutlierDetectionOptions{
+		ConsecutiveFailures: 2,
+		BaseEjectionTime:    time.Minute,
+	})
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PostProcessors: []routing.PostProcessor{loadbalancer.NewAlgorithmProvider(), outliers},
+		Log:            tl,
+	})
+	defer rt.Close()
+
+	p := WithParams(Params{Routing: rt, OutlierDetector: outliers})
+	defer p.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := httptest.NewServer(p)
+	defer ps.Close()
+
+	for i := 0; i < 20; i++ {
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+	}
+
+	if n := atomic.LoadInt32(&failingCount); n != 2 {
+		t.Errorf("expected the failing endpoint to receive 2 requests before ejection, got: %d", n)
+	}
+
+	if n := atomic.LoadInt32(&healthyCount); n != 18 {
+		t.Errorf("expected the healthy endpoint to receive the remaining requests, got: %d", n)
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	ot "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	log "github.com/sirupsen/logrus"
 	"github.com/zalando/skipper/circuit"
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
@@ -28,6 +29,7 @@ import (
 	circuitfilters "github.com/zalando/skipper/filters/circuit"
 	flowidFilter "github.com/zalando/skipper/filters/flowid"
 	ratelimitfilters "github.com/zalando/skipper/filters/ratelimit"
+	retryfilters "github.com/zalando/skipper/filters/retry"
 	tracingfilter "github.com/zalando/skipper/filters/tracing"
 	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/logging"
@@ -38,6 +40,7 @@ import (
 	"github.com/zalando/skipper/routing"
 	"github.com/zalando/skipper/scheduler"
 	"github.com/zalando/skipper/tracing"
+	"golang.org/x/net/http2"
 )
 
 const (
@@ -202,6 +205,11 @@ type Params struct {
 	// LoadBalancer to report unhealthy or dead backends to
 	LoadBalancer *loadbalancer.LB
 
+	// OutlierDetector to report the results of the requests made to
+	// the endpoints of load balanced routes. When set, it needs to be
+	// used as a routing post-processor, too.
+	OutlierDetector *loadbalancer.OutlierDetector
+
 	// Defines the time period of how often the idle connections are
 	// forcibly closed. The default is 12 seconds. When set to less than
 	// 0, the proxy doesn't force closing the idle connections.
@@ -319,6 +327,8 @@ type Proxy struct {
 	defaultHTTPStatus        int
 	routing                  *routing.Routing
 	roundTripper             http.RoundTripper
+	http2RoundTripper        http.RoundTripper
+	h2cRoundTripper          http.RoundTripper
 	priorityRoutes           []PriorityRoute
 	flags                    Flags
 	metrics                  metrics.Metrics
@@ -329,6 +339,8 @@ type Proxy struct {
 	log                      logging.Logger
 	tracing                  *proxyTracing
 	lb                       *loadbalancer.LB
+	outliers                 *loadbalancer.OutlierDetector
+	retryBudgets             *retryfilters.Budgets
 	upgradeAuditLogOut       io.Writer
 	upgradeAuditLogErr       io.Writer
 	auditLogHook             chan struct{}
@@ -478,7 +490,12 @@ func mapRequest(ctx *context, requestContext stdlibcontext.Context, removeHopHea
 		setRequestURLFromRequest(u, r)
 		setRequestURLForDynamicBackend(u, stateBag)
 	case eskip.LBBackend:
-		endpoint = setRequestURLForLoadBalancedBackend(u, rt, &routing.LBContext{Request: r, Route: rt, Params: stateBag})
+		endpoint = setRequestURLForLoadBalancedBackend(u, rt, &routing.LBContext{
+			Request:     r,
+			Route:       rt,
+			LBEndpoints: retryEndpoints(rt, ctx.excludedEndpoints),
+			Params:      stateBag,
+		})
 	default:
 		u.Scheme = rt.Scheme
 		u.Host = rt.Host
@@ -497,9 +514,19 @@ func mapRequest(ctx *context, requestContext stdlibcontext.Context, removeHopHea
 	rr.ContentLength = r.ContentLength
 	if removeHopHeaders {
 		rr.Header = cloneHeaderExcluding(r.Header, hopHeaders)
+
+		// gRPC and other HTTP/2 protocols require the "Te: trailers"
+		// header to be passed to the backend
+		if acceptsTrailers(r.Header) {
+			rr.Header.Set("Te", "trailers")
+		}
 	} else {
 		rr.Header = cloneHeader(r.Header)
 	}
+
+	// request trailers are populated after the request body was read,
+	// sharing the map makes them available for the outgoing request
+	rr.Trailer = r.Trailer
 	// Disable default net/http user agent when user agent is not specified
 	if _, ok := rr.Header["User-Agent"]; !ok {
 		rr.Header["User-Agent"] = []string{""}
@@ -525,6 +552,18 @@ func mapRequest(ctx *context, requestContext stdlibcontext.Context, removeHopHea
 	return rr, endpoint, nil
 }
 
+func acceptsTrailers(h http.Header) bool {
+	for _, v := range h["Te"] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), "trailers") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 type proxyUrlContextKey struct{}
 
 func forwardToProxy(incoming, outgoing *http.Request) *http.Request {
@@ -624,12 +663,14 @@ func WithParams(p Params) *Proxy {
 		}
 	}
 
+	dialer := newSkipperDialer(net.Dialer{
+		Timeout:   p.Timeout,
+		KeepAlive: p.KeepAlive,
+		DualStack: p.DualStack,
+	})
+
 	tr := &http.Transport{
-		DialContext: newSkipperDialer(net.Dialer{
-			Timeout:   p.Timeout,
-			KeepAlive: p.KeepAlive,
-			DualStack: p.DualStack,
-		}).DialContext,
+		DialContext:           dialer.DialContext,
 		TLSHandshakeTimeout:   p.TLSHandshakeTimeout,
 		ResponseHeaderTimeout: p.ResponseHeaderTimeout,
 		ExpectContinueTimeout: p.ExpectContinueTimeout,
@@ -640,6 +681,22 @@ func WithParams(p Params) *Proxy {
 		Proxy:                 proxyFromContext,
 	}
 
+	if p.ClientTLS != nil {
+		tr.TLSClientConfig = p.ClientTLS
+	}
+
+	if p.Flags.Insecure() {
+		if tr.TLSClientConfig == nil {
+			/* #nosec */
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		} else {
+			/* #nosec */
+			tr.TLSClientConfig.InsecureSkipVerify = true
+		}
+	}
+
+	tr2, h2c := newHTTP2Transports(tr, dialer)
+
 	quit := make(chan struct{})
 	// We need this to reliably fade on DNS change, which is right
 	// now not fixed with IdleConnTimeout in the http.Transport.
@@ -650,6 +707,8 @@ func WithParams(p Params) *Proxy {
 				select {
 				case <-time.After(p.CloseIdleConnsPeriod):
 					tr.CloseIdleConnections()
+					tr2.CloseIdleConnections()
+					h2c.CloseIdleConnections()
 				case <-quit:
 					return
 				}
@@ -657,20 +716,6 @@ func WithParams(p Params) *Proxy {
 		}()
 	}
 
-	if p.ClientTLS != nil {
-		tr.TLSClientConfig = p.ClientTLS
-	}
-
-	if p.Flags.Insecure() {
-		if tr.TLSClientConfig == nil {
-			/* #nosec */
-			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		} else {
-			/* #nosec */
-			tr.TLSClientConfig.InsecureSkipVerify = true
-		}
-	}
-
 	m := metrics.Default
 	if p.Flags.Debug() {
 		m = metrics.Void
@@ -693,6 +738,8 @@ func WithParams(p Params) *Proxy {
 	return &Proxy{
 		routing:                  p.Routing,
 		roundTripper:             p.CustomHttpRoundTripperWrap(tr),
+		http2RoundTripper:        p.CustomHttpRoundTripperWrap(tr2),
+		h2cRoundTripper:          p.CustomHttpRoundTripperWrap(h2c),
 		priorityRoutes:           p.PriorityRoutes,
 		flags:                    p.Flags,
 		metrics:                  m,
@@ -703,6 +750,8 @@ func WithParams(p Params) *Proxy {
 		maxLoops:                 p.MaxLoopbacks,
 		breakers:                 p.CircuitBreakers,
 		lb:                       p.LoadBalancer,
+		outliers:                 p.OutlierDetector,
+		retryBudgets:             retryfilters.NewBudgets(),
 		limiters:                 p.RateLimiters,
 		log:                      &logging.DefaultLog{},
 		defaultHTTPStatus:        defaultHTTPStatus,
@@ -715,6 +764,20 @@ func WithParams(p Params) *Proxy {
 	}
 }
 
+// newHTTP2Transports creates the transports used for the backends that
+// require HTTP/2. The first one negotiates HTTP/2 via ALPN for TLS
+// backends, the second one uses cleartext HTTP/2 with prior knowledge
+// (h2c).
+func newHTTP2Transports(tr *http.Transport, dialer *skipperDialer) (*http.Transport, *h2cTransport) {
+	tr2 := tr.Clone()
+	tr2.ForceAttemptHTTP2 = true
+	if err := http2.ConfigureTransport(tr2); err != nil {
+		log.Errorf("Failed to configure HTTP/2 transport: %v", err)
+	}
+
+	return tr2, newH2CTransport(dialer)
+}
+
 var caughtPanic = false
 
 // tryCatch executes function `p` and `onErr` if `p` panics
@@ -869,7 +932,28 @@ func (p *Proxy) makeUpgradeRequest(ctx *context, req *http.Request) error {
 	return nil
 }
 
-func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Context) (*http.Response, *proxyError) {
+// outlierFailure tells whether the result of a backend request should be
+// reported as failed to the outlier detection.
+func outlierFailure(rsp *http.Response, perr *proxyError) bool {
+	if perr != nil {
+		return !perr.handled
+	}
+
+	return rsp != nil && rsp.StatusCode >= http.StatusInternalServerError
+}
+
+// reportOutlier reports the result of a backend request to the outlier
+// detection. The requests canceled by the clients are not reported,
+// because they don't tell whether the endpoint is healthy.
+func (p *Proxy) reportOutlier(ctx *context, e *routing.LBEndpoint, rsp *http.Response, perr *proxyError, latency time.Duration) {
+	if p.outliers == nil || perr != nil && perr.code == 499 {
+		return
+	}
+
+	p.outliers.Report(ctx.route, *e, outlierFailure(rsp, perr), latency)
+}
+
+func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Context) (rsp *http.Response, perr *proxyError) {
 	req, endpoint, err := mapRequest(ctx, requestContext, p.flags.HopHeadersRemoval())
 	if err != nil {
 		return nil, &proxyError{err: fmt.Errorf("could not map backend request: %w", err)}
@@ -893,6 +977,19 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 		return nil, &proxyError{handled: true}
 	}
 
+	if endpoint != nil {
+		start := time.Now()
+		defer func() {
+			latency := time.Since(start)
+			p.reportOutlier(ctx, endpoint, rsp, perr, latency)
+			if perr != nil && perr.code == 499 {
+				return
+			}
+
+			endpoint.Metrics.ObserveLatency(latency)
+		}()
+	}
+
 	roundTripper, err := p.getRoundTripper(ctx, req)
 	if err != nil {
 		return nil, &proxyError{err: fmt.Errorf("failed to get roundtripper: %w", err), code: http.StatusBadGateway}
@@ -912,6 +1009,9 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 		setTag(ctx.proxySpan, SkipperRouteIDTag, ctx.route.Id).
 		setTag(ctx.proxySpan, HTTPUrlTag, u.String())
 	p.setCommonSpanInfo(u, req, ctx.proxySpan)
+	if ctx.retryAttempt > 0 {
+		p.tracing.setTag(ctx.proxySpan, RetryAttemptTag, ctx.retryAttempt)
+	}
 
 	carrier := ot.HTTPHeadersCarrier(req.Header)
 	_ = p.tracing.tracer.Inject(ctx.proxySpan.Context(), ot.HTTPHeaders, carrier)
@@ -991,7 +1091,18 @@ func (p *Proxy) getRoundTripper(ctx *context, req *http.Request) (http.RoundTrip
 		req.RemoteAddr = ctx.request.RemoteAddr
 
 		return rt, nil
+	case "h2c":
+		req.URL.Scheme = "http"
+		return p.h2cRoundTripper, nil
 	default:
+		if _, ok := ctx.StateBag()[filters.BackendHTTP2Key]; ok {
+			if req.URL.Scheme == "http" {
+				return p.h2cRoundTripper, nil
+			}
+
+			return p.http2RoundTripper, nil
+		}
+
 		return p.roundTripper, nil
 	}
 }
@@ -1012,7 +1123,7 @@ func (p *Proxy) rejectBackend(ctx *context, req *http.Request) (*http.Response,
 	return nil, false
 }
 
-func (p *Proxy) checkBreaker(c *context) (func(bool), bool) {
+func (p *Proxy) checkBreaker(c *context) (func(int, time.Duration), bool) {
 	if p.breakers == nil {
 		return nil, true
 	}
@@ -1025,7 +1136,7 @@ func (p *Proxy) checkBreaker(c *context) (func(bool), bool) {
 		return nil, true
 	}
 
-	done, ok := b.Allow()
+	done, ok := b.AllowRequest()
 	if !ok && c.request.Body != nil {
 		// consume the body to prevent goroutine leaks
 		io.Copy(io.Discard, c.request.Body)
@@ -1051,6 +1162,21 @@ func (p *Proxy) do(ctx *context) error {
 		for _, done := range pendingLIFO {
 			done()
 		}
+
+		pendingFIFO, _ := ctx.StateBag()[scheduler.FIFOKey].([]func())
+		for _, done := range pendingFIFO {
+			done()
+		}
+
+		pendingAdaptive, _ := ctx.StateBag()[scheduler.AdaptiveKey].([]func())
+		for _, done := range pendingAdaptive {
+			done()
+		}
+
+		pendingConcurrency, _ := ctx.StateBag()[ratelimit.ConcurrencyKey].([]func())
+		for _, release := range pendingConcurrency {
+			release()
+		}
 	}()
 
 	// proxy global setting
@@ -1121,9 +1247,14 @@ func (p *Proxy) do(ctx *context) error {
 
 		backendStart := time.Now()
 		rsp, perr := p.makeBackendRequest(ctx, backendContext)
+		if policy, ok := ctx.StateBag()[filters.RetryKey].(*retryfilters.Retry); ok {
+			p.retryBudgets.Deposit(ctx.route.Id, policy)
+			rsp, perr = p.retryBackendRequest(ctx, backendContext, policy, rsp, perr)
+		}
+
 		if perr != nil {
 			if done != nil {
-				done(false)
+				done(0, time.Since(backendStart))
 			}
 
 			p.metrics.IncErrorsBackend(ctx.route.Id)
@@ -1137,6 +1268,7 @@ func (p *Proxy) do(ctx *context) error {
 				tracing.LogKV("retry", ctx.route.Id, ctx.Request().Context())
 
 				perr = nil
+				rewindBody(ctx)
 				var perr2 *proxyError
 				rsp, perr2 = p.makeBackendRequest(ctx, backendContext)
 				if perr2 != nil {
@@ -1156,7 +1288,7 @@ func (p *Proxy) do(ctx *context) error {
 		}
 
 		if done != nil {
-			done(rsp.StatusCode < http.StatusInternalServerError)
+			done(rsp.StatusCode, time.Since(backendStart))
 		}
 
 		ctx.setResponse(rsp, p.flags.PreserveOriginal())
@@ -1170,10 +1302,15 @@ func (p *Proxy) do(ctx *context) error {
 }
 
 func retryable(ctx *context, perr *proxyError) bool {
+	// the retry filter takes precedence
+	if _, ok := ctx.StateBag()[filters.RetryKey]; ok {
+		return false
+	}
+
 	req := ctx.Request()
 	return perr.code != 499 && perr.DialError() &&
 		ctx.route.BackendType == eskip.LBBackend &&
-		req != nil && (req.Body == nil || req.Body == http.NoBody)
+		req != nil && (req.Body == nil || req.Body == http.NoBody || requestBodyBuffer(ctx) != nil)
 }
 
 func (p *Proxy) serveResponse(ctx *context) {
@@ -1189,9 +1326,14 @@ func (p *Proxy) serveResponse(ctx *context) {
 		return
 	}
 
+	if ctx.grpcMethod != "" {
+		convertGRPCErrorResponse(ctx.response)
+	}
+
 	start := time.Now()
 	p.tracing.logStreamEvent(ctx.proxySpan, StreamHeadersEvent, StartEvent)
 	copyHeader(ctx.responseWriter.Header(), ctx.response.Header)
+	announcedTrailers := announceTrailers(ctx.responseWriter.Header(), ctx.response.Trailer)
 
 	if err := ctx.Request().Context().Err(); err != nil {
 		// deadline exceeded or canceled in stdlib, client closed request
@@ -1216,11 +1358,56 @@ func (p *Proxy) serveResponse(ctx *context) {
 		p.tracing.setTag(ctx.proxySpan, StreamBodyEvent, StreamBodyError)
 		p.tracing.logStreamEvent(ctx.proxySpan, StreamBodyEvent, fmt.Sprintf("Failed to stream response: %v", err))
 	} else {
+		copyTrailers(ctx.responseWriter.Header(), ctx.response.Trailer, announcedTrailers)
 		p.metrics.MeasureResponse(ctx.response.StatusCode, ctx.request.Method, ctx.route.Id, start)
 	}
+
+	if ctx.grpcMethod != "" {
+		status := grpcStatusFromResponse(ctx.response)
+		if err != nil {
+			status = grpcStatusUnavailable
+		}
+
+		p.measureGRPC(ctx.route.Id, ctx.grpcMethod, status, ctx.startServe)
+	}
 	p.metrics.MeasureServe(ctx.route.Id, ctx.metricsHost(), ctx.request.Method, ctx.response.StatusCode, ctx.startServe)
 }
 
+// announceTrailers declares the trailers known before the response body
+// was read, in the Trailer header of the response. It returns the number
+// of the announced trailers.
+func announceTrailers(h http.Header, trailer http.Header) int {
+	if len(trailer) == 0 {
+		return 0
+	}
+
+	keys := make([]string, 0, len(trailer))
+	for k := range trailer {
+		keys = append(keys, k)
+	}
+
+	h.Add("Trailer", strings.Join(keys, ", "))
+	return len(keys)
+}
+
+// copyTrailers copies the response trailers after the body was streamed.
+// Trailers that were not announced before sending the headers, e.g. the
+// grpc-status of a gRPC backend, are sent with the http.TrailerPrefix.
+func copyTrailers(h http.Header, trailer http.Header, announced int) {
+	if len(trailer) == 0 {
+		return
+	}
+
+	if len(trailer) == announced {
+		copyHeader(h, trailer)
+		return
+	}
+
+	for k, v := range trailer {
+		h[http.TrailerPrefix+k] = v
+	}
+}
+
 func (p *Proxy) errorResponse(ctx *context, err error) {
 	perr, ok := err.(*proxyError)
 	if ok && perr.handled {
@@ -1306,6 +1493,11 @@ func (p *Proxy) errorResponse(ctx *context, err error) {
 		req.UserAgent(),
 	)
 
+	if ctx.grpcMethod != "" {
+		p.sendGRPCError(ctx, id, code)
+		return
+	}
+
 	p.sendError(ctx, id, code)
 }
 
@@ -1429,6 +1621,8 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				p.log.Errorf("error during closing the response body: %v", err)
 			}
 		}
+
+		releaseBodyBuffer(ctx)
 	}()
 
 	err := p.do(ctx)
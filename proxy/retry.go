@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"net/http"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	retryfilters "github.com/zalando/skipper/filters/retry"
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/tracing"
+)
+
+// retryEndpoints returns the endpoints of the route, that were not tried
+// before by the retries. It returns nil when all of them were tried, and
+// so all of them can be selected again.
+func retryEndpoints(rt *routing.Route, excluded map[string]bool) []routing.LBEndpoint {
+	if len(excluded) == 0 {
+		return nil
+	}
+
+	var endpoints []routing.LBEndpoint
+	for _, e := range rt.LBEndpoints {
+		if !excluded[e.Host] {
+			endpoints = append(endpoints, e)
+		}
+	}
+
+	return endpoints
+}
+
+// requestBodyBuffer returns the request body buffered by the
+// bufferRequestBody filter, if any.
+func requestBodyBuffer(ctx *context) *snet.BodyBuffer {
+	b, _ := ctx.StateBag()[filters.RequestBodyBufferKey].(*snet.BodyBuffer)
+	return b
+}
+
+func replayableBody(ctx *context) bool {
+	req := ctx.Request()
+	return req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 || requestBodyBuffer(ctx) != nil
+}
+
+// rewindBody sets the request body to read the buffered body from the
+// beginning, before sending the request again. The replaced reader is
+// closed, because it holds a reference of the buffer, that may keep a
+// temporary file open.
+func rewindBody(ctx *context) {
+	if b := requestBodyBuffer(ctx); b != nil {
+		if ctx.request.Body != nil {
+			ctx.request.Body.Close()
+		}
+
+		ctx.request.Body = b.NewReader()
+	}
+}
+
+// releaseBodyBuffer releases the buffered request body, and the reader of
+// it, which is not closed by the transport, when the request was not sent
+// to the backend, e.g. when a filter served the response.
+func releaseBodyBuffer(ctx *context) {
+	if b := requestBodyBuffer(ctx); b != nil {
+		if ctx.request.Body != nil {
+			ctx.request.Body.Close()
+		}
+
+		b.Close()
+	}
+}
+
+// retryRequired decides based on the retry policy whether the result of
+// the last backend request should be retried.
+func retryRequired(policy *retryfilters.Retry, rsp *http.Response, perr *proxyError) bool {
+	if perr == nil {
+		return policy.RetryOnStatus(rsp.StatusCode)
+	}
+
+	switch {
+	case perr.handled, perr.code == 499:
+		return false
+	case perr.DialError():
+		return policy.RetryOnConnectFailure()
+	case perr.code == http.StatusGatewayTimeout:
+		return policy.RetryOnTimeout()
+	default:
+		return policy.RetryOnStatus(perr.code)
+	}
+}
+
+func sleepContext(ctx stdlibcontext.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// retryBackendRequest retries the backend request according to the retry
+// policy set by the retry() filter, and returns the result of the last
+// attempt. The backend timeout of the route applies to every attempt, so
+// that the timed out attempts can be retried, too.
+func (p *Proxy) retryBackendRequest(
+	ctx *context,
+	backendContext stdlibcontext.Context,
+	policy *retryfilters.Retry,
+	rsp *http.Response,
+	perr *proxyError,
+) (*http.Response, *proxyError) {
+	req := ctx.Request()
+	if !policy.RetryableMethod(req.Method) || !replayableBody(ctx) {
+		return rsp, perr
+	}
+
+	timeout, hasTimeout := ctx.StateBag()[filters.BackendTimeout].(time.Duration)
+	for attempt := 1; attempt < policy.MaxAttempts && retryRequired(policy, rsp, perr); attempt++ {
+		if !p.retryBudgets.Withdraw(ctx.route.Id) {
+			p.metrics.IncCounter("retry.budgetexhausted." + ctx.route.Id)
+			tracing.LogKV("retry", "budget exhausted", req.Context())
+			break
+		}
+
+		if !sleepContext(ctx.request.Context(), policy.BackoffDuration(attempt)) {
+			break
+		}
+
+		if rsp != nil {
+			rsp.Body.Close()
+		}
+
+		if hasTimeout {
+			if ctx.cancelBackendContext != nil {
+				ctx.cancelBackendContext()
+			}
+
+			backendContext, ctx.cancelBackendContext = stdlibcontext.WithTimeout(ctx.request.Context(), timeout)
+		}
+
+		if ctx.proxySpan != nil {
+			ctx.proxySpan.Finish()
+			ctx.proxySpan = nil
+		}
+
+		if ctx.excludedEndpoints == nil {
+			ctx.excludedEndpoints = make(map[string]bool)
+		}
+
+		ctx.excludedEndpoints[req.URL.Host] = true
+		ctx.retryAttempt = attempt
+		rewindBody(ctx)
+
+		p.metrics.IncCounter("retry." + ctx.route.Id)
+		tracing.LogKV("retry", ctx.route.Id, req.Context())
+		rsp, perr = p.makeBackendRequest(ctx, backendContext)
+	}
+
+	return rsp, perr
+}
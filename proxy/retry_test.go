@@ -0,0 +1,280 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/metrics/metricstest"
+	snet "github.com/zalando/skipper/net"
+)
+
+func newCountingBackend(status int, counter *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(counter, 1)
+
+		// the test requests are sent with the same body
+		if b, _ := io.ReadAll(r.Body); r.ContentLength > 0 && string(b) != "foo" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(status)
+	}))
+}
+
+func TestRetryFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		filter          string
+		method          string
+		body            string
+		expectedStatus  int
+		expectedFailing int32
+		expectedHealthy int32
+		expectedRetries int64
+	}{{
+		name:            "retry on status picks the other endpoint",
+		filter:          `retry(2, "503", 0)`,
+		method:          "GET",
+		expectedStatus:  http.StatusOK,
+		expectedFailing: 1,
+		expectedHealthy: 1,
+		expectedRetries: 1,
+	}, {
+		name:            "no retry on other status",
+		filter:          `retry(2, "502", 0)`,
+		method:          "GET",
+		expectedStatus:  http.StatusServiceUnavailable,
+		expectedFailing: 1,
+	}, {
+		name:            "no retry of non-idempotent methods",
+		filter:          `retry(2, "503", 0)`,
+		method:          "POST",
+		expectedStatus:  http.StatusServiceUnavailable,
+		expectedFailing: 1,
+	}, {
+		name:            "retry of non-idempotent methods when enabled",
+		filter:          `retry(2, "503,non-idempotent", 0)`,
+		method:          "POST",
+		expectedStatus:  http.StatusOK,
+		expectedFailing: 1,
+		expectedHealthy: 1,
+		expectedRetries: 1,
+	}, {
+		name:            "no retry of requests with body",
+		filter:          `retry(2, "503", 0)`,
+		method:          "PUT",
+		body:            "foo",
+		expectedStatus:  http.StatusServiceUnavailable,
+		expectedFailing: 1,
+	}, {
+		name:            "retry of requests with buffered body",
+		filter:          `bufferRequestBody(1024) -> retry(2, "503", 0)`,
+		method:          "PUT",
+		body:            "foo",
+		expectedStatus:  http.StatusOK,
+		expectedFailing: 1,
+		expectedHealthy: 1,
+		expectedRetries: 1,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			var failing, healthy int32
+			fb := newCountingBackend(http.StatusServiceUnavailable, &failing)
+			defer fb.Close()
+			hb := newCountingBackend(http.StatusOK, &healthy)
+			defer hb.Close()
+
+			// consistent hashing selects the same endpoint for the same key,
+			// the retry has to select the other endpoint
+			doc := fmt.Sprintf(`* -> %s -> <consistentHash, "%s", "%s">`, tt.filter, fb.URL, hb.URL)
+			tp, err := newTestProxy(doc, FlagsNone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tp.close()
+
+			m := &metricstest.MockMetrics{Now: time.Now()}
+			tp.proxy.metrics = m
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			// find a client address that is hashed to the failing endpoint
+			var rsp *http.Response
+			for i := 0; i < 100; i++ {
+				atomic.StoreInt32(&failing, 0)
+				atomic.StoreInt32(&healthy, 0)
+
+				req, err := http.NewRequest(tt.method, ps.URL, strings.NewReader(tt.body))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				req.Header.Set("X-Forwarded-For", fmt.Sprintf("10.0.0.%d", i))
+				rsp, err = http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rsp.Body.Close()
+
+				if atomic.LoadInt32(&failing) > 0 {
+					break
+				}
+
+				m = &metricstest.MockMetrics{Now: time.Now()}
+				tp.proxy.metrics = m
+			}
+
+			if rsp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got: %d", tt.expectedStatus, rsp.StatusCode)
+			}
+
+			if f := atomic.LoadInt32(&failing); f != tt.expectedFailing {
+				t.Errorf("expected %d requests to the failing backend, got: %d", tt.expectedFailing, f)
+			}
+
+			if h := atomic.LoadInt32(&healthy); h != tt.expectedHealthy {
+				t.Errorf("expected %d requests to the healthy backend, got: %d", tt.expectedHealthy, h)
+			}
+
+			m.WithCounters(func(counters map[string]int64) {
+				var retries int64
+				for k, v := range counters {
+					if strings.HasPrefix(k, "retry.") && !strings.HasPrefix(k, "retry.budgetexhausted.") {
+						retries += v
+					}
+				}
+
+				if retries != tt.expectedRetries {
+					t.Errorf("expected %d retries, got: %v", tt.expectedRetries, counters)
+				}
+			})
+		})
+	}
+}
+
+func TestRetryBudgetExhausted(t *testing.T) {
+	var failing int32
+	fb := newCountingBackend(http.StatusServiceUnavailable, &failing)
+	defer fb.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> retry(2, "503", 0, 0, 0) -> "%s"`, fb.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	// the initial budget allows 10 retries, and the routes with 0% budget
+	// can't deposit
+	for i := 0; i < 10; i++ {
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+	}
+
+	if f := atomic.LoadInt32(&failing); f != 20 {
+		t.Fatalf("expected 20 backend requests, got: %d", f)
+	}
+
+	rsp, err := http.Get(ps.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if f := atomic.LoadInt32(&failing); f != 21 {
+		t.Errorf("expected no retries after the budget was exhausted, got %d backend requests", f)
+	}
+}
+
+func TestRetryBackendTimeout(t *testing.T) {
+	var requests int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only the first attempt times out
+		if atomic.AddInt32(&requests, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> backendTimeout("50ms") -> retry(2, "timeout", 0) -> "%s"`, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	rsp, err := http.Get(ps.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got: %d", rsp.StatusCode)
+	}
+
+	if r := atomic.LoadInt32(&requests); r != 2 {
+		t.Errorf("expected 2 backend requests, got: %d", r)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestRewindBodyReleasesReaders(t *testing.T) {
+	// memory limit 0 stores the body in a temporary file
+	b, err := snet.NewBodyBuffer(strings.NewReader("foo"), 1024, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := &closeRecorder{Reader: strings.NewReader("")}
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Body = original
+	ctx := &context{
+		request:  req,
+		stateBag: map[string]interface{}{filters.RequestBodyBufferKey: b},
+	}
+
+	rewindBody(ctx)
+	if !original.closed {
+		t.Error("expected the replaced body to be closed")
+	}
+
+	first := ctx.request.Body
+	rewindBody(ctx)
+	if _, err := io.ReadAll(first); err != nil {
+		t.Errorf("expected the closed reader to be readable until the buffer is released, got: %v", err)
+	}
+
+	last := ctx.request.Body
+	releaseBodyBuffer(ctx)
+	if _, err := io.ReadAll(last); err == nil {
+		t.Error("expected the temporary file to be closed after releasing the buffer")
+	}
+}
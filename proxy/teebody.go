@@ -5,6 +5,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+
+	snet "github.com/zalando/skipper/net"
 )
 
 type teeTie struct {
@@ -36,7 +38,8 @@ func (tt *teeTie) Read(b []byte) (int, error) {
 func (tt *teeTie) Close() error { return nil }
 
 // Returns the cloned request and the tee body to be used on the main request.
-func cloneRequestForSplit(u *url.URL, req *http.Request) (*http.Request, io.ReadCloser, error) {
+// When the request body was buffered, both requests read it independently.
+func cloneRequestForSplit(u *url.URL, req *http.Request, buffer *snet.BodyBuffer) (*http.Request, io.ReadCloser, error) {
 	h := make(http.Header)
 	for k, v := range req.Header {
 		h[k] = v
@@ -45,7 +48,9 @@ func cloneRequestForSplit(u *url.URL, req *http.Request) (*http.Request, io.Read
 	var teeBody io.ReadCloser
 	mainBody := req.Body
 
-	if req.ContentLength != 0 {
+	if buffer != nil {
+		teeBody = buffer.NewReader()
+	} else if req.ContentLength != 0 {
 		pr, pw := io.Pipe()
 		teeBody = pr
 		mainBody = &teeTie{mainBody, pw}
@@ -0,0 +1,237 @@
+package ratelimit
+
+import (
+	"context"
+	crand "crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/net"
+)
+
+const (
+	// ConcurrencyKey is used during routing to pass the release
+	// functions of the cluster concurrency leases from the filters to
+	// the proxy.
+	ConcurrencyKey = "clusterConcurrency"
+
+	concurrencyRedisKeyPrefix   = "ccy."
+	concurrencyMetricPrefix     = "concurrency.redis."
+	concurrencyMetricLatency    = concurrencyMetricPrefix + "latency"
+	concurrencyMetricFallback   = concurrencyMetricPrefix + "fallback"
+	concurrencySpanName         = "redis_concurrency"
+	concurrencyReleaseTimeout   = time.Second
+	concurrencyInstanceTTL      = time.Minute
+	defaultConcurrencyLeaseTTL  = time.Minute
+	defaultConcurrencyInstances = 1
+)
+
+// Implements the acquisition of the cluster concurrency leases as a
+// Redis lua script.
+//
+//go:embed concurrency.lua
+var concurrencyScript string
+
+var (
+	instanceIDOnce sync.Once
+	instanceID     string
+	leaseSequence  uint64
+)
+
+// getInstanceID returns the random id of the current skipper instance,
+// used to identify its leases and to count the instances sharing the
+// limit.
+func getInstanceID() string {
+	instanceIDOnce.Do(func() {
+		b := make([]byte, 8)
+		if _, err := crand.Read(b); err != nil {
+			log.Errorf("Failed to generate the instance id: %v", err)
+		}
+
+		instanceID = hex.EncodeToString(b)
+	})
+
+	return instanceID
+}
+
+// ClusterConcurrency limits the number of the concurrent requests
+// across all the skipper instances. Every request acquires a lease
+// stored in Redis, which is released, when the request is done. The
+// leases expire after their time to live, in case the instance holding
+// them fails to release them.
+//
+// When Redis is not available, each instance falls back to limiting the
+// concurrent requests locally, to its share of the limit, based on the
+// number of the instances last seen acquiring leases of the same group.
+type ClusterConcurrency struct {
+	max        int
+	leaseTTL   time.Duration
+	leasesKey  string
+	instKey    string
+	script     *net.RedisScript
+	ringClient *net.RedisRingClient
+	metrics    metrics.Metrics
+	now        func() time.Time
+
+	instances int64
+
+	mu    sync.Mutex
+	local int
+}
+
+// NewClusterConcurrency creates a cluster wide limit of max concurrent
+// requests, shared by the requests of the same group. The leaseTTL
+// should be longer than the longest expected request, defaults to one
+// minute. Without the redis options of the registry, it limits the
+// concurrent requests locally.
+func NewClusterConcurrency(r *Registry, group string, max int, leaseTTL time.Duration) *ClusterConcurrency {
+	return newClusterConcurrency(r.redisRing, group, max, leaseTTL, time.Now)
+}
+
+func newClusterConcurrency(ringClient *net.RedisRingClient, group string, max int, leaseTTL time.Duration, now func() time.Time) *ClusterConcurrency {
+	if leaseTTL <= 0 {
+		leaseTTL = defaultConcurrencyLeaseTTL
+	}
+
+	// the hash tag puts the leases and the instances of the group on
+	// the same shard, which is required to run the script
+	key := concurrencyRedisKeyPrefix + "{" + getHashedKey(group) + "}."
+	c := &ClusterConcurrency{
+		max:        max,
+		leaseTTL:   leaseTTL,
+		leasesKey:  key + "leases",
+		instKey:    key + "instances",
+		ringClient: ringClient,
+		metrics:    metrics.Default,
+		now:        now,
+		instances:  defaultConcurrencyInstances,
+	}
+
+	if ringClient != nil {
+		c.script = ringClient.NewScript(concurrencyScript)
+	}
+
+	return c
+}
+
+// Acquire tries to acquire a lease for a request. When it succeeds, it
+// returns true and the function to release the lease, which can be
+// called multiple times. The lease is released in the background.
+func (c *ClusterConcurrency) Acquire(ctx context.Context) (func(), bool) {
+	if c.ringClient == nil {
+		return c.acquireLocal()
+	}
+
+	now := c.now()
+	span := c.startSpan(ctx)
+	defer span.Finish()
+	defer c.metrics.MeasureSince(concurrencyMetricLatency, now)
+
+	lease := getInstanceID() + "." + strconv.FormatUint(atomic.AddUint64(&leaseSequence, 1), 36)
+	acquired, err := c.acquire(ctx, lease, now)
+	if err != nil {
+		ext.Error.Set(span, true)
+		c.metrics.IncCounter(concurrencyMetricFallback)
+		log.Errorf("Failed to acquire cluster concurrency lease, falling back to the local share: %v", err)
+		return c.acquireLocal()
+	}
+
+	if !acquired {
+		return nil, false
+	}
+
+	// the release does not block the response
+	var once sync.Once
+	return func() { once.Do(func() { go c.release(lease) }) }, true
+}
+
+func (c *ClusterConcurrency) acquire(ctx context.Context, lease string, now time.Time) (bool, error) {
+	r, err := c.ringClient.RunScript(ctx, c.script,
+		[]string{c.leasesKey, c.instKey},
+		c.max,
+		now.UnixMilli(),
+		c.leaseTTL.Milliseconds(),
+		lease,
+		concurrencyInstanceTTL.Milliseconds(),
+		getInstanceID(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	res, ok := r.([]interface{})
+	if !ok || len(res) != 2 {
+		return false, errors.New("failed to evaluate redis data")
+	}
+
+	acquired, _ := res[0].(int64)
+	if instances, _ := res[1].(int64); instances > 0 {
+		atomic.StoreInt64(&c.instances, instances)
+	}
+
+	return acquired == 1, nil
+}
+
+func (c *ClusterConcurrency) release(lease string) {
+	// the request context may be already canceled, but the lease
+	// should be released anyway
+	ctx, cancel := context.WithTimeout(context.Background(), concurrencyReleaseTimeout)
+	defer cancel()
+
+	if _, err := c.ringClient.ZRem(ctx, c.leasesKey, lease); err != nil {
+		log.Errorf("Failed to release cluster concurrency lease, it expires in %v: %v", c.leaseTTL, err)
+	}
+}
+
+// share returns the local share of the limit, at least one.
+func (c *ClusterConcurrency) share() int {
+	instances := int(atomic.LoadInt64(&c.instances))
+	share := (c.max + instances - 1) / instances
+	if share < 1 {
+		return 1
+	}
+
+	return share
+}
+
+func (c *ClusterConcurrency) acquireLocal() (func(), bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.local >= c.share() {
+		return nil, false
+	}
+
+	c.local++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			c.local--
+			c.mu.Unlock()
+		})
+	}, true
+}
+
+func (c *ClusterConcurrency) startSpan(ctx context.Context) (span opentracing.Span) {
+	parent := opentracing.SpanFromContext(ctx)
+	if parent != nil {
+		span = c.ringClient.StartSpan(concurrencySpanName, opentracing.ChildOf(parent.Context()))
+	} else {
+		span = opentracing.NoopTracer{}.StartSpan("")
+	}
+	ext.Component.Set(span, "skipper")
+	ext.SpanKind.Set(span, "client")
+	return
+}
@@ -0,0 +1,26 @@
+local leases_id = KEYS[1]                 -- sorted set of the leases, scored by their expiry
+local instances_id = KEYS[2]              -- sorted set of the instances, scored by their expiry
+local max = tonumber(ARGV[1])             -- maximum number of concurrent leases
+local now = tonumber(ARGV[2])             -- current time in milliseconds
+local lease_ttl = tonumber(ARGV[3])       -- lease time to live in milliseconds
+local lease = ARGV[4]                     -- id of the new lease
+local instance_ttl = tonumber(ARGV[5])    -- instance time to live in milliseconds
+local instance = ARGV[6]                  -- id of the instance acquiring the lease
+
+-- The expired leases of the crashed or partitioned instances are dropped, so they don't
+-- block the others forever. The instances are tracked to calculate their local share of
+-- the limit, when Redis is not available.
+redis.call("ZREMRANGEBYSCORE", leases_id, "-inf", now)
+redis.call("ZREMRANGEBYSCORE", instances_id, "-inf", now)
+
+redis.call("ZADD", instances_id, now + instance_ttl, instance)
+redis.call("PEXPIRE", instances_id, instance_ttl)
+local instances = redis.call("ZCARD", instances_id)
+
+if redis.call("ZCARD", leases_id) >= max then
+    return {0, instances}
+end
+
+redis.call("ZADD", leases_id, now + lease_ttl, lease)
+redis.call("PEXPIRE", leases_id, lease_ttl)
+return {1, instances}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/net/redistest"
+)
+
+func TestClusterConcurrency(t *testing.T) {
+	redisAddr, done := redistest.NewTestRedis(t)
+	defer done()
+
+	ringClient := net.NewRedisRingClient(&net.RedisOptions{Addrs: []string{redisAddr}})
+	defer ringClient.Close()
+
+	now := time.Now()
+	clock := func() time.Time { return now }
+	ctx := context.Background()
+
+	c1 := newClusterConcurrency(ringClient, "agroup", 2, time.Minute, clock)
+	c2 := newClusterConcurrency(ringClient, "agroup", 2, time.Minute, clock)
+	other := newClusterConcurrency(ringClient, "bgroup", 2, time.Minute, clock)
+
+	release1, ok := c1.Acquire(ctx)
+	require.True(t, ok)
+
+	release2, ok := c2.Acquire(ctx)
+	require.True(t, ok)
+
+	_, ok = c1.Acquire(ctx)
+	assert.False(t, ok, "the limit is shared by the group")
+
+	_, ok = other.Acquire(ctx)
+	assert.True(t, ok, "the groups are limited separately")
+
+	release1()
+	release1()
+
+	// the lease is released in the background
+	assert.Eventually(t, func() bool {
+		_, ok := c2.Acquire(ctx)
+		return ok
+	}, time.Second, 10*time.Millisecond, "released lease")
+
+	_, ok = c1.Acquire(ctx)
+	assert.False(t, ok, "released only once")
+
+	// the leases of the failed instances expire
+	now = now.Add(2 * time.Minute)
+	_, ok = c1.Acquire(ctx)
+	assert.True(t, ok, "expired leases")
+
+	release2()
+}
+
+func TestClusterConcurrencyRedisError(t *testing.T) {
+	ringClient := net.NewRedisRingClient(&net.RedisOptions{Addrs: []string{"no-such-host.test:123"}})
+	defer ringClient.Close()
+
+	c := newClusterConcurrency(ringClient, "agroup", 10, time.Minute, time.Now)
+
+	// falls back to the local share of the last known instances
+	c.instances = 3
+
+	var releases []func()
+	for i := 0; i < 4; i++ {
+		release, ok := c.Acquire(context.Background())
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+
+	_, ok := c.Acquire(context.Background())
+	assert.False(t, ok)
+
+	releases[0]()
+	releases[0]()
+
+	_, ok = c.Acquire(context.Background())
+	assert.True(t, ok)
+
+	_, ok = c.Acquire(context.Background())
+	assert.False(t, ok)
+}
+
+func TestClusterConcurrencyWithoutRedis(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+
+	c := NewClusterConcurrency(r, "agroup", 2, time.Minute)
+
+	release, ok := c.Acquire(context.Background())
+	require.True(t, ok)
+
+	_, ok = c.Acquire(context.Background())
+	require.True(t, ok)
+
+	_, ok = c.Acquire(context.Background())
+	assert.False(t, ok)
+
+	release()
+	_, ok = c.Acquire(context.Background())
+	assert.True(t, ok)
+}
+
+func TestClusterConcurrencyShare(t *testing.T) {
+	for _, tc := range []struct {
+		max, instances, share int
+	}{
+		{max: 100, instances: 1, share: 100},
+		{max: 100, instances: 3, share: 34},
+		{max: 2, instances: 5, share: 1},
+	} {
+		c := newClusterConcurrency(nil, "agroup", tc.max, 0, time.Now)
+		c.instances = int64(tc.instances)
+		assert.Equal(t, tc.share, c.share())
+	}
+}
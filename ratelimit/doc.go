@@ -22,6 +22,26 @@ calculate rate limiting. If there is no such header it will use the
 remote IP of the request. This is the default Lookuper and may be the
 one most users want to use.
 
+Lookuper Type - ClientIPPrefixLookuper
+
+This lookuper will use the network of the remote IP, masked to the
+configured IPv4 or IPv6 prefix length, to calculate rate limiting.
+
+Lookuper Type - JWTClaimLookuper
+
+This lookuper will use a claim of the token validated by a preceding
+jwtValidation, oauthOidc* or oauthGrant filter to calculate rate
+limiting. It does not parse the Authorization header, so the requests
+without a validated token are not rate limited. The claims are
+available only from the filter context, see FilterContextLookuper.
+
+Lookuper Type - CookieLookuper, QueryLookuper and PathParamLookuper
+
+These lookupers will use the value of the specified cookie, query
+parameter or path parameter of the route to calculate rate limiting.
+The path parameters are available only from the filter context, see
+FilterContextLookuper.
+
 Usage
 
 When imported as a package, the Registry can be used to hold the rate
@@ -80,7 +100,10 @@ enumeration or DDoS attacks. ClusterServiceRatelimit and
 ClusterClientRatelimit using redis ring shards should be carefully
 tested, because of redis. Redis ring based cluster ratelimits should
 not create a significant memory footprint for skipper instances, but
-might create load to redis.
+might create load to redis. TokenBucketRatelimit and
+ClusterTokenBucketRatelimit limit the average rate of the clients with
+a token bucket, while they allow short bursts up to Burst requests.
+They store a single timestamp per client, independent of MaxHits.
 
 Settings - MaxHits
 
@@ -92,6 +115,12 @@ Defines the time window until rate limits will be enforced, if maximum
 number of requests are exceeded. This is defined as a string
 representation of Go's time.Duration, e.g. 1m30s.
 
+Settings - Burst
+
+Defines the capacity of the token bucket for the token bucket types,
+which is refilled with MaxHits tokens per TimeWindow. Defaults to
+MaxHits.
+
 Settings - Lookuper
 
 Defines an optional configuration to choose which Header should be
@@ -122,6 +151,47 @@ request:
 
 Both are based on RFC 6585.
 
+When enabled with -ratelimit-quota-headers, the ratelimit filters also
+set the RateLimit headers, as defined by the IETF draft
+https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/,
+on the rate limited and on the allowed responses, showing the number of
+requests allowed in a burst, the remaining quota, the seconds until the
+full quota is available again, and the policy as the maximum requests
+per time window in seconds:
+
+	RateLimit-Limit: 10
+	RateLimit-Remaining: 7
+	RateLimit-Reset: 42
+	RateLimit-Policy: 10;w=60
+
+The token bucket ratelimits with a burst set report the burst as the
+limit, and add it to the policy, e.g. "10;w=60;burst=20". When a route
+has multiple ratelimits, the one with the least remaining quota is
+reported. The redis based cluster ratelimits report the remaining quota
+of the same query that checks the limit. In case of the client and the
+SWIM based cluster ratelimits the remaining quota is an estimation,
+until the limit is reached.
+
+Usage Plans
+
+UsagePlans limits the requests of the consumers according to the
+tiers of a usage plan configuration, loaded and periodically reloaded
+from a PlanSource, e.g. a PlanFile. Each plan can define a rate limit,
+calculated as a ClusterClientRatelimit, and daily and monthly quotas,
+stored as counters in Redis. The consumers without an explicit plan
+get the default plan. Without a Redis client in the registry, the daily
+and monthly quotas are not limited.
+
+Cluster Concurrency
+
+ClusterConcurrency limits the number of the concurrent requests across
+all skipper instances with leases stored in Redis. The leases expire
+after their time to live, in case an instance fails to release them.
+When Redis is not available, the instances fall back to limiting the
+concurrent requests locally to their share of the limit. Without Redis
+configured, every instance limits the concurrent requests locally to
+the full limit. The SWIM based swarm is not supported.
+
 Registry
 
 The active rate limiters are stored in a registry. They are created
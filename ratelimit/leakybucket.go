@@ -50,20 +50,27 @@ var leakyBucketScript string
 // the average rate at which water is poured in exceeds the rate at which the bucket leaks or if more water than
 // the capacity of the bucket is poured in all at once.
 // See https://en.wikipedia.org/wiki/Leaky_bucket
+//
+// Without the redis options of the registry, Add returns an error.
 func NewClusterLeakyBucket(r *Registry, capacity int, emission time.Duration) *ClusterLeakyBucket {
 	return newClusterLeakyBucket(r.redisRing, capacity, emission, time.Now)
 }
 
 func newClusterLeakyBucket(ringClient *net.RedisRingClient, capacity int, emission time.Duration, now func() time.Time) *ClusterLeakyBucket {
-	return &ClusterLeakyBucket{
+	b := &ClusterLeakyBucket{
 		capacity:    capacity,
 		emission:    emission,
 		labelPrefix: fmt.Sprintf("%d-%v-", capacity, emission),
-		script:      ringClient.NewScript(leakyBucketScript),
 		ringClient:  ringClient,
 		metrics:     metrics.Default,
 		now:         now,
 	}
+
+	if ringClient != nil {
+		b.script = ringClient.NewScript(leakyBucketScript)
+	}
+
+	return b
 }
 
 // Add adds an increment amount to the bucket identified by the label.
@@ -75,6 +82,10 @@ func (b *ClusterLeakyBucket) Add(ctx context.Context, label string, increment in
 		return false, 0, nil
 	}
 
+	if b.ringClient == nil {
+		return false, 0, errNoRedis
+	}
+
 	now := b.now()
 	span := b.startSpan(ctx)
 	defer span.Finish()
@@ -107,6 +107,16 @@ func TestLeakyBucketRedisError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestLeakyBucketWithoutRedis(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+
+	bucket := NewClusterLeakyBucket(r, 1, time.Minute)
+	_, _, err := bucket.Add(context.Background(), "alabel", 1)
+
+	assert.Equal(t, errNoRedis, err)
+}
+
 func TestLeakyBucketId(t *testing.T) {
 	const label = "alabel"
 
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	circularbuffer "github.com/szuecs/rate-limit-buffer"
+)
+
+// serviceLimiter adds the remaining quota to the service ratelimit of
+// the circularbuffer package.
+type serviceLimiter struct {
+	circularbuffer.RateLimiter
+	window time.Duration
+}
+
+func newServiceLimiter(s Settings) *serviceLimiter {
+	return &serviceLimiter{
+		RateLimiter: circularbuffer.NewRateLimiter(s.MaxHits, s.TimeWindow),
+		window:      s.TimeWindow,
+	}
+}
+
+// Quota returns the number of calls allowed until the limit is reached,
+// and the duration until the most recent call leaves the time window.
+func (l *serviceLimiter) Quota(context.Context, string) (int, time.Duration) {
+	b := l.RateLimiter.(*circularbuffer.CircularBuffer)
+	remaining := b.Cap() - b.Len()
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return remaining, resetAfter(b.Current(""), l.window, time.Now())
+}
+
+// clientLimiter adds the remaining quota to the client ratelimit of the
+// circularbuffer package.
+type clientLimiter struct {
+	*circularbuffer.ClientRateLimiter
+	maxHits int
+	window  time.Duration
+}
+
+func newClientLimiter(s Settings) *clientLimiter {
+	return &clientLimiter{
+		ClientRateLimiter: circularbuffer.NewClientRateLimiter(s.MaxHits, s.TimeWindow, s.CleanInterval),
+		maxHits:           s.MaxHits,
+		window:            s.TimeWindow,
+	}
+}
+
+// Quota returns the number of calls allowed until the limit is reached,
+// and the duration until the most recent call leaves the time window.
+//
+// The client buffers only expose the oldest and the most recent call,
+// so the remaining quota is exact when the key has no calls in the time
+// window or when the limit is reached. Otherwise, it is estimated from
+// the rate of the calls in the buffer, the same way as the swarm based
+// cluster ratelimit does it, and it is at least 1.
+func (l *clientLimiter) Quota(_ context.Context, key string) (int, time.Duration) {
+	now := time.Now()
+	current := l.Current(key)
+	if current.IsZero() || !current.Add(l.window).After(now) {
+		return l.maxHits, 0
+	}
+
+	reset := resetAfter(current, l.window, now)
+	oldest := l.Oldest(key)
+	if oldest.Add(l.window).After(now) {
+		return 0, reset
+	}
+
+	calls := 1
+	if d := current.Sub(oldest); !oldest.IsZero() && d > 0 {
+		calls = int(math.Ceil(float64(l.maxHits) * float64(l.window) / float64(d)))
+	}
+
+	if calls >= l.maxHits {
+		calls = l.maxHits - 1
+	}
+
+	return l.maxHits - calls, reset
+}
+
+// resetAfter returns the duration until the call leaves the time window.
+func resetAfter(call time.Time, window time.Duration, now time.Time) time.Duration {
+	if call.IsZero() {
+		return 0
+	}
+
+	reset := call.Add(window).Sub(now)
+	if reset < 0 {
+		return 0
+	}
+
+	return reset
+}
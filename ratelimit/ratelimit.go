@@ -6,13 +6,13 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	stdlibnet "net"
 	"net/http"
 	"strconv"
 	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	circularbuffer "github.com/szuecs/rate-limit-buffer"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/net"
 )
@@ -25,6 +25,23 @@ const (
 	// long a client should wait before making a new request
 	RetryAfterHeader = "Retry-After"
 
+	// LimitHeader is the name of the header which will be used to
+	// indicate the quota of the client, as defined by the IETF draft
+	// https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+	LimitHeader = "RateLimit-Limit"
+
+	// RemainingHeader is the name of the header which will be used to
+	// indicate the remaining quota of the client
+	RemainingHeader = "RateLimit-Remaining"
+
+	// ResetHeader is the name of the header which will be used to
+	// indicate the seconds until the quota of the client is restored
+	ResetHeader = "RateLimit-Reset"
+
+	// PolicyHeader is the name of the header which will be used to
+	// indicate the quota policy of the ratelimit
+	PolicyHeader = "RateLimit-Policy"
+
 	// Deprecated, use filters.RatelimitName instead
 	ServiceRatelimitName = filters.RatelimitName
 
@@ -70,8 +87,12 @@ func (rt *RatelimitType) UnmarshalYAML(unmarshal func(interface{}) error) error
 		*rt = ClusterServiceRatelimit
 	case "disabled":
 		*rt = DisableRatelimit
+	case "tokenBucket":
+		*rt = TokenBucketRatelimit
+	case "clusterTokenBucket":
+		*rt = ClusterTokenBucketRatelimit
 	default:
-		return fmt.Errorf("invalid ratelimit type %v (allowed values are: client, service or disabled)", value)
+		return fmt.Errorf("invalid ratelimit type %v (allowed values are: client, service, clusterClient, clusterService, tokenBucket, clusterTokenBucket or disabled)", value)
 	}
 
 	return nil
@@ -126,6 +147,20 @@ const (
 
 	// DisableRatelimit is used to disable rate limit
 	DisableRatelimit
+
+	// TokenBucketRatelimit is used to have a local rate limit per
+	// user for a backend, which allows the clients to burst up to
+	// Burst requests, while limiting the average rate to MaxHits
+	// per TimeWindow. It is calculated and measured within each
+	// instance, and one filter consumes memory per individual
+	// client, independent of MaxHits.
+	TokenBucketRatelimit
+
+	// ClusterTokenBucketRatelimit is the same as the
+	// TokenBucketRatelimit, but it is calculated for the whole
+	// skipper fleet. It needs redis to be configured, otherwise it
+	// doesn't limit the requests.
+	ClusterTokenBucketRatelimit
 )
 
 func (rt RatelimitType) String() string {
@@ -142,6 +177,10 @@ func (rt RatelimitType) String() string {
 		return LocalRatelimitName
 	case ServiceRatelimit:
 		return filters.RatelimitName
+	case TokenBucketRatelimit:
+		return filters.TokenBucketRatelimitName
+	case ClusterTokenBucketRatelimit:
+		return filters.ClusterTokenBucketRatelimitName
 	default:
 		return filters.UnknownRatelimitName
 
@@ -276,6 +315,198 @@ func (rrl *RoundRobinLookuper) String() string {
 	return "RoundRobinLookuper"
 }
 
+// FilterContextLookuper extends Lookuper for the lookupers, that need
+// more than the request to select a bucket, e.g. the path parameters
+// of the route.
+type FilterContextLookuper interface {
+	Lookuper
+
+	// LookupFilterContext is used instead of Lookup, when the filter
+	// context is available.
+	LookupFilterContext(filters.FilterContext) string
+}
+
+func lookupFilterContext(l Lookuper, ctx filters.FilterContext) string {
+	if lc, ok := l.(FilterContextLookuper); ok {
+		return lc.LookupFilterContext(ctx)
+	}
+
+	return l.Lookup(ctx.Request())
+}
+
+// LookupFilterContext returns the combined string of all Lookupers
+// part of the tuple, using the filter context where it is supported.
+func (t TupleLookuper) LookupFilterContext(ctx filters.FilterContext) string {
+	if t.l == nil {
+		return ""
+	}
+
+	buf := bytes.Buffer{}
+	for _, l := range *(t.l) {
+		buf.WriteString(lookupFilterContext(l, ctx))
+	}
+	return buf.String()
+}
+
+// JWTClaimLookuper implements the FilterContextLookuper interface and
+// will select a bucket by a claim of the token validated by a preceding
+// jwtValidation, oauthOidc* or oauthGrant filter. The Authorization
+// header itself is not parsed, so the requests without a validated
+// token are not ratelimited.
+type JWTClaimLookuper struct {
+	claim string
+}
+
+// NewJWTClaimLookuper returns JWTClaimLookuper configured to lookup the
+// claim named c, e.g. "sub" or "client_id".
+func NewJWTClaimLookuper(c string) JWTClaimLookuper {
+	return JWTClaimLookuper{claim: c}
+}
+
+// Lookup returns always empty string, because the validated claims are
+// only available from the filter context.
+func (JWTClaimLookuper) Lookup(*http.Request) string {
+	return ""
+}
+
+// LookupFilterContext returns the value of the claim, or empty string,
+// when there is no validated token or it does not have the claim.
+func (j JWTClaimLookuper) LookupFilterContext(ctx filters.FilterContext) string {
+	claims, ok := ctx.StateBag()[filters.ValidatedClaimsKey].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	switch c := claims[j.claim].(type) {
+	case nil:
+		return ""
+	case string:
+		return c
+	default:
+		return fmt.Sprint(c)
+	}
+}
+
+func (j JWTClaimLookuper) String() string {
+	return "JWTClaimLookuper"
+}
+
+// CookieLookuper implements Lookuper interface and will select a
+// bucket by the value of a cookie.
+type CookieLookuper struct {
+	name string
+}
+
+// NewCookieLookuper returns CookieLookuper configured to lookup the
+// cookie named n.
+func NewCookieLookuper(n string) CookieLookuper {
+	return CookieLookuper{name: n}
+}
+
+// Lookup returns the value of the cookie.
+func (c CookieLookuper) Lookup(req *http.Request) string {
+	cookie, err := req.Cookie(c.name)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+func (c CookieLookuper) String() string {
+	return "CookieLookuper"
+}
+
+// QueryLookuper implements Lookuper interface and will select a bucket
+// by the value of a query parameter.
+type QueryLookuper struct {
+	key string
+}
+
+// NewQueryLookuper returns QueryLookuper configured to lookup the query
+// parameter named k.
+func NewQueryLookuper(k string) QueryLookuper {
+	return QueryLookuper{key: k}
+}
+
+// Lookup returns the first value of the query parameter.
+func (q QueryLookuper) Lookup(req *http.Request) string {
+	if req.URL == nil {
+		return ""
+	}
+
+	return req.URL.Query().Get(q.key)
+}
+
+func (q QueryLookuper) String() string {
+	return "QueryLookuper"
+}
+
+// PathParamLookuper implements FilterContextLookuper interface and will
+// select a bucket by the value of a path parameter of the route, e.g.
+// "id" for Path("/users/:id").
+type PathParamLookuper struct {
+	name string
+}
+
+// NewPathParamLookuper returns PathParamLookuper configured to lookup
+// the path parameter named n.
+func NewPathParamLookuper(n string) PathParamLookuper {
+	return PathParamLookuper{name: n}
+}
+
+// Lookup returns always empty string, because the path parameters are
+// not available from the request.
+func (PathParamLookuper) Lookup(*http.Request) string {
+	return ""
+}
+
+// LookupFilterContext returns the value of the path parameter.
+func (p PathParamLookuper) LookupFilterContext(ctx filters.FilterContext) string {
+	return ctx.PathParam(p.name)
+}
+
+func (p PathParamLookuper) String() string {
+	return "PathParamLookuper"
+}
+
+// ClientIPPrefixLookuper implements Lookuper interface and will select
+// a bucket by the network of the client IP, e.g. to put all the clients
+// of an IPv6 /64 network in the same bucket. The client IP is taken
+// from the X-Forwarded-For header, or the remote address of the
+// request, the same way as XForwardedForLookuper does.
+type ClientIPPrefixLookuper struct {
+	v4Bits int
+	v6Bits int
+}
+
+// NewClientIPPrefixLookuper returns ClientIPPrefixLookuper configured
+// to mask the IPv4 addresses to v4Bits and the IPv6 addresses to
+// v6Bits long prefixes.
+func NewClientIPPrefixLookuper(v4Bits, v6Bits int) ClientIPPrefixLookuper {
+	return ClientIPPrefixLookuper{v4Bits: v4Bits, v6Bits: v6Bits}
+}
+
+// Lookup returns the network of the client IP in CIDR notation.
+func (c ClientIPPrefixLookuper) Lookup(req *http.Request) string {
+	ip := net.RemoteHost(req)
+	if ip == nil {
+		return ""
+	}
+
+	bits, size := c.v6Bits, 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits, size = ip4, c.v4Bits, 32
+	}
+
+	n := stdlibnet.IPNet{IP: ip.Mask(stdlibnet.CIDRMask(bits, size)), Mask: stdlibnet.CIDRMask(bits, size)}
+	return n.String()
+}
+
+func (c ClientIPPrefixLookuper) String() string {
+	return "ClientIPPrefixLookuper"
+}
+
 // Settings configures the chosen rate limiter
 type Settings struct {
 	// Type of the chosen rate limiter
@@ -298,10 +529,16 @@ type Settings struct {
 	CleanInterval time.Duration `yaml:"-"`
 
 	// Group is a string to group ratelimiters of Type
-	// ClusterServiceRatelimit or ClusterClientRatelimit.
-	// A ratelimit group considers all hits to the same group as
-	// one target.
+	// ClusterServiceRatelimit, ClusterClientRatelimit or
+	// ClusterTokenBucketRatelimit. A ratelimit group considers all
+	// hits to the same group as one target.
 	Group string `yaml:"group"`
+
+	// Burst is the capacity of the token bucket of Type
+	// TokenBucketRatelimit or ClusterTokenBucketRatelimit, the
+	// number of requests allowed at once. The bucket is refilled
+	// with MaxHits tokens per TimeWindow. Defaults to MaxHits.
+	Burst int `yaml:"burst"`
 }
 
 func (s Settings) Empty() bool {
@@ -322,6 +559,10 @@ func (s Settings) String() string {
 		return fmt.Sprintf("ratelimit(type=clusterService,max-hits=%d,time-window=%s,group=%s)", s.MaxHits, s.TimeWindow, s.Group)
 	case ClusterClientRatelimit:
 		return fmt.Sprintf("ratelimit(type=clusterClient,max-hits=%d,time-window=%s,group=%s)", s.MaxHits, s.TimeWindow, s.Group)
+	case TokenBucketRatelimit:
+		return fmt.Sprintf("ratelimit(type=tokenBucket,max-hits=%d,time-window=%s,burst=%d)", s.MaxHits, s.TimeWindow, s.Burst)
+	case ClusterTokenBucketRatelimit:
+		return fmt.Sprintf("ratelimit(type=clusterTokenBucket,max-hits=%d,time-window=%s,burst=%d,group=%s)", s.MaxHits, s.TimeWindow, s.Burst, s.Group)
 	default:
 		return "non"
 	}
@@ -360,6 +601,43 @@ type contextLimiter interface {
 	AllowContext(context.Context, string) bool
 }
 
+// quotaLimiter extends limiter with a Quota method that reports the
+// remaining quota, used for the RateLimit response headers.
+type quotaLimiter interface {
+	limiter
+
+	// Quota returns the number of calls allowed until the limit is
+	// reached, and the duration until the full quota is available
+	// again
+	Quota(context.Context, string) (remaining int, reset time.Duration)
+}
+
+// allowQuotaLimiter extends limiter with an AllowQuota method that
+// decides to allow or not and reports the remaining quota at once, e.g.
+// to avoid additional queries of the cluster ratelimits.
+type allowQuotaLimiter interface {
+	limiter
+	AllowQuota(context.Context, string) (allow bool, remaining int, reset time.Duration)
+}
+
+// Quota describes the state of a ratelimit for a single key, as
+// reported by the RateLimit response headers.
+type Quota struct {
+	// Limit is the number of calls allowed in a burst
+	Limit int
+
+	// Remaining is the number of calls allowed until the limit is
+	// reached
+	Remaining int
+
+	// Reset is the duration until the full quota is available again
+	Reset time.Duration
+
+	// Policy describes the ratelimit, e.g. "10;w=60" for 10 calls per
+	// minute
+	Policy string
+}
+
 // Ratelimit is a proxy object that delegates to limiter
 // implemetations and stores settings for the ratelimiter
 type Ratelimit struct {
@@ -409,6 +687,63 @@ func (l *Ratelimit) RetryAfter(s string) int {
 	return l.impl.RetryAfter(s)
 }
 
+// Quota returns the current quota of s. It returns false, when the
+// limiter implementation does not report the remaining quota, e.g. for
+// disabled ratelimits, or when it reports it only together with
+// allowing a call, e.g. for the redis based cluster ratelimits.
+func (l *Ratelimit) Quota(ctx context.Context, s string) (Quota, bool) {
+	if l == nil {
+		return Quota{}, false
+	}
+
+	implq, ok := l.impl.(quotaLimiter)
+	if !ok {
+		return Quota{}, false
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return l.quota(implq.Quota(ctx, s)), true
+}
+
+// AllowQuota is like AllowContext, but it also returns the quota of s
+// after the call. The quota is reported the same way as by Quota, but
+// the implementations that check the limit remotely report it without
+// additional queries.
+func (l *Ratelimit) AllowQuota(ctx context.Context, s string) (bool, Quota, bool) {
+	if l == nil {
+		return true, Quota{}, false
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if impla, ok := l.impl.(allowQuotaLimiter); ok {
+		allow, remaining, reset := impla.AllowQuota(ctx, s)
+		return allow, l.quota(remaining, reset), true
+	}
+
+	allow := l.AllowContext(ctx, s)
+	q, ok := l.Quota(ctx, s)
+	return allow, q, ok
+}
+
+func (l *Ratelimit) quota(remaining int, reset time.Duration) Quota {
+	q := Quota{Limit: l.settings.MaxHits, Policy: policy(l.settings), Remaining: remaining, Reset: reset}
+	if l.settings.Burst > 0 && (l.settings.Type == TokenBucketRatelimit || l.settings.Type == ClusterTokenBucketRatelimit) {
+		q.Limit = l.settings.Burst
+	}
+
+	if q.Remaining > q.Limit {
+		q.Remaining = q.Limit
+	}
+
+	return q
+}
+
 func (l *Ratelimit) Delta(s string) time.Duration {
 	return l.impl.Delta(s)
 }
@@ -444,6 +779,8 @@ func (zeroRatelimit) RetryAfter(string) int      { return zeroRetry }
 func (zeroRatelimit) Delta(string) time.Duration { return zeroDelta }
 func (zeroRatelimit) Resize(string, int)         {}
 
+func (zeroRatelimit) Quota(context.Context, string) (int, time.Duration) { return 0, zeroDelta }
+
 func newRatelimit(s Settings, sw Swarmer, redisRing *net.RedisRingClient) *Ratelimit {
 	var impl limiter
 	if s.MaxHits == 0 {
@@ -451,17 +788,36 @@ func newRatelimit(s Settings, sw Swarmer, redisRing *net.RedisRingClient) *Ratel
 	} else {
 		switch s.Type {
 		case ServiceRatelimit:
-			impl = circularbuffer.NewRateLimiter(s.MaxHits, s.TimeWindow)
+			impl = newServiceLimiter(s)
 		case LocalRatelimit:
 			log.Warning("LocalRatelimit is deprecated, please use ClientRatelimit instead")
 			fallthrough
 		case ClientRatelimit:
-			impl = circularbuffer.NewClientRateLimiter(s.MaxHits, s.TimeWindow, s.CleanInterval)
+			impl = newClientLimiter(s)
 		case ClusterServiceRatelimit:
 			s.CleanInterval = 0
 			fallthrough
 		case ClusterClientRatelimit:
 			impl = newClusterRateLimiter(s, sw, redisRing, s.Group)
+		case TokenBucketRatelimit:
+			if b, err := newTokenBucket(s, time.Now); err != nil {
+				log.Errorf("Invalid token bucket ratelimit, the requests will not be limited: %v", err)
+				impl = voidRatelimit{}
+			} else {
+				impl = b
+			}
+		case ClusterTokenBucketRatelimit:
+			if redisRing != nil {
+				if b, err := newClusterTokenBucket(s, redisRing, time.Now); err != nil {
+					log.Errorf("Invalid cluster token bucket ratelimit, the requests will not be limited: %v", err)
+					impl = voidRatelimit{}
+				} else {
+					impl = b
+				}
+			} else {
+				log.Warning("ClusterTokenBucketRatelimit requires redis, the requests will not be limited")
+				impl = voidRatelimit{}
+			}
 		default:
 			impl = voidRatelimit{}
 		}
@@ -481,6 +837,31 @@ func Headers(maxHits int, timeWindow time.Duration, retryAfter int) http.Header
 	}
 }
 
+// seconds rounds the duration up to seconds
+func seconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+func policy(s Settings) string {
+	p := fmt.Sprintf("%d;w=%d", s.MaxHits, seconds(s.TimeWindow))
+	if s.Burst > 0 && (s.Type == TokenBucketRatelimit || s.Type == ClusterTokenBucketRatelimit) {
+		p += fmt.Sprintf(";burst=%d", s.Burst)
+	}
+
+	return p
+}
+
+// QuotaHeaders returns the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers of the quota.
+func QuotaHeaders(q Quota) http.Header {
+	h := make(http.Header)
+	h.Set(LimitHeader, strconv.Itoa(q.Limit))
+	h.Set(RemainingHeader, strconv.Itoa(q.Remaining))
+	h.Set(ResetHeader, strconv.FormatInt(seconds(q.Reset), 10))
+	h.Set(PolicyHeader, q.Policy)
+	return h
+}
+
 func getHashedKey(clearText string) string {
 	h := sha256.Sum256([]byte(clearText))
 	return hex.EncodeToString(h[:])
@@ -1,11 +1,15 @@
 package ratelimit
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
 )
 
 func checkRatelimitted(t *testing.T, rl *Ratelimit, client string) {
@@ -133,6 +137,94 @@ func TestDisableRatelimit(t *testing.T) {
 	})
 }
 
+func TestQuota(t *testing.T) {
+	for _, typ := range []RatelimitType{ServiceRatelimit, ClientRatelimit, TokenBucketRatelimit} {
+		t.Run(typ.String(), func(t *testing.T) {
+			rl := newRatelimit(Settings{
+				Type:       typ,
+				MaxHits:    3,
+				TimeWindow: time.Minute,
+			}, nil, nil)
+			defer rl.Close()
+
+			q, ok := rl.Quota(context.Background(), "foo")
+			if !ok {
+				t.Fatal("quota not supported")
+			}
+
+			if q.Limit != 3 || q.Remaining != 3 || q.Reset != 0 || q.Policy != "3;w=60" {
+				t.Errorf("unexpected initial quota: %+v", q)
+			}
+
+			for i := 2; i >= 0; i-- {
+				checkNotRatelimitted(t, rl, "foo")
+				q, _ = rl.Quota(context.Background(), "foo")
+				if typ == ClientRatelimit && i > 0 {
+					// estimated until the limit is reached
+					if q.Remaining < i || q.Remaining > 2 {
+						t.Errorf("expected %d to 2 remaining, got: %d", i, q.Remaining)
+					}
+				} else if q.Remaining != i {
+					t.Errorf("expected %d remaining, got: %d", i, q.Remaining)
+				}
+
+				if q.Reset <= 0 || q.Reset > time.Minute {
+					t.Errorf("unexpected reset: %v", q.Reset)
+				}
+			}
+
+			checkRatelimitted(t, rl, "foo")
+			q, _ = rl.Quota(context.Background(), "foo")
+			if q.Remaining != 0 {
+				t.Errorf("expected no remaining, got: %d", q.Remaining)
+			}
+		})
+	}
+
+	t.Run("burst", func(t *testing.T) {
+		rl := newRatelimit(Settings{
+			Type:       TokenBucketRatelimit,
+			MaxHits:    3,
+			TimeWindow: time.Minute,
+			Burst:      5,
+		}, nil, nil)
+		defer rl.Close()
+
+		q, _ := rl.Quota(context.Background(), "foo")
+		if q.Limit != 5 || q.Remaining != 5 || q.Policy != "3;w=60;burst=5" {
+			t.Errorf("unexpected quota: %+v", q)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		rl := newRatelimit(Settings{Type: DisableRatelimit, MaxHits: 3, TimeWindow: time.Minute}, nil, nil)
+		if _, ok := rl.Quota(context.Background(), "foo"); ok {
+			t.Error("unexpected quota of disabled ratelimit")
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var rl *Ratelimit
+		if _, ok := rl.Quota(context.Background(), "foo"); ok {
+			t.Error("unexpected quota of nil ratelimit")
+		}
+	})
+}
+
+func TestQuotaHeaders(t *testing.T) {
+	h := QuotaHeaders(Quota{Limit: 10, Remaining: 3, Reset: 1500 * time.Millisecond, Policy: "10;w=60"})
+	for k, v := range map[string]string{
+		"RateLimit-Limit":     "10",
+		"RateLimit-Remaining": "3",
+		"RateLimit-Reset":     "2",
+		"RateLimit-Policy":    "10;w=60",
+	} {
+		if got := h.Get(k); got != v {
+			t.Errorf("unexpected %s header, expected %s, got: %s", k, v, got)
+		}
+	}
+}
+
 func TestXForwardedForLookuper(t *testing.T) {
 	req, err := http.NewRequest("GET", "/foo", nil)
 	if err != nil {
@@ -213,6 +305,105 @@ func TestTupleLookuper(t *testing.T) {
 	})
 }
 
+func TestJWTClaimLookuper(t *testing.T) {
+	// {"sub": "foo", "exp": 123}
+	const token = "eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28iLCJleHAiOjEyM30.sig"
+
+	req, _ := http.NewRequest("GET", "/foo", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	l := NewJWTClaimLookuper("sub")
+	if got := l.Lookup(req); got != "" {
+		t.Errorf("unexpected lookup of an unvalidated token: %q", got)
+	}
+
+	ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+	if got := l.LookupFilterContext(ctx); got != "" {
+		t.Errorf("unexpected lookup of an unvalidated token: %q", got)
+	}
+
+	ctx.FStateBag[filters.ValidatedClaimsKey] = map[string]interface{}{"sub": "bar", "exp": float64(123)}
+	for _, tc := range []struct {
+		claim, expected string
+	}{
+		{"sub", "bar"},
+		{"exp", "123"},
+		{"client_id", ""},
+	} {
+		if got := NewJWTClaimLookuper(tc.claim).LookupFilterContext(ctx); got != tc.expected {
+			t.Errorf("Failed to lookup the claim %s, expected %q, got: %q", tc.claim, tc.expected, got)
+		}
+	}
+}
+
+func TestCookieLookuper(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/foo", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "foo"})
+
+	if NewCookieLookuper("session").Lookup(req) != "foo" {
+		t.Errorf("Failed to lookup request")
+	}
+
+	if NewCookieLookuper("other").Lookup(req) != "" {
+		t.Errorf("Failed to lookup missing cookie")
+	}
+}
+
+func TestQueryLookuper(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/foo?key=foo&key=bar", nil)
+
+	if NewQueryLookuper("key").Lookup(req) != "foo" {
+		t.Errorf("Failed to lookup request")
+	}
+
+	if NewQueryLookuper("other").Lookup(req) != "" {
+		t.Errorf("Failed to lookup missing query parameter")
+	}
+}
+
+func TestPathParamLookuper(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/users/foo", nil)
+	ctx := &filtertest.Context{FRequest: req, FParams: map[string]string{"id": "foo"}}
+
+	l := NewPathParamLookuper("id")
+	if l.Lookup(req) != "" {
+		t.Errorf("Unexpected lookup without the filter context")
+	}
+
+	if l.LookupFilterContext(ctx) != "foo" {
+		t.Errorf("Failed to lookup filter context")
+	}
+
+	tl := NewTupleLookuper(NewHeaderLookuper("X-Foo"), l)
+	req.Header.Set("X-Foo", "bar")
+	if tl.LookupFilterContext(ctx) != "barfoo" {
+		t.Errorf("Failed to lookup filter context with tuple lookuper")
+	}
+}
+
+func TestClientIPPrefixLookuper(t *testing.T) {
+	for _, tc := range []struct {
+		name, remoteAddr, xff, expected string
+	}{
+		{"IPv4", "192.0.2.17:1234", "", "192.0.2.0/24"},
+		{"IPv4 X-Forwarded-For", "10.0.0.1:1234", "198.51.100.42, 10.0.0.2", "198.51.100.0/24"},
+		{"IPv6", "[2001:db8:1:2:3:4:5:6]:1234", "", "2001:db8:1:2::/64"},
+		{"no IP", "", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/foo", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.xff != "" {
+				req.Header.Set("X-Forwarded-For", tc.xff)
+			}
+
+			if got := NewClientIPPrefixLookuper(24, 64).Lookup(req); got != tc.expected {
+				t.Errorf("Failed to lookup request, expected %q, got: %q", tc.expected, got)
+			}
+		})
+	}
+}
+
 func TestRoundRobinLookuper(t *testing.T) {
 	for _, tc := range []struct {
 		n, concurrency, iterations int
@@ -35,6 +35,10 @@ const (
 	oldestScoreSpanName = "redis_oldest_score"
 )
 
+// errNoRedis is returned by the limiters that depend on redis, when
+// the registry was created without the redis options.
+var errNoRedis = errors.New("redis is not configured")
+
 // newClusterRateLimiterRedis creates a new clusterLimitRedis for given
 // Settings. Group is used to identify the ratelimit instance, is used
 // in log messages and has to be the same in all skipper instances.
@@ -117,28 +121,49 @@ func (c *clusterLimitRedis) startSpan(ctx context.Context, spanName string) func
 //
 // If a context is provided, it uses it for creating an OpenTracing span.
 func (c *clusterLimitRedis) AllowContext(ctx context.Context, clearText string) bool {
+	allow, _, _ := c.AllowQuota(ctx, clearText)
+	return allow
+}
+
+// AllowQuota is like AllowContext, but it also returns the number of
+// calls allowed until the limit is reached, and the duration until the
+// current call leaves the time window. It uses the count of the calls
+// queried to decide to allow or not, so it does not need any
+// additional roundtrip. When the call is not allowed, the returned
+// duration is the time window, as an upper bound. When redis is not
+// available, it reports the full quota, the same way as it fails open.
+func (c *clusterLimitRedis) AllowQuota(ctx context.Context, clearText string) (bool, int, time.Duration) {
 	c.metrics.IncCounter(redisMetricsPrefix + "total")
 	now := time.Now()
 	finishSpan := c.startSpan(ctx, allowSpanName)
 
-	allow, err := c.allow(ctx, clearText)
+	allow, count, err := c.allow(ctx, clearText)
 	failed := err != nil
 
 	finishSpan(failed)
 	c.measureQuery(allowMetricsFormat, allowMetricsFormatWithGroup, &failed, now)
 
+	remaining, reset := int(c.maxHits-count), c.window
 	if failed {
 		allow = true // fail open
+		remaining, reset = int(c.maxHits), 0
 	}
 	if allow {
 		c.metrics.IncCounter(redisMetricsPrefix + "allows")
 	} else {
 		c.metrics.IncCounter(redisMetricsPrefix + "forbids")
 	}
-	return allow
+
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return allow, remaining, reset
 }
 
-func (c *clusterLimitRedis) allow(ctx context.Context, clearText string) (bool, error) {
+// allow returns the number of calls in the time window, including the
+// current one, when it was allowed.
+func (c *clusterLimitRedis) allow(ctx context.Context, clearText string) (bool, int64, error) {
 	s := getHashedKey(clearText)
 	key := c.prefixKey(s)
 
@@ -149,31 +174,31 @@ func (c *clusterLimitRedis) allow(ctx context.Context, clearText string) (bool,
 	// drop all elements of the set which occurred before one interval ago.
 	_, err := c.ringClient.ZRemRangeByScore(ctx, key, 0.0, float64(clearBefore))
 	if err != nil {
-		return false, err
+		return false, 0, err
 	}
 
 	// get cardinality
 	count, err := c.ringClient.ZCard(ctx, key)
 	if err != nil {
-		return false, err
+		return false, 0, err
 	}
 
 	// we increase later with ZAdd, so max-1
 	if count >= c.maxHits {
-		return false, nil
+		return false, count, nil
 	}
 
 	_, err = c.ringClient.ZAdd(ctx, key, nowNanos, float64(nowNanos))
 	if err != nil {
-		return false, err
+		return false, 0, err
 	}
 
 	_, err = c.ringClient.Expire(ctx, key, c.window+time.Second)
 	if err != nil {
-		return false, err
+		return false, 0, err
 	}
 
-	return true, nil
+	return true, count + 1, nil
 }
 
 // Allow is like AllowContext, but not using a context.
@@ -214,35 +239,36 @@ func (c *clusterLimitRedis) Delta(clearText string) time.Duration {
 func (c *clusterLimitRedis) oldest(ctx context.Context, clearText string) (time.Time, error) {
 	s := getHashedKey(clearText)
 	key := c.prefixKey(s)
-	now := time.Now()
 
 	finishSpan := c.startSpan(ctx, oldestScoreSpanName)
-	res, err := c.ringClient.ZRangeByScoreWithScoresFirst(ctx, key, 0.0, float64(now.UnixNano()), 0, 1)
+	oldest, err := c.member(ctx, key, time.Now(), 0)
+	finishSpan(err != nil)
+	return oldest, err
+}
 
+// member returns the time of the call at the offset in the order of the
+// calls.
+func (c *clusterLimitRedis) member(ctx context.Context, key string, now time.Time, offset int64) (time.Time, error) {
+	res, err := c.ringClient.ZRangeByScoreWithScoresFirst(ctx, key, 0.0, float64(now.UnixNano()), offset, 1)
 	if err != nil {
-		finishSpan(true)
 		return time.Time{}, err
 	}
 
 	if res == nil {
-		finishSpan(false)
 		return time.Time{}, nil
 	}
 
 	s, ok := res.(string)
 	if !ok {
-		finishSpan(true)
 		return time.Time{}, errors.New("failed to evaluate redis data")
 	}
 
-	oldest, err := strconv.ParseInt(s, 10, 64)
+	t, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
-		finishSpan(true)
 		return time.Time{}, fmt.Errorf("failed to convert value to int64: %w", err)
 	}
 
-	finishSpan(false)
-	return time.Unix(0, oldest), nil
+	return time.Unix(0, t), nil
 }
 
 // Oldest returns the oldest known request time.
@@ -291,6 +291,76 @@ func Test_clusterLimitRedis_Oldest(t *testing.T) {
 	}
 }
 
+func Test_clusterLimitRedis_AllowQuota(t *testing.T) {
+	redisAddr, done := redistest.NewTestRedis(t)
+	defer done()
+
+	clusterClientlimit := Settings{
+		Type:       ClusterClientRatelimit,
+		Lookuper:   NewHeaderLookuper("X-Test"),
+		MaxHits:    10,
+		TimeWindow: time.Minute,
+		Group:      "A",
+	}
+
+	tests := []struct {
+		name          string
+		iterations    int
+		wantAllow     bool
+		wantRemaining int
+	}{
+		{
+			name:          "no calls",
+			iterations:    0,
+			wantAllow:     true,
+			wantRemaining: 9,
+		},
+		{
+			name:          "some calls",
+			iterations:    3,
+			wantAllow:     true,
+			wantRemaining: 6,
+		},
+		{
+			name:          "over the limit",
+			iterations:    12,
+			wantAllow:     false,
+			wantRemaining: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ringClient := net.NewRedisRingClient(&net.RedisOptions{Addrs: []string{redisAddr}})
+			defer ringClient.Close()
+			c := newClusterRateLimiterRedis(
+				clusterClientlimit,
+				ringClient,
+				clusterClientlimit.Group,
+			)
+
+			client := "client-" + tt.name
+			for i := 0; i < tt.iterations; i++ {
+				_ = c.Allow(client)
+			}
+
+			allow, remaining, reset := c.AllowQuota(context.Background(), client)
+			if allow != tt.wantAllow {
+				t.Errorf("clusterLimitRedis.AllowQuota() allow = %v, want %v", allow, tt.wantAllow)
+			}
+
+			if remaining != tt.wantRemaining {
+				t.Errorf("clusterLimitRedis.AllowQuota() remaining = %d, want %d", remaining, tt.wantRemaining)
+			}
+
+			if reset != time.Minute {
+				t.Errorf("clusterLimitRedis.AllowQuota() unexpected reset = %v", reset)
+			}
+		})
+	}
+}
+
 func Test_clusterLimitRedis_RetryAfter(t *testing.T) {
 	redisAddr, done := redistest.NewTestRedis(t)
 	defer done()
@@ -50,14 +50,17 @@ func NewSwarmRegistry(swarm Swarmer, ro *net.RedisOptions, settings ...Settings)
 	}
 
 	r := &Registry{
-		once:      sync.Once{},
-		defaults:  defaults,
-		global:    defaults,
-		lookup:    make(map[Settings]*Ratelimit),
-		swarm:     swarm,
-		redisRing: net.NewRedisRingClient(ro),
+		once:     sync.Once{},
+		defaults: defaults,
+		global:   defaults,
+		lookup:   make(map[Settings]*Ratelimit),
+		swarm:    swarm,
 	}
+
+	// without redis options the ring stays nil, so that the limiters
+	// depending on redis can fall back
 	if ro != nil {
+		r.redisRing = net.NewRedisRingClient(ro)
 		r.redisRing.StartMetricsCollection()
 	}
 
@@ -72,7 +75,9 @@ func NewSwarmRegistry(swarm Swarmer, ro *net.RedisOptions, settings ...Settings)
 func (r *Registry) Close() {
 	r.once.Do(func() {
 		r.closed = true
-		r.redisRing.Close()
+		if r.redisRing != nil {
+			r.redisRing.Close()
+		}
 	})
 }
 
@@ -121,7 +126,7 @@ func (r *Registry) Check(req *http.Request) (Settings, int) {
 	case LocalRatelimit:
 		log.Warning("LocalRatelimit is deprecated, please use ClientRatelimit instead")
 		fallthrough
-	case ClusterClientRatelimit:
+	case ClusterClientRatelimit, TokenBucketRatelimit, ClusterTokenBucketRatelimit:
 		fallthrough
 	case ClientRatelimit:
 		ip := net.RemoteHost(req)
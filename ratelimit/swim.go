@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"context"
 	"math"
 	"time"
 
@@ -128,6 +129,28 @@ func (c *clusterLimitSwim) calcTotalRequestRate(now int64, swarmValues map[strin
 	return requestRate
 }
 
+// Quota returns the number of calls allowed until the cluster wide
+// limit is reached, based on the same rate as Allow, and the duration
+// until the most recent local call leaves the time window.
+func (c *clusterLimitSwim) Quota(_ context.Context, clearText string) (int, time.Duration) {
+	s := getHashedKey(clearText)
+	key := swarmPrefix + c.group + "." + s
+
+	now := time.Now().UTC().UnixNano()
+	rate := c.calcTotalRequestRate(now, c.swarm.Values(key))
+	remaining := c.maxHits - int(math.Ceil(rate))
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	var reset time.Duration
+	if l, ok := c.local.(interface{ Current(string) time.Time }); ok {
+		reset = resetAfter(l.Current(s), c.window, time.Now())
+	}
+
+	return remaining, reset
+}
+
 // Close should be called to teardown the clusterLimitSwim.
 func (c *clusterLimitSwim) Close() {
 	close(c.quit)
@@ -0,0 +1,381 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/net"
+)
+
+const (
+	tokenBucketRedisKeyPrefix = "tkb."
+	tokenBucketMetricPrefix   = "tokenbucket.redis."
+	tokenBucketMetricLatency  = tokenBucketMetricPrefix + "latency"
+	tokenBucketSpanName       = "redis_tokenbucket"
+)
+
+// gcra holds the parameters of the generic cell rate algorithm, which
+// is equivalent to a token bucket of Burst tokens, refilled with
+// MaxHits tokens per TimeWindow.
+//
+// See https://en.wikipedia.org/wiki/Generic_cell_rate_algorithm
+type gcra struct {
+	// emission is the time to refill a single token
+	emission time.Duration
+
+	// tolerance is the time to refill the bucket, when it has only
+	// one token left
+	tolerance time.Duration
+}
+
+func newGCRA(s Settings) (gcra, error) {
+	switch {
+	case s.MaxHits <= 0:
+		return gcra{}, fmt.Errorf("invalid token bucket max hits: %d", s.MaxHits)
+	case s.TimeWindow <= 0:
+		return gcra{}, fmt.Errorf("invalid token bucket time window: %v", s.TimeWindow)
+	case s.Burst < 0:
+		return gcra{}, fmt.Errorf("invalid token bucket burst: %d", s.Burst)
+	}
+
+	emission := s.TimeWindow / time.Duration(s.MaxHits)
+	if emission <= 0 {
+		return gcra{}, fmt.Errorf("token bucket time window %v is too short for %d max hits", s.TimeWindow, s.MaxHits)
+	}
+
+	burst := s.Burst
+	if burst == 0 {
+		burst = s.MaxHits
+	}
+
+	return gcra{
+		emission:  emission,
+		tolerance: time.Duration(burst-1) * emission,
+	}, nil
+}
+
+// delta returns the duration until the next token is available, based
+// on the theoretical arrival time of the next request. Negative values
+// mean that a token is available.
+func (g gcra) delta(tat, now time.Time) time.Duration {
+	if tat.Before(now) {
+		tat = now
+	}
+
+	return tat.Add(-g.tolerance).Sub(now)
+}
+
+// quota returns the available tokens and the duration until the bucket
+// is full, based on the duration until the next token is available.
+func (g gcra) quota(delta time.Duration) (int, time.Duration) {
+	remaining := int((g.emission - delta) / g.emission)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	reset := delta + g.tolerance
+	if reset < 0 {
+		reset = 0
+	}
+
+	return remaining, reset
+}
+
+// retryAfter rounds the delta up to seconds, so that the clients don't
+// retry before the next token is available.
+func retryAfter(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// tokenBucket implements the limiter interface with an instance local
+// token bucket per key.
+type tokenBucket struct {
+	gcra
+	mu   sync.Mutex
+	tats map[string]time.Time
+	now  func() time.Time
+	quit chan struct{}
+	once sync.Once
+}
+
+func newTokenBucket(s Settings, now func() time.Time) (*tokenBucket, error) {
+	g, err := newGCRA(s)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &tokenBucket{
+		gcra: g,
+		tats: make(map[string]time.Time),
+		now:  now,
+		quit: make(chan struct{}),
+	}
+
+	cleanInterval := s.CleanInterval
+	if cleanInterval <= 0 {
+		cleanInterval = s.TimeWindow
+	}
+
+	go b.cleanup(cleanInterval)
+	return b, nil
+}
+
+// cleanup drops the full buckets, because they are equivalent to the
+// missing ones.
+func (b *tokenBucket) cleanup(d time.Duration) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			b.mu.Lock()
+			now := b.now()
+			for key, tat := range b.tats {
+				if !tat.After(now) {
+					delete(b.tats, key)
+				}
+			}
+
+			b.mu.Unlock()
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// Allow takes a token from the bucket identified by the key, and returns
+// true if it was available.
+func (b *tokenBucket) Allow(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	tat := b.tats[key]
+	if b.delta(tat, now) > 0 {
+		return false
+	}
+
+	if tat.Before(now) {
+		tat = now
+	}
+
+	b.tats[key] = tat.Add(b.emission)
+	return true
+}
+
+// Close stops the cleanup of the buckets.
+func (b *tokenBucket) Close() {
+	b.once.Do(func() { close(b.quit) })
+}
+
+// Delta returns the duration until the next token is available in the
+// bucket identified by the key.
+func (b *tokenBucket) Delta(key string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.delta(b.tats[key], b.now())
+}
+
+// Oldest returns the time when the bucket identified by the key was last
+// full.
+func (b *tokenBucket) Oldest(key string) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tat, ok := b.tats[key]
+	if !ok {
+		return time.Time{}
+	}
+
+	return tat.Add(-b.tolerance - b.emission)
+}
+
+// Resize is noop to implement the limiter interface
+func (*tokenBucket) Resize(string, int) {}
+
+// RetryAfter returns the seconds until the next token is available in
+// the bucket identified by the key.
+func (b *tokenBucket) RetryAfter(key string) int {
+	return retryAfter(b.Delta(key))
+}
+
+// Quota returns the available tokens and the duration until the bucket
+// identified by the key is full.
+func (b *tokenBucket) Quota(_ context.Context, key string) (int, time.Duration) {
+	return b.quota(b.Delta(key))
+}
+
+// Implements the generic cell rate algorithm as a Redis lua script, the
+// same way as the leaky bucket.
+//
+//go:embed tokenbucket.lua
+var tokenBucketScript string
+
+// clusterTokenBucket implements the limiter interface with a token
+// bucket per key, shared by the skipper instances via Redis.
+type clusterTokenBucket struct {
+	gcra
+	labelPrefix string
+	script      *net.RedisScript
+	ringClient  *net.RedisRingClient
+	metrics     metrics.Metrics
+	now         func() time.Time
+}
+
+func newClusterTokenBucket(s Settings, ringClient *net.RedisRingClient, now func() time.Time) (*clusterTokenBucket, error) {
+	g, err := newGCRA(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusterTokenBucket{
+		gcra:        g,
+		labelPrefix: fmt.Sprintf("%s-%v-%v-", s.Group, g.emission, g.tolerance),
+		script:      ringClient.NewScript(tokenBucketScript),
+		ringClient:  ringClient,
+		metrics:     metrics.Default,
+		now:         now,
+	}, nil
+}
+
+func (b *clusterTokenBucket) getBucketId(label string) string {
+	return tokenBucketRedisKeyPrefix + getHashedKey(b.labelPrefix+label)
+}
+
+func (b *clusterTokenBucket) startSpan(ctx context.Context) (span opentracing.Span) {
+	parent := opentracing.SpanFromContext(ctx)
+	if parent != nil {
+		span = b.ringClient.StartSpan(tokenBucketSpanName, opentracing.ChildOf(parent.Context()))
+	} else {
+		span = opentracing.NoopTracer{}.StartSpan("")
+	}
+	ext.Component.Set(span, "skipper")
+	ext.SpanKind.Set(span, "client")
+	return
+}
+
+// run returns the duration until the next token is available, and takes
+// a token when it is available and take is set. It returns true, when
+// it took a token. Negative durations mean that tokens are available,
+// and tell how much earlier the next token was available.
+func (b *clusterTokenBucket) run(ctx context.Context, label string, take bool) (bool, time.Duration, error) {
+	now := b.now()
+	span := b.startSpan(ctx)
+	defer span.Finish()
+	defer b.metrics.MeasureSince(tokenBucketMetricLatency, now)
+
+	var t int
+	if take {
+		t = 1
+	}
+
+	r, err := b.ringClient.RunScript(ctx, b.script,
+		[]string{b.getBucketId(label)},
+		b.emission.Microseconds(),
+		b.tolerance.Microseconds(),
+		now.UnixMicro(),
+		t,
+	)
+	if err == nil {
+		if res, ok := r.([]interface{}); ok && len(res) == 2 {
+			taken, ok1 := res[0].(int64)
+			d, ok2 := res[1].(int64)
+			if ok1 && ok2 {
+				return taken == 1, -time.Duration(d) * time.Microsecond, nil
+			}
+		}
+
+		err = errors.New("failed to evaluate redis data")
+	}
+
+	ext.Error.Set(span, true)
+	return false, 0, err
+}
+
+// AllowContext takes a token from the bucket identified by the key, and
+// returns true if it was available. It fails open, when Redis is not
+// available.
+func (b *clusterTokenBucket) AllowContext(ctx context.Context, key string) bool {
+	allow, _, _ := b.AllowQuota(ctx, key)
+	return allow
+}
+
+// AllowQuota is like AllowContext, but it also returns the available
+// tokens and the duration until the bucket is full, after taking the
+// token, without an additional query.
+func (b *clusterTokenBucket) AllowQuota(ctx context.Context, key string) (bool, int, time.Duration) {
+	allow, d, err := b.run(ctx, key, true)
+	if err != nil {
+		log.Errorf("Failed to take a token from the cluster token bucket: %v", err)
+		allow, d = true, -b.tolerance
+	}
+
+	remaining, reset := b.quota(d)
+	return allow, remaining, reset
+}
+
+// Allow is like AllowContext, but not using a context.
+func (b *clusterTokenBucket) Allow(key string) bool {
+	return b.AllowContext(context.Background(), key)
+}
+
+// Close can not decide to teardown redis ring, because it is not the
+// owner of it.
+func (*clusterTokenBucket) Close() {}
+
+// Delta returns the duration until the next token is available in the
+// bucket identified by the key.
+func (b *clusterTokenBucket) Delta(key string) time.Duration {
+	_, d, err := b.run(context.Background(), key, false)
+	if err != nil {
+		log.Errorf("Failed to get the duration until the next token is available: %v", err)
+		return 0
+	}
+
+	// a negative value means that tokens are available
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+// Quota returns the available tokens and the duration until the bucket
+// identified by the key is full. When Redis is not available, it
+// reports a full bucket, the same way as AllowContext fails open.
+func (b *clusterTokenBucket) Quota(ctx context.Context, key string) (int, time.Duration) {
+	_, d, err := b.run(ctx, key, false)
+	if err != nil {
+		log.Errorf("Failed to get the available tokens of the cluster token bucket: %v", err)
+		d = -b.tolerance
+	}
+
+	return b.quota(d)
+}
+
+// Oldest is not supported by the cluster token bucket, it returns the
+// zero time.
+func (*clusterTokenBucket) Oldest(string) time.Time { return time.Time{} }
+
+// Resize is noop to implement the limiter interface
+func (*clusterTokenBucket) Resize(string, int) {}
+
+// RetryAfter returns the seconds until the next token is available in
+// the bucket identified by the key.
+func (b *clusterTokenBucket) RetryAfter(key string) int {
+	return retryAfter(b.Delta(key))
+}
@@ -0,0 +1,32 @@
+local bucket_id = KEYS[1]           -- bucket id
+local emission = tonumber(ARGV[1])  -- time to refill one token in microseconds (emission > 0)
+local tolerance = tonumber(ARGV[2]) -- burst tolerance in microseconds, (burst - 1) * emission
+local now = tonumber(ARGV[3])       -- current time in microseconds (now >= 0)
+local take = tonumber(ARGV[4])      -- 1 to take a token when available, 0 to only check
+
+-- Redis stores the theoretical arrival time (TAT) of the next request, as defined by the
+-- generic cell rate algorithm (GCRA). This is equivalent to a token bucket, where the bucket
+-- is full when the TAT is in the past.
+-- The timestamp is stored in microseconds (and not nanoseconds) to keep values below 2^53.
+local tat = redis.call("GET", bucket_id)
+if not tat then
+    tat = now
+else
+    tat = tonumber(tat)
+    if tat < now then
+        tat = now
+    end
+end
+
+-- The request is allowed when the bucket has at least one token. Besides whether a token was
+-- taken, the time since the next token is available is returned, which tells how many tokens
+-- are in the bucket, or, as a negative number, the time to wait for the next token.
+local allow_at = tat - tolerance
+if allow_at > now or take == 0 then
+    return {0, now - allow_at}
+end
+
+tat = tat + emission
+redis.call("SET", bucket_id, tat, "PX", math.ceil((tat - now) / 1000))
+
+return {1, now - (tat - tolerance)}
@@ -0,0 +1,256 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/net/redistest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tokenAttempt struct {
+	tplus     int
+	allowed   bool
+	retry     int
+	remaining int
+}
+
+// 10 requests per minute with bursts of 3 requests
+var tokenBucketAttempts = []tokenAttempt{
+	// initial burst empties the bucket
+	{+0, true, 0, 2},
+	{+0, true, 0, 1},
+	{+1, true, 0, 0},
+	// the bucket is empty, one token is refilled in every 6s
+	{+1, false, 5, 0},
+	{+2, false, 4, 0},
+	{+6, true, 0, 0},
+	{+6, false, 6, 0},
+	// the bucket is full again after 18s
+	{+30, true, 0, 2},
+	{+30, true, 0, 1},
+	{+30, true, 0, 0},
+	{+30, false, 6, 0},
+	{+35, false, 1, 0},
+	{+36, true, 0, 0},
+}
+
+func verifyTokenBucket(t *testing.T, l quotaLimiter, now *time.Time, attempts []tokenAttempt) {
+	t0 := *now
+	for _, a := range attempts {
+		*now = t0.Add(time.Duration(a.tplus) * time.Second)
+		if allowed := l.Allow("alabel"); allowed != a.allowed {
+			t.Errorf("error at %+d: allowed mismatch, expected %v, got %v", a.tplus, a.allowed, allowed)
+		}
+
+		if retry := l.RetryAfter("alabel"); !a.allowed && retry != a.retry {
+			t.Errorf("error at %+d: retry mismatch, expected %v, got %v", a.tplus, a.retry, retry)
+		}
+
+		if remaining, _ := l.Quota(context.Background(), "alabel"); remaining != a.remaining {
+			t.Errorf("error at %+d: remaining mismatch, expected %v, got %v", a.tplus, a.remaining, remaining)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b, err := newTokenBucket(Settings{
+		Type:       TokenBucketRatelimit,
+		MaxHits:    10,
+		TimeWindow: time.Minute,
+		Burst:      3,
+	}, func() time.Time { return now })
+	require.NoError(t, err)
+	defer b.Close()
+
+	verifyTokenBucket(t, b, &now, tokenBucketAttempts)
+}
+
+func TestTokenBucketDefaultBurst(t *testing.T) {
+	now := time.Now()
+	b, err := newTokenBucket(Settings{
+		Type:       TokenBucketRatelimit,
+		MaxHits:    2,
+		TimeWindow: time.Minute,
+	}, func() time.Time { return now })
+	require.NoError(t, err)
+	defer b.Close()
+
+	verifyTokenBucket(t, b, &now, []tokenAttempt{
+		{+0, true, 0, 1},
+		{+0, true, 0, 0},
+		{+0, false, 30, 0},
+		{+30, true, 0, 0},
+		{+30, false, 30, 0},
+	})
+}
+
+func TestTokenBucketIndependentKeys(t *testing.T) {
+	b, err := newTokenBucket(Settings{
+		Type:       TokenBucketRatelimit,
+		MaxHits:    1,
+		TimeWindow: time.Hour,
+	}, time.Now)
+	require.NoError(t, err)
+	defer b.Close()
+
+	assert.True(t, b.Allow("foo"))
+	assert.False(t, b.Allow("foo"))
+	assert.True(t, b.Allow("bar"))
+
+	assert.Equal(t, 3600, b.RetryAfter("foo"))
+	assert.Equal(t, 0, b.RetryAfter("baz"))
+	assert.True(t, b.Delta("baz") <= 0)
+}
+
+func TestTokenBucketCleanup(t *testing.T) {
+	now := time.Now()
+	b, err := newTokenBucket(Settings{
+		Type:          TokenBucketRatelimit,
+		MaxHits:       10,
+		TimeWindow:    10 * time.Millisecond,
+		CleanInterval: time.Millisecond,
+	}, func() time.Time { return now })
+	require.NoError(t, err)
+	defer b.Close()
+
+	b.Allow("foo")
+
+	b.mu.Lock()
+	now = now.Add(time.Second)
+	b.mu.Unlock()
+
+	assert.Eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.tats) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestClusterTokenBucket(t *testing.T) {
+	redisAddr, done := redistest.NewTestRedis(t)
+	defer done()
+
+	ringClient := net.NewRedisRingClient(
+		&net.RedisOptions{
+			Addrs: []string{redisAddr},
+		},
+	)
+	defer ringClient.Close()
+
+	now := time.Now()
+	b, err := newClusterTokenBucket(Settings{
+		Type:       ClusterTokenBucketRatelimit,
+		MaxHits:    10,
+		TimeWindow: time.Minute,
+		Burst:      3,
+		Group:      "agroup",
+	}, ringClient, func() time.Time { return now })
+	require.NoError(t, err)
+
+	verifyTokenBucket(t, b, &now, tokenBucketAttempts)
+}
+
+func TestClusterTokenBucketAllowQuota(t *testing.T) {
+	redisAddr, done := redistest.NewTestRedis(t)
+	defer done()
+
+	ringClient := net.NewRedisRingClient(
+		&net.RedisOptions{
+			Addrs: []string{redisAddr},
+		},
+	)
+	defer ringClient.Close()
+
+	now := time.Now()
+	b, err := newClusterTokenBucket(Settings{
+		Type:       ClusterTokenBucketRatelimit,
+		MaxHits:    10,
+		TimeWindow: time.Minute,
+		Burst:      3,
+		Group:      "agroup",
+	}, ringClient, func() time.Time { return now })
+	require.NoError(t, err)
+
+	t0 := now
+	for _, a := range tokenBucketAttempts {
+		now = t0.Add(time.Duration(a.tplus) * time.Second)
+		allowed, remaining, _ := b.AllowQuota(context.Background(), "alabel")
+		if allowed != a.allowed {
+			t.Errorf("error at %+d: allowed mismatch, expected %v, got %v", a.tplus, a.allowed, allowed)
+		}
+
+		if remaining != a.remaining {
+			t.Errorf("error at %+d: remaining mismatch, expected %v, got %v", a.tplus, a.remaining, remaining)
+		}
+	}
+}
+
+func TestClusterTokenBucketRedisError(t *testing.T) {
+	ringClient := net.NewRedisRingClient(
+		&net.RedisOptions{
+			Addrs: []string{"no-such-host.test:123"},
+		},
+	)
+	defer ringClient.Close()
+
+	b, err := newClusterTokenBucket(Settings{
+		Type:       ClusterTokenBucketRatelimit,
+		MaxHits:    1,
+		TimeWindow: time.Minute,
+	}, ringClient, time.Now)
+	require.NoError(t, err)
+
+	// fails open
+	assert.True(t, b.AllowContext(context.Background(), "alabel"))
+	assert.Equal(t, 0, b.RetryAfter("alabel"))
+
+	remaining, _ := b.Quota(context.Background(), "alabel")
+	assert.Equal(t, 1, remaining)
+
+	allowed, remaining, _ := b.AllowQuota(context.Background(), "alabel")
+	assert.True(t, allowed)
+	assert.Equal(t, 1, remaining)
+}
+
+func TestClusterTokenBucketId(t *testing.T) {
+	const label = "alabel"
+
+	s := Settings{Type: ClusterTokenBucketRatelimit, MaxHits: 10, TimeWindow: time.Minute, Group: "a"}
+	b1, err := newClusterTokenBucket(s, nil, time.Now)
+	require.NoError(t, err)
+
+	s.Burst = 5
+	b2, err := newClusterTokenBucket(s, nil, time.Now)
+	require.NoError(t, err)
+
+	s.Burst = 0
+	s.Group = "b"
+	b3, err := newClusterTokenBucket(s, nil, time.Now)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, b1.getBucketId(label), b2.getBucketId(label))
+	assert.NotEqual(t, b1.getBucketId(label), b3.getBucketId(label))
+}
+
+func TestTokenBucketInvalidSettings(t *testing.T) {
+	for _, s := range []Settings{
+		{MaxHits: 0, TimeWindow: time.Minute},
+		{MaxHits: -1, TimeWindow: time.Minute},
+		{MaxHits: 10, TimeWindow: 0},
+		{MaxHits: 10, TimeWindow: time.Minute, Burst: -1},
+		{MaxHits: 10, TimeWindow: 5 * time.Nanosecond},
+	} {
+		_, err := newGCRA(s)
+		assert.Error(t, err, "%v", s)
+	}
+
+	// falls back to not limiting, instead of panicking
+	r := newRatelimit(Settings{Type: TokenBucketRatelimit, MaxHits: 10, TimeWindow: 5 * time.Nanosecond}, nil, nil)
+	assert.True(t, r.Allow("foo"))
+}
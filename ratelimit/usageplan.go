@@ -0,0 +1,410 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/net"
+)
+
+const (
+	usagePlanRedisKeyPrefix = "plan."
+	usagePlanMetricPrefix   = "usageplan.redis."
+	usagePlanMetricLatency  = usagePlanMetricPrefix + "latency"
+	usagePlanSpanName       = "redis_usageplan"
+
+	defaultPlansUpdateInterval = time.Minute
+)
+
+// Plan defines the limits of a usage plan tier. The zero value of any
+// limit means that it is not limited.
+type Plan struct {
+	// MaxHits is the number of requests allowed in TimeWindow
+	MaxHits int `yaml:"max-hits"`
+
+	// TimeWindow is the time window of the rate limit, e.g. 1s
+	TimeWindow time.Duration `yaml:"time-window"`
+
+	// Daily is the number of requests allowed per day, in UTC
+	Daily int64 `yaml:"daily"`
+
+	// Monthly is the number of requests allowed per month, in UTC
+	Monthly int64 `yaml:"monthly"`
+}
+
+// Plans maps the consumers to the usage plans. The consumers without
+// a plan get the default plan.
+//
+// Example:
+//
+//	default: free
+//	plans:
+//	  free:
+//	    max-hits: 10
+//	    time-window: 1s
+//	    daily: 1000
+//	  gold:
+//	    max-hits: 100
+//	    time-window: 1s
+//	    monthly: 10000000
+//	consumers:
+//	  client-a: gold
+type Plans struct {
+	Default   string            `yaml:"default"`
+	Plans     map[string]Plan   `yaml:"plans"`
+	Consumers map[string]string `yaml:"consumers"`
+}
+
+func (p *Plans) validate() error {
+	if _, ok := p.Plans[p.Default]; !ok {
+		return fmt.Errorf("default plan not found: %q", p.Default)
+	}
+
+	for name, plan := range p.Plans {
+		if plan.MaxHits < 0 || plan.Daily < 0 || plan.Monthly < 0 {
+			return fmt.Errorf("negative limit in plan: %q", name)
+		}
+
+		if plan.MaxHits > 0 && plan.TimeWindow <= 0 {
+			return fmt.Errorf("missing time window in plan: %q", name)
+		}
+	}
+
+	for consumer, name := range p.Consumers {
+		if _, ok := p.Plans[name]; !ok {
+			return fmt.Errorf("plan %q of consumer %q not found", name, consumer)
+		}
+	}
+
+	return nil
+}
+
+// plan returns the plan of the consumer and its name.
+func (p *Plans) plan(consumer string) (string, Plan) {
+	name, ok := p.Consumers[consumer]
+	if !ok {
+		name = p.Default
+	}
+
+	return name, p.Plans[name]
+}
+
+// PlanSource is used to load the usage plans, e.g. from a file.
+type PlanSource interface {
+	LoadPlans() (*Plans, error)
+}
+
+// PlanFile implements PlanSource, and loads the usage plans from a YAML
+// file.
+type PlanFile string
+
+// LoadPlans reads and parses the file.
+func (f PlanFile) LoadPlans() (*Plans, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+
+	var p Plans
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse usage plans: %w", err)
+	}
+
+	return &p, nil
+}
+
+// Implements the daily and monthly quotas as a Redis lua script, so
+// that the rejected requests are not counted.
+//
+//go:embed usageplan.lua
+var usagePlanScript string
+
+// UsagePlans limits the requests of the consumers according to their
+// usage plans. The rate limits of the plans are calculated across the
+// skipper instances, the same way as the cluster client ratelimits, and
+// the daily and monthly quotas are stored in Redis. The plans are
+// reloaded from the source periodically.
+type UsagePlans struct {
+	registry   *Registry
+	source     PlanSource
+	script     *net.RedisScript
+	ringClient *net.RedisRingClient
+	metrics    metrics.Metrics
+	now        func() time.Time
+
+	mu    sync.RWMutex
+	plans *Plans
+
+	quit chan struct{}
+	once sync.Once
+}
+
+// NewUsagePlans creates UsagePlans, using the ratelimits and the Redis
+// client of the registry. It loads the plans from the source every d
+// interval, keeping the previous plans when it fails. It returns an
+// error, when the initial plans cannot be loaded. Without Redis, the
+// daily and monthly quotas of the plans are not limited.
+func NewUsagePlans(r *Registry, source PlanSource, d time.Duration) (*UsagePlans, error) {
+	return newUsagePlans(r, source, d, time.Now)
+}
+
+func newUsagePlans(r *Registry, source PlanSource, d time.Duration, now func() time.Time) (*UsagePlans, error) {
+	u := &UsagePlans{
+		registry:   r,
+		source:     source,
+		ringClient: r.redisRing,
+		metrics:    metrics.Default,
+		now:        now,
+		quit:       make(chan struct{}),
+	}
+
+	if u.ringClient != nil {
+		u.script = u.ringClient.NewScript(usagePlanScript)
+	}
+
+	if err := u.update(); err != nil {
+		return nil, err
+	}
+
+	if u.ringClient == nil {
+		log.Warn("Usage plans without redis, the daily and monthly quotas will not be limited")
+	}
+
+	if d <= 0 {
+		d = defaultPlansUpdateInterval
+	}
+
+	go u.run(d)
+	return u, nil
+}
+
+func (u *UsagePlans) update() error {
+	p, err := u.source.LoadPlans()
+	if err != nil {
+		return err
+	}
+
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	u.plans = p
+	u.mu.Unlock()
+	return nil
+}
+
+func (u *UsagePlans) run(d time.Duration) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := u.update(); err != nil {
+				log.Errorf("Failed to update the usage plans, keeping the previous ones: %v", err)
+			}
+		case <-u.quit:
+			return
+		}
+	}
+}
+
+// Close stops the updates of the plans.
+func (u *UsagePlans) Close() {
+	u.once.Do(func() { close(u.quit) })
+}
+
+// Plan returns the name and the limits of the plan of the consumer.
+func (u *UsagePlans) Plan(consumer string) (string, Plan) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.plans.plan(consumer)
+}
+
+func rateSettings(group, name string, p Plan) Settings {
+	return Settings{
+		Type:          ClusterClientRatelimit,
+		Group:         usagePlanRedisKeyPrefix + group + "." + name,
+		MaxHits:       p.MaxHits,
+		TimeWindow:    p.TimeWindow,
+		CleanInterval: 10 * p.TimeWindow,
+	}
+}
+
+// AllowContext returns true, when the request of the consumer is
+// allowed by all the limits of its plan. Otherwise, it returns the
+// seconds until the next request can be allowed. It also returns the
+// quota of the most restrictive limit, with the policies of all of
+// them. The group identifies the counters of the consumers, and it can
+// span one or more routes. The requests rejected by the rate are not
+// counted in the daily and monthly quotas, and the requests rejected by
+// the quotas are not counted in the rate. It fails open, when Redis is
+// not available, and it skips the daily and monthly quotas, when it is
+// not configured.
+func (u *UsagePlans) AllowContext(ctx context.Context, group, consumer string) (bool, int, Quota) {
+	name, p := u.Plan(consumer)
+	checkQuota := (p.Daily > 0 || p.Monthly > 0) && u.ringClient != nil
+
+	var quotas []Quota
+	if checkQuota {
+		allowed, retry, qs := u.allowQuota(ctx, group, consumer, p, 1)
+		if !allowed {
+			return false, retry, mostRestrictive(qs)
+		}
+
+		quotas = qs
+	}
+
+	if p.MaxHits > 0 {
+		rl := u.registry.Get(rateSettings(group, name, p))
+		allowed, q, ok := rl.AllowQuota(ctx, consumer)
+		if !allowed {
+			if checkQuota {
+				u.allowQuota(ctx, group, consumer, p, -1)
+			}
+
+			q.Remaining = 0
+			return false, rl.RetryAfter(consumer), q
+		}
+
+		if ok {
+			quotas = append([]Quota{q}, quotas...)
+		}
+	}
+
+	return true, 0, mostRestrictive(quotas)
+}
+
+func mostRestrictive(quotas []Quota) Quota {
+	if len(quotas) == 0 {
+		return Quota{}
+	}
+
+	q := quotas[0]
+	policies := []string{q.Policy}
+	for _, qi := range quotas[1:] {
+		if qi.Remaining < q.Remaining {
+			q = qi
+		}
+
+		policies = append(policies, qi.Policy)
+	}
+
+	q.Policy = strings.Join(policies, ", ")
+	return q
+}
+
+func (u *UsagePlans) startSpan(ctx context.Context) (span opentracing.Span) {
+	parent := opentracing.SpanFromContext(ctx)
+	if parent != nil {
+		span = u.ringClient.StartSpan(usagePlanSpanName, opentracing.ChildOf(parent.Context()))
+	} else {
+		span = opentracing.NoopTracer{}.StartSpan("")
+	}
+	ext.Component.Set(span, "skipper")
+	ext.SpanKind.Set(span, "client")
+	return
+}
+
+// allowQuota counts the request in the daily and monthly quotas, when
+// the increment is 1 and both of them allow it, or refunds a counted
+// request, when the increment is -1.
+func (u *UsagePlans) allowQuota(ctx context.Context, group, consumer string, p Plan, increment int) (bool, int, []Quota) {
+	now := u.now().UTC()
+	span := u.startSpan(ctx)
+	defer span.Finish()
+	defer u.metrics.MeasureSince(usagePlanMetricLatency, now)
+
+	y, m, d := now.Date()
+	dayEnd := time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	monthEnd := time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+	untilDayEnd, untilMonthEnd := dayEnd.Sub(now), monthEnd.Sub(now)
+
+	// the hash tag puts the counters of the same consumer on the same
+	// shard, which is required to run the script
+	id := usagePlanRedisKeyPrefix + "{" + getHashedKey(group+"."+consumer) + "}."
+	r, err := u.ringClient.RunScript(ctx, u.script,
+		[]string{id + "d." + now.Format("20060102"), id + "m." + now.Format("200601")},
+		p.Daily,
+		p.Monthly,
+		untilDayEnd.Milliseconds(),
+		untilMonthEnd.Milliseconds(),
+		increment,
+	)
+
+	var res []interface{}
+	if err == nil {
+		var ok bool
+		if res, ok = r.([]interface{}); !ok || len(res) != 3 {
+			err = errors.New("failed to evaluate redis data")
+		}
+	}
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		log.Errorf("Failed to check the usage plan quota: %v", err)
+		return true, 0, nil
+	}
+
+	allowed, _ := res[0].(int64)
+	daily, _ := res[1].(int64)
+	monthly, _ := res[2].(int64)
+
+	var (
+		quotas []Quota
+		retry  time.Duration
+	)
+
+	if p.Daily > 0 {
+		quotas = append(quotas, Quota{
+			Limit:     int(p.Daily),
+			Remaining: int(max64(p.Daily-daily, 0)),
+			Reset:     untilDayEnd,
+			Policy:    fmt.Sprintf("%d;w=%d", p.Daily, seconds(24*time.Hour)),
+		})
+
+		if daily >= p.Daily {
+			retry = untilDayEnd
+		}
+	}
+
+	if p.Monthly > 0 {
+		quotas = append(quotas, Quota{
+			Limit:     int(p.Monthly),
+			Remaining: int(max64(p.Monthly-monthly, 0)),
+			Reset:     untilMonthEnd,
+			Policy:    fmt.Sprintf("%d;w=%d", p.Monthly, seconds(monthEnd.Sub(time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)))),
+		})
+
+		if monthly >= p.Monthly {
+			retry = untilMonthEnd
+		}
+	}
+
+	if allowed == 1 {
+		return true, 0, quotas
+	}
+
+	return false, int(seconds(retry)), quotas
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
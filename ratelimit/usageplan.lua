@@ -0,0 +1,41 @@
+local daily_id = KEYS[1]                   -- counter of the current day
+local monthly_id = KEYS[2]                 -- counter of the current month
+local daily_limit = tonumber(ARGV[1])      -- requests allowed per day, 0 means unlimited
+local monthly_limit = tonumber(ARGV[2])    -- requests allowed per month, 0 means unlimited
+local daily_ttl = tonumber(ARGV[3])        -- milliseconds until the end of the day
+local monthly_ttl = tonumber(ARGV[4])      -- milliseconds until the end of the month
+local increment = tonumber(ARGV[5])        -- 1 to count the request, -1 to refund a counted one
+
+-- The counters are only increased, when both of the quotas allow the request, so the
+-- rejected requests are not counted. The counters expire at the end of their period.
+local daily = tonumber(redis.call("GET", daily_id) or "0")
+local monthly = tonumber(redis.call("GET", monthly_id) or "0")
+
+-- The refund decreases only the existing counters, so that they keep their expiry.
+if increment < 0 then
+    if daily > 0 then
+        daily = redis.call("DECR", daily_id)
+    end
+
+    if monthly > 0 then
+        monthly = redis.call("DECR", monthly_id)
+    end
+
+    return {1, daily, monthly}
+end
+
+if (daily_limit > 0 and daily >= daily_limit) or (monthly_limit > 0 and monthly >= monthly_limit) then
+    return {0, daily, monthly}
+end
+
+daily = redis.call("INCR", daily_id)
+if daily == 1 then
+    redis.call("PEXPIRE", daily_id, daily_ttl)
+end
+
+monthly = redis.call("INCR", monthly_id)
+if monthly == 1 then
+    redis.call("PEXPIRE", monthly_id, monthly_ttl)
+end
+
+return {1, daily, monthly}
@@ -0,0 +1,258 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/net/redistest"
+)
+
+const testPlans = `
+default: free
+plans:
+  free:
+    max-hits: 10
+    time-window: 1s
+    daily: 3
+  gold:
+    monthly: 5
+consumers:
+  client-a: gold
+`
+
+type planSource struct {
+	mu    sync.Mutex
+	plans *Plans
+	err   error
+}
+
+func (s *planSource) set(p *Plans, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plans, s.err = p, err
+}
+
+func (s *planSource) LoadPlans() (*Plans, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.plans, s.err
+}
+
+func TestPlanFile(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "plans.yaml")
+	require.NoError(t, os.WriteFile(f, []byte(testPlans), 0644))
+
+	p, err := PlanFile(f).LoadPlans()
+	require.NoError(t, err)
+	require.NoError(t, p.validate())
+
+	name, plan := p.plan("client-a")
+	assert.Equal(t, "gold", name)
+	assert.Equal(t, Plan{Monthly: 5}, plan)
+
+	name, plan = p.plan("client-b")
+	assert.Equal(t, "free", name)
+	assert.Equal(t, Plan{MaxHits: 10, TimeWindow: time.Second, Daily: 3}, plan)
+
+	_, err = PlanFile(filepath.Join(t.TempDir(), "missing.yaml")).LoadPlans()
+	assert.Error(t, err)
+}
+
+func TestPlansValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		plans Plans
+	}{{
+		name:  "missing default",
+		plans: Plans{Default: "free", Plans: map[string]Plan{"gold": {Daily: 1}}},
+	}, {
+		name:  "negative limit",
+		plans: Plans{Default: "free", Plans: map[string]Plan{"free": {Daily: -1}}},
+	}, {
+		name:  "missing time window",
+		plans: Plans{Default: "free", Plans: map[string]Plan{"free": {MaxHits: 1}}},
+	}, {
+		name: "missing consumer plan",
+		plans: Plans{
+			Default:   "free",
+			Plans:     map[string]Plan{"free": {Daily: 1}},
+			Consumers: map[string]string{"client-a": "gold"},
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Error(t, tc.plans.validate())
+		})
+	}
+}
+
+func TestUsagePlansUpdate(t *testing.T) {
+	source := &planSource{plans: &Plans{Default: "free", Plans: map[string]Plan{"free": {Daily: 1}}}}
+
+	r := NewRegistry()
+	defer r.Close()
+
+	u, err := NewUsagePlans(r, source, 10*time.Millisecond)
+	require.NoError(t, err)
+	defer u.Close()
+
+	name, _ := u.Plan("client-a")
+	assert.Equal(t, "free", name)
+
+	source.set(&Plans{
+		Default:   "free",
+		Plans:     map[string]Plan{"free": {Daily: 1}, "gold": {Daily: 2}},
+		Consumers: map[string]string{"client-a": "gold"},
+	}, nil)
+
+	assert.Eventually(t, func() bool {
+		name, _ := u.Plan("client-a")
+		return name == "gold"
+	}, time.Second, 10*time.Millisecond)
+
+	// keeps the previous plans on error
+	source.set(nil, errors.New("failed to load"))
+	time.Sleep(50 * time.Millisecond)
+
+	name, _ = u.Plan("client-a")
+	assert.Equal(t, "gold", name)
+}
+
+func TestUsagePlansInitialError(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+
+	_, err := NewUsagePlans(r, &planSource{err: errors.New("failed to load")}, time.Minute)
+	assert.Error(t, err)
+
+	_, err = NewUsagePlans(r, &planSource{plans: &Plans{Default: "free"}}, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestUsagePlansWithoutRedis(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+
+	u, err := NewUsagePlans(r, &planSource{plans: &Plans{Default: "free", Plans: map[string]Plan{"free": {Daily: 1}}}}, time.Minute)
+	require.NoError(t, err)
+	defer u.Close()
+
+	for i := 0; i < 3; i++ {
+		allowed, _, q := u.AllowContext(context.Background(), "api", "client-a")
+		assert.True(t, allowed)
+		assert.Equal(t, Quota{}, q)
+	}
+}
+
+func TestUsagePlansQuota(t *testing.T) {
+	redisAddr, done := redistest.NewTestRedis(t)
+	defer done()
+
+	r := NewSwarmRegistry(nil, &net.RedisOptions{Addrs: []string{redisAddr}})
+	defer r.Close()
+
+	source := &planSource{plans: &Plans{
+		Default:   "free",
+		Plans:     map[string]Plan{"free": {Daily: 3}, "gold": {Daily: 10, Monthly: 4}},
+		Consumers: map[string]string{"client-a": "gold"},
+	}}
+
+	now := time.Date(2022, 10, 30, 23, 0, 0, 0, time.UTC)
+	u, err := newUsagePlans(r, source, time.Minute, func() time.Time { return now })
+	require.NoError(t, err)
+	defer u.Close()
+
+	ctx := context.Background()
+	for i := 2; i >= 0; i-- {
+		allowed, _, q := u.AllowContext(ctx, "agroup", "client-b")
+		assert.True(t, allowed)
+		assert.Equal(t, Quota{Limit: 3, Remaining: i, Reset: time.Hour, Policy: "3;w=86400"}, q)
+	}
+
+	allowed, retry, q := u.AllowContext(ctx, "agroup", "client-b")
+	assert.False(t, allowed)
+	assert.Equal(t, 3600, retry)
+	assert.Equal(t, 0, q.Remaining)
+
+	// the groups are counted separately
+	allowed, _, _ = u.AllowContext(ctx, "bgroup", "client-b")
+	assert.True(t, allowed)
+
+	// the next day
+	now = now.Add(time.Hour)
+	allowed, _, _ = u.AllowContext(ctx, "agroup", "client-b")
+	assert.True(t, allowed)
+
+	// the monthly quota is more restrictive
+	for i := 3; i >= 0; i-- {
+		allowed, _, q := u.AllowContext(ctx, "agroup", "client-a")
+		assert.True(t, allowed)
+		assert.Equal(t, i, q.Remaining)
+		assert.Equal(t, "10;w=86400, 4;w=2678400", q.Policy)
+	}
+
+	allowed, retry, _ = u.AllowContext(ctx, "agroup", "client-a")
+	assert.False(t, allowed)
+	assert.Equal(t, 24*3600, retry)
+}
+
+func TestUsagePlansRateNotCountedInQuota(t *testing.T) {
+	redisAddr, done := redistest.NewTestRedis(t)
+	defer done()
+
+	r := NewSwarmRegistry(nil, &net.RedisOptions{Addrs: []string{redisAddr}})
+	defer r.Close()
+
+	source := &planSource{plans: &Plans{
+		Default: "free",
+		Plans:   map[string]Plan{"free": {MaxHits: 1, TimeWindow: time.Minute, Daily: 2}},
+	}}
+
+	u, err := NewUsagePlans(r, source, time.Minute)
+	require.NoError(t, err)
+	defer u.Close()
+
+	ctx := context.Background()
+	allowed, _, _ := u.AllowContext(ctx, "agroup", "client-a")
+	assert.True(t, allowed)
+
+	// rejected by the rate, and refunded in the daily quota
+	for i := 0; i < 3; i++ {
+		allowed, _, q := u.AllowContext(ctx, "agroup", "client-a")
+		assert.False(t, allowed)
+		assert.Equal(t, "1;w=60", q.Policy)
+	}
+
+	source.set(&Plans{
+		Default: "free",
+		Plans:   map[string]Plan{"free": {Daily: 2}},
+	}, nil)
+	require.NoError(t, u.update())
+
+	allowed, _, q := u.AllowContext(ctx, "agroup", "client-a")
+	assert.True(t, allowed)
+	assert.Equal(t, 0, q.Remaining)
+}
+
+func TestUsagePlansRedisError(t *testing.T) {
+	r := NewSwarmRegistry(nil, &net.RedisOptions{Addrs: []string{"no-such-host.test:123"}})
+	defer r.Close()
+
+	u, err := NewUsagePlans(r, &planSource{plans: &Plans{Default: "free", Plans: map[string]Plan{"free": {Daily: 1}}}}, time.Minute)
+	require.NoError(t, err)
+	defer u.Close()
+
+	// fails open
+	for i := 0; i < 3; i++ {
+		allowed, _, _ := u.AllowContext(context.Background(), "agroup", "client-a")
+		assert.True(t, allowed)
+	}
+}
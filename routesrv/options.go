@@ -54,6 +54,14 @@ type Options struct {
 	// KubernetesIngressV1 will switch the dataclient to read ingress v1 resources, instead of v1beta1
 	KubernetesIngressV1 bool
 
+	// KubernetesEnableEndpointSlices makes the Kubernetes dataclient
+	// read the EndpointSlices instead of the legacy Endpoints.
+	KubernetesEnableEndpointSlices bool
+
+	// KubernetesWatch makes the Kubernetes dataclient watch the
+	// changes of the resources instead of listing them on every poll.
+	KubernetesWatch bool
+
 	// KubernetesIngressClass is a regular expression, that will make
 	// skipper load only the ingress resources that have a matching
 	// kubernetes.io/ingress.class annotation. For backwards compatibility,
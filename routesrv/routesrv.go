@@ -75,6 +75,8 @@ func New(opts Options) (*RouteServer, error) {
 		ReverseSourcePredicate:            opts.ReverseSourcePredicate,
 		RouteGroupClass:                   opts.KubernetesRouteGroupClass,
 		WhitelistedHealthCheckCIDR:        opts.WhitelistedHealthCheckCIDR,
+		KubernetesEnableEndpointSlices:    opts.KubernetesEnableEndpointSlices,
+		KubernetesWatch:                   opts.KubernetesWatch,
 	})
 	if err != nil {
 		return nil, err
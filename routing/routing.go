@@ -3,6 +3,7 @@ package routing
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -138,9 +139,22 @@ type RouteFilter struct {
 	Index int
 }
 
+// lbLatencyDecay is the time constant of the decay of the latency
+// tracked by LBMetrics.
+const lbLatencyDecay = 10 * time.Second
+
 // LBMetrics contains metrics used by LB algorithms
 type LBMetrics struct {
 	inflightRequests int64
+	latency          atomic.Value // of *lbLatency
+
+	// now is used in the tests
+	now func() time.Time
+}
+
+type lbLatency struct {
+	value   float64
+	updated time.Time
 }
 
 // IncInflightRequest increments the number of outstanding requests from the proxy to a given backend.
@@ -158,14 +172,72 @@ func (m *LBMetrics) GetInflightRequests() int {
 	return int(atomic.LoadInt64(&m.inflightRequests))
 }
 
+// latencyWeight returns the weight of the latency observed at the time of the last update, decaying
+// with the time passed since then.
+func latencyWeight(updated, now time.Time) float64 {
+	return math.Exp(-float64(now.Sub(updated)) / float64(lbLatencyDecay))
+}
+
+// ObserveLatency records the response time of a request proxied to a given backend. It maintains a
+// peak exponentially weighted moving average: higher values than the decayed average are taken
+// immediately, while lower values decrease the decayed average depending on the time passed since the
+// previous observation.
+func (m *LBMetrics) ObserveLatency(d time.Duration) {
+	now := m.timeNow()
+	v := float64(d)
+	for {
+		old := m.latency.Load()
+		l := &lbLatency{value: v, updated: now}
+		if prev, ok := old.(*lbLatency); ok {
+			w := latencyWeight(prev.updated, now)
+			if decayed := prev.value * w; v <= decayed {
+				l.value = decayed*w + v*(1-w)
+			}
+		}
+
+		if m.latency.CompareAndSwap(old, l) {
+			return
+		}
+	}
+}
+
+func (m *LBMetrics) timeNow() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+
+	return time.Now()
+}
+
+// GetLatency returns the peak EWMA latency of a given backend, or zero when no latency was observed yet.
+// The latency decays towards zero with the time passed since the last observation, so that the
+// backends which had a latency spike and were not selected since then, get selected again eventually,
+// and their latency gets observed again.
+func (m *LBMetrics) GetLatency() time.Duration {
+	l, ok := m.latency.Load().(*lbLatency)
+	if !ok {
+		return 0
+	}
+
+	return time.Duration(l.value * latencyWeight(l.updated, m.timeNow()))
+}
+
 // LBEndpoint represents the scheme and the host of load balanced
 // backends.
 type LBEndpoint struct {
 	Scheme, Host string
 	Metrics      *LBMetrics
 
+	// Weight is the relative weight of the endpoint, set from the weights of the LB endpoints in the
+	// route definition. Zero means the default weight of 1.
+	Weight float64
+
+	// Zone is the availability zone of the endpoint, when known, set from the zones of the LB endpoints
+	// in the route definition.
+	Zone string
+
 	// Detected represents the time when skipper instances first detected a new LB endpoint. This detection
-	// time is used for the fade-in feature of the round-robin and random LB algorithms.
+	// time is used for the fade-in feature of the round-robin, random, leastConnections and peakEWMA LB algorithms.
 	Detected time.Time
 }
 
@@ -180,7 +252,13 @@ type LBAlgorithm interface {
 type LBContext struct {
 	Request *http.Request
 	Route   *Route
-	Params  map[string]interface{}
+
+	// LBEndpoints, when set, limits the endpoints that the algorithm can select to a subset of the
+	// endpoints of the route, e.g. to exclude the endpoints already tried by the retries. When not set,
+	// all the endpoints of the route can be selected.
+	LBEndpoints []LBEndpoint
+
+	Params map[string]interface{}
 }
 
 // NewLBContext is used to create a new LBContext, to pass data to the
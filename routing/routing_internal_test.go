@@ -2,6 +2,7 @@ package routing
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/zalando/skipper/eskip"
@@ -75,3 +76,46 @@ func TestSlice(t *testing.T) {
 		})
 	}
 }
+
+func TestLBMetricsLatencyDecay(t *testing.T) {
+	now := time.Now()
+	m := &LBMetrics{now: func() time.Time { return now }}
+	if l := m.GetLatency(); l != 0 {
+		t.Fatalf("expected no latency, got: %v", l)
+	}
+
+	m.ObserveLatency(100 * time.Millisecond)
+	if l := m.GetLatency(); l != 100*time.Millisecond {
+		t.Fatalf("expected the observed latency, got: %v", l)
+	}
+
+	now = now.Add(lbLatencyDecay)
+	if l := m.GetLatency(); l < 36*time.Millisecond || l > 37*time.Millisecond {
+		t.Fatalf("expected the decayed latency, got: %v", l)
+	}
+
+	now = now.Add(10 * lbLatencyDecay)
+	if l := m.GetLatency(); l > time.Millisecond {
+		t.Fatalf("expected the latency to decay towards zero, got: %v", l)
+	}
+}
+
+func TestLBMetricsLatencyAfterIdle(t *testing.T) {
+	now := time.Now()
+	m := &LBMetrics{now: func() time.Time { return now }}
+	m.ObserveLatency(100 * time.Millisecond)
+
+	// lower latency right after the peak only lowers the average slightly
+	now = now.Add(time.Millisecond)
+	m.ObserveLatency(10 * time.Millisecond)
+	if l := m.GetLatency(); l < 99*time.Millisecond {
+		t.Fatalf("expected the peak to be kept, got: %v", l)
+	}
+
+	// after an idle period, a fresh sample higher than the decayed latency replaces it
+	now = now.Add(10 * lbLatencyDecay)
+	m.ObserveLatency(10 * time.Millisecond)
+	if l := m.GetLatency(); l != 10*time.Millisecond {
+		t.Fatalf("expected the fresh sample to replace the decayed latency, got: %v", l)
+	}
+}
@@ -0,0 +1,323 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aryszka/jobqueue"
+	"github.com/zalando/skipper/metrics"
+)
+
+const (
+	// AdaptiveKey is used during routing to pass the adaptive concurrency
+	// values from the filters to the proxy.
+	AdaptiveKey = "adaptiveConcurrency"
+
+	// AlgorithmGradient adjusts the concurrency limit based on the ratio
+	// of the long term and the short term latency of the backend.
+	AlgorithmGradient = "gradient"
+
+	// AlgorithmAIMD increases the concurrency limit additively while the
+	// backend responds successfully, and decreases it multiplicatively on
+	// errors and on too high latency.
+	AlgorithmAIMD = "aimd"
+
+	defaultAdaptiveMinLimit = 10
+
+	aimdBackoffRatio   = 0.9
+	gradientTolerance  = 1.5
+	gradientSmoothing  = 0.2
+	longLatencyWindow  = 600
+	shortLatencyWindow = 10
+)
+
+// AdaptiveConfig is used to configure the adaptive concurrency limits. It
+// must stay comparable, because it is used to detect the changes of the
+// route specific configuration.
+type AdaptiveConfig struct {
+	// Algorithm is either AlgorithmGradient or AlgorithmAIMD. Defaults to
+	// AlgorithmGradient.
+	Algorithm string
+
+	// MinLimit is the lowest concurrency limit. It is also the initial
+	// limit. Defaults to 10.
+	MinLimit int
+
+	// MaxLimit is the highest concurrency limit. Defaults to MinLimit.
+	MaxLimit int
+
+	// MaxQueueSize defines how many requests may be waiting, when the
+	// concurrency limit is reached. Defaults to infinite.
+	MaxQueueSize int
+
+	// Timeout defines how long a request can be waiting in the queue.
+	// Defaults to infinite.
+	Timeout time.Duration
+
+	// LatencyThreshold is used by the AIMD algorithm. When the latency
+	// of a request is higher, the limit is decreased. Defaults to
+	// infinite.
+	LatencyThreshold time.Duration
+}
+
+// AdaptiveQueue implements a concurrency limit that is adjusted to the
+// measured latency of the backend. The requests over the limit are held
+// in a LIFO queue, and they can wait briefly for the limit to allow them.
+// Currently, they can be used from the adaptiveConcurrency filter in the
+// filters/scheduler package only.
+type AdaptiveQueue struct {
+	queue  *Queue
+	config AdaptiveConfig
+
+	mu           sync.Mutex
+	limit        float64
+	inflight     int
+	longLatency  float64
+	shortLatency float64
+
+	metrics          metrics.Metrics
+	limitMetricsKey  string
+	activeMetricsKey string
+	queuedMetricsKey string
+}
+
+func normalizeAdaptiveConfig(c AdaptiveConfig) AdaptiveConfig {
+	if c.Algorithm == "" {
+		c.Algorithm = AlgorithmGradient
+	}
+
+	if c.MinLimit <= 0 {
+		c.MinLimit = defaultAdaptiveMinLimit
+	}
+
+	if c.MaxLimit < c.MinLimit {
+		c.MaxLimit = c.MinLimit
+	}
+
+	return c
+}
+
+func newAdaptiveQueue(c AdaptiveConfig) *AdaptiveQueue {
+	c = normalizeAdaptiveConfig(c)
+	q := &AdaptiveQueue{
+		config: c,
+		limit:  float64(c.MinLimit),
+	}
+
+	q.queue = &Queue{
+		config: q.queueConfig(),
+		queue: jobqueue.With(jobqueue.Options{
+			MaxConcurrency: c.MinLimit,
+			MaxStackSize:   c.MaxQueueSize,
+			Timeout:        c.Timeout,
+		}),
+	}
+
+	return q
+}
+
+// must be called with the lock held
+func (q *AdaptiveQueue) queueConfig() Config {
+	return Config{
+		MaxConcurrency: int(q.limit),
+		MaxQueueSize:   q.config.MaxQueueSize,
+		Timeout:        q.config.Timeout,
+	}
+}
+
+// must be called with the lock held
+func (q *AdaptiveQueue) applyLimit() {
+	q.limit = math.Max(float64(q.config.MinLimit), math.Min(float64(q.config.MaxLimit), q.limit))
+	if c := q.queueConfig(); c != q.queue.config {
+		q.queue.config = c
+		q.queue.reconfigure()
+	}
+}
+
+func (q *AdaptiveQueue) reconfigure(c AdaptiveConfig) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.config = normalizeAdaptiveConfig(c)
+	q.applyLimit()
+}
+
+// Wait blocks until a request can be processed, or needs to be rejected.
+// When it can be processed, calling done indicates that it has finished.
+// It is mandatory to call done() when the request was processed. When the
+// request needs to be rejected, an error will be returned.
+func (q *AdaptiveQueue) Wait() (done func(), err error) {
+	release, err := q.queue.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	q.inflight++
+	q.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			q.inflight--
+			q.mu.Unlock()
+			release()
+		})
+	}, nil
+}
+
+// Sample adjusts the concurrency limit based on the latency and the
+// outcome of a request. It needs to be called before the done function
+// returned by Wait.
+func (q *AdaptiveQueue) Sample(latency time.Duration, success bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch q.config.Algorithm {
+	case AlgorithmAIMD:
+		q.sampleAIMD(latency, success)
+	default:
+		q.sampleGradient(latency, success)
+	}
+
+	q.applyLimit()
+}
+
+// must be called with the lock held
+func (q *AdaptiveQueue) sampleAIMD(latency time.Duration, success bool) {
+	if !success || q.config.LatencyThreshold > 0 && latency > q.config.LatencyThreshold {
+		q.limit = math.Floor(q.limit * aimdBackoffRatio)
+		return
+	}
+
+	// only increasing when the limit is actually used
+	if q.inflight*2 >= int(q.limit) {
+		q.limit++
+	}
+}
+
+// must be called with the lock held
+func (q *AdaptiveQueue) sampleGradient(latency time.Duration, success bool) {
+	if !success {
+		// the latency of the failed requests is not representative
+		return
+	}
+
+	l := float64(latency)
+	if q.longLatency == 0 {
+		q.longLatency = l
+		q.shortLatency = l
+		return
+	}
+
+	q.longLatency += (l - q.longLatency) / longLatencyWindow
+	q.shortLatency += (l - q.shortLatency) / shortLatencyWindow
+
+	// when the backend became faster, the long term latency catches up
+	if q.longLatency > q.shortLatency {
+		q.longLatency = q.shortLatency
+	}
+
+	// not growing when the limit is not actually used
+	if q.inflight*2 < int(q.limit) {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, gradientTolerance*q.longLatency/q.shortLatency))
+	next := q.limit*gradient + math.Sqrt(q.limit)
+	q.limit = q.limit*(1-gradientSmoothing) + next*gradientSmoothing
+}
+
+// Limit returns the current concurrency limit.
+func (q *AdaptiveQueue) Limit() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int(q.limit)
+}
+
+// Status returns the current status of the queue.
+func (q *AdaptiveQueue) Status() QueueStatus {
+	return q.queue.Status()
+}
+
+// Config returns the configuration that the queue was created with.
+func (q *AdaptiveQueue) Config() AdaptiveConfig {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.config
+}
+
+func (q *AdaptiveQueue) close() {
+	q.queue.close()
+}
+
+func (q *AdaptiveQueue) updateMetrics() {
+	if q.metrics == nil {
+		return
+	}
+
+	s := q.Status()
+	q.metrics.UpdateGauge(q.limitMetricsKey, float64(q.Limit()))
+	q.metrics.UpdateGauge(q.activeMetricsKey, float64(s.ActiveRequests))
+	q.metrics.UpdateGauge(q.queuedMetricsKey, float64(s.QueuedRequests))
+}
+
+// AdaptiveFilter is the interface that needs to be implemented by the
+// filters that use an adaptive concurrency limit maintained by the
+// registry.
+type AdaptiveFilter interface {
+	// SetQueue will be used by the registry to pass in the right queue
+	// to the filter.
+	SetQueue(*AdaptiveQueue)
+
+	// GetQueue is currently used only by tests.
+	GetQueue() *AdaptiveQueue
+
+	// Config will be called by the registry once during processing the
+	// routing to get the right settings from the filter.
+	Config() AdaptiveConfig
+}
+
+func (r *Registry) getAdaptiveQueue(id queueId, c AdaptiveConfig) *AdaptiveQueue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if q, ok := r.queues[id].(*AdaptiveQueue); ok {
+		if q.Config() != normalizeAdaptiveConfig(c) {
+			q.reconfigure(c)
+		}
+
+		return q
+	}
+
+	q := r.newAdaptiveQueue(id.name, c)
+	r.queues[id] = q
+	return q
+}
+
+func (r *Registry) newAdaptiveQueue(name string, c AdaptiveConfig) *AdaptiveQueue {
+	q := newAdaptiveQueue(c)
+
+	// the limit is always published, because it cannot be derived from
+	// the configuration
+	if r.options.Metrics != nil {
+		if name == "" {
+			name = "unknown"
+		}
+
+		q.limitMetricsKey = fmt.Sprintf("adaptiveconcurrency.%s.limit", name)
+		q.activeMetricsKey = fmt.Sprintf("adaptiveconcurrency.%s.active", name)
+		q.queuedMetricsKey = fmt.Sprintf("adaptiveconcurrency.%s.queued", name)
+		q.metrics = r.options.Metrics
+
+		q.queue.errorFullMetricsKey = fmt.Sprintf("adaptiveconcurrency.%s.error.full", name)
+		q.queue.errorOtherMetricsKey = fmt.Sprintf("adaptiveconcurrency.%s.error.other", name)
+		q.queue.errorTimeoutMetricsKey = fmt.Sprintf("adaptiveconcurrency.%s.error.timeout", name)
+		q.queue.metrics = r.options.Metrics
+		r.measure()
+	}
+
+	return q
+}
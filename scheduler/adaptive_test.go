@@ -0,0 +1,151 @@
+package scheduler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+	"github.com/zalando/skipper/scheduler"
+)
+
+func TestAdaptiveAIMD(t *testing.T) {
+	q := scheduler.NewAdaptiveQueue(scheduler.AdaptiveConfig{
+		Algorithm:        scheduler.AlgorithmAIMD,
+		MinLimit:         2,
+		MaxLimit:         10,
+		LatencyThreshold: 50 * time.Millisecond,
+	})
+	defer scheduler.CloseAdaptiveQueue(q)
+
+	var pending []func()
+	for i := 0; i < 2; i++ {
+		done, err := q.Wait()
+		require.NoError(t, err)
+		pending = append(pending, done)
+	}
+
+	q.Sample(10*time.Millisecond, true)
+	assert.Equal(t, 3, q.Limit())
+
+	q.Sample(10*time.Millisecond, true)
+	assert.Equal(t, 4, q.Limit())
+
+	q.Sample(10*time.Millisecond, true)
+	assert.Equal(t, 5, q.Limit())
+
+	// the limit is not used enough to grow further
+	q.Sample(10*time.Millisecond, true)
+	assert.Equal(t, 5, q.Limit())
+
+	q.Sample(10*time.Millisecond, false)
+	assert.Equal(t, 4, q.Limit())
+
+	q.Sample(100*time.Millisecond, true)
+	assert.Equal(t, 3, q.Limit())
+
+	q.Sample(10*time.Millisecond, false)
+	assert.Equal(t, 2, q.Limit())
+
+	// never below the min limit
+	q.Sample(10*time.Millisecond, false)
+	assert.Equal(t, 2, q.Limit())
+
+	for _, done := range pending {
+		done()
+	}
+
+	assert.Equal(t, scheduler.QueueStatus{}, q.Status())
+}
+
+func TestAdaptiveGradient(t *testing.T) {
+	q := scheduler.NewAdaptiveQueue(scheduler.AdaptiveConfig{
+		Algorithm: scheduler.AlgorithmGradient,
+		MinLimit:  1,
+		MaxLimit:  100,
+	})
+	defer scheduler.CloseAdaptiveQueue(q)
+
+	var pending []func()
+	sample := func(latency time.Duration) {
+		// keeping the limit used, otherwise it doesn't grow
+		for len(pending) < q.Limit() {
+			done, err := q.Wait()
+			require.NoError(t, err)
+			pending = append(pending, done)
+		}
+
+		q.Sample(latency, true)
+	}
+
+	for i := 0; i < 500; i++ {
+		sample(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, 100, q.Limit())
+
+	// the failed requests don't change the limit
+	q.Sample(time.Second, false)
+	assert.Equal(t, 100, q.Limit())
+
+	for i := 0; i < 50; i++ {
+		sample(100 * time.Millisecond)
+	}
+
+	assert.Less(t, q.Limit(), 20)
+
+	for _, done := range pending {
+		done()
+	}
+}
+
+func TestAdaptiveConfig(t *testing.T) {
+	*scheduler.ExportQueueCloseDelay = 0
+	defer func() { *scheduler.ExportQueueCloseDelay = time.Minute }()
+
+	dc, err := testdataclient.NewDoc(`r: * -> adaptiveConcurrency("aimd", 2, 8) -> <shunt>`)
+	require.NoError(t, err)
+
+	reg := scheduler.NewRegistry()
+	defer reg.Close()
+
+	rt := routing.New(routing.Options{
+		SignalFirstLoad: true,
+		FilterRegistry:  builtin.MakeRegistry(),
+		DataClients:     []routing.DataClient{dc},
+		PreProcessors:   []routing.PreProcessor{reg.PreProcessor()},
+		PostProcessors:  []routing.PostProcessor{reg},
+	})
+	defer rt.Close()
+
+	<-rt.FirstLoad()
+
+	getQueue := func() *scheduler.AdaptiveQueue {
+		req, _ := http.NewRequest("GET", "http://skipper.test", nil)
+		r, _ := rt.Route(req)
+		require.NotNil(t, r)
+		return r.Filters[0].Filter.(scheduler.AdaptiveFilter).GetQueue()
+	}
+
+	q := getQueue()
+	require.NotNil(t, q)
+	assert.Equal(t, 2, q.Limit())
+
+	// the queue is preserved and the limit is adjusted to the new bounds
+	require.NoError(t, dc.UpdateDoc(`r: * -> adaptiveConcurrency("aimd", 4, 8) -> <shunt>`, nil))
+	time.Sleep(120 * time.Millisecond)
+
+	assert.Same(t, q, getQueue())
+	assert.Equal(t, 4, q.Limit())
+
+	require.NoError(t, dc.UpdateDoc(`r: * -> setPath("/foo") -> <shunt>`, nil))
+	require.NoError(t, dc.UpdateDoc(`r2: Path("/bar") -> adaptiveConcurrency() -> <shunt>`, nil))
+	time.Sleep(120 * time.Millisecond)
+
+	assert.True(t, q.Status().Closed)
+}
@@ -3,3 +3,11 @@ package scheduler
 var (
 	ExportQueueCloseDelay = &queueCloseDelay
 )
+
+func NewAdaptiveQueue(c AdaptiveConfig) *AdaptiveQueue {
+	return newAdaptiveQueue(c)
+}
+
+func CloseAdaptiveQueue(q *AdaptiveQueue) {
+	q.close()
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/aryszka/jobqueue"
+)
+
+// fifoQueue processes the waiting jobs in first in first out order. It
+// has the same interface and returns the same errors as the
+// jobqueue.Stack, which is used for the LIFO ordering.
+type fifoQueue struct {
+	mu      sync.Mutex
+	options jobqueue.Options
+	active  int
+	waiting *list.List
+	closed  bool
+}
+
+func newFifo(o jobqueue.Options) *fifoQueue {
+	if o.MaxConcurrency <= 0 {
+		o.MaxConcurrency = 1
+	}
+
+	return &fifoQueue{
+		options: o,
+		waiting: list.New(),
+	}
+}
+
+func (q *fifoQueue) full() bool {
+	return q.options.MaxStackSize > 0 && q.waiting.Len() >= q.options.MaxStackSize
+}
+
+// must be called with the lock held
+func (q *fifoQueue) dispatch() {
+	for q.active < q.options.MaxConcurrency && q.waiting.Len() > 0 {
+		q.active++
+		ready := q.waiting.Remove(q.waiting.Front()).(chan error)
+		ready <- nil
+	}
+}
+
+func (q *fifoQueue) done() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.active--
+			q.dispatch()
+		})
+	}
+}
+
+func (q *fifoQueue) Wait() (func(), error) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil, jobqueue.ErrClosed
+	}
+
+	if q.active < q.options.MaxConcurrency && q.waiting.Len() == 0 {
+		q.active++
+		q.mu.Unlock()
+		return q.done(), nil
+	}
+
+	if q.full() {
+		q.mu.Unlock()
+		return nil, jobqueue.ErrStackFull
+	}
+
+	// buffered, so that the queue never blocks on notifying
+	ready := make(chan error, 1)
+	e := q.waiting.PushBack(ready)
+	timeout := q.options.Timeout
+	q.mu.Unlock()
+
+	var to <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		to = t.C
+	}
+
+	select {
+	case err := <-ready:
+		return q.notified(err)
+	case <-to:
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		// the request may have been notified since the timeout
+		select {
+		case err := <-ready:
+			return q.notified(err)
+		default:
+		}
+
+		q.waiting.Remove(e)
+		return nil, jobqueue.ErrTimeout
+	}
+}
+
+func (q *fifoQueue) notified(err error) (func(), error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return q.done(), nil
+}
+
+func (q *fifoQueue) Reconfigure(o jobqueue.Options) error {
+	if o.MaxConcurrency <= 0 {
+		o.MaxConcurrency = 1
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.options = o
+	q.dispatch()
+
+	// the latest requests are rejected when the queue became shorter
+	for o.MaxStackSize > 0 && q.waiting.Len() > o.MaxStackSize {
+		ready := q.waiting.Remove(q.waiting.Back()).(chan error)
+		ready <- jobqueue.ErrStackFull
+	}
+
+	return nil
+}
+
+func (q *fifoQueue) Status() jobqueue.Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return jobqueue.Status{
+		ActiveJobs: q.active,
+		QueuedJobs: q.waiting.Len(),
+		Closed:     q.closed,
+	}
+}
+
+// Close rejects the new requests, while the ones already in the queue
+// are still processed.
+func (q *fifoQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+}
@@ -0,0 +1,215 @@
+package scheduler_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+	"github.com/zalando/skipper/scheduler"
+)
+
+func TestFifoConfig(t *testing.T) {
+	waitForStatus := func(t *testing.T, q *scheduler.Queue, s scheduler.QueueStatus) {
+		t.Helper()
+		timeout := time.After(120 * time.Millisecond)
+		for {
+			if q.Status() == s {
+				return
+			}
+
+			select {
+			case <-timeout:
+				t.Fatalf("failed to reach status, want: %v, got: %v", s, q.Status())
+			default:
+			}
+		}
+	}
+
+	initTest := func(doc string) (*routing.Routing, *testdataclient.Client, func()) {
+		cli, err := testdataclient.NewDoc(doc)
+		if err != nil {
+			t.Fatalf("Failed to create a test dataclient: %v", err)
+		}
+
+		reg := scheduler.NewRegistry()
+		ro := routing.Options{
+			SignalFirstLoad: true,
+			FilterRegistry:  builtin.MakeRegistry(),
+			DataClients:     []routing.DataClient{cli},
+			PostProcessors: []routing.PostProcessor{
+				reg,
+			},
+		}
+
+		rt := routing.New(ro)
+		<-rt.FirstLoad()
+		return rt, cli, func() {
+			rt.Close()
+			reg.Close()
+		}
+	}
+
+	updateDoc := func(t *testing.T, dc *testdataclient.Client, upsertDoc string, deletedIDs []string) {
+		t.Helper()
+		if err := dc.UpdateDoc(upsertDoc, deletedIDs); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(120 * time.Millisecond)
+	}
+
+	getQueue := func(path string, rt *routing.Routing) *scheduler.Queue {
+		req := &http.Request{URL: &url.URL{Path: path}}
+		r, _ := rt.Route(req)
+		f := r.Filters[0]
+		return f.Filter.(scheduler.LIFOFilter).GetQueue()
+	}
+
+	t.Run("group config applied", func(t *testing.T) {
+		const doc = `
+			g1: Path("/one") -> fifoGroup("g", 2, 2) -> <shunt>;
+			g2: Path("/two") -> fifoGroup("g") -> <shunt>;
+		`
+
+		rt, _, close := initTest(doc)
+		defer close()
+
+		req1 := &http.Request{URL: &url.URL{Path: "/one"}}
+		req2 := &http.Request{URL: &url.URL{Path: "/two"}}
+
+		r1, _ := rt.Route(req1)
+		r2, _ := rt.Route(req2)
+
+		f1 := r1.Filters[0]
+		f2 := r2.Filters[0]
+
+		// fill up the group queue:
+		go f1.Request(&filtertest.Context{FRequest: req1, FStateBag: make(map[string]interface{})})
+		go f1.Request(&filtertest.Context{FRequest: req1, FStateBag: make(map[string]interface{})})
+		go f2.Request(&filtertest.Context{FRequest: req2, FStateBag: make(map[string]interface{})})
+		go f2.Request(&filtertest.Context{FRequest: req2, FStateBag: make(map[string]interface{})})
+
+		q1 := f1.Filter.(scheduler.LIFOFilter).GetQueue()
+		q2 := f2.Filter.(scheduler.LIFOFilter).GetQueue()
+
+		if q1 != q2 {
+			t.Error("the queues in the group don't match")
+		}
+
+		waitForStatus(t, q1, scheduler.QueueStatus{ActiveRequests: 2, QueuedRequests: 2})
+	})
+
+	t.Run("lifo and fifo groups with the same name", func(t *testing.T) {
+		const doc = `
+			g1: Path("/one") -> fifoGroup("g", 2, 2) -> <shunt>;
+			g2: Path("/two") -> lifoGroup("g", 2, 2) -> <shunt>;
+		`
+
+		rt, _, close := initTest(doc)
+		defer close()
+
+		q1 := getQueue("/one", rt)
+		q2 := getQueue("/two", rt)
+		if q1 == q2 {
+			t.Error("the lifo and the fifo groups share the queue")
+		}
+
+		if q1.Config().Ordering != scheduler.FIFO || q2.Config().Ordering != scheduler.LIFO {
+			t.Errorf("unexpected ordering, fifo: %v, lifo: %v", q1.Config().Ordering, q2.Config().Ordering)
+		}
+	})
+
+	t.Run("update config", func(t *testing.T) {
+		const doc = `route: * -> fifo(2, 2) -> <shunt>`
+		rt, dc, close := initTest(doc)
+		defer close()
+
+		req := &http.Request{URL: &url.URL{}}
+		r, _ := rt.Route(req)
+		f := r.Filters[0]
+
+		// fill up the queue:
+		go f.Request(&filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})})
+		go f.Request(&filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})})
+		go f.Request(&filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})})
+		go f.Request(&filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})})
+
+		q := f.Filter.(scheduler.LIFOFilter).GetQueue()
+		waitForStatus(t, q, scheduler.QueueStatus{ActiveRequests: 2, QueuedRequests: 2})
+
+		// change the configuration, should decrease the queue size:
+		updateDoc(t, dc, `route: * -> fifo(2, 1) -> <shunt>`, nil)
+
+		if getQueue("/", rt) != q {
+			t.Error("failed to preserve the queue")
+		}
+
+		waitForStatus(t, q, scheduler.QueueStatus{ActiveRequests: 2, QueuedRequests: 1})
+
+		// increase the concurrency, should process the queued request:
+		updateDoc(t, dc, `route: * -> fifo(3, 1) -> <shunt>`, nil)
+		waitForStatus(t, q, scheduler.QueueStatus{ActiveRequests: 3, QueuedRequests: 0})
+	})
+
+	t.Run("queue gets closed when removed", func(t *testing.T) {
+		*scheduler.ExportQueueCloseDelay = 0
+		defer func() { *scheduler.ExportQueueCloseDelay = time.Minute }()
+
+		const doc = `
+			f1: Path("/one") -> fifo(2, 2) -> <shunt>;
+			f2: Path("/two") -> fifo(2, 2) -> <shunt>;
+		`
+
+		rt, dc, close := initTest(doc)
+		defer close()
+
+		q1 := getQueue("/one", rt)
+		q2 := getQueue("/two", rt)
+
+		updateDoc(t, dc, `f2: Path("/two") -> setPath("/foo") -> <shunt>`, []string{"f1"})
+		updateDoc(t, dc, `f3: Path("/three") -> fifo(2, 2) -> <shunt>`, nil)
+
+		waitForStatus(t, q1, scheduler.QueueStatus{Closed: true})
+		waitForStatus(t, q2, scheduler.QueueStatus{Closed: true})
+		assert.NotNil(t, getQueue("/three", rt))
+
+		updateDoc(t, dc, `f3: Path("/three") -> setPath("/bar") -> fifo(2, 2) -> <shunt>`, nil)
+		req := &http.Request{URL: &url.URL{Path: "/three"}}
+		r, _ := rt.Route(req)
+		require.NotNil(t, r)
+
+		q3 := r.Filters[1].Filter.(scheduler.LIFOFilter).GetQueue()
+		waitForStatus(t, q3, scheduler.QueueStatus{Closed: false})
+	})
+}
+
+func TestRegistryPreProcessorFifo(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`* -> fifo(777) -> fifoGroup("g") -> fifo(999) -> fifo() -> lifo(3) -> setPath("/bar") -> <shunt>`)
+	require.NoError(t, err)
+
+	reg := scheduler.RegistryWith(scheduler.Options{})
+	defer reg.Close()
+
+	rt := routing.New(routing.Options{
+		SignalFirstLoad: true,
+		FilterRegistry:  builtin.MakeRegistry(),
+		DataClients:     []routing.DataClient{dc},
+		PreProcessors:   []routing.PreProcessor{reg.PreProcessor()},
+		PostProcessors:  []routing.PostProcessor{reg},
+	})
+	defer rt.Close()
+
+	<-rt.FirstLoad()
+
+	req, _ := http.NewRequest("GET", "http://skipper.test", nil)
+	route, _ := rt.Route(req)
+
+	assert.Equal(t, `* -> fifoGroup("g") -> fifo() -> lifo(3) -> setPath("/bar") -> <shunt>`, route.String())
+}
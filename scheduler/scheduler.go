@@ -1,9 +1,10 @@
 // Package scheduler provides a registry to be used as a postprocessor for the routes
-// that use a LIFO filter.
+// that use a LIFO, a FIFO or an adaptive concurrency filter.
 package scheduler
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,8 +21,31 @@ import (
 const (
 	// Key used during routing to pass lifo values from the filters to the proxy.
 	LIFOKey = "lifo"
+
+	// Key used during routing to pass fifo values from the filters to the proxy.
+	FIFOKey = "fifo"
+)
+
+// Ordering defines in which order the waiting requests of a queue are
+// processed.
+type Ordering int
+
+const (
+	// LIFO processes the latest waiting request first.
+	LIFO Ordering = iota
+
+	// FIFO processes the earliest waiting request first.
+	FIFO
 )
 
+func (o Ordering) String() string {
+	if o == FIFO {
+		return "FIFO"
+	}
+
+	return "LIFO"
+}
+
 // Config can be used to provide configuration of the registry.
 type Config struct {
 
@@ -40,6 +64,10 @@ type Config struct {
 	// CloseTimeout sets a maximum duration for how long the queue can wait
 	// for the active and queued jobs to finish. Defaults to infinite.
 	CloseTimeout time.Duration
+
+	// Ordering defines in which order the waiting jobs are processed.
+	// Defaults to LIFO.
+	Ordering Ordering
 }
 
 // QueueStatus reports the current status of a queue. It can be used for metrics.
@@ -55,11 +83,11 @@ type QueueStatus struct {
 	Closed bool
 }
 
-// Queue objects implement a LIFO queue for handling requests, with a maximum allowed
-// concurrency and queue size. Currently, they can be used from the lifo and lifoGroup
-// filters in the filters/scheduler package only.
+// Queue objects implement a LIFO or a FIFO queue for handling requests, with a maximum
+// allowed concurrency and queue size. Currently, they can be used from the lifo, lifoGroup,
+// fifo and fifoGroup filters in the filters/scheduler package only.
 type Queue struct {
-	queue                    *jobqueue.Stack
+	queue                    jobQueue
 	config                   Config
 	metrics                  metrics.Metrics
 	activeRequestsMetricsKey string
@@ -69,6 +97,15 @@ type Queue struct {
 	queuedRequestsMetricsKey string
 }
 
+// jobQueue is implemented by jobqueue.Stack for the LIFO ordering, and by
+// fifoQueue for the FIFO ordering.
+type jobQueue interface {
+	Wait() (func(), error)
+	Status() jobqueue.Status
+	Reconfigure(jobqueue.Options) error
+	Close()
+}
+
 // Options provides options for the registry.
 type Options struct {
 
@@ -79,19 +116,24 @@ type Options struct {
 	// EnableRouteLIFOMetrics enables collecting metrics about the LIFO queues.
 	EnableRouteLIFOMetrics bool
 
+	// EnableRouteFIFOMetrics enables collecting metrics about the FIFO queues.
+	EnableRouteFIFOMetrics bool
+
 	// Metrics must be provided to the registry in order to collect the LIFO metrics.
 	Metrics metrics.Metrics
 }
 
-// Registry maintains a set of LIFO queues. It is used to preserve LIFO queue instances
-// across multiple generations of the routing. It implements the routing.PostProcessor
+// Registry maintains a set of LIFO and FIFO queues. It is used to preserve the queue
+// instances across multiple generations of the routing. It implements the routing.PostProcessor
 // interface, it is enough to just pass in to routing.Routing when initializing it.
 //
 // When the EnableRouteLIFOMetrics is set, then the registry starts a background goroutine
 // for regularly take snapshots of the active lifo queues and update the corresponding
 // metrics. This goroutine is started when the first lifo filter is detected and returns
 // when the registry is closed. Individual metrics objects (keys) are used for each
-// lifo filter, and one for each lifo group defined by the lifoGroup filter.
+// lifo filter, and one for each lifo group defined by the lifoGroup filter. The same
+// applies to the fifo and fifoGroup filters, when EnableRouteFIFOMetrics is set. The
+// metrics of the adaptiveConcurrency filters are collected whenever Metrics is set.
 //
 type Registry struct {
 	options   Options
@@ -99,20 +141,30 @@ type Registry struct {
 	quit      chan struct{}
 
 	mu      sync.Mutex
-	queues  map[queueId]*Queue
-	deleted map[*Queue]time.Time
+	queues  map[queueId]registeredQueue
+	deleted map[registeredQueue]time.Time
+}
+
+// registeredQueue is implemented by the queues maintained by the
+// registry, *Queue and *AdaptiveQueue.
+type registeredQueue interface {
+	updateMetrics()
+	close()
 }
 
 type queueId struct {
-	name    string
-	grouped bool
+	name     string
+	grouped  bool
+	adaptive bool
+	ordering Ordering
 }
 
 // Amount of time to wait before closing the deleted queues
 var queueCloseDelay = 1 * time.Minute
 
 // LIFOFilter is the interface that needs to be implemented by the filters that
-// use a LIFO queue maintained by the registry.
+// use a LIFO or a FIFO queue maintained by the registry. The ordering of the
+// queue is set in the Config returned by the filter.
 type LIFOFilter interface {
 
 	// SetQueue will be used by the registry to pass in the right queue to
@@ -187,6 +239,16 @@ func (q *Queue) close() {
 	q.queue.Close()
 }
 
+func (q *Queue) updateMetrics() {
+	if q.metrics == nil {
+		return
+	}
+
+	s := q.Status()
+	q.metrics.UpdateGauge(q.activeRequestsMetricsKey, float64(s.ActiveRequests))
+	q.metrics.UpdateGauge(q.queuedRequestsMetricsKey, float64(s.QueuedRequests))
+}
+
 // RegistryWith (Options) creates a registry with the provided options.
 func RegistryWith(o Options) *Registry {
 	if o.MetricsUpdateTimeout <= 0 {
@@ -196,8 +258,8 @@ func RegistryWith(o Options) *Registry {
 	return &Registry{
 		options: o,
 		quit:    make(chan struct{}),
-		queues:  make(map[queueId]*Queue),
-		deleted: make(map[*Queue]time.Time),
+		queues:  make(map[queueId]registeredQueue),
+		deleted: make(map[registeredQueue]time.Time),
 	}
 }
 
@@ -210,40 +272,55 @@ func (r *Registry) getQueue(id queueId, c Config) *Queue {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	q, ok := r.queues[id]
-	if ok {
+	if q, ok := r.queues[id].(*Queue); ok {
 		if q.config != c {
 			q.config = c
 			q.reconfigure()
 		}
-	} else {
-		q = r.newQueue(id.name, c)
-		r.queues[id] = q
+
+		return q
 	}
+
+	q := r.newQueue(id.name, c)
+	r.queues[id] = q
 	return q
 }
 
 func (r *Registry) newQueue(name string, c Config) *Queue {
-	q := &Queue{
-		config: c,
+	q := &Queue{config: c}
+
+	o := jobqueue.Options{
+		MaxConcurrency: c.MaxConcurrency,
+		MaxStackSize:   c.MaxQueueSize,
+		Timeout:        c.Timeout,
+	}
+
+	var (
+		prefix         string
+		metricsEnabled bool
+	)
+
+	if c.Ordering == FIFO {
+		q.queue = newFifo(o)
+		prefix = "fifo"
+		metricsEnabled = r.options.EnableRouteFIFOMetrics
+	} else {
 		// renaming Stack -> Queue in the jobqueue project will follow
-		queue: jobqueue.With(jobqueue.Options{
-			MaxConcurrency: c.MaxConcurrency,
-			MaxStackSize:   c.MaxQueueSize,
-			Timeout:        c.Timeout,
-		}),
+		q.queue = jobqueue.With(o)
+		prefix = "lifo"
+		metricsEnabled = r.options.EnableRouteLIFOMetrics
 	}
 
-	if r.options.EnableRouteLIFOMetrics {
+	if metricsEnabled {
 		if name == "" {
 			name = "unknown"
 		}
 
-		q.activeRequestsMetricsKey = fmt.Sprintf("lifo.%s.active", name)
-		q.queuedRequestsMetricsKey = fmt.Sprintf("lifo.%s.queued", name)
-		q.errorFullMetricsKey = fmt.Sprintf("lifo.%s.error.full", name)
-		q.errorOtherMetricsKey = fmt.Sprintf("lifo.%s.error.other", name)
-		q.errorTimeoutMetricsKey = fmt.Sprintf("lifo.%s.error.timeout", name)
+		q.activeRequestsMetricsKey = fmt.Sprintf("%s.%s.active", prefix, name)
+		q.queuedRequestsMetricsKey = fmt.Sprintf("%s.%s.queued", prefix, name)
+		q.errorFullMetricsKey = fmt.Sprintf("%s.%s.error.full", prefix, name)
+		q.errorOtherMetricsKey = fmt.Sprintf("%s.%s.error.other", prefix, name)
+		q.errorTimeoutMetricsKey = fmt.Sprintf("%s.%s.error.timeout", prefix, name)
 		q.metrics = r.options.Metrics
 		r.measure()
 	}
@@ -273,7 +350,8 @@ func (r *Registry) deleteUnused(inUse map[queueId]struct{}) {
 	}
 }
 
-// Returns routing.PreProcessor that ensures single lifo filter instance per route
+// Returns routing.PreProcessor that ensures single lifo, single fifo and single adaptiveConcurrency
+// filter instance per route
 //
 // Registry can not implement routing.PreProcessor directly due to unfortunate method name clash with routing.PostProcessor
 func (r *Registry) PreProcessor() routing.PreProcessor {
@@ -284,27 +362,33 @@ type registryPreProcessor struct{}
 
 func (registryPreProcessor) Do(routes []*eskip.Route) []*eskip.Route {
 	for _, r := range routes {
-		lifoCount := 0
-		for _, f := range r.Filters {
-			if f.Name == filters.LifoName {
-				lifoCount++
-			}
+		removeNonLast(r, filters.LifoName)
+		removeNonLast(r, filters.FifoName)
+		removeNonLast(r, filters.AdaptiveConcurrencyName)
+	}
+	return routes
+}
+
+func removeNonLast(r *eskip.Route, name string) {
+	count := 0
+	for _, f := range r.Filters {
+		if f.Name == name {
+			count++
 		}
-		// remove all but last lifo instances
-		if lifoCount > 1 {
-			old := r.Filters
-			r.Filters = make([]*eskip.Filter, 0, len(old)-lifoCount+1)
-			for _, f := range old {
-				if lifoCount > 1 && f.Name == filters.LifoName {
-					log.Debugf("Removing non-last %v from %s", f, r.Id)
-					lifoCount--
-				} else {
-					r.Filters = append(r.Filters, f)
-				}
+	}
+	// remove all but last instances
+	if count > 1 {
+		old := r.Filters
+		r.Filters = make([]*eskip.Filter, 0, len(old)-count+1)
+		for _, f := range old {
+			if count > 1 && f.Name == name {
+				log.Debugf("Removing non-last %v from %s", f, r.Id)
+				count--
+			} else {
+				r.Filters = append(r.Filters, f)
 			}
 		}
 	}
-	return routes
 }
 
 // Do implements routing.PostProcessor and sets the queue for the scheduler filters.
@@ -313,15 +397,25 @@ func (registryPreProcessor) Do(routes []*eskip.Route) []*eskip.Route {
 func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 	rr := make([]*routing.Route, len(routes))
 	inUse := make(map[queueId]struct{})
-	groups := make(map[string][]GroupedLIFOFilter)
+	groups := make(map[queueId][]GroupedLIFOFilter)
 
 	for i, ri := range routes {
 		rr[i] = ri
-		var lifoCount int
+		var adaptiveCount int
+		counts := make(map[Ordering]int)
 		for _, fi := range ri.Filters {
 			if glf, ok := fi.Filter.(GroupedLIFOFilter); ok {
-				groupName := glf.Group()
-				groups[groupName] = append(groups[groupName], glf)
+				id := queueId{name: glf.Group(), grouped: true, ordering: glf.Config().Ordering}
+				groups[id] = append(groups[id], glf)
+				continue
+			}
+
+			if af, ok := fi.Filter.(AdaptiveFilter); ok {
+				adaptiveCount++
+
+				id := queueId{name: ri.Id, adaptive: true}
+				inUse[id] = struct{}{}
+				af.SetQueue(r.getAdaptiveQueue(id, af.Config()))
 				continue
 			}
 
@@ -330,26 +424,34 @@ func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 				continue
 			}
 
-			lifoCount++
+			c := lf.Config()
+			counts[c.Ordering]++
 
-			id := queueId{ri.Id, false}
+			id := queueId{name: ri.Id, ordering: c.Ordering}
 			inUse[id] = struct{}{}
 
-			q := r.getQueue(id, lf.Config())
+			q := r.getQueue(id, c)
 
 			lf.SetQueue(q)
 		}
 
-		if lifoCount > 1 {
-			log.Warnf("Found multiple lifo filters on route: %q", ri.Id)
+		for ordering, count := range counts {
+			if count > 1 {
+				log.Warnf("Found multiple %s filters on route: %q", strings.ToLower(ordering.String()), ri.Id)
+			}
+		}
+
+		if adaptiveCount > 1 {
+			log.Warnf("Found multiple adaptiveConcurrency filters on route: %q", ri.Id)
 		}
 	}
 
-	for name, group := range groups {
-		var (
-			c           Config
-			foundConfig bool
-		)
+	for id, group := range groups {
+		var foundConfig bool
+
+		// the group uses the default config, when none of the filters
+		// has one
+		c := Config{Ordering: id.ordering}
 
 		for _, glf := range group {
 			if !glf.HasConfig() {
@@ -357,7 +459,7 @@ func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 			}
 
 			if foundConfig && glf.Config() != c {
-				log.Warnf("Found mismatching configuration for the LIFO group: %s", name)
+				log.Warnf("Found mismatching configuration for the %s group: %s", id.ordering, id.name)
 				continue
 			}
 
@@ -365,7 +467,6 @@ func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 			foundConfig = true
 		}
 
-		id := queueId{name, true}
 		inUse[id] = struct{}{}
 
 		q := r.getQueue(id, c)
@@ -403,9 +504,7 @@ func (r *Registry) updateMetrics() {
 	defer r.mu.Unlock()
 
 	for _, q := range r.queues {
-		s := q.Status()
-		r.options.Metrics.UpdateGauge(q.activeRequestsMetricsKey, float64(s.ActiveRequests))
-		r.options.Metrics.UpdateGauge(q.queuedRequestsMetricsKey, float64(s.QueuedRequests))
+		q.updateMetrics()
 	}
 }
 
@@ -3,6 +3,7 @@ package skipper
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net"
@@ -41,7 +42,9 @@ import (
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/metrics"
 	skpnet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/policy"
 	pauth "github.com/zalando/skipper/predicates/auth"
+	"github.com/zalando/skipper/predicates/clientcert"
 	"github.com/zalando/skipper/predicates/cookie"
 	"github.com/zalando/skipper/predicates/cron"
 	"github.com/zalando/skipper/predicates/forwarded"
@@ -181,6 +184,19 @@ type Options struct {
 	// KubernetesIngressV1 will switch the dataclient to read ingress v1 resources, instead of v1beta1
 	KubernetesIngressV1 bool
 
+	// KubernetesTopologyZones enables setting the availability zones
+	// of the load balanced endpoints from the topology labels of the
+	// nodes where they run.
+	KubernetesTopologyZones bool
+
+	// KubernetesEnableEndpointSlices makes the Kubernetes dataclient
+	// read the EndpointSlices instead of the legacy Endpoints.
+	KubernetesEnableEndpointSlices bool
+
+	// KubernetesWatch makes the Kubernetes dataclient watch the
+	// changes of the resources instead of listing them on every poll.
+	KubernetesWatch bool
+
 	// KubernetesIngressClass is a regular expression, that will make
 	// skipper load only the ingress resources that have a matching
 	// kubernetes.io/ingress.class annotation. For backwards compatibility,
@@ -571,6 +587,19 @@ type Options struct {
 	// multiple keys, the order must match the one given in CertPathTLS
 	KeyPathTLS string
 
+	// Path of the CA certificate(s) used to verify the TLS client
+	// certificates, multiple may be given comma separated. When set, and
+	// ClientAuthTLS is not, the client certificates are verified when
+	// given. It requires TLS, and it can't be combined with ProxyTLS.
+	ClientCAPathTLS string
+
+	// ClientAuthTLS sets the policy of the TLS client authentication.
+	// When nil, it defaults to tls.VerifyClientCertIfGiven with
+	// ClientCAPathTLS, otherwise to tls.NoClientCert. Policies other
+	// than tls.NoClientCert require TLS, and they can't be combined
+	// with ProxyTLS.
+	ClientAuthTLS *tls.ClientAuthType
+
 	// TLS Settings for Proxy Server
 	ProxyTLS *tls.Config
 
@@ -604,6 +633,16 @@ type Options struct {
 	// BreakerSettings contain global and host specific settings for the circuit breakers.
 	BreakerSettings []circuit.BreakerSettings
 
+	// EnableSharedBreakers enables sharing the open transitions of the circuit breakers between the skipper
+	// instances, via Redis or the SWIM based swarm. Requires EnableSwarm.
+	EnableSharedBreakers bool
+
+	// EnableBreakersOverride enables the POST requests on the
+	// /circuitbreakers endpoint of the support listener, that override
+	// the state of the circuit breakers of a backend host. The requests
+	// are not authenticated. Without it, the endpoint is read-only.
+	EnableBreakersOverride bool
+
 	// EnableRatelimiters enables the usage of the ratelimiter in the route definitions without initializing any
 	// by default. It is a shortcut for setting the RatelimitSettings to:
 	//
@@ -617,6 +656,9 @@ type Options struct {
 	// EnableRouteLIFOMetrics enables metrics for the individual route LIFO queues, if any.
 	EnableRouteLIFOMetrics bool
 
+	// EnableRouteFIFOMetrics enables metrics for the individual route FIFO queues, if any.
+	EnableRouteFIFOMetrics bool
+
 	// OpenTracing enables opentracing
 	OpenTracing []string
 
@@ -689,6 +731,23 @@ type Options struct {
 	// unhealthy routes
 	LoadBalancerHealthCheckInterval time.Duration
 
+	// EnableOutlierDetection enables the passive outlier detection
+	// of the load balanced endpoints, ejecting temporarily the
+	// endpoints that fail consecutively.
+	EnableOutlierDetection bool
+
+	// OutlierDetection configures the passive outlier detection.
+	// Unset values take the defaults of the loadbalancer package.
+	OutlierDetection loadbalancer.OutlierDetectionOptions
+
+	// EnableZoneAwareLoadBalancing enables preferring the load
+	// balanced endpoints in the same availability zone as the proxy.
+	EnableZoneAwareLoadBalancing bool
+
+	// ZoneAwareLoadBalancing configures the zone aware load
+	// balancing. The zone of the proxy needs to be set.
+	ZoneAwareLoadBalancing loadbalancer.ZoneAwareOptions
+
 	// ReverseSourcePredicate enables the automatic use of IP
 	// whitelisting in different places to use the reversed way of
 	// identifying a client IP within the X-Forwarded-For
@@ -718,6 +777,15 @@ type Options struct {
 	// OAuthTokeninfoTimeout sets timeout duration while calling oauth token service
 	OAuthTokeninfoTimeout time.Duration
 
+	// OAuthTokeninfoCacheSize, when greater than zero, enables caching
+	// the tokeninfo responses across requests, and sets the maximum
+	// number of the cached tokens.
+	OAuthTokeninfoCacheSize int
+
+	// OAuthTokeninfoCacheTTL sets the maximum time to cache a tokeninfo
+	// response.
+	OAuthTokeninfoCacheTTL time.Duration
+
 	// OAuth2SecretFile contains the filename with the encryption key for the
 	// authentication cookie and grant flow state stored in Secrets.
 	OAuth2SecretFile string
@@ -745,6 +813,15 @@ type Options struct {
 	// OAuthTokenintrospectionTimeout sets timeout duration while calling oauth tokenintrospection service
 	OAuthTokenintrospectionTimeout time.Duration
 
+	// OAuthTokenintrospectionCacheSize, when greater than zero, enables
+	// caching the token introspection responses across requests, and
+	// sets the maximum number of the cached tokens per issuer.
+	OAuthTokenintrospectionCacheSize int
+
+	// OAuthTokenintrospectionCacheTTL sets the maximum time to cache a
+	// token introspection response.
+	OAuthTokenintrospectionCacheTTL time.Duration
+
 	// OAuth2AuthURLParameters the additional parameters to send to OAuth2 authorize and token endpoints.
 	OAuth2AuthURLParameters map[string]string
 
@@ -792,6 +869,20 @@ type Options struct {
 	// WebhookTimeout sets timeout duration while calling a custom webhook auth service
 	WebhookTimeout time.Duration
 
+	// PolicyBundleReloadInterval sets how often the policy bundles of
+	// the policyAuthorize filters are reloaded. Defaults to 1m.
+	PolicyBundleReloadInterval time.Duration
+
+	// PolicyBundleDir is the directory, from which the policyAuthorize
+	// filters can load the policy bundle files. Without it, the
+	// bundles can't be loaded from files.
+	PolicyBundleDir string
+
+	// PolicyBundleURLPrefixes lists the URLs, under which the
+	// policyAuthorize filters can load the policy bundles from. Without
+	// it, the bundles can't be loaded from URLs.
+	PolicyBundleURLPrefixes []string
+
 	// MaxAuditBody sets the maximum read size of the body read by the audit log filter
 	MaxAuditBody int
 
@@ -826,6 +917,19 @@ type Options struct {
 	// ClusterRatelimitMaxGroupShards specifies the maximum number of group shards for the clusterRatelimit filter
 	ClusterRatelimitMaxGroupShards int
 
+	// RatelimitQuotaHeaders enables the RateLimit-Limit,
+	// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+	// response headers of the ratelimit filters.
+	RatelimitQuotaHeaders bool
+
+	// RatelimitPlansFile is the path of a YAML file with the usage
+	// plans of the consumers, used by the usagePlanRatelimit filter
+	RatelimitPlansFile string
+
+	// RatelimitPlansUpdateInterval defines how often the usage plans
+	// are reloaded from RatelimitPlansFile. Defaults to 1m.
+	RatelimitPlansUpdateInterval time.Duration
+
 	// KubernetesEnableTLS enables kubernetes to use resources to terminate tls
 	KubernetesEnableTLS bool
 
@@ -955,6 +1059,9 @@ func createDataClients(o Options, auth innkeeper.Authentication, cr *certregistr
 			RouteGroupClass:                   o.KubernetesRouteGroupClass,
 			WhitelistedHealthCheckCIDR:        o.WhitelistedHealthCheckCIDR,
 			CertificateRegistry:               cr,
+			TopologyZones:                     o.KubernetesTopologyZones,
+			KubernetesEnableEndpointSlices:    o.KubernetesEnableEndpointSlices,
+			KubernetesWatch:                   o.KubernetesWatch,
 		})
 		if err != nil {
 			return nil, err
@@ -1015,12 +1122,21 @@ func initLog(o Options) error {
 }
 
 func (o *Options) tlsConfig(cr *certregistry.CertRegistry) (*tls.Config, error) {
+	clientAuth := o.ClientCAPathTLS != "" || (o.ClientAuthTLS != nil && *o.ClientAuthTLS != tls.NoClientCert)
 
 	if o.ProxyTLS != nil {
+		if clientAuth {
+			return nil, fmt.Errorf("the TLS client authentication options can't be combined with ProxyTLS, set them in ProxyTLS instead")
+		}
+
 		return o.ProxyTLS, nil
 	}
 
 	if o.CertPathTLS == "" && o.KeyPathTLS == "" && !o.KubernetesEnableTLS {
+		if clientAuth {
+			return nil, fmt.Errorf("the TLS client authentication requires TLS, set the certificate and the key paths or enable the Kubernetes TLS")
+		}
+
 		return nil, nil
 	}
 
@@ -1029,7 +1145,7 @@ func (o *Options) tlsConfig(cr *certregistry.CertRegistry) (*tls.Config, error)
 			MinVersion:     o.TLSMinVersion,
 			GetCertificate: cr.GetCertFromHello,
 		}
-		return config, nil
+		return config, o.clientAuthTLS(config)
 	}
 
 	crts := strings.Split(o.CertPathTLS, ",")
@@ -1055,7 +1171,40 @@ func (o *Options) tlsConfig(cr *certregistry.CertRegistry) (*tls.Config, error)
 		}
 		config.Certificates = append(config.Certificates, keypair)
 	}
-	return config, nil
+	return config, o.clientAuthTLS(config)
+}
+
+func (o *Options) clientAuthTLS(config *tls.Config) error {
+	if o.ClientAuthTLS != nil {
+		config.ClientAuth = *o.ClientAuthTLS
+	}
+
+	if o.ClientCAPathTLS == "" {
+		if config.ClientAuth >= tls.VerifyClientCertIfGiven {
+			return fmt.Errorf("verifying the client certificates requires the client CA certificates")
+		}
+
+		return nil
+	}
+
+	pool := x509.NewCertPool()
+	for _, p := range strings.Split(o.ClientCAPathTLS, ",") {
+		pem, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA certificates from %s: %w", p, err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid client CA certificates found in %s", p)
+		}
+	}
+
+	config.ClientCAs = pool
+	if o.ClientAuthTLS == nil {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return nil
 }
 
 func listen(o *Options, mtr metrics.Metrics) (net.Listener, error) {
@@ -1305,6 +1454,11 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		lbInstance = loadbalancer.New(o.LoadBalancerHealthCheckInterval)
 	}
 
+	var outlierDetector *loadbalancer.OutlierDetector
+	if o.EnableOutlierDetection {
+		outlierDetector = loadbalancer.NewOutlierDetector(o.OutlierDetection)
+	}
+
 	if err := o.findAndLoadPlugins(); err != nil {
 		return err
 	}
@@ -1347,6 +1501,8 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 			Timeout:      o.OAuthTokeninfoTimeout,
 			MaxIdleConns: o.IdleConnectionsPerHost,
 			Tracer:       tracer,
+			CacheSize:    o.OAuthTokeninfoCacheSize,
+			CacheTTL:     o.OAuthTokeninfoCacheTTL,
 		}
 
 		o.CustomFilters = append(o.CustomFilters,
@@ -1374,6 +1530,8 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		Timeout:      o.OAuthTokenintrospectionTimeout,
 		MaxIdleConns: o.IdleConnectionsPerHost,
 		Tracer:       tracer,
+		CacheSize:    o.OAuthTokenintrospectionCacheSize,
+		CacheTTL:     o.OAuthTokenintrospectionCacheTTL,
 	}
 
 	oo := auth.OidcOptions{
@@ -1388,6 +1546,18 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		Tracer:       tracer,
 	}
 
+	policyRegistry, err := policy.NewRegistry(policy.Options{
+		ReloadInterval:     o.PolicyBundleReloadInterval,
+		BaseDir:            o.PolicyBundleDir,
+		AllowedURLPrefixes: o.PolicyBundleURLPrefixes,
+	})
+	if err != nil {
+		return err
+	}
+	defer policyRegistry.Close()
+
+	policyAuthorize := auth.NewPolicyAuthorize(policyRegistry)
+
 	admissionControlFilter := shedder.NewAdmissionControl(shedder.Options{
 		Tracer: tracer,
 	})
@@ -1409,6 +1579,7 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		auth.TokenintrospectionWithOptions(auth.NewSecureOAuthTokenintrospectionAnyKV, tio),
 		auth.TokenintrospectionWithOptions(auth.NewSecureOAuthTokenintrospectionAllKV, tio),
 		auth.WebhookWithOptions(who),
+		policyAuthorize,
 		auth.NewOAuthOidcUserInfosWithOptions(o.OIDCSecretsFile, o.SecretsRegistry, oo),
 		auth.NewOAuthOidcAnyClaimsWithOptions(o.OIDCSecretsFile, o.SecretsRegistry, oo),
 		auth.NewOAuthOidcAllClaimsWithOptions(o.OIDCSecretsFile, o.SecretsRegistry, oo),
@@ -1502,7 +1673,10 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 
 	}
 
-	var ratelimitRegistry *ratelimit.Registry
+	var (
+		ratelimitRegistry *ratelimit.Registry
+		concurrencyLimit  filters.Spec
+	)
 	if o.EnableRatelimiters || len(o.RatelimitSettings) > 0 {
 		log.Infof("enabled ratelimiters %v: %v", o.EnableRatelimiters, o.RatelimitSettings)
 		ratelimitRegistry = ratelimit.NewSwarmRegistry(swarmer, redisOptions, o.RatelimitSettings...)
@@ -1518,6 +1692,10 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		}
 
 		provider := ratelimitfilters.NewRatelimitProvider(ratelimitRegistry)
+		if o.RatelimitQuotaHeaders {
+			provider = ratelimitfilters.NewQuotaHeadersRatelimitProvider(ratelimitRegistry)
+		}
+
 		o.CustomFilters = append(o.CustomFilters,
 			ratelimitfilters.NewClientRatelimit(provider),
 			ratelimitfilters.NewLocalRatelimit(provider),
@@ -1525,12 +1703,32 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 			ratelimitfilters.NewShardedClusterRateLimit(provider, o.ClusterRatelimitMaxGroupShards),
 			ratelimitfilters.NewClusterClientRateLimit(provider),
 			ratelimitfilters.NewDisableRatelimit(provider),
+			ratelimitfilters.NewTokenBucketRatelimit(provider),
+			ratelimitfilters.NewClusterTokenBucketRatelimit(provider),
 			ratelimitfilters.NewBackendRatelimit(),
 		)
 
 		if redisOptions != nil {
 			o.CustomFilters = append(o.CustomFilters, ratelimitfilters.NewClusterLeakyBucketRatelimit(ratelimitRegistry))
 		}
+
+		// without redis, the concurrency is limited locally
+		concurrencyLimit = ratelimitfilters.NewClusterConcurrencyLimit(ratelimitRegistry)
+		o.CustomFilters = append(o.CustomFilters, concurrencyLimit)
+
+		if o.RatelimitPlansFile != "" {
+			if redisOptions == nil {
+				return fmt.Errorf("usage plans require redis, set -swarm-redis-urls or -kubernetes-redis-service-name")
+			}
+
+			plans, err := ratelimit.NewUsagePlans(ratelimitRegistry, ratelimit.PlanFile(o.RatelimitPlansFile), o.RatelimitPlansUpdateInterval)
+			if err != nil {
+				return fmt.Errorf("failed to load usage plans: %w", err)
+			}
+			defer plans.Close()
+
+			o.CustomFilters = append(o.CustomFilters, ratelimitfilters.NewUsagePlanRatelimit(plans))
+		}
 	}
 
 	if o.TLSMinVersion == 0 {
@@ -1633,6 +1831,9 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		forwarded.NewForwardedHost(),
 		forwarded.NewForwardedProto(),
 		host.NewAny(),
+		clientcert.NewSubject(),
+		clientcert.NewSAN(),
+		clientcert.NewIssuer(),
 	)
 
 	// provide default value for wrapper if not defined
@@ -1645,6 +1846,7 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	schedulerRegistry := scheduler.RegistryWith(scheduler.Options{
 		Metrics:                mtr,
 		EnableRouteLIFOMetrics: o.EnableRouteLIFOMetrics,
+		EnableRouteFIFOMetrics: o.EnableRouteFIFOMetrics,
 	})
 	defer schedulerRegistry.Close()
 
@@ -1668,6 +1870,25 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		SignalFirstLoad: o.WaitFirstRouteLoad,
 	}
 
+	if outlierDetector != nil {
+		ro.PostProcessors = append(ro.PostProcessors, outlierDetector)
+	}
+
+	if pp, ok := policyAuthorize.(routing.PostProcessor); ok {
+		ro.PostProcessors = append(ro.PostProcessors, pp)
+	}
+
+	if pp, ok := concurrencyLimit.(routing.PostProcessor); ok {
+		ro.PostProcessors = append(ro.PostProcessors, pp)
+	}
+
+	if o.EnableZoneAwareLoadBalancing {
+		zo := o.ZoneAwareLoadBalancing
+		zo.OutlierDetector = outlierDetector
+		zo.Metrics = mtr
+		ro.PostProcessors = append(ro.PostProcessors, loadbalancer.NewZoneAware(zo))
+	}
+
 	if o.DefaultFilters != nil {
 		ro.PreProcessors = append(ro.PreProcessors, o.DefaultFilters)
 	}
@@ -1708,6 +1929,7 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		MaxLoopbacks:               o.MaxLoopbacks,
 		DefaultHTTPStatus:          o.DefaultHTTPStatus,
 		LoadBalancer:               lbInstance,
+		OutlierDetector:            outlierDetector,
 		Timeout:                    o.TimeoutBackend,
 		ResponseHeaderTimeout:      o.ResponseHeaderTimeoutBackend,
 		ExpectContinueTimeout:      o.ExpectContinueTimeoutBackend,
@@ -1723,7 +1945,28 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	}
 
 	if o.EnableBreakers || len(o.BreakerSettings) > 0 {
-		proxyParams.CircuitBreakers = circuit.NewRegistry(o.BreakerSettings...)
+		var shared circuit.SharedState
+		if o.EnableSharedBreakers {
+			switch {
+			case redisOptions != nil:
+				ro := *redisOptions
+				ro.MetricsPrefix = "circuitbreaker.redis."
+				ringClient := skpnet.NewRedisRingClient(&ro)
+				defer ringClient.Close()
+				shared = circuit.NewRedisState(ringClient)
+			case swarmer != nil:
+				shared = circuit.NewSwarmState(swarmer)
+			default:
+				log.Warn("Shared circuit breakers require swarm, the circuit breakers are not shared")
+			}
+		}
+
+		if shared != nil {
+			proxyParams.CircuitBreakers = circuit.NewSharedRegistry(shared, o.BreakerSettings...)
+			defer proxyParams.CircuitBreakers.Close()
+		} else {
+			proxyParams.CircuitBreakers = circuit.NewRegistry(o.BreakerSettings...)
+		}
 	}
 
 	if o.DebugListener != "" {
@@ -1747,6 +1990,14 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		mux.Handle("/routes", routing)
 		mux.Handle("/routes/", routing)
 
+		if proxyParams.CircuitBreakers != nil {
+			if o.EnableBreakersOverride {
+				mux.Handle("/circuitbreakers", proxyParams.CircuitBreakers.OverrideHandler())
+			} else {
+				mux.Handle("/circuitbreakers", proxyParams.CircuitBreakers)
+			}
+		}
+
 		metricsHandler := metrics.NewHandler(mtrOpts, mtr)
 		mux.Handle("/metrics", metricsHandler)
 		mux.Handle("/metrics/", metricsHandler)
@@ -120,6 +120,35 @@ func TestOptionsTLSConfig(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, uint16(tls.VersionTLS13), c.MinVersion)
 	require.Equal(t, []tls.Certificate{cert, cert2}, c.Certificates)
+
+	// client CA verifies the client certificates when given
+	o = &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test.key", ClientCAPathTLS: "fixtures/test.crt,fixtures/test2.crt"}
+	c, err = o.tlsConfig(cr)
+	require.NoError(t, err)
+	require.Equal(t, tls.VerifyClientCertIfGiven, c.ClientAuth)
+	require.Len(t, c.ClientCAs.Subjects(), 2)
+
+	// client auth policy
+	o = &Options{KubernetesEnableTLS: true, ClientCAPathTLS: "fixtures/test.crt", ClientAuthTLS: clientAuthTLS(tls.RequireAndVerifyClientCert)}
+	c, err = o.tlsConfig(cr)
+	require.NoError(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, c.ClientAuth)
+
+	// explicitly disabled client auth with client CA
+	o = &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test.key", ClientCAPathTLS: "fixtures/test.crt", ClientAuthTLS: clientAuthTLS(tls.NoClientCert)}
+	c, err = o.tlsConfig(cr)
+	require.NoError(t, err)
+	require.Equal(t, tls.NoClientCert, c.ClientAuth)
+
+	// explicitly disabled client auth without TLS
+	o = &Options{ClientAuthTLS: clientAuthTLS(tls.NoClientCert)}
+	c, err = o.tlsConfig(cr)
+	require.NoError(t, err)
+	require.Nil(t, c)
+}
+
+func clientAuthTLS(a tls.ClientAuthType) *tls.ClientAuthType {
+	return &a
 }
 
 func TestOptionsTLSConfigInvalidPaths(t *testing.T) {
@@ -136,6 +165,13 @@ func TestOptionsTLSConfigInvalidPaths(t *testing.T) {
 		{"cert key mismatch", &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test2.key"}},
 		{"multiple cert key count mismatch", &Options{CertPathTLS: "fixtures/test.crt,fixtures/test2.crt", KeyPathTLS: "fixtures/test.key"}},
 		{"multiple cert key mismatch", &Options{CertPathTLS: "fixtures/test.crt,fixtures/test2.crt", KeyPathTLS: "fixtures/test2.key,fixtures/test.key"}},
+		{"wrong client CA path", &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test.key", ClientCAPathTLS: "fixtures/notFound.crt"}},
+		{"invalid client CA", &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test.key", ClientCAPathTLS: "fixtures/test.key"}},
+		{"verify without client CA", &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test.key", ClientAuthTLS: clientAuthTLS(tls.VerifyClientCertIfGiven)}},
+		{"client CA without TLS", &Options{ClientCAPathTLS: "fixtures/test.crt"}},
+		{"client auth without TLS", &Options{ClientAuthTLS: clientAuthTLS(tls.RequestClientCert)}},
+		{"client CA with proxy TLS", &Options{ProxyTLS: &tls.Config{}, ClientCAPathTLS: "fixtures/test.crt"}},
+		{"client auth with proxy TLS", &Options{ProxyTLS: &tls.Config{}, ClientAuthTLS: clientAuthTLS(tls.RequireAnyClientCert)}},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			_, err := tt.options.tlsConfig(cr)